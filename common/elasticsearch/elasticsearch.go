@@ -15,6 +15,7 @@ package elasticsearch
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
 	"time"
@@ -37,6 +38,9 @@ type ElasticSearchService struct {
 	EsClient    *esClient
 	baseIndex   string
 	ClusterName string
+	// Documents are appended to a data stream per type instead of daily indices.
+	dataStream bool
+	lifecycle  lifecycleConfig
 }
 
 func (esSvc *ElasticSearchService) Index(date time.Time) string {
@@ -50,12 +54,28 @@ func (esSvc *ElasticSearchService) FlushData() error {
 	return esSvc.EsClient.FlushBulk()
 }
 
+// Stop sends the buffered documents and stops the periodic flushing.
+func (esSvc *ElasticSearchService) Stop() error {
+	return esSvc.EsClient.StopBulk()
+}
+
 // SaveDataIntoES save metrics and events to ES by using ES client
 func (esSvc *ElasticSearchService) SaveData(date time.Time, typeName string, sinkData []interface{}) error {
 	if typeName == "" || len(sinkData) == 0 {
 		return nil
 	}
 
+	if esSvc.dataStream {
+		// Data streams are created by Elasticsearch from the index template.
+		stream := esSvc.IndexAlias(typeName)
+		for _, data := range sinkData {
+			if err := esSvc.EsClient.AddDataStreamReq(stream, date, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	indexName := esSvc.Index(date)
 
 	// Use the IndexExists service to check if a specified index exists.
@@ -65,8 +85,12 @@ func (esSvc *ElasticSearchService) SaveData(date time.Time, typeName string, sin
 	}
 
 	if !exists {
-		// Create a new index.
-		createIndex, err := esSvc.EsClient.CreateIndex(indexName, mapping)
+		// Create a new index. The mapping of Elasticsearch 7 and later comes from the index template.
+		indexMapping := mapping
+		if esSvc.EsClient.version >= 7 {
+			indexMapping = ""
+		}
+		createIndex, err := esSvc.EsClient.CreateIndex(indexName, indexMapping)
 		if err != nil {
 			return err
 		}
@@ -77,6 +101,8 @@ func (esSvc *ElasticSearchService) SaveData(date time.Time, typeName string, sin
 			ack = i.Acknowledged
 		case *elastic5.IndicesCreateResult:
 			ack = i.Acknowledged
+		case *acknowledgedResponse:
+			ack = i.Acknowledged
 		}
 		if !ack {
 			return errors.New("Failed to acknoledge index creation")
@@ -95,6 +121,8 @@ func (esSvc *ElasticSearchService) SaveData(date time.Time, typeName string, sin
 		hasAlias = a.Indices[indexName].HasAlias(aliasName)
 	case *elastic5.AliasesResult:
 		hasAlias = a.Indices[indexName].HasAlias(aliasName)
+	case aliasesResponse:
+		hasAlias = a.HasAlias(indexName, aliasName)
 	}
 	if !hasAlias {
 		createAlias, err := esSvc.EsClient.AddAlias(indexName, esSvc.IndexAlias(typeName))
@@ -108,6 +136,8 @@ func (esSvc *ElasticSearchService) SaveData(date time.Time, typeName string, sin
 			ack = i.Acknowledged
 		case *elastic5.AliasResult:
 			ack = i.Acknowledged
+		case *acknowledgedResponse:
+			ack = i.Acknowledged
 		}
 		if !ack {
 			return errors.New("Failed to acknoledge index alias creation")
@@ -121,6 +151,40 @@ func (esSvc *ElasticSearchService) SaveData(date time.Time, typeName string, sin
 	return nil
 }
 
+// setupTemplates creates the lifecycle policy and the composable index template used by
+// Elasticsearch 7 and later.
+func (esSvc *ElasticSearchService) setupTemplates() error {
+	if esSvc.lifecycle.managed() {
+		var policy string
+		var err error
+		if esSvc.EsClient.openSearch {
+			policy, err = ismPolicy(esSvc.lifecycle, esSvc.baseIndex, esSvc.dataStream)
+		} else {
+			policy, err = ilmPolicy(esSvc.lifecycle, esSvc.dataStream)
+		}
+		if err != nil {
+			return err
+		}
+		if err := esSvc.EsClient.PutLifecyclePolicy(esSvc.lifecycle.Policy, policy); err != nil {
+			return fmt.Errorf("Failed to create lifecycle policy %s: %v", esSvc.lifecycle.Policy, err)
+		}
+	}
+
+	// OpenSearch attaches ISM policies to indices with the ISM template of the policy.
+	ilmPolicyName := esSvc.lifecycle.Policy
+	if esSvc.EsClient.openSearch {
+		ilmPolicyName = ""
+	}
+	template, err := indexTemplate(esSvc.baseIndex, esSvc.dataStream, ilmPolicyName)
+	if err != nil {
+		return err
+	}
+	if err := esSvc.EsClient.PutIndexTemplate(esSvc.baseIndex, template); err != nil {
+		return fmt.Errorf("Failed to create index template %s: %v", esSvc.baseIndex, err)
+	}
+	return nil
+}
+
 // CreateElasticSearchConfig creates an ElasticSearch configuration struct
 // which contains an ElasticSearch client for later use
 func CreateElasticSearchService(uri *url.URL) (*ElasticSearchService, error) {
@@ -152,15 +216,22 @@ func CreateElasticSearchService(uri *url.URL) (*ElasticSearchService, error) {
 
 	var startupFnsV5 []elastic5.ClientOptionFunc
 	var startupFnsV2 []elastic2.ClientOptionFunc
+	// Configuration of the REST client of version 7 and later.
+	var nodes []string
+	var username, password string
+	var maxRetries int
+	var httpClient *http.Client
 
 	// Set the URL endpoints of the ES's nodes. Notice that when sniffing is
 	// enabled, these URLs are used to initially sniff the cluster on startup.
 	if len(opts["nodes"]) > 0 {
 		startupFnsV2 = append(startupFnsV2, elastic2.SetURL(opts["nodes"]...))
 		startupFnsV5 = append(startupFnsV5, elastic5.SetURL(opts["nodes"]...))
+		nodes = opts["nodes"]
 	} else if uri.Scheme != "" && uri.Host != "" {
 		startupFnsV2 = append(startupFnsV2, elastic2.SetURL(uri.Scheme+"://"+uri.Host))
 		startupFnsV5 = append(startupFnsV5, elastic5.SetURL(uri.Scheme+"://"+uri.Host))
+		nodes = []string{uri.Scheme + "://" + uri.Host}
 	} else {
 		return nil, errors.New("There is no node assigned for connecting ES cluster")
 	}
@@ -170,10 +241,11 @@ func CreateElasticSearchService(uri *url.URL) (*ElasticSearchService, error) {
 	if len(opts["esUserName"]) > 0 && len(opts["esUserSecret"]) > 0 {
 		startupFnsV2 = append(startupFnsV2, elastic2.SetBasicAuth(opts["esUserName"][0], opts["esUserSecret"][0]))
 		startupFnsV5 = append(startupFnsV5, elastic5.SetBasicAuth(opts["esUserName"][0], opts["esUserSecret"][0]))
+		username, password = opts["esUserName"][0], opts["esUserSecret"][0]
 	}
 
 	if len(opts["maxRetries"]) > 0 {
-		maxRetries, err := strconv.Atoi(opts["maxRetries"][0])
+		maxRetries, err = strconv.Atoi(opts["maxRetries"][0])
 		if err != nil {
 			return nil, errors.New("Failed to parse URL's maxRetries value into an int")
 		}
@@ -210,6 +282,7 @@ func CreateElasticSearchService(uri *url.URL) (*ElasticSearchService, error) {
 
 		startupFnsV2 = append(startupFnsV2, elastic2.SetHttpClient(awsClient), elastic2.SetSniff(false))
 		startupFnsV5 = append(startupFnsV5, elastic5.SetHttpClient(awsClient), elastic5.SetSniff(false))
+		httpClient = awsClient
 	} else {
 		if len(opts["sniff"]) > 0 {
 			sniff, err := strconv.ParseBool(opts["sniff"][0])
@@ -234,11 +307,50 @@ func CreateElasticSearchService(uri *url.URL) (*ElasticSearchService, error) {
 		pipeline = opts["pipeline"][0]
 	}
 
+	openSearch := false
+	if len(opts["openSearch"]) > 0 {
+		openSearch, err = strconv.ParseBool(opts["openSearch"][0])
+		if err != nil {
+			return nil, errors.New("Failed to parse URL's openSearch value into a bool")
+		}
+	}
+
+	if len(opts["dataStream"]) > 0 {
+		esSvc.dataStream, err = strconv.ParseBool(opts["dataStream"][0])
+		if err != nil {
+			return nil, errors.New("Failed to parse URL's dataStream value into a bool")
+		}
+	}
+
+	if len(opts["ilmPolicy"]) > 0 {
+		esSvc.lifecycle.Policy = opts["ilmPolicy"][0]
+	}
+	if len(opts["rolloverMaxAge"]) > 0 {
+		esSvc.lifecycle.RolloverMaxAge = opts["rolloverMaxAge"][0]
+	}
+	if len(opts["rolloverMaxSize"]) > 0 {
+		esSvc.lifecycle.RolloverMaxSize = opts["rolloverMaxSize"][0]
+	}
+	if len(opts["deleteAfter"]) > 0 {
+		esSvc.lifecycle.DeleteAfter = opts["deleteAfter"][0]
+	}
+	if esSvc.lifecycle.managed() && esSvc.lifecycle.Policy == "" {
+		esSvc.lifecycle.Policy = esSvc.baseIndex
+	}
+	if (esSvc.lifecycle.RolloverMaxAge != "" || esSvc.lifecycle.RolloverMaxSize != "") && !esSvc.dataStream {
+		return nil, errors.New("rolloverMaxAge and rolloverMaxSize require dataStream")
+	}
+	if version < 7 && (openSearch || esSvc.dataStream || esSvc.lifecycle.Policy != "") {
+		return nil, errors.New("openSearch, dataStream and lifecycle policies require ElasticSearch version 7 or later")
+	}
+
 	switch version {
 	case 2:
 		esSvc.EsClient, err = newEsClientV2(startupFnsV2, bulkWorkers)
 	case 5:
 		esSvc.EsClient, err = newEsClientV5(startupFnsV5, bulkWorkers, pipeline)
+	case 7, 8:
+		esSvc.EsClient = newEsClientV7(version, nodes, httpClient, username, password, maxRetries, pipeline, openSearch)
 	default:
 		return nil, UnsupportedVersion{}
 	}
@@ -246,6 +358,12 @@ func CreateElasticSearchService(uri *url.URL) (*ElasticSearchService, error) {
 		return nil, fmt.Errorf("Failed to create ElasticSearch client: %v", err)
 	}
 
+	if version >= 7 {
+		if err := esSvc.setupTemplates(); err != nil {
+			return nil, err
+		}
+	}
+
 	glog.V(2).Infof("ElasticSearch sink configure successfully")
 
 	return &esSvc, nil
@@ -14,12 +14,14 @@
 package elasticsearch
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/golang/glog"
 	"github.com/pborman/uuid"
 	"golang.org/x/net/context"
 	elastic2 "gopkg.in/olivere/elastic.v3"
 	elastic5 "gopkg.in/olivere/elastic.v5"
+	"net/http"
 	"time"
 )
 
@@ -35,6 +37,10 @@ type esClient struct {
 	clientV5        *elastic5.Client
 	bulkProcessorV2 *elastic2.BulkProcessor
 	bulkProcessorV5 *elastic5.BulkProcessor
+	// Client of Elasticsearch 7 and later, and of OpenSearch.
+	clientV7        *esRestClient
+	bulkProcessorV7 *esBulkProcessor
+	openSearch      bool
 	pipeline        string
 }
 
@@ -59,6 +65,14 @@ func newEsClientV5(startupFns []elastic5.ClientOptionFunc, bulkWorkers int, pipe
 	}
 	return &esClient{version: 5, clientV5: client, bulkProcessorV5: bps, pipeline: pipeline}, nil
 }
+func newEsClientV7(version int, urls []string, httpClient *http.Client, username, password string, maxRetries int, pipeline string, openSearch bool) *esClient {
+	client := newEsRestClient(urls, httpClient, username, password, maxRetries)
+	bps := newEsBulkProcessor(client,
+		1000,           // commit if # requests >= 1000
+		2<<20,          // commit if size of requests >= 2 MB
+		10*time.Second) // commit every 10s
+	return &esClient{version: version, clientV7: client, bulkProcessorV7: bps, pipeline: pipeline, openSearch: openSearch}
+}
 func newEsClientV2(startupFns []elastic2.ClientOptionFunc, bulkWorkers int) (*esClient, error) {
 	client, err := elastic2.NewClient(startupFns...)
 	if err != nil {
@@ -84,6 +98,8 @@ func (es *esClient) IndexExists(indices ...string) (bool, error) {
 		return es.clientV2.IndexExists(indices...).Do()
 	case 5:
 		return es.clientV5.IndexExists(indices...).Do(context.Background())
+	case 7, 8:
+		return es.clientV7.IndexExists(indices...)
 	default:
 		return false, UnsupportedVersion{}
 	}
@@ -95,6 +111,8 @@ func (es *esClient) CreateIndex(name string, mapping string) (interface{}, error
 		return es.clientV2.CreateIndex(name).BodyString(mapping).Do()
 	case 5:
 		return es.clientV5.CreateIndex(name).BodyString(mapping).Do(context.Background())
+	case 7, 8:
+		return es.clientV7.CreateIndex(name, mapping)
 	default:
 		return nil, UnsupportedVersion{}
 	}
@@ -106,6 +124,8 @@ func (es *esClient) GetAliases(index string) (interface{}, error) {
 		return es.clientV2.Aliases().Index(index).Do()
 	case 5:
 		return es.clientV5.Aliases().Index(index).Do(context.Background())
+	case 7, 8:
+		return es.clientV7.GetAliases(index)
 	default:
 		return nil, UnsupportedVersion{}
 	}
@@ -117,6 +137,8 @@ func (es *esClient) AddAlias(index string, alias string) (interface{}, error) {
 		return es.clientV2.Alias().Add(index, alias).Do()
 	case 5:
 		return es.clientV5.Alias().Add(index, alias).Do(context.Background())
+	case 7, 8:
+		return es.clientV7.AddAlias(index, alias)
 	default:
 		return nil, UnsupportedVersion{}
 	}
@@ -143,6 +165,77 @@ func (es *esClient) AddBulkReq(index, typeName string, data interface{}) error {
 
 		es.bulkProcessorV5.Add(req)
 		return nil
+	case 7, 8:
+		doc, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return es.bulkProcessorV7.Add("index", bulkAction{
+			Index:    index,
+			Id:       uuid.NewUUID().String(),
+			Pipeline: es.pipeline,
+		}, doc)
+	default:
+		return UnsupportedVersion{}
+	}
+}
+
+// AddDataStreamReq appends the document to the data stream. The date is added to the document
+// as its TimestampField, which is required by data streams.
+func (es *esClient) AddDataStreamReq(stream string, date time.Time, data interface{}) error {
+	switch es.version {
+	case 7, 8:
+		doc, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		doc, err = withTimestamp(doc, date)
+		if err != nil {
+			return err
+		}
+		return es.bulkProcessorV7.Add("create", bulkAction{
+			Index:    stream,
+			Pipeline: es.pipeline,
+		}, doc)
+	default:
+		return UnsupportedVersion{}
+	}
+}
+
+// withTimestamp adds the TimestampField to the JSON object.
+func withTimestamp(doc []byte, date time.Time) ([]byte, error) {
+	timestamp, err := json.Marshal(date.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) < 2 || doc[0] != '{' {
+		return nil, fmt.Errorf("documents of data streams have to be objects, got %s", string(doc))
+	}
+	result := make([]byte, 0, len(doc)+len(TimestampField)+len(timestamp)+4)
+	result = append(result, `{"`+TimestampField+`":`...)
+	result = append(result, timestamp...)
+	if len(doc) > 2 {
+		result = append(result, ',')
+	}
+	return append(result, doc[1:]...), nil
+}
+
+func (es *esClient) PutIndexTemplate(name, body string) error {
+	switch es.version {
+	case 7, 8:
+		return es.clientV7.PutIndexTemplate(name, body)
+	default:
+		return UnsupportedVersion{}
+	}
+}
+
+// PutLifecyclePolicy creates or updates the ILM policy, or creates the ISM policy of OpenSearch.
+func (es *esClient) PutLifecyclePolicy(name, body string) error {
+	switch {
+	case es.version >= 7 && es.openSearch:
+		return es.clientV7.PutISMPolicy(name, body)
+	case es.version >= 7:
+		return es.clientV7.PutLifecyclePolicy(name, body)
 	default:
 		return UnsupportedVersion{}
 	}
@@ -154,6 +247,22 @@ func (es *esClient) FlushBulk() error {
 		return es.bulkProcessorV2.Flush()
 	case 5:
 		return es.bulkProcessorV5.Flush()
+	case 7, 8:
+		return es.bulkProcessorV7.Flush()
+	default:
+		return UnsupportedVersion{}
+	}
+}
+
+// StopBulk sends the buffered bulk requests and stops the bulk processor.
+func (es *esClient) StopBulk() error {
+	switch es.version {
+	case 2:
+		return es.bulkProcessorV2.Close()
+	case 5:
+		return es.bulkProcessorV5.Close()
+	case 7, 8:
+		return es.bulkProcessorV7.Stop()
 	default:
 		return UnsupportedVersion{}
 	}
@@ -0,0 +1,213 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+)
+
+// TimestampField is the timestamp of the documents of data streams.
+const TimestampField = "@timestamp"
+
+// lifecycleConfig describes the policy rolling over and deleting the indices.
+type lifecycleConfig struct {
+	// Name of the ILM policy of Elasticsearch, or of the ISM policy of OpenSearch.
+	Policy string
+	// Conditions of the rollover of the data streams, e.g. "1d" and "50gb".
+	RolloverMaxAge  string
+	RolloverMaxSize string
+	// Age of the indices after which they are deleted, e.g. "30d".
+	DeleteAfter string
+}
+
+// managed returns whether Heapster creates the policy, or only refers to an existing one.
+func (lc lifecycleConfig) managed() bool {
+	return lc.RolloverMaxAge != "" || lc.RolloverMaxSize != "" || lc.DeleteAfter != ""
+}
+
+// typelessMapping returns the mappings of all the document types of the legacy mapping merged
+// into the single type of Elasticsearch 7 and later. String fields become keyword fields when
+// they are not analyzed, and text fields otherwise.
+func typelessMapping() (map[string]interface{}, error) {
+	var legacy struct {
+		Mappings map[string]struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := json.Unmarshal([]byte(mapping), &legacy); err != nil {
+		return nil, err
+	}
+	properties := map[string]interface{}{
+		TimestampField: map[string]interface{}{"type": "date"},
+	}
+	for typeName, typeMapping := range legacy.Mappings {
+		if typeName == "_default_" {
+			continue
+		}
+		mergeProperties(properties, convertProperties(typeMapping.Properties))
+	}
+	return map[string]interface{}{"properties": properties}, nil
+}
+
+func convertProperties(properties map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(properties))
+	for name, field := range properties {
+		if field, ok := field.(map[string]interface{}); ok {
+			result[name] = convertField(field)
+		}
+	}
+	return result
+}
+
+func convertField(field map[string]interface{}) map[string]interface{} {
+	if nested, ok := field["properties"].(map[string]interface{}); ok {
+		return map[string]interface{}{"properties": convertProperties(nested)}
+	}
+	if field["type"] != "string" {
+		return field
+	}
+	if field["index"] == "not_analyzed" {
+		return map[string]interface{}{"type": "keyword"}
+	}
+	result := map[string]interface{}{"type": "text"}
+	if fields, ok := field["fields"].(map[string]interface{}); ok {
+		result["fields"] = convertProperties(fields)
+	}
+	return result
+}
+
+// mergeProperties adds the fields of src to dst. The fields of objects present in both are
+// merged, e.g. the metrics of the different metric families.
+func mergeProperties(dst, src map[string]interface{}) {
+	for name, field := range src {
+		existing, found := dst[name].(map[string]interface{})
+		if !found {
+			dst[name] = field
+			continue
+		}
+		existingProperties, existingIsObject := existing["properties"].(map[string]interface{})
+		srcProperties, srcIsObject := field.(map[string]interface{})["properties"].(map[string]interface{})
+		if existingIsObject && srcIsObject {
+			mergeProperties(existingProperties, srcProperties)
+		}
+	}
+}
+
+// indexTemplate returns the composable index template of the indices, or of the data streams,
+// whose name starts with the base index.
+func indexTemplate(baseIndex string, dataStream bool, ilmPolicy string) (string, error) {
+	mappings, err := typelessMapping()
+	if err != nil {
+		return "", err
+	}
+	template := map[string]interface{}{
+		"mappings": mappings,
+	}
+	if ilmPolicy != "" {
+		template["settings"] = map[string]interface{}{
+			"index.lifecycle.name": ilmPolicy,
+		}
+	}
+	body := map[string]interface{}{
+		"index_patterns": []string{baseIndex + "-*"},
+		"priority":       200,
+		"template":       template,
+		"_meta": map[string]interface{}{
+			"managed_by": "heapster",
+		},
+	}
+	if dataStream {
+		body["data_stream"] = map[string]interface{}{}
+	}
+	data, err := json.Marshal(body)
+	return string(data), err
+}
+
+// ilmPolicy returns the ILM policy rolling over the data streams and deleting old indices.
+func ilmPolicy(lc lifecycleConfig, dataStream bool) (string, error) {
+	phases := map[string]interface{}{}
+	rollover := map[string]interface{}{}
+	if dataStream && lc.RolloverMaxAge != "" {
+		rollover["max_age"] = lc.RolloverMaxAge
+	}
+	if dataStream && lc.RolloverMaxSize != "" {
+		rollover["max_size"] = lc.RolloverMaxSize
+	}
+	if len(rollover) > 0 {
+		phases["hot"] = map[string]interface{}{
+			"actions": map[string]interface{}{"rollover": rollover},
+		}
+	}
+	if lc.DeleteAfter != "" {
+		phases["delete"] = map[string]interface{}{
+			"min_age": lc.DeleteAfter,
+			"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"policy": map[string]interface{}{"phases": phases},
+	})
+	return string(data), err
+}
+
+// ismPolicy returns the equivalent of ilmPolicy for the Index State Management plugin of
+// OpenSearch. The policy is attached to the new indices by its ISM template.
+func ismPolicy(lc lifecycleConfig, baseIndex string, dataStream bool) (string, error) {
+	hotActions := []interface{}{}
+	rollover := map[string]interface{}{}
+	if dataStream && lc.RolloverMaxAge != "" {
+		rollover["min_index_age"] = lc.RolloverMaxAge
+	}
+	if dataStream && lc.RolloverMaxSize != "" {
+		rollover["min_size"] = lc.RolloverMaxSize
+	}
+	if len(rollover) > 0 {
+		hotActions = append(hotActions, map[string]interface{}{"rollover": rollover})
+	}
+	hotTransitions := []interface{}{}
+	states := []interface{}{}
+	if lc.DeleteAfter != "" {
+		hotTransitions = append(hotTransitions, map[string]interface{}{
+			"state_name": "delete",
+			"conditions": map[string]interface{}{"min_index_age": lc.DeleteAfter},
+		})
+	}
+	states = append(states, map[string]interface{}{
+		"name":        "hot",
+		"actions":     hotActions,
+		"transitions": hotTransitions,
+	})
+	if lc.DeleteAfter != "" {
+		states = append(states, map[string]interface{}{
+			"name":        "delete",
+			"actions":     []interface{}{map[string]interface{}{"delete": map[string]interface{}{}}},
+			"transitions": []interface{}{},
+		})
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"policy": map[string]interface{}{
+			"description":   "Rollover and retention of the Heapster indices",
+			"default_state": "hot",
+			"states":        states,
+			"ism_template": []interface{}{
+				map[string]interface{}{
+					"index_patterns": []string{baseIndex + "-*", ".ds-" + baseIndex + "-*"},
+					"priority":       100,
+				},
+			},
+		},
+	})
+	return string(data), err
+}
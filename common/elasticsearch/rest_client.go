@@ -0,0 +1,350 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/common/httputil"
+)
+
+// esRestClient talks to the REST API of Elasticsearch 7 and later, and of OpenSearch, which
+// are not supported by the vendored clients. The nodes are used in turn, and requests that
+// fail because of the network or an unavailable node are retried on the next one.
+type esRestClient struct {
+	sync.Mutex
+	urls       []string
+	next       int
+	httpClient *http.Client
+	username   string
+	password   string
+	maxRetries int
+}
+
+func newEsRestClient(urls []string, httpClient *http.Client, username, password string, maxRetries int) *esRestClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: time.Minute}
+	}
+	return &esRestClient{
+		urls:       urls,
+		httpClient: httpClient,
+		username:   username,
+		password:   password,
+		maxRetries: maxRetries,
+	}
+}
+
+func (c *esRestClient) nextURL() string {
+	c.Lock()
+	defer c.Unlock()
+	url := c.urls[c.next%len(c.urls)]
+	c.next++
+	return strings.TrimSuffix(url, "/")
+}
+
+// isRetryableStatus returns whether the request can be sent again. Bulk requests, the only
+// POSTs, may have been indexed behind a gateway error, and sending them again would
+// duplicate the documents.
+func isRetryableStatus(method string, status int) bool {
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		return true
+	}
+	return method != "POST" && (status == http.StatusBadGateway || status == http.StatusGatewayTimeout)
+}
+
+// perform sends the request and returns the status and the body of the response.
+func (c *esRestClient) perform(method, path string, body []byte, contentType string) (int, []byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, c.nextURL()+path, reader)
+		if err != nil {
+			return 0, nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if c.username != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		respBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if isRetryableStatus(method, resp.StatusCode) {
+			lastErr = fmt.Errorf("%s %s failed - %q", method, path, resp.Status)
+			continue
+		}
+		return resp.StatusCode, respBody, nil
+	}
+	return 0, nil, lastErr
+}
+
+// request sends the request and returns an error for unsuccessful responses.
+func (c *esRestClient) request(method, path string, body []byte, contentType string) ([]byte, error) {
+	status, respBody, err := c.perform(method, path, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	if status/100 != 2 {
+		return nil, fmt.Errorf("%s %s failed - status %d, response: %q", method, path, status,
+			httputil.ErrorBody(bytes.NewReader(respBody)))
+	}
+	return respBody, nil
+}
+
+// acknowledgedResponse is the response of the index and alias management APIs.
+type acknowledgedResponse struct {
+	Acknowledged bool `json:"acknowledged"`
+}
+
+func (c *esRestClient) acknowledgedRequest(method, path string, body []byte) (*acknowledgedResponse, error) {
+	respBody, err := c.request(method, path, body, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	ack := &acknowledgedResponse{}
+	if err := json.Unmarshal(respBody, ack); err != nil {
+		return nil, fmt.Errorf("failed to parse the response of %s %s: %v", method, path, err)
+	}
+	return ack, nil
+}
+
+func (c *esRestClient) IndexExists(indices ...string) (bool, error) {
+	path := "/" + strings.Join(indices, ",")
+	status, _, err := c.perform("HEAD", path, nil, "")
+	if err != nil {
+		return false, err
+	}
+	switch status {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("HEAD %s failed - status %d", path, status)
+	}
+}
+
+func (c *esRestClient) CreateIndex(name string, body string) (*acknowledgedResponse, error) {
+	var data []byte
+	if body != "" {
+		data = []byte(body)
+	}
+	return c.acknowledgedRequest("PUT", "/"+name, data)
+}
+
+// aliasesResponse holds the aliases of every index.
+type aliasesResponse map[string]struct {
+	Aliases map[string]interface{} `json:"aliases"`
+}
+
+func (a aliasesResponse) HasAlias(index, alias string) bool {
+	_, found := a[index].Aliases[alias]
+	return found
+}
+
+func (c *esRestClient) GetAliases(index string) (aliasesResponse, error) {
+	respBody, err := c.request("GET", "/"+index+"/_alias", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	aliases := aliasesResponse{}
+	if err := json.Unmarshal(respBody, &aliases); err != nil {
+		return nil, fmt.Errorf("failed to parse aliases of %s: %v", index, err)
+	}
+	return aliases, nil
+}
+
+func (c *esRestClient) AddAlias(index, alias string) (*acknowledgedResponse, error) {
+	return c.acknowledgedRequest("PUT", "/"+index+"/_alias/"+alias, nil)
+}
+
+func (c *esRestClient) PutIndexTemplate(name, body string) error {
+	_, err := c.acknowledgedRequest("PUT", "/_index_template/"+name, []byte(body))
+	return err
+}
+
+func (c *esRestClient) PutLifecyclePolicy(name, body string) error {
+	_, err := c.acknowledgedRequest("PUT", "/_ilm/policy/"+name, []byte(body))
+	return err
+}
+
+// PutISMPolicy creates the policy of the Index State Management plugin of OpenSearch. Existing
+// policies are left unchanged, since they can only be updated given their current version.
+func (c *esRestClient) PutISMPolicy(name, body string) error {
+	path := "/_plugins/_ism/policies/" + name
+	status, respBody, err := c.perform("PUT", path, []byte(body), "application/json")
+	if err != nil {
+		return err
+	}
+	if status == http.StatusConflict {
+		glog.V(2).Infof("ISM policy %s already exists, leaving it unchanged", name)
+		return nil
+	}
+	if status/100 != 2 {
+		return fmt.Errorf("PUT %s failed - status %d, response: %q", path, status, string(respBody))
+	}
+	return nil
+}
+
+// esBulkProcessor buffers bulk actions, and sends them when there are enough of them, when
+// Flush is called or periodically.
+type esBulkProcessor struct {
+	sync.Mutex
+	client     *esRestClient
+	buffer     bytes.Buffer
+	actions    int
+	maxActions int
+	maxSize    int
+	stopCh     chan struct{}
+}
+
+func newEsBulkProcessor(client *esRestClient, maxActions, maxSize int, flushInterval time.Duration) *esBulkProcessor {
+	bp := &esBulkProcessor{
+		client:     client,
+		maxActions: maxActions,
+		maxSize:    maxSize,
+		stopCh:     make(chan struct{}),
+	}
+	if flushInterval > 0 {
+		go bp.run(flushInterval)
+	}
+	return bp
+}
+
+func (bp *esBulkProcessor) run(flushInterval time.Duration) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := bp.Flush(); err != nil {
+				glog.Warningf("Failed to execute bulk operation to ElasticSearch: %v", err)
+			}
+		case <-bp.stopCh:
+			return
+		}
+	}
+}
+
+// bulkAction is the metadata line of a bulk action.
+type bulkAction struct {
+	Index    string `json:"_index"`
+	Id       string `json:"_id,omitempty"`
+	Pipeline string `json:"pipeline,omitempty"`
+}
+
+// Add buffers the action, e.g. "index" or "create", for the JSON encoded document.
+func (bp *esBulkProcessor) Add(action string, meta bulkAction, doc []byte) error {
+	line, err := json.Marshal(map[string]bulkAction{action: meta})
+	if err != nil {
+		return err
+	}
+	bp.Lock()
+	defer bp.Unlock()
+	bp.buffer.Write(line)
+	bp.buffer.WriteByte('\n')
+	bp.buffer.Write(doc)
+	bp.buffer.WriteByte('\n')
+	bp.actions++
+	if bp.actions >= bp.maxActions || bp.buffer.Len() >= bp.maxSize {
+		return bp.flushLocked()
+	}
+	return nil
+}
+
+func (bp *esBulkProcessor) Flush() error {
+	bp.Lock()
+	defer bp.Unlock()
+	return bp.flushLocked()
+}
+
+// bulkResponse is the part of the bulk response used to report failed actions.
+type bulkResponse struct {
+	Errors bool                                `json:"errors"`
+	Items  []map[string]bulkResponseItemResult `json:"items"`
+}
+
+type bulkResponseItemResult struct {
+	Index  string      `json:"_index"`
+	Status int         `json:"status"`
+	Error  interface{} `json:"error,omitempty"`
+}
+
+func (bp *esBulkProcessor) flushLocked() error {
+	if bp.actions == 0 {
+		return nil
+	}
+	body := make([]byte, bp.buffer.Len())
+	copy(body, bp.buffer.Bytes())
+	bp.buffer.Reset()
+	bp.actions = 0
+
+	respBody, err := bp.client.request("POST", "/_bulk", body, "application/x-ndjson")
+	if err != nil {
+		return err
+	}
+	response := bulkResponse{}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return fmt.Errorf("failed to parse bulk response: %v", err)
+	}
+	if !response.Errors {
+		return nil
+	}
+	failed := 0
+	var firstErr error
+	for _, item := range response.Items {
+		for name, result := range item {
+			if result.Error == nil {
+				continue
+			}
+			failed++
+			glog.V(3).Infof("Failed to execute bulk operation to ElasticSearch on %s: %v", name, result.Error)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s on %s - status %d: %v", name, result.Index, result.Status, result.Error)
+			}
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d bulk actions failed, first: %v", failed, len(response.Items), firstErr)
+}
+
+// Stop stops the periodic flushing and sends the remaining buffered actions.
+func (bp *esBulkProcessor) Stop() error {
+	close(bp.stopCh)
+	return bp.Flush()
+}
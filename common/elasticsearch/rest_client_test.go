@@ -0,0 +1,261 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeElasticsearch records the requests and mimics the responses of Elasticsearch 7.
+type fakeElasticsearch struct {
+	sync.Mutex
+	requests []string
+	bodies   map[string]string
+	indices  map[string]bool
+	// bulkResponse replaces the successful response to bulk requests when set.
+	bulkResponse string
+	// bulkStatus is the status of the responses to bulk requests when set.
+	bulkStatus int
+}
+
+func newFakeElasticsearch() *fakeElasticsearch {
+	return &fakeElasticsearch{bodies: make(map[string]string), indices: make(map[string]bool)}
+}
+
+func (es *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	es.Lock()
+	defer es.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	request := r.Method + " " + r.URL.Path
+	es.requests = append(es.requests, request)
+	es.bodies[request] = string(body)
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/_bulk" && es.bulkStatus != 0:
+		w.WriteHeader(es.bulkStatus)
+	case r.URL.Path == "/_bulk" && es.bulkResponse != "":
+		w.Write([]byte(es.bulkResponse))
+	case r.URL.Path == "/_bulk":
+		w.Write([]byte(`{"errors":false,"items":[]}`))
+	case r.Method == "HEAD":
+		if !es.indices[parts[0]] {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == "GET" && len(parts) == 2 && parts[1] == "_alias":
+		w.Write([]byte(`{"` + parts[0] + `":{"aliases":{}}}`))
+	case r.Method == "PUT" && len(parts) == 1:
+		es.indices[parts[0]] = true
+		w.Write([]byte(`{"acknowledged":true}`))
+	default:
+		w.Write([]byte(`{"acknowledged":true}`))
+	}
+}
+
+func createV7Service(t *testing.T, server *httptest.Server, query string) *ElasticSearchService {
+	uri, err := url.Parse(server.URL + "?ver=7&" + query)
+	require.NoError(t, err)
+	esSvc, err := CreateElasticSearchService(uri)
+	require.NoError(t, err)
+	return esSvc
+}
+
+func TestCreateElasticSearchServiceV7DataStream(t *testing.T) {
+	fake := newFakeElasticsearch()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	esSvc := createV7Service(t, server, "dataStream=true&rolloverMaxAge=1d&rolloverMaxSize=50gb&deleteAfter=30d")
+	defer esSvc.Stop()
+	assert.Equal(t, []string{"PUT /_ilm/policy/heapster", "PUT /_index_template/heapster"}, fake.requests)
+	assert.JSONEq(t,
+		`{"policy":{"phases":{"hot":{"actions":{"rollover":{"max_age":"1d","max_size":"50gb"}}},"delete":{"min_age":"30d","actions":{"delete":{}}}}}}`,
+		fake.bodies["PUT /_ilm/policy/heapster"])
+
+	template := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(fake.bodies["PUT /_index_template/heapster"]), &template))
+	assert.Equal(t, []interface{}{"heapster-*"}, template["index_patterns"])
+	assert.Equal(t, map[string]interface{}{}, template["data_stream"])
+	assert.Equal(t, map[string]interface{}{"index.lifecycle.name": "heapster"}, template["template"].(map[string]interface{})["settings"])
+
+	date := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, esSvc.SaveData(date, "cpu", []interface{}{map[string]interface{}{"MetricsTags": map[string]string{"pod_name": "p"}}}))
+	require.NoError(t, esSvc.FlushData())
+	assert.Equal(t, "POST /_bulk", fake.requests[len(fake.requests)-1])
+	assert.Equal(t,
+		`{"create":{"_index":"heapster-cpu"}}`+"\n"+
+			`{"@timestamp":"2017-05-01T10:00:00Z","MetricsTags":{"pod_name":"p"}}`+"\n",
+		fake.bodies["POST /_bulk"])
+}
+
+func TestBulkItemFailures(t *testing.T) {
+	fake := newFakeElasticsearch()
+	fake.bulkResponse = `{"errors":true,"items":[` +
+		`{"create":{"_index":"heapster-cpu","status":201}},` +
+		`{"create":{"_index":"heapster-cpu","status":400,"error":{"type":"mapper_parsing_exception"}}},` +
+		`{"create":{"_index":"heapster-cpu","status":429,"error":{"type":"es_rejected_execution_exception"}}}]}`
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	esSvc := createV7Service(t, server, "dataStream=true")
+	defer esSvc.Stop()
+	date := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	point := map[string]interface{}{"MetricsTags": map[string]string{"pod_name": "p"}}
+	require.NoError(t, esSvc.SaveData(date, "cpu", []interface{}{point, point, point}))
+	err := esSvc.FlushData()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 of 3 bulk actions failed")
+	assert.Contains(t, err.Error(), "mapper_parsing_exception")
+}
+
+func TestStopFlushesBufferedActions(t *testing.T) {
+	fake := newFakeElasticsearch()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	esSvc := createV7Service(t, server, "dataStream=true")
+	date := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, esSvc.SaveData(date, "cpu", []interface{}{map[string]interface{}{"MetricsTags": map[string]string{"pod_name": "p"}}}))
+	require.NoError(t, esSvc.Stop())
+	assert.Equal(t, "POST /_bulk", fake.requests[len(fake.requests)-1])
+}
+
+func TestBulkRetries(t *testing.T) {
+	fake := newFakeElasticsearch()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newEsRestClient([]string{server.URL}, nil, "", "", 2)
+
+	// The bulk request may have been indexed behind a gateway error, it is not sent again.
+	fake.bulkStatus = http.StatusBadGateway
+	_, err := client.request("POST", "/_bulk", []byte("{}\n"), "application/x-ndjson")
+	assert.Error(t, err)
+	assert.Len(t, fake.requests, 1)
+
+	fake.bulkStatus = http.StatusServiceUnavailable
+	_, err = client.request("POST", "/_bulk", []byte("{}\n"), "application/x-ndjson")
+	assert.Error(t, err)
+	assert.Len(t, fake.requests, 4)
+}
+
+func TestCreateElasticSearchServiceV7DailyIndices(t *testing.T) {
+	fake := newFakeElasticsearch()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	esSvc := createV7Service(t, server, "index=k8s&pipeline=enrich")
+	defer esSvc.Stop()
+	// The policy is neither created nor referenced.
+	assert.Equal(t, []string{"PUT /_index_template/k8s"}, fake.requests)
+	assert.NotContains(t, fake.bodies["PUT /_index_template/k8s"], "data_stream")
+	assert.NotContains(t, fake.bodies["PUT /_index_template/k8s"], "lifecycle")
+
+	date := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, esSvc.SaveData(date, "events", []interface{}{map[string]interface{}{"Reason": "BackOff"}}))
+	require.NoError(t, esSvc.FlushData())
+	assert.Equal(t, []string{
+		"PUT /_index_template/k8s",
+		"HEAD /k8s-2017.05.01",
+		"PUT /k8s-2017.05.01",
+		"GET /k8s-2017.05.01/_alias",
+		"PUT /k8s-2017.05.01/_alias/k8s-events",
+		"POST /_bulk",
+	}, fake.requests)
+	assert.Empty(t, fake.bodies["PUT /k8s-2017.05.01"])
+
+	lines := strings.Split(strings.TrimSpace(fake.bodies["POST /_bulk"]), "\n")
+	require.Len(t, lines, 2)
+	action := map[string]map[string]string{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &action))
+	assert.Equal(t, "k8s-2017.05.01", action["index"]["_index"])
+	assert.Equal(t, "enrich", action["index"]["pipeline"])
+	assert.NotEmpty(t, action["index"]["_id"])
+	assert.NotContains(t, lines[0], "_type")
+	assert.Equal(t, `{"Reason":"BackOff"}`, lines[1])
+}
+
+func TestCreateElasticSearchServiceOpenSearch(t *testing.T) {
+	fake := newFakeElasticsearch()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	esSvc := createV7Service(t, server, "openSearch=true&ilmPolicy=retention&deleteAfter=7d")
+	defer esSvc.Stop()
+	assert.Equal(t, []string{"PUT /_plugins/_ism/policies/retention", "PUT /_index_template/heapster"}, fake.requests)
+	assert.Contains(t, fake.bodies["PUT /_plugins/_ism/policies/retention"], `"ism_template"`)
+	assert.Contains(t, fake.bodies["PUT /_plugins/_ism/policies/retention"], `"min_index_age":"7d"`)
+	assert.NotContains(t, fake.bodies["PUT /_index_template/heapster"], "lifecycle")
+}
+
+func TestCreateElasticSearchServiceInvalidLifecycle(t *testing.T) {
+	for _, query := range []string{
+		"ver=5&dataStream=true",
+		"ver=5&ilmPolicy=heapster",
+		"ver=7&rolloverMaxAge=1d",
+		"ver=7&dataStream=maybe",
+	} {
+		uri, err := url.Parse("http://localhost:9200?" + query)
+		require.NoError(t, err)
+		_, err = CreateElasticSearchService(uri)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestTypelessMapping(t *testing.T) {
+	mappings, err := typelessMapping()
+	require.NoError(t, err)
+	data, err := json.Marshal(mappings)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"string"`)
+	assert.NotContains(t, string(data), `"not_analyzed"`)
+
+	properties := mappings["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "date"}, properties["@timestamp"])
+	assert.Contains(t, properties, "CpuMetricsTimestamp")
+	assert.Contains(t, properties, "LastOccurrenceTimestamp")
+
+	// The metrics of all the families are merged.
+	metrics := properties["Metrics"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Contains(t, metrics, "cpu/usage")
+	assert.Contains(t, metrics, "memory/usage")
+
+	tags := properties["MetricsTags"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "keyword"}, tags["pod_id"])
+	assert.Equal(t, map[string]interface{}{
+		"type":   "text",
+		"fields": map[string]interface{}{"raw": map[string]interface{}{"type": "keyword"}},
+	}, tags["pod_name"])
+}
+
+func TestWithTimestamp(t *testing.T) {
+	date := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	doc, err := withTimestamp([]byte(`{}`), date)
+	require.NoError(t, err)
+	assert.Equal(t, `{"@timestamp":"2017-05-01T10:00:00Z"}`, string(doc))
+
+	_, err = withTimestamp([]byte(`[]`), date)
+	assert.Error(t, err)
+}
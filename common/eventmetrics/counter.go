@@ -0,0 +1,94 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventmetrics
+
+import (
+	"sync"
+	"time"
+
+	kube_api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// How long the count of an event is remembered after its last update. Longer than
+// the time to live of the events in the apiserver, one hour by default.
+const countRetention = 2 * time.Hour
+
+// Key identifies a counter of events.
+type Key struct {
+	Namespace string
+	Kind      string
+	Reason    string
+	Type      string
+}
+
+func KeyOf(event *kube_api.Event) Key {
+	return Key{
+		Namespace: event.Namespace,
+		Kind:      event.InvolvedObject.Kind,
+		Reason:    event.Reason,
+		Type:      event.Type,
+	}
+}
+
+type seenCount struct {
+	count int32
+	seen  time.Time
+}
+
+// Counter counts the occurrences of the events from the counts of their updates, so that
+// an update of an event is counted once whatever the number of times it is received.
+type Counter struct {
+	lock sync.Mutex
+	seen map[types.UID]seenCount
+}
+
+func NewCounter() *Counter {
+	return &Counter{
+		seen: make(map[types.UID]seenCount),
+	}
+}
+
+// Add counts the occurrences of the event since its previous update, and returns them.
+func (c *Counter) Add(event *kube_api.Event, now time.Time) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	count := event.Count
+	if count < 1 {
+		count = 1
+	}
+	occurrences := int64(1)
+	if previous, found := c.seen[event.UID]; found {
+		if count <= previous.count {
+			return 0
+		}
+		occurrences = int64(count - previous.count)
+	}
+	// The occurrences of an event before it was first received are not counted,
+	// e.g. when the eventer restarts.
+	c.seen[event.UID] = seenCount{count: count, seen: now}
+	return occurrences
+}
+
+// Prune forgets the counts of the events not updated for a while.
+func (c *Counter) Prune(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for uid, seen := range c.seen {
+		if now.Sub(seen.seen) > countRetention {
+			delete(c.seen, uid)
+		}
+	}
+}
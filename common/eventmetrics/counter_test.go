@@ -0,0 +1,63 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventmetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newEvent(uid, reason string, count int32) *kube_api.Event {
+	return &kube_api.Event{
+		ObjectMeta:     metav1.ObjectMeta{UID: types.UID(uid), Namespace: "ns"},
+		InvolvedObject: kube_api.ObjectReference{Kind: "Pod"},
+		Reason:         reason,
+		Type:           kube_api.EventTypeWarning,
+		Count:          count,
+	}
+}
+
+func TestCounter(t *testing.T) {
+	counter := NewCounter()
+	now := time.Now()
+
+	assert.Equal(t, int64(1), counter.Add(newEvent("a", "BackOff", 3), now))
+	assert.Equal(t, int64(2), counter.Add(newEvent("a", "BackOff", 5), now))
+	// Received twice.
+	assert.Equal(t, int64(0), counter.Add(newEvent("a", "BackOff", 5), now))
+	assert.Equal(t, int64(1), counter.Add(newEvent("b", "BackOff", 0), now))
+	assert.Equal(t, int64(1), counter.Add(newEvent("c", "Evicted", 1), now))
+
+	assert.Equal(t, Key{Namespace: "ns", Kind: "Pod", Reason: "Evicted", Type: kube_api.EventTypeWarning},
+		KeyOf(newEvent("c", "Evicted", 1)))
+}
+
+func TestCounterPrune(t *testing.T) {
+	counter := NewCounter()
+	now := time.Now()
+
+	counter.Add(newEvent("a", "BackOff", 1), now)
+	counter.Add(newEvent("b", "BackOff", 1), now.Add(2*time.Hour))
+	counter.Prune(now.Add(3 * time.Hour))
+	assert.Len(t, counter.seen, 1)
+	// A forgotten event is counted again, a remembered one is not.
+	assert.Equal(t, int64(1), counter.Add(newEvent("a", "BackOff", 1), now.Add(3*time.Hour)))
+	assert.Equal(t, int64(0), counter.Add(newEvent("b", "BackOff", 1), now.Add(3*time.Hour)))
+}
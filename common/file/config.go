@@ -0,0 +1,162 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+
+	DefaultMaxSize        = 100 * 1024 * 1024
+	DefaultRotateInterval = time.Hour
+	DefaultMaxFiles       = 24
+	DefaultS3Region       = "us-east-1"
+)
+
+type FileConfig struct {
+	// Directory holding the segments.
+	Dir string
+	// Prefix of the segment names.
+	Prefix string
+	// Either FormatJSON or FormatCSV.
+	Format string
+	// Uncompressed size in bytes after which the segment is rotated.
+	MaxSize int64
+	// Age after which the segment is rotated, zero to rotate by size only.
+	RotateInterval time.Duration
+	Gzip           bool
+	// Number of finished segments kept in Dir, zero to keep all of them.
+	MaxFiles int
+	// Finished segments are uploaded when set.
+	S3 *S3Config
+}
+
+// S3Config describes the bucket of an S3 compatible object store, e.g. AWS S3 or Minio.
+type S3Config struct {
+	// Base URL of the object store, e.g. https://s3.amazonaws.com.
+	Endpoint string
+	Bucket   string
+	Region   string
+	// Prefix of the object keys.
+	KeyPrefix string
+	AccessKey string
+	SecretKey string
+}
+
+// BuildConfig parses the sink URI. The path of the URI is the directory of the segments, and
+// defaultPrefix is used when the `prefix` option is not set.
+func BuildConfig(uri *url.URL, defaultPrefix string) (*FileConfig, error) {
+	config := FileConfig{
+		Dir:            uri.Path,
+		Prefix:         defaultPrefix,
+		Format:         FormatJSON,
+		MaxSize:        DefaultMaxSize,
+		RotateInterval: DefaultRotateInterval,
+		MaxFiles:       DefaultMaxFiles,
+	}
+	if config.Dir == "" {
+		config.Dir = uri.Opaque
+	}
+	if config.Dir == "" {
+		return nil, fmt.Errorf("the directory of the file sink is missing")
+	}
+
+	opts := uri.Query()
+	if len(opts["prefix"]) >= 1 {
+		config.Prefix = opts["prefix"][0]
+	}
+	if len(opts["format"]) >= 1 {
+		config.Format = opts["format"][0]
+		if config.Format != FormatJSON && config.Format != FormatCSV {
+			return nil, fmt.Errorf("format '%s' is illegal. Use json or csv", config.Format)
+		}
+	}
+	if len(opts["max_size"]) >= 1 {
+		maxSize, err := strconv.ParseInt(opts["max_size"][0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `max_size` flag - %v", err)
+		}
+		if maxSize <= 0 {
+			return nil, fmt.Errorf("`max_size` flag has to be positive, got %d", maxSize)
+		}
+		config.MaxSize = maxSize
+	}
+	if len(opts["rotate_interval"]) >= 1 {
+		interval, err := time.ParseDuration(opts["rotate_interval"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `rotate_interval` flag - %v", err)
+		}
+		config.RotateInterval = interval
+	}
+	if len(opts["gzip"]) >= 1 {
+		gzip, err := strconv.ParseBool(opts["gzip"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `gzip` flag - %v", err)
+		}
+		config.Gzip = gzip
+	}
+	if len(opts["max_files"]) >= 1 {
+		maxFiles, err := strconv.Atoi(opts["max_files"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `max_files` flag - %v", err)
+		}
+		if maxFiles < 0 {
+			return nil, fmt.Errorf("`max_files` flag can't be negative, got %d", maxFiles)
+		}
+		config.MaxFiles = maxFiles
+	}
+
+	if len(opts["s3_bucket"]) >= 1 {
+		s3 := S3Config{
+			Endpoint:  "https://s3.amazonaws.com",
+			Bucket:    opts["s3_bucket"][0],
+			Region:    DefaultS3Region,
+			AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}
+		if len(opts["s3_endpoint"]) >= 1 {
+			s3.Endpoint = opts["s3_endpoint"][0]
+		}
+		if len(opts["s3_region"]) >= 1 {
+			s3.Region = opts["s3_region"][0]
+		}
+		if len(opts["s3_prefix"]) >= 1 {
+			s3.KeyPrefix = opts["s3_prefix"][0]
+		}
+		if len(opts["s3_access_key"]) >= 1 {
+			s3.AccessKey = opts["s3_access_key"][0]
+		}
+		if len(opts["s3_secret_key"]) >= 1 {
+			s3.SecretKey = opts["s3_secret_key"][0]
+		}
+		if s3.AccessKey == "" || s3.SecretKey == "" {
+			return nil, fmt.Errorf("the S3 credentials are missing, set `s3_access_key` and `s3_secret_key` or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		if _, err := url.Parse(s3.Endpoint); err != nil {
+			return nil, fmt.Errorf("failed to parse `s3_endpoint` flag - %v", err)
+		}
+		config.S3 = &s3
+	} else if len(opts["s3_endpoint"]) >= 1 {
+		return nil, fmt.Errorf("`s3_bucket` flag has to be set to upload segments")
+	}
+	return &config, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildConfigDefaults(t *testing.T) {
+	uri, _ := url.Parse("/var/lib/heapster")
+	config, err := BuildConfig(uri, "metrics")
+	require.NoError(t, err)
+	assert.Equal(t, FileConfig{
+		Dir:            "/var/lib/heapster",
+		Prefix:         "metrics",
+		Format:         FormatJSON,
+		MaxSize:        DefaultMaxSize,
+		RotateInterval: DefaultRotateInterval,
+		MaxFiles:       DefaultMaxFiles,
+	}, *config)
+}
+
+func TestBuildConfigOptions(t *testing.T) {
+	uri, _ := url.Parse("/data?prefix=cluster1&format=csv&max_size=1024&rotate_interval=10m&gzip=true&max_files=3" +
+		"&s3_bucket=archive&s3_endpoint=http://minio:9000&s3_region=eu-west-1&s3_prefix=heapster&s3_access_key=ak&s3_secret_key=sk")
+	config, err := BuildConfig(uri, "metrics")
+	require.NoError(t, err)
+	assert.Equal(t, "cluster1", config.Prefix)
+	assert.Equal(t, FormatCSV, config.Format)
+	assert.Equal(t, int64(1024), config.MaxSize)
+	assert.Equal(t, 10*time.Minute, config.RotateInterval)
+	assert.True(t, config.Gzip)
+	assert.Equal(t, 3, config.MaxFiles)
+	assert.Equal(t, &S3Config{
+		Endpoint:  "http://minio:9000",
+		Bucket:    "archive",
+		Region:    "eu-west-1",
+		KeyPrefix: "heapster",
+		AccessKey: "ak",
+		SecretKey: "sk",
+	}, config.S3)
+}
+
+func TestBuildConfigErrors(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"/data?format=xml",
+		"/data?max_size=0",
+		"/data?max_files=-1",
+		"/data?rotate_interval=often",
+		"/data?s3_endpoint=http://minio:9000",
+	} {
+		uri, _ := url.Parse(raw)
+		_, err := BuildConfig(uri, "metrics")
+		assert.Error(t, err, raw)
+	}
+}
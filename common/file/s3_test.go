@@ -0,0 +1,75 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Upload(t *testing.T) {
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		assert.Equal(t, "PUT", r.Method)
+		assert.Equal(t, "/archive/heapster/metrics-1.ndjson", r.URL.Path)
+		assert.Equal(t, "a\n", string(body))
+		assert.Equal(t, hex.EncodeToString(sum[:]), r.Header.Get("X-Amz-Content-Sha256"))
+		assert.Equal(t, "20170501T100000Z", r.Header.Get("X-Amz-Date"))
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		if len(authorizations) > 1 {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("SignatureDoesNotMatch"))
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "heapster-file-sink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "metrics-1.ndjson")
+	require.NoError(t, ioutil.WriteFile(filename, []byte("a\n"), 0644))
+
+	uploader := newS3Uploader(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "archive",
+		Region:    "eu-west-1",
+		KeyPrefix: "heapster/",
+		AccessKey: "AKID",
+		SecretKey: "secret",
+	})
+	uploader.now = func() time.Time { return time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC) }
+	require.NoError(t, uploader.Upload(filename))
+	require.Len(t, authorizations, 1)
+	assert.Regexp(t, "^AWS4-HMAC-SHA256 Credential=AKID/20170501/eu-west-1/s3/aws4_request, "+
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$", authorizations[0])
+
+	err = uploader.Upload(filename)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SignatureDoesNotMatch")
+	// Signing is deterministic.
+	assert.Equal(t, authorizations[0], authorizations[1])
+}
@@ -0,0 +1,294 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// Suffix of the segment being written.
+	partSuffix = ".part"
+	// Uploads of finished segments that wait for the uploader. Segments are not uploaded
+	// when the queue is full.
+	uploadQueueSize = 100
+	uploadAttempts  = 3
+)
+
+// Uploader copies finished segments to an object store.
+type Uploader interface {
+	Upload(filename string) error
+}
+
+// RotatingWriter appends data to segment files. A segment is written to a ".part" file, and
+// renamed when it is rotated or the writer is closed. Finished segments are then uploaded, and
+// the oldest ones removed to keep at most MaxFiles of them.
+type RotatingWriter struct {
+	sync.Mutex
+	config FileConfig
+	// Written at the beginning of every segment, e.g. the header row of CSV files.
+	header []byte
+	now    func() time.Time
+
+	file   *os.File
+	writer io.Writer
+	gzip   *gzip.Writer
+	name   string
+	size   int64
+	opened time.Time
+
+	uploader Uploader
+	// Delay before the first retry of a failed upload.
+	uploadBackoff time.Duration
+	uploads       chan string
+	// Finished segments waiting for their upload, which are not removed by the retention.
+	pending      map[string]bool
+	uploaderDone chan struct{}
+}
+
+func NewRotatingWriter(config FileConfig, header []byte) (*RotatingWriter, error) {
+	var uploader Uploader
+	if config.S3 != nil {
+		uploader = newS3Uploader(*config.S3)
+	}
+	return newRotatingWriter(config, header, uploader)
+}
+
+func newRotatingWriter(config FileConfig, header []byte, uploader Uploader) (*RotatingWriter, error) {
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s - %v", config.Dir, err)
+	}
+	w := &RotatingWriter{
+		config:        config,
+		header:        header,
+		now:           time.Now,
+		uploader:      uploader,
+		uploadBackoff: time.Second,
+		pending:       make(map[string]bool),
+	}
+	if err := w.recoverParts(); err != nil {
+		return nil, err
+	}
+	if uploader != nil {
+		w.uploads = make(chan string, uploadQueueSize)
+		w.uploaderDone = make(chan struct{})
+		go w.upload()
+	}
+	return w, nil
+}
+
+// Extension returns the extension of the finished segments.
+func (w *RotatingWriter) Extension() string {
+	extension := ".ndjson"
+	if w.config.Format == FormatCSV {
+		extension = ".csv"
+	}
+	if w.config.Gzip {
+		extension += ".gz"
+	}
+	return extension
+}
+
+// recoverParts finishes the segments left behind by a previous run, e.g. after a crash. They
+// are neither uploaded nor guaranteed to be complete.
+func (w *RotatingWriter) recoverParts() error {
+	parts, err := filepath.Glob(filepath.Join(w.config.Dir, w.config.Prefix+"-*"+w.Extension()+partSuffix))
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		glog.Warningf("Recovering unfinished segment %s", part)
+		if err := os.Rename(part, strings.TrimSuffix(part, partSuffix)); err != nil {
+			return fmt.Errorf("failed to recover segment %s - %v", part, err)
+		}
+	}
+	return nil
+}
+
+// Write appends the data to the current segment. Segments are only rotated between writes, so
+// data written at once is never split across segments.
+func (w *RotatingWriter) Write(data []byte) error {
+	w.Lock()
+	defer w.Unlock()
+	if w.file != nil && w.shouldRotate() {
+		if err := w.finish(); err != nil {
+			glog.Errorf("Failed to finish segment %s: %v", w.name, err)
+		}
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	n, err := w.writer.Write(data)
+	w.size += int64(n)
+	return err
+}
+
+func (w *RotatingWriter) shouldRotate() bool {
+	if w.size >= w.config.MaxSize {
+		return true
+	}
+	return w.config.RotateInterval > 0 && w.now().Sub(w.opened) >= w.config.RotateInterval
+}
+
+func (w *RotatingWriter) open() error {
+	w.opened = w.now()
+	// Segment names sort by creation time.
+	stamp := w.opened.UTC()
+	for {
+		w.name = filepath.Join(w.config.Dir, fmt.Sprintf("%s-%s%s", w.config.Prefix, stamp.Format("20060102T150405.000Z"), w.Extension()))
+		if _, err := os.Stat(w.name); os.IsNotExist(err) {
+			break
+		}
+		stamp = stamp.Add(time.Millisecond)
+	}
+
+	file, err := os.OpenFile(w.name+partSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment %s - %v", w.name, err)
+	}
+	w.file = file
+	w.writer = file
+	if w.config.Gzip {
+		w.gzip = gzip.NewWriter(file)
+		w.writer = w.gzip
+	}
+	w.size = 0
+	if len(w.header) > 0 {
+		if _, err := w.writer.Write(w.header); err != nil {
+			return err
+		}
+		w.size += int64(len(w.header))
+	}
+	return nil
+}
+
+// finish closes the current segment, queues its upload and applies the retention.
+func (w *RotatingWriter) finish() error {
+	var err error
+	if w.gzip != nil {
+		err = w.gzip.Close()
+		w.gzip = nil
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	w.writer = nil
+	if renameErr := os.Rename(w.name+partSuffix, w.name); err == nil {
+		err = renameErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if w.uploads != nil {
+		select {
+		case w.uploads <- w.name:
+			w.pending[w.name] = true
+		default:
+			glog.Errorf("Upload queue is full, segment %s will not be uploaded", w.name)
+		}
+	}
+	w.applyRetention()
+	return nil
+}
+
+// applyRetention removes the oldest finished segments beyond MaxFiles. Segments waiting for
+// their upload are kept.
+func (w *RotatingWriter) applyRetention() {
+	if w.config.MaxFiles <= 0 {
+		return
+	}
+	segments, err := w.Segments()
+	if err != nil {
+		glog.Errorf("Failed to list segments in %s: %v", w.config.Dir, err)
+		return
+	}
+	for i := 0; i < len(segments)-w.config.MaxFiles; i++ {
+		if w.pending[segments[i]] {
+			continue
+		}
+		if err := os.Remove(segments[i]); err != nil {
+			glog.Errorf("Failed to remove segment %s: %v", segments[i], err)
+		}
+	}
+}
+
+func (w *RotatingWriter) upload() {
+	defer close(w.uploaderDone)
+	for name := range w.uploads {
+		var err error
+		backoff := w.uploadBackoff
+		for attempt := 1; attempt <= uploadAttempts; attempt++ {
+			if err = w.uploader.Upload(name); err == nil {
+				break
+			}
+			if attempt < uploadAttempts {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+		}
+		if err != nil {
+			glog.Errorf("Failed to upload segment %s: %v", name, err)
+		} else {
+			glog.V(4).Infof("Uploaded segment %s", name)
+		}
+
+		w.Lock()
+		delete(w.pending, name)
+		w.applyRetention()
+		w.Unlock()
+	}
+}
+
+// Close finishes the current segment and waits for the queued uploads.
+func (w *RotatingWriter) Close() error {
+	w.Lock()
+	var err error
+	if w.file != nil {
+		err = w.finish()
+	}
+	if w.uploads != nil {
+		close(w.uploads)
+	}
+	w.Unlock()
+
+	if w.uploaderDone != nil {
+		<-w.uploaderDone
+	}
+	return err
+}
+
+// Segments returns the names of the finished segments, oldest first.
+func (w *RotatingWriter) Segments() ([]string, error) {
+	segments, err := filepath.Glob(filepath.Join(w.config.Dir, w.config.Prefix+"-*"+w.Extension()))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
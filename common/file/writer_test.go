@@ -0,0 +1,185 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUploader struct {
+	sync.Mutex
+	uploaded []string
+	contents []string
+	failures int
+}
+
+func (u *fakeUploader) Upload(filename string) error {
+	u.Lock()
+	defer u.Unlock()
+	if u.failures > 0 {
+		u.failures--
+		return errors.New("unavailable")
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	u.uploaded = append(u.uploaded, filepath.Base(filename))
+	u.contents = append(u.contents, string(data))
+	return nil
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestWriter(t *testing.T, config FileConfig, header []byte, uploader Uploader) (*RotatingWriter, *fakeClock) {
+	dir, err := ioutil.TempDir("", "heapster-file-sink")
+	require.NoError(t, err)
+	config.Dir = dir
+	if config.Prefix == "" {
+		config.Prefix = "metrics"
+	}
+	if config.MaxSize == 0 {
+		config.MaxSize = DefaultMaxSize
+	}
+	writer, err := newRotatingWriter(config, header, uploader)
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)}
+	writer.now = clock.Now
+	writer.uploadBackoff = time.Millisecond
+	return writer, clock
+}
+
+func readSegments(t *testing.T, writer *RotatingWriter) []string {
+	segments, err := writer.Segments()
+	require.NoError(t, err)
+	contents := []string{}
+	for _, segment := range segments {
+		data, err := ioutil.ReadFile(segment)
+		require.NoError(t, err)
+		contents = append(contents, string(data))
+	}
+	return contents
+}
+
+func TestRotateBySize(t *testing.T) {
+	writer, clock := newTestWriter(t, FileConfig{MaxSize: 10}, []byte("h\n"), nil)
+	defer os.RemoveAll(writer.config.Dir)
+
+	require.NoError(t, writer.Write([]byte("aaaa\n")))
+	require.NoError(t, writer.Write([]byte("bbbb\n")))
+	// The segment being written is not listed.
+	assert.Empty(t, readSegments(t, writer))
+	clock.now = clock.now.Add(time.Second)
+	require.NoError(t, writer.Write([]byte("cccc\n")))
+	require.NoError(t, writer.Close())
+
+	segments, err := writer.Segments()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"metrics-20170501T100000.000Z.ndjson", "metrics-20170501T100001.000Z.ndjson"},
+		[]string{filepath.Base(segments[0]), filepath.Base(segments[1])})
+	assert.Equal(t, []string{"h\naaaa\nbbbb\n", "h\ncccc\n"}, readSegments(t, writer))
+}
+
+func TestRotateByInterval(t *testing.T) {
+	writer, clock := newTestWriter(t, FileConfig{RotateInterval: time.Minute}, nil, nil)
+	defer os.RemoveAll(writer.config.Dir)
+
+	require.NoError(t, writer.Write([]byte("a\n")))
+	clock.now = clock.now.Add(30 * time.Second)
+	require.NoError(t, writer.Write([]byte("b\n")))
+	clock.now = clock.now.Add(30 * time.Second)
+	require.NoError(t, writer.Write([]byte("c\n")))
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, []string{"a\nb\n", "c\n"}, readSegments(t, writer))
+}
+
+func TestGzip(t *testing.T) {
+	writer, _ := newTestWriter(t, FileConfig{Format: FormatCSV, Gzip: true}, []byte("h\n"), nil)
+	defer os.RemoveAll(writer.config.Dir)
+
+	require.NoError(t, writer.Write([]byte("a\n")))
+	require.NoError(t, writer.Close())
+
+	segments, err := writer.Segments()
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	assert.True(t, strings.HasSuffix(segments[0], ".csv.gz"), segments[0])
+	file, err := os.Open(segments[0])
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "h\na\n", string(data))
+}
+
+func TestRetention(t *testing.T) {
+	writer, clock := newTestWriter(t, FileConfig{MaxSize: 1, MaxFiles: 2}, nil, nil)
+	defer os.RemoveAll(writer.config.Dir)
+
+	for _, data := range []string{"a\n", "b\n", "c\n", "d\n"} {
+		require.NoError(t, writer.Write([]byte(data)))
+		clock.now = clock.now.Add(time.Second)
+	}
+	require.NoError(t, writer.Close())
+	assert.Equal(t, []string{"c\n", "d\n"}, readSegments(t, writer))
+}
+
+func TestRecoverParts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "heapster-file-sink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	part := filepath.Join(dir, "metrics-20170501T090000.000Z.ndjson.part")
+	require.NoError(t, ioutil.WriteFile(part, []byte("a\n"), 0644))
+
+	writer, err := newRotatingWriter(FileConfig{Dir: dir, Prefix: "metrics", MaxSize: DefaultMaxSize}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a\n"}, readSegments(t, writer))
+}
+
+func TestUpload(t *testing.T) {
+	uploader := &fakeUploader{failures: 1}
+	writer, clock := newTestWriter(t, FileConfig{MaxSize: 1, MaxFiles: 1}, nil, uploader)
+	defer os.RemoveAll(writer.config.Dir)
+
+	require.NoError(t, writer.Write([]byte("a\n")))
+	clock.now = clock.now.Add(time.Second)
+	require.NoError(t, writer.Write([]byte("b\n")))
+	require.NoError(t, writer.Close())
+
+	// The first upload is retried, and segments are only removed once uploaded.
+	assert.Equal(t, []string{"metrics-20170501T100000.000Z.ndjson", "metrics-20170501T100001.000Z.ndjson"}, uploader.uploaded)
+	assert.Equal(t, []string{"a\n", "b\n"}, uploader.contents)
+	assert.Equal(t, []string{"b\n"}, readSegments(t, writer))
+}
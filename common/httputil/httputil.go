@@ -0,0 +1,29 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httputil
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// Only the beginning of an error response is included in the returned errors.
+const MaxErrorBodySize = 4096
+
+// ErrorBody reads the beginning of the body of an error response, to include it in an error.
+func ErrorBody(body io.Reader) string {
+	data, _ := ioutil.ReadAll(io.LimitReader(body, MaxErrorBodySize))
+	return string(data)
+}
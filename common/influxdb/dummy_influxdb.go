@@ -27,10 +27,13 @@ type PointSavedToInfluxdb struct {
 
 type FakeInfluxDBClient struct {
 	Pnts []PointSavedToInfluxdb
+	// Flux queries received, and the records returned for them.
+	FluxQueries []string
+	FluxRecords []FluxRecord
 }
 
 func NewFakeInfluxDBClient() *FakeInfluxDBClient {
-	return &FakeInfluxDBClient{[]PointSavedToInfluxdb{}}
+	return &FakeInfluxDBClient{Pnts: []PointSavedToInfluxdb{}}
 }
 
 func (client *FakeInfluxDBClient) Write(bps influxdb.BatchPoints) (*influxdb.Response, error) {
@@ -49,6 +52,11 @@ func (client *FakeInfluxDBClient) Query(q influxdb.Query) (*influxdb.Response, e
 	}, nil
 }
 
+func (client *FakeInfluxDBClient) QueryFlux(query string) ([]FluxRecord, error) {
+	client.FluxQueries = append(client.FluxQueries, query)
+	return client.FluxRecords, nil
+}
+
 func (client *FakeInfluxDBClient) Ping() (time.Duration, string, error) {
 	return 0, "", nil
 }
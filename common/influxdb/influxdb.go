@@ -44,6 +44,12 @@ type InfluxdbConfig struct {
 	ClusterName           string
 	DisableCounterMetrics bool
 	Concurrency           int
+	// Version is the major version of the InfluxDB API, 1 or 2.
+	Version int
+	// Organization, Bucket and Token are only used by the 2.x API.
+	Organization string
+	Bucket       string
+	Token        string
 }
 
 func NewClient(c InfluxdbConfig) (InfluxdbClient, error) {
@@ -55,6 +61,14 @@ func NewClient(c InfluxdbConfig) (InfluxdbClient, error) {
 		url.Scheme = "https"
 	}
 
+	if c.Version == 2 {
+		client := newV2Client(c, *url)
+		if _, _, err := client.Ping(); err != nil {
+			return nil, fmt.Errorf("failed to ping InfluxDB server at %q - %v", c.Host, err)
+		}
+		return client, nil
+	}
+
 	iConfig := &influxdb.Config{
 		URL:       *url,
 		Username:  c.User,
@@ -86,6 +100,7 @@ func BuildConfig(uri *url.URL) (*InfluxdbConfig, error) {
 		ClusterName:           "default",
 		DisableCounterMetrics: false,
 		Concurrency:           1,
+		Version:               1,
 	}
 
 	if len(uri.Host) > 0 {
@@ -153,5 +168,32 @@ func BuildConfig(uri *url.URL) (*InfluxdbConfig, error) {
 		config.Concurrency = concurrency
 	}
 
+	if len(opts["version"]) >= 1 {
+		version, err := strconv.Atoi(opts["version"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `version` flag - %v", err)
+		}
+		if version != 1 && version != 2 {
+			return nil, fmt.Errorf("unsupported InfluxDB API version %d, must be 1 or 2", version)
+		}
+		config.Version = version
+	}
+
+	if config.Version == 2 {
+		if len(opts["org"]) < 1 || opts["org"][0] == "" {
+			return nil, errors.New("`org` flag is required by the InfluxDB 2.x API")
+		}
+		config.Organization = opts["org"][0]
+		// The bucket defaults to the database name.
+		config.Bucket = config.DbName
+		if len(opts["bucket"]) >= 1 {
+			config.Bucket = opts["bucket"][0]
+		}
+		// TODO: use more secure way to pass the token.
+		if len(opts["token"]) >= 1 {
+			config.Token = opts["token"][0]
+		}
+	}
+
 	return &config, nil
 }
@@ -0,0 +1,237 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"k8s.io/heapster/common/httputil"
+	"k8s.io/heapster/version"
+
+	influxdb "github.com/influxdata/influxdb/client"
+)
+
+// FluxRecord is a row of a Flux query result, indexed by column name. The "result" column holds
+// the name of the yield that produced the row.
+type FluxRecord map[string]string
+
+// FluxClient is implemented by the clients of InfluxDB 2.x servers.
+type FluxClient interface {
+	QueryFlux(query string) ([]FluxRecord, error)
+}
+
+// influxdbV2Client talks to the InfluxDB 2.x HTTP API. Points are written to a single bucket of
+// an organization, and InfluxQL queries are not supported.
+type influxdbV2Client struct {
+	url        url.URL
+	org        string
+	bucket     string
+	token      string
+	userAgent  string
+	httpClient *http.Client
+}
+
+func newV2Client(c InfluxdbConfig, serverURL url.URL) *influxdbV2Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: c.InsecureSsl,
+		},
+	}
+	return &influxdbV2Client{
+		url:       serverURL,
+		org:       c.Organization,
+		bucket:    c.Bucket,
+		token:     c.Token,
+		userAgent: fmt.Sprintf("%v/%v", "heapster", version.HeapsterVersion),
+		httpClient: &http.Client{
+			Transport: transport,
+		},
+	}
+}
+
+func (client *influxdbV2Client) newRequest(method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := client.url
+	u.Path = path
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", client.userAgent)
+	if client.token != "" {
+		req.Header.Set("Authorization", "Token "+client.token)
+	}
+	return req, nil
+}
+
+// do sends the request and returns an error describing unsuccessful responses.
+func (client *influxdbV2Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		body := httputil.ErrorBody(resp.Body)
+		apiErr := struct {
+			Message string `json:"message"`
+		}{}
+		if json.Unmarshal([]byte(body), &apiErr) == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("request failed - %q: %s", resp.Status, apiErr.Message)
+		}
+		return nil, fmt.Errorf("request failed - %q, response: %q", resp.Status, body)
+	}
+	return resp, nil
+}
+
+// Write sends the points in line protocol. The database and retention policy of the batch are
+// ignored in favor of the configured bucket.
+func (client *influxdbV2Client) Write(bp influxdb.BatchPoints) (*influxdb.Response, error) {
+	var body bytes.Buffer
+	for _, point := range bp.Points {
+		if point.Time.IsZero() {
+			point.Time = bp.Time
+		}
+		body.WriteString(point.MarshalString())
+		body.WriteByte('\n')
+	}
+
+	query := url.Values{}
+	query.Set("org", client.org)
+	query.Set("bucket", client.bucket)
+	query.Set("precision", "ns")
+	req, err := client.newRequest("POST", "/api/v2/write", query, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return nil, nil
+}
+
+func (client *influxdbV2Client) Query(q influxdb.Query) (*influxdb.Response, error) {
+	return nil, errors.New("InfluxQL queries are not supported by the InfluxDB 2.x client")
+}
+
+func (client *influxdbV2Client) Ping() (time.Duration, string, error) {
+	start := time.Now()
+	req, err := client.newRequest("GET", "/ping", nil, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := client.do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	resp.Body.Close()
+	return time.Since(start), resp.Header.Get("X-Influxdb-Version"), nil
+}
+
+// QueryFlux runs the Flux query in the configured organization and returns the rows of all the
+// tables of all the results.
+func (client *influxdbV2Client) QueryFlux(query string) ([]FluxRecord, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query": query,
+		"type":  "flux",
+		"dialect": map[string]interface{}{
+			"header":      true,
+			"annotations": []string{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("org", client.org)
+	req, err := client.newRequest("POST", "/api/v2/query", params, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/csv")
+
+	resp, err := client.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return parseFluxCSV(resp.Body)
+}
+
+// parseFluxCSV parses the CSV encoding of Flux results. Every table starts with its own header
+// row, and errors that happen while the response is streamed are reported in an "error" table.
+func parseFluxCSV(body io.Reader) ([]FluxRecord, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = false
+
+	records := []FluxRecord{}
+	var header []string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Flux response: %v", err)
+		}
+		if isFluxHeader(row) || isFluxErrorHeader(row) {
+			header = row
+			continue
+		}
+		if header == nil {
+			return nil, fmt.Errorf("failed to parse Flux response: missing header")
+		}
+		record := make(FluxRecord, len(header))
+		for i, column := range header {
+			if column != "" && i < len(row) {
+				record[column] = row[i]
+			}
+		}
+		if isFluxErrorHeader(header) {
+			return nil, fmt.Errorf("Flux query failed: %s", record["error"])
+		}
+		records = append(records, record)
+	}
+}
+
+func isFluxHeader(row []string) bool {
+	return len(row) > 2 && row[1] == "result" && row[2] == "table"
+}
+
+func isFluxErrorHeader(row []string) bool {
+	for i := 0; i+1 < len(row) && i < 2; i++ {
+		if row[i] == "error" && row[i+1] == "reference" {
+			return true
+		}
+	}
+	return false
+}
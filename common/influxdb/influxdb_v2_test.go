@@ -0,0 +1,113 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	influxdb "github.com/influxdata/influxdb/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildV2Config(t *testing.T) {
+	uri, err := url.Parse("influxdb:http://localhost:8086?version=2&org=acme&token=secret")
+	require.NoError(t, err)
+	config, err := BuildConfig(uri)
+	require.NoError(t, err)
+	assert.Equal(t, 2, config.Version)
+	assert.Equal(t, "acme", config.Organization)
+	assert.Equal(t, "k8s", config.Bucket)
+	assert.Equal(t, "secret", config.Token)
+
+	for _, options := range []string{"?version=2", "?version=3", "?version=two&org=acme"} {
+		uri, err := url.Parse("influxdb:http://localhost:8086" + options)
+		require.NoError(t, err)
+		_, err = BuildConfig(uri)
+		assert.Error(t, err, options)
+	}
+}
+
+func TestV2Client(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		requests = append(requests, req)
+		bodies = append(bodies, string(body))
+		switch req.URL.Path {
+		case "/ping":
+			w.Header().Set("X-Influxdb-Version", "2.0.0")
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/write":
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/query":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write([]byte(",result,table,_time,_value\r\n,k0,0,2017-10-01T00:00:00Z,1\r\n,k0,0,2017-10-01T00:01:00Z,2\r\n\r\n" +
+				",result,table,_value,nodename\r\n,_result,0,3,node1\r\n"))
+		}
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL + "?version=2&org=acme&bucket=metrics&token=secret")
+	require.NoError(t, err)
+	config, err := BuildConfig(serverURL)
+	require.NoError(t, err)
+	client, err := NewClient(*config)
+	require.NoError(t, err)
+
+	_, err = client.Write(influxdb.BatchPoints{
+		Points: []influxdb.Point{{
+			Measurement: "cpu/usage",
+			Tags:        map[string]string{"nodename": "node1"},
+			Fields:      map[string]interface{}{"value": int64(5)},
+			Time:        time.Unix(1, 0),
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(requests))
+	write := requests[1]
+	assert.Equal(t, "/api/v2/write", write.URL.Path)
+	assert.Equal(t, "acme", write.URL.Query().Get("org"))
+	assert.Equal(t, "metrics", write.URL.Query().Get("bucket"))
+	assert.Equal(t, "Token secret", write.Header.Get("Authorization"))
+	assert.Equal(t, "cpu/usage,nodename=node1 value=5i 1000000000\n", bodies[1])
+
+	records, err := client.(FluxClient).QueryFlux(`from(bucket: "metrics")`)
+	require.NoError(t, err)
+	var query map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(bodies[2]), &query))
+	assert.Equal(t, `from(bucket: "metrics")`, query["query"])
+	assert.Equal(t, []FluxRecord{
+		{"result": "k0", "table": "0", "_time": "2017-10-01T00:00:00Z", "_value": "1"},
+		{"result": "k0", "table": "0", "_time": "2017-10-01T00:01:00Z", "_value": "2"},
+		{"result": "_result", "table": "0", "_value": "3", "nodename": "node1"},
+	}, records)
+
+	_, err = client.Query(influxdb.Query{Command: "SHOW DATABASES"})
+	assert.Error(t, err)
+}
+
+func TestParseFluxError(t *testing.T) {
+	_, err := parseFluxCSV(strings.NewReader("error,reference\r\nfailed to compile,897\r\n"))
+	assert.EqualError(t, err, "Flux query failed: failed to compile")
+}
@@ -48,6 +48,15 @@ type KafkaClient interface {
 	Name() string
 	Stop()
 	ProduceKafkaMessage(msgData interface{}) error
+	// ProduceKafkaMessages sends already encoded messages in a single request.
+	ProduceKafkaMessages(messages []KafkaMessage) error
+}
+
+// KafkaMessage is an encoded message. Messages with a key are partitioned by the hash of the key,
+// so all the messages with the same key are delivered in order.
+type KafkaMessage struct {
+	Key   []byte
+	Value []byte
 }
 
 type kafkaSink struct {
@@ -75,6 +84,34 @@ func (sink *kafkaSink) ProduceKafkaMessage(msgData interface{}) error {
 	return nil
 }
 
+func (sink *kafkaSink) ProduceKafkaMessages(messages []KafkaMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	start := time.Now()
+	size := 0
+	producerMessages := make([]*kafka.ProducerMessage, 0, len(messages))
+	for _, message := range messages {
+		producerMessage := &kafka.ProducerMessage{
+			Topic: sink.dataTopic,
+			Value: kafka.ByteEncoder(message.Value),
+		}
+		if message.Key != nil {
+			producerMessage.Key = kafka.ByteEncoder(message.Key)
+		}
+		producerMessages = append(producerMessages, producerMessage)
+		size += len(message.Value)
+	}
+
+	err := sink.producer.SendMessages(producerMessages)
+	if err != nil {
+		return fmt.Errorf("failed to produce %d messages to %s: %s", len(messages), sink.dataTopic, err)
+	}
+	end := time.Now()
+	glog.V(4).Infof("Exported %d messages (%d bytes) to kafka in %s", len(messages), size, end.Sub(start))
+	return nil
+}
+
 func (sink *kafkaSink) Name() string {
 	return "Apache Kafka Sink"
 }
@@ -83,6 +120,41 @@ func (sink *kafkaSink) Stop() {
 	sink.producer.Close()
 }
 
+// keyedPartitioner keeps the round-robin distribution of messages without a key and sends the
+// messages with a key to the partition picked by its hash.
+type keyedPartitioner struct {
+	roundRobin kafka.Partitioner
+	hash       kafka.Partitioner
+}
+
+func newKeyedPartitioner(topic string) kafka.Partitioner {
+	return &keyedPartitioner{
+		roundRobin: kafka.NewRoundRobinPartitioner(topic),
+		hash:       kafka.NewHashPartitioner(topic),
+	}
+}
+
+func (p *keyedPartitioner) Partition(message *kafka.ProducerMessage, numPartitions int32) (int32, error) {
+	if message.Key == nil {
+		return p.roundRobin.Partition(message, numPartitions)
+	}
+	return p.hash.Partition(message, numPartitions)
+}
+
+func (p *keyedPartitioner) RequiresConsistency() bool {
+	return true
+}
+
+// getPartitioner returns the keyed partitioner when the messages have a key. It makes the
+// messages wait for the partition of their key, while messages without a key are sent to
+// any available partition.
+func getPartitioner(opts url.Values, topicType string) kafka.PartitionerConstructor {
+	if topicType == TimeSeriesTopic && len(opts["key_label"]) > 0 {
+		return newKeyedPartitioner
+	}
+	return kafka.NewRoundRobinPartitioner
+}
+
 func getTopic(opts map[string][]string, topicType string) (string, error) {
 	var topic string
 	switch topicType {
@@ -216,7 +288,7 @@ func NewKafkaClient(uri *url.URL, topicType string) (KafkaClient, error) {
 	config.Producer.Retry.Max = brokerLeaderRetryLimit
 	config.Producer.Retry.Backoff = brokerLeaderRetryWait
 	config.Producer.Compression = compression
-	config.Producer.Partitioner = kafka.NewRoundRobinPartitioner
+	config.Producer.Partitioner = getPartitioner(opts, topicType)
 	config.Producer.RequiredAcks = kafka.WaitForLocal
 	config.Producer.Return.Errors = true
 	config.Producer.Return.Successes = true
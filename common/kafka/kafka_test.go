@@ -0,0 +1,37 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"net/url"
+	"testing"
+
+	kafka "github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPartitioner(t *testing.T) {
+	keyed := url.Values{"key_label": []string{"pod_id"}}
+	assert.True(t, getPartitioner(keyed, TimeSeriesTopic)("topic").RequiresConsistency())
+	assert.False(t, getPartitioner(url.Values{}, TimeSeriesTopic)("topic").RequiresConsistency())
+	assert.False(t, getPartitioner(keyed, EventsTopic)("topic").RequiresConsistency())
+
+	message := &kafka.ProducerMessage{Key: kafka.StringEncoder("pod1")}
+	partition, err := getPartitioner(keyed, TimeSeriesTopic)("topic").Partition(message, 8)
+	assert.NoError(t, err)
+	again, err := getPartitioner(keyed, TimeSeriesTopic)("topic").Partition(message, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, partition, again)
+}
@@ -146,3 +146,32 @@ func GetKubeClientConfig(uri *url.URL) (*kube_rest.Config, error) {
 
 	return kubeConfig, nil
 }
+
+// GetClusterName returns the `cluster_name` option of the source, empty if unset.
+func GetClusterName(uri *url.URL) string {
+	opts := uri.Query()
+	if len(opts["cluster_name"]) > 0 {
+		return opts["cluster_name"][0]
+	}
+	return ""
+}
+
+// ValidateClusterNames checks that each of several sources is given a distinct
+// `cluster_name`, which tells their data apart.
+func ValidateClusterNames(uris []*url.URL) error {
+	if len(uris) < 2 {
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, uri := range uris {
+		name := GetClusterName(uri)
+		if name == "" {
+			return fmt.Errorf("`cluster_name` is required when there are several sources, missing in %q", uri.String())
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate `cluster_name` %q", name)
+		}
+		names[name] = true
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseUrls(t *testing.T, uris ...string) []*url.URL {
+	result := []*url.URL{}
+	for _, uri := range uris {
+		parsed, err := url.Parse(uri)
+		require.NoError(t, err)
+		result = append(result, parsed)
+	}
+	return result
+}
+
+func TestValidateClusterNames(t *testing.T) {
+	assert.NoError(t, ValidateClusterNames(parseUrls(t, "")))
+	assert.NoError(t, ValidateClusterNames(parseUrls(t, "https://east?cluster_name=east", "https://west?cluster_name=west")))
+	assert.Error(t, ValidateClusterNames(parseUrls(t, "https://east?cluster_name=east", "https://west")))
+	assert.Error(t, ValidateClusterNames(parseUrls(t, "https://east?cluster_name=east", "https://west?cluster_name=east")))
+}
+
+func TestGetClusterName(t *testing.T) {
+	assert.Equal(t, "east", GetClusterName(parseUrls(t, "https://east?cluster_name=east&inClusterConfig=false")[0]))
+	assert.Equal(t, "", GetClusterName(parseUrls(t, "https://east")[0]))
+}
@@ -0,0 +1,276 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderelection
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	kube_api "k8s.io/api/core/v1"
+	kubeapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubev1core "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const (
+	// Annotation of the ConfigMap holding the lease, as in the Kubernetes components.
+	LeaderAnnotation = "control-plane.alpha.kubernetes.io/leader"
+
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+var (
+	isLeader = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "heapster",
+			Subsystem: "leader_election",
+			Name:      "is_leader",
+			Help:      "Whether this replica holds the lease, 1 or 0.",
+		},
+		[]string{"name"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(isLeader)
+}
+
+// LeaderElectionRecord is the lease, stored in the annotation of the ConfigMap.
+type LeaderElectionRecord struct {
+	HolderIdentity       string      `json:"holderIdentity"`
+	LeaseDurationSeconds int         `json:"leaseDurationSeconds"`
+	AcquireTime          metav1.Time `json:"acquireTime"`
+	RenewTime            metav1.Time `json:"renewTime"`
+	LeaderTransitions    int         `json:"leaderTransitions"`
+}
+
+type LeaderElectionConfig struct {
+	Client kubev1core.ConfigMapsGetter
+	// ConfigMap holding the lease.
+	Namespace string
+	Name      string
+	// Unique among the replicas, e.g. the pod name.
+	Identity string
+	// How long the replicas wait after the last renewal observed before taking the lease.
+	LeaseDuration time.Duration
+	// How long the leader retries to renew the lease before it stops leading.
+	RenewDeadline time.Duration
+	// How often the lease is renewed or tried to be acquired.
+	RetryPeriod time.Duration
+}
+
+// LeaderElector acquires and renews a lease, kept in a ConfigMap updated with
+// optimistic concurrency, so that a single replica of a component is active.
+type LeaderElector struct {
+	config LeaderElectionConfig
+	client kubev1core.ConfigMapInterface
+
+	lock    sync.Mutex
+	leading bool
+	// Time of the last successful renewal.
+	renewed time.Time
+	// Last record read, and when it last changed.
+	observed     LeaderElectionRecord
+	observedTime time.Time
+}
+
+func NewLeaderElector(config LeaderElectionConfig) (*LeaderElector, error) {
+	if config.Namespace == "" || config.Name == "" || config.Identity == "" {
+		return nil, fmt.Errorf("leader election requires a namespace, a name and an identity")
+	}
+	if config.LeaseDuration <= config.RenewDeadline {
+		return nil, fmt.Errorf("lease duration %s must be greater than renew deadline %s", config.LeaseDuration, config.RenewDeadline)
+	}
+	if config.RenewDeadline <= config.RetryPeriod {
+		return nil, fmt.Errorf("renew deadline %s must be greater than retry period %s", config.RenewDeadline, config.RetryPeriod)
+	}
+	return &LeaderElector{
+		config: config,
+		client: config.Client.ConfigMaps(config.Namespace),
+	}, nil
+}
+
+func (le *LeaderElector) IsLeader() bool {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	return le.leading
+}
+
+// Run tries to acquire and renew the lease until stop is closed, then releases it.
+func (le *LeaderElector) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(le.config.RetryPeriod)
+	defer ticker.Stop()
+	for {
+		le.tick(time.Now())
+		select {
+		case <-ticker.C:
+		case <-stop:
+			le.release()
+			return
+		}
+	}
+}
+
+func (le *LeaderElector) tick(now time.Time) {
+	acquired, err := le.tryAcquireOrRenew(now)
+	if err != nil {
+		glog.Errorf("Failed to update the lease %s/%s: %v", le.config.Namespace, le.config.Name, err)
+	}
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	switch {
+	case acquired:
+		le.renewed = now
+		if !le.leading {
+			glog.Infof("%s acquired the lease %s/%s", le.config.Identity, le.config.Namespace, le.config.Name)
+			le.setLeading(true)
+		}
+	case le.leading && (le.observed.HolderIdentity != le.config.Identity || now.Sub(le.renewed) > le.config.RenewDeadline):
+		glog.Warningf("%s lost the lease %s/%s", le.config.Identity, le.config.Namespace, le.config.Name)
+		le.setLeading(false)
+	}
+}
+
+func (le *LeaderElector) setLeading(leading bool) {
+	le.leading = leading
+	value := 0.0
+	if leading {
+		value = 1
+	}
+	isLeader.WithLabelValues(le.config.Name).Set(value)
+}
+
+// tryAcquireOrRenew returns whether this replica holds the lease after the call.
+func (le *LeaderElector) tryAcquireOrRenew(now time.Time) (bool, error) {
+	record := LeaderElectionRecord{
+		HolderIdentity:       le.config.Identity,
+		LeaseDurationSeconds: int(le.config.LeaseDuration / time.Second),
+		AcquireTime:          metav1.NewTime(now),
+		RenewTime:            metav1.NewTime(now),
+	}
+
+	configMap, err := le.client.Get(le.config.Name, metav1.GetOptions{})
+	if kubeapierrors.IsNotFound(err) {
+		configMap = &kube_api.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: le.config.Namespace, Name: le.config.Name},
+		}
+		if err := setRecord(configMap, record); err != nil {
+			return false, err
+		}
+		if _, err := le.client.Create(configMap); err != nil {
+			return false, err
+		}
+		le.observe(record, now)
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var old LeaderElectionRecord
+	if value, found := configMap.Annotations[LeaderAnnotation]; found {
+		if err := json.Unmarshal([]byte(value), &old); err != nil {
+			glog.Warningf("Invalid lease %s/%s, overwriting it: %v", le.config.Namespace, le.config.Name, err)
+		}
+	}
+	le.observe(old, now)
+	leaseDuration := time.Duration(old.LeaseDurationSeconds) * time.Second
+	if old.HolderIdentity != "" && old.HolderIdentity != le.config.Identity && now.Before(le.getObservedTime().Add(leaseDuration)) {
+		return false, nil
+	}
+
+	if old.HolderIdentity == le.config.Identity {
+		record.AcquireTime = old.AcquireTime
+		record.LeaderTransitions = old.LeaderTransitions
+	} else {
+		record.LeaderTransitions = old.LeaderTransitions + 1
+	}
+	if err := setRecord(configMap, record); err != nil {
+		return false, err
+	}
+	// Fails with a conflict if another replica updated the lease since it was read.
+	if _, err := le.client.Update(configMap); err != nil {
+		if kubeapierrors.IsConflict(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	le.observe(record, now)
+	return true, nil
+}
+
+// observe records the lease read, and when it changed, since the clocks of the
+// replicas may differ.
+func (le *LeaderElector) observe(record LeaderElectionRecord, now time.Time) {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	if record != le.observed {
+		le.observed = record
+		le.observedTime = now
+	}
+}
+
+func (le *LeaderElector) getObservedTime() time.Time {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	return le.observedTime
+}
+
+// release lets the other replicas acquire the lease right away.
+func (le *LeaderElector) release() {
+	le.lock.Lock()
+	leading, transitions := le.leading, le.observed.LeaderTransitions
+	le.lock.Unlock()
+	if !leading {
+		return
+	}
+	configMap, err := le.client.Get(le.config.Name, metav1.GetOptions{})
+	if err == nil {
+		now := metav1.Now()
+		err = setRecord(configMap, LeaderElectionRecord{
+			LeaseDurationSeconds: 1,
+			AcquireTime:          now,
+			RenewTime:            now,
+			LeaderTransitions:    transitions,
+		})
+	}
+	if err == nil {
+		_, err = le.client.Update(configMap)
+	}
+	if err != nil {
+		glog.Errorf("Failed to release the lease %s/%s: %v", le.config.Namespace, le.config.Name, err)
+	}
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	le.setLeading(false)
+}
+
+func setRecord(configMap *kube_api.ConfigMap, record LeaderElectionRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+	configMap.Annotations[LeaderAnnotation] = string(value)
+	return nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderelection
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kube_api "k8s.io/api/core/v1"
+	kubeapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubev1core "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+var configMapsResource = schema.GroupResource{Resource: "configmaps"}
+
+// fakeConfigMapClient rejects updates of a stale resource version, as the API server.
+type fakeConfigMapClient struct {
+	kubev1core.ConfigMapInterface
+	lock       sync.Mutex
+	configMaps map[string]*kube_api.ConfigMap
+	version    int
+}
+
+func newFakeConfigMapClient() *fakeConfigMapClient {
+	return &fakeConfigMapClient{configMaps: make(map[string]*kube_api.ConfigMap)}
+}
+
+func (c *fakeConfigMapClient) ConfigMaps(namespace string) kubev1core.ConfigMapInterface {
+	return c
+}
+
+func (c *fakeConfigMapClient) Get(name string, options metav1.GetOptions) (*kube_api.ConfigMap, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if configMap, found := c.configMaps[name]; found {
+		return configMap.DeepCopy(), nil
+	}
+	return nil, kubeapierrors.NewNotFound(configMapsResource, name)
+}
+
+func (c *fakeConfigMapClient) Create(configMap *kube_api.ConfigMap) (*kube_api.ConfigMap, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, found := c.configMaps[configMap.Name]; found {
+		return nil, kubeapierrors.NewAlreadyExists(configMapsResource, configMap.Name)
+	}
+	return c.store(configMap), nil
+}
+
+func (c *fakeConfigMapClient) Update(configMap *kube_api.ConfigMap) (*kube_api.ConfigMap, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.configMaps[configMap.Name].ResourceVersion != configMap.ResourceVersion {
+		return nil, kubeapierrors.NewConflict(configMapsResource, configMap.Name, nil)
+	}
+	return c.store(configMap), nil
+}
+
+func (c *fakeConfigMapClient) store(configMap *kube_api.ConfigMap) *kube_api.ConfigMap {
+	c.version++
+	configMap = configMap.DeepCopy()
+	configMap.ResourceVersion = strconv.Itoa(c.version)
+	c.configMaps[configMap.Name] = configMap
+	return configMap.DeepCopy()
+}
+
+func (c *fakeConfigMapClient) record(t *testing.T) LeaderElectionRecord {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var record LeaderElectionRecord
+	require.NoError(t, json.Unmarshal([]byte(c.configMaps["eventer"].Annotations[LeaderAnnotation]), &record))
+	return record
+}
+
+func newElector(t *testing.T, client *fakeConfigMapClient, identity string) *LeaderElector {
+	elector, err := NewLeaderElector(LeaderElectionConfig{
+		Client:        client,
+		Namespace:     "kube-system",
+		Name:          "eventer",
+		Identity:      identity,
+		LeaseDuration: DefaultLeaseDuration,
+		RenewDeadline: DefaultRenewDeadline,
+		RetryPeriod:   DefaultRetryPeriod,
+	})
+	require.NoError(t, err)
+	return elector
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, config := range []LeaderElectionConfig{
+		{Namespace: "kube-system", Name: "eventer", LeaseDuration: time.Minute, RenewDeadline: time.Second, RetryPeriod: time.Millisecond},
+		{Namespace: "kube-system", Name: "eventer", Identity: "a", LeaseDuration: time.Second, RenewDeadline: time.Second, RetryPeriod: time.Millisecond},
+		{Namespace: "kube-system", Name: "eventer", Identity: "a", LeaseDuration: time.Minute, RenewDeadline: time.Second, RetryPeriod: time.Second},
+	} {
+		config.Client = newFakeConfigMapClient()
+		_, err := NewLeaderElector(config)
+		assert.Error(t, err, "%+v", config)
+	}
+}
+
+func TestSingleLeader(t *testing.T) {
+	client := newFakeConfigMapClient()
+	a := newElector(t, client, "a")
+	b := newElector(t, client, "b")
+	now := time.Now()
+
+	a.tick(now)
+	b.tick(now)
+	assert.True(t, a.IsLeader())
+	assert.False(t, b.IsLeader())
+
+	// The standby waits while the lease is renewed.
+	for i := 1; i <= 10; i++ {
+		now = now.Add(DefaultRetryPeriod)
+		a.tick(now)
+		b.tick(now)
+		assert.True(t, a.IsLeader())
+		assert.False(t, b.IsLeader())
+	}
+	record := client.record(t)
+	assert.Equal(t, "a", record.HolderIdentity)
+	assert.Equal(t, 15, record.LeaseDurationSeconds)
+	assert.Equal(t, 0, record.LeaderTransitions)
+}
+
+func TestTakeoverWhenLeaseExpires(t *testing.T) {
+	client := newFakeConfigMapClient()
+	a := newElector(t, client, "a")
+	b := newElector(t, client, "b")
+	now := time.Now()
+	a.tick(now)
+	b.tick(now)
+	require.True(t, a.IsLeader())
+
+	// The leader stops renewing, e.g. it is partitioned from the API server.
+	now = now.Add(DefaultLeaseDuration - DefaultRetryPeriod)
+	b.tick(now)
+	assert.False(t, b.IsLeader())
+	now = now.Add(DefaultRetryPeriod)
+	b.tick(now)
+	assert.True(t, b.IsLeader())
+	assert.Equal(t, "b", client.record(t).HolderIdentity)
+	assert.Equal(t, 1, client.record(t).LeaderTransitions)
+
+	// The former leader steps down once it sees the new holder.
+	a.tick(now)
+	assert.False(t, a.IsLeader())
+}
+
+func TestLeaderStepsDownAfterRenewDeadline(t *testing.T) {
+	client := newFakeConfigMapClient()
+	a := newElector(t, client, "a")
+	now := time.Now()
+	a.tick(now)
+	require.True(t, a.IsLeader())
+
+	// Another writer keeps updating the ConfigMap between the reads and the updates of a.
+	a.client = &conflictingClient{a.client}
+	a.tick(now.Add(DefaultRetryPeriod))
+	assert.True(t, a.IsLeader())
+	a.tick(now.Add(DefaultRenewDeadline + DefaultRetryPeriod))
+	assert.False(t, a.IsLeader())
+}
+
+type conflictingClient struct {
+	kubev1core.ConfigMapInterface
+}
+
+func (c *conflictingClient) Update(configMap *kube_api.ConfigMap) (*kube_api.ConfigMap, error) {
+	return nil, kubeapierrors.NewConflict(configMapsResource, configMap.Name, nil)
+}
+
+func TestRunReleasesLease(t *testing.T) {
+	client := newFakeConfigMapClient()
+	a := newElector(t, client, "a")
+	b := newElector(t, client, "b")
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		a.Run(stop)
+		close(done)
+	}()
+	for start := time.Now(); !a.IsLeader() && time.Since(start) < 5*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.True(t, a.IsLeader())
+	close(stop)
+	<-done
+	assert.False(t, a.IsLeader())
+	assert.Equal(t, "", client.record(t).HolderIdentity)
+
+	// The standby acquires the released lease without waiting for it to expire.
+	b.tick(time.Now())
+	assert.True(t, b.IsLeader())
+}
@@ -0,0 +1,339 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"k8s.io/heapster/common/httputil"
+	"k8s.io/heapster/version"
+)
+
+const (
+	DefaultGrpcPort     = "4317"
+	DefaultHttpPort     = "4318"
+	DefaultTimeout      = 10 * time.Second
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = time.Second
+	DefaultClusterName  = "default"
+
+	metricsGrpcMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+	logsGrpcMethod    = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+	metricsHttpPath   = "/v1/metrics"
+	logsHttpPath      = "/v1/logs"
+)
+
+// OtlpClient sends encoded OTLP export requests to a collector.
+type OtlpClient interface {
+	// ExportMetrics sends an ExportMetricsServiceRequest.
+	ExportMetrics(request []byte) error
+	// ExportLogs sends an ExportLogsServiceRequest.
+	ExportLogs(request []byte) error
+	Stop()
+}
+
+type OtlpConfig struct {
+	// Address of the collector, host:port.
+	Endpoint string
+	// Either "grpc" or "http".
+	Protocol    string
+	Secure      bool
+	InsecureSsl bool
+	// Headers added to all the requests, e.g. for authentication.
+	Headers      map[string]string
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+	ClusterName  string
+}
+
+func BuildConfig(uri *url.URL) (*OtlpConfig, error) {
+	config := OtlpConfig{
+		Headers:      make(map[string]string),
+		Timeout:      DefaultTimeout,
+		MaxRetries:   DefaultMaxRetries,
+		RetryBackoff: DefaultRetryBackoff,
+		ClusterName:  DefaultClusterName,
+	}
+
+	defaultPort := DefaultGrpcPort
+	switch uri.Scheme {
+	case "grpc":
+		config.Protocol = "grpc"
+	case "grpcs":
+		config.Protocol = "grpc"
+		config.Secure = true
+	case "http":
+		config.Protocol = "http"
+		defaultPort = DefaultHttpPort
+	case "https":
+		config.Protocol = "http"
+		config.Secure = true
+		defaultPort = DefaultHttpPort
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q, must be grpc, grpcs, http or https", uri.Scheme)
+	}
+	if uri.Host == "" {
+		return nil, fmt.Errorf("the OTLP endpoint is missing")
+	}
+	config.Endpoint = uri.Host
+	if uri.Port() == "" {
+		config.Endpoint = net.JoinHostPort(uri.Hostname(), defaultPort)
+	}
+
+	opts := uri.Query()
+	for _, header := range opts["header"] {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid header %q, must be Name:Value", header)
+		}
+		config.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	if len(opts["timeout"]) >= 1 {
+		timeout, err := time.ParseDuration(opts["timeout"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `timeout` flag - %v", err)
+		}
+		config.Timeout = timeout
+	}
+	if len(opts["max_retries"]) >= 1 {
+		maxRetries, err := strconv.Atoi(opts["max_retries"][0])
+		if err != nil || maxRetries < 0 {
+			return nil, fmt.Errorf("invalid `max_retries` flag %q", opts["max_retries"][0])
+		}
+		config.MaxRetries = maxRetries
+	}
+	if len(opts["retry_backoff"]) >= 1 {
+		backoff, err := time.ParseDuration(opts["retry_backoff"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `retry_backoff` flag - %v", err)
+		}
+		config.RetryBackoff = backoff
+	}
+	if len(opts["insecuressl"]) >= 1 {
+		insecure, err := strconv.ParseBool(opts["insecuressl"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `insecuressl` flag - %v", err)
+		}
+		config.InsecureSsl = insecure
+	}
+	if len(opts["cluster_name"]) >= 1 {
+		config.ClusterName = opts["cluster_name"][0]
+	}
+	return &config, nil
+}
+
+func NewClient(config OtlpConfig) (OtlpClient, error) {
+	if config.Protocol == "grpc" {
+		return newGrpcClient(config)
+	}
+	return newHttpClient(config), nil
+}
+
+// exportWithRetry calls export until it succeeds, fails with an error that is not retryable,
+// or the retries are exhausted. The delay between attempts doubles every time.
+func exportWithRetry(config OtlpConfig, what string, export func() (retryable bool, err error)) error {
+	backoff := config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := export()
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= config.MaxRetries {
+			return fmt.Errorf("failed to export %s to %s: %v", what, config.Endpoint, err)
+		}
+		glog.V(4).Infof("Retrying the export of %s to %s in %v: %v", what, config.Endpoint, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// rawCodec sends the already encoded requests as is, since the OTLP types are not generated.
+type rawCodec struct{}
+
+type rawMessage struct {
+	data []byte
+}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	message, ok := v.(*rawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return message.data, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(*rawMessage)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	message.data = append(message.data[:0], data...)
+	return nil
+}
+
+func (rawCodec) String() string {
+	return "proto"
+}
+
+type grpcClient struct {
+	config OtlpConfig
+	conn   *grpc.ClientConn
+}
+
+func newGrpcClient(config OtlpConfig) (*grpcClient, error) {
+	opts := []grpc.DialOption{
+		grpc.WithCodec(rawCodec{}),
+		grpc.WithUserAgent(fmt.Sprintf("heapster/%v", version.HeapsterVersion)),
+	}
+	if config.Secure {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: config.InsecureSsl})))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	// The connection is established in the background, so an unavailable collector does not
+	// prevent the sink from starting.
+	conn, err := grpc.Dial(config.Endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcClient{config: config, conn: conn}, nil
+}
+
+// Status codes of failed exports that are retried, as defined by the OTLP specification.
+var retryableCodes = map[codes.Code]bool{
+	codes.Canceled:          true,
+	codes.DeadlineExceeded:  true,
+	codes.ResourceExhausted: true,
+	codes.Aborted:           true,
+	codes.OutOfRange:        true,
+	codes.Unavailable:       true,
+	codes.DataLoss:          true,
+}
+
+func (c *grpcClient) export(method, what string, request []byte) error {
+	return exportWithRetry(c.config, what, func() (bool, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+		defer cancel()
+		if len(c.config.Headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(c.config.Headers))
+		}
+		err := grpc.Invoke(ctx, method, &rawMessage{data: request}, &rawMessage{}, c.conn)
+		if err != nil {
+			return retryableCodes[grpc.Code(err)], err
+		}
+		return false, nil
+	})
+}
+
+func (c *grpcClient) ExportMetrics(request []byte) error {
+	return c.export(metricsGrpcMethod, "metrics", request)
+}
+
+func (c *grpcClient) ExportLogs(request []byte) error {
+	return c.export(logsGrpcMethod, "logs", request)
+}
+
+func (c *grpcClient) Stop() {
+	c.conn.Close()
+}
+
+type httpClient struct {
+	config  OtlpConfig
+	baseURL url.URL
+	client  *http.Client
+}
+
+func newHttpClient(config OtlpConfig) *httpClient {
+	baseURL := url.URL{Scheme: "http", Host: config.Endpoint}
+	if config.Secure {
+		baseURL.Scheme = "https"
+	}
+	return &httpClient{
+		config:  config,
+		baseURL: baseURL,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: config.InsecureSsl},
+			},
+		},
+	}
+}
+
+// HTTP status codes of failed exports that are retried, as defined by the OTLP specification.
+var retryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+func (c *httpClient) export(path, what string, request []byte) error {
+	u := c.baseURL
+	u.Path = path
+	return exportWithRetry(c.config, what, func() (bool, error) {
+		req, err := http.NewRequest("POST", u.String(), bytes.NewReader(request))
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("User-Agent", fmt.Sprintf("heapster/%v", version.HeapsterVersion))
+		for name, value := range c.config.Headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			// Network errors are retried.
+			return true, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 == 2 {
+			io.Copy(ioutil.Discard, resp.Body)
+			return false, nil
+		}
+		return retryableStatusCodes[resp.StatusCode], fmt.Errorf("request failed - %q, response: %q", resp.Status, httputil.ErrorBody(resp.Body))
+	})
+}
+
+func (c *httpClient) ExportMetrics(request []byte) error {
+	return c.export(metricsHttpPath, "metrics", request)
+}
+
+func (c *httpClient) ExportLogs(request []byte) error {
+	return c.export(logsHttpPath, "logs", request)
+}
+
+func (c *httpClient) Stop() {
+	// nothing needs to be done.
+}
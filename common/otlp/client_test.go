@@ -0,0 +1,140 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildConfigDefaults(t *testing.T) {
+	uri, _ := url.Parse("grpc://collector")
+	config, err := BuildConfig(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "grpc", config.Protocol)
+	assert.Equal(t, "collector:4317", config.Endpoint)
+	assert.False(t, config.Secure)
+	assert.Equal(t, DefaultTimeout, config.Timeout)
+	assert.Equal(t, DefaultMaxRetries, config.MaxRetries)
+	assert.Equal(t, DefaultClusterName, config.ClusterName)
+
+	uri, _ = url.Parse("https://collector")
+	config, err = BuildConfig(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "http", config.Protocol)
+	assert.Equal(t, "collector:4318", config.Endpoint)
+	assert.True(t, config.Secure)
+}
+
+func TestBuildConfigOptions(t *testing.T) {
+	uri, _ := url.Parse("grpcs://collector:1234?header=Authorization:Bearer%20abc&header=X-Tenant:%20t1&timeout=3s&max_retries=5&retry_backoff=100ms&insecuressl=true&cluster_name=prod")
+	config, err := BuildConfig(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "collector:1234", config.Endpoint)
+	assert.True(t, config.Secure)
+	assert.True(t, config.InsecureSsl)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer abc", "X-Tenant": "t1"}, config.Headers)
+	assert.Equal(t, 3*time.Second, config.Timeout)
+	assert.Equal(t, 5, config.MaxRetries)
+	assert.Equal(t, 100*time.Millisecond, config.RetryBackoff)
+	assert.Equal(t, "prod", config.ClusterName)
+}
+
+func TestBuildConfigErrors(t *testing.T) {
+	for _, raw := range []string{
+		"udp://collector",
+		"http://",
+		"http://collector?header=invalid",
+		"http://collector?max_retries=-1",
+		"http://collector?timeout=soon",
+	} {
+		uri, _ := url.Parse(raw)
+		_, err := BuildConfig(uri)
+		assert.Error(t, err, raw)
+	}
+}
+
+func newTestHttpClient(t *testing.T, server *httptest.Server, maxRetries int) OtlpClient {
+	uri, _ := url.Parse(server.URL + "?header=X-Tenant:t1&retry_backoff=1ms")
+	config, err := BuildConfig(uri)
+	require.NoError(t, err)
+	config.MaxRetries = maxRetries
+	client, err := NewClient(*config)
+	require.NoError(t, err)
+	return client
+}
+
+func TestHttpExport(t *testing.T) {
+	var paths []string
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "t1", r.Header.Get("X-Tenant"))
+		body, _ := ioutil.ReadAll(r.Body)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	client := newTestHttpClient(t, server, 0)
+	require.NoError(t, client.ExportMetrics([]byte("metrics")))
+	require.NoError(t, client.ExportLogs([]byte("logs")))
+	assert.Equal(t, []string{"/v1/metrics", "/v1/logs"}, paths)
+	assert.Equal(t, []string{"metrics", "logs"}, bodies)
+}
+
+func TestHttpExportRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestHttpClient(t, server, 2)
+	require.NoError(t, client.ExportMetrics([]byte("metrics")))
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	client = newTestHttpClient(t, server, 1)
+	assert.Error(t, client.ExportMetrics([]byte("metrics")))
+	assert.Equal(t, 2, attempts)
+}
+
+func TestHttpExportPermanentError(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request"))
+	}))
+	defer server.Close()
+
+	client := newTestHttpClient(t, server, 3)
+	err := client.ExportLogs([]byte("logs"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad request")
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,200 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// FakeOtlpClient records the requests instead of sending them.
+type FakeOtlpClient struct {
+	MetricsRequests [][]byte
+	LogsRequests    [][]byte
+	Err             error
+	Stopped         bool
+}
+
+func NewFakeOtlpClient() *FakeOtlpClient {
+	return &FakeOtlpClient{}
+}
+
+func (client *FakeOtlpClient) ExportMetrics(request []byte) error {
+	client.MetricsRequests = append(client.MetricsRequests, request)
+	return client.Err
+}
+
+func (client *FakeOtlpClient) ExportLogs(request []byte) error {
+	client.LogsRequests = append(client.LogsRequests, request)
+	return client.Err
+}
+
+func (client *FakeOtlpClient) Stop() {
+	client.Stopped = true
+}
+
+// The types below decode the subset of OTLP written by the sinks, with the oneofs flattened.
+
+type FakeAnyValue struct {
+	StringValue *string `protobuf:"bytes,1,opt,name=string_value"`
+	IntValue    *int64  `protobuf:"varint,3,opt,name=int_value"`
+}
+
+func (m *FakeAnyValue) Reset()         { *m = FakeAnyValue{} }
+func (m *FakeAnyValue) String() string { return proto.CompactTextString(m) }
+func (*FakeAnyValue) ProtoMessage()    {}
+
+type FakeKeyValue struct {
+	Key   string        `protobuf:"bytes,1,opt,name=key"`
+	Value *FakeAnyValue `protobuf:"bytes,2,opt,name=value"`
+}
+
+func (m *FakeKeyValue) Reset()         { *m = FakeKeyValue{} }
+func (m *FakeKeyValue) String() string { return proto.CompactTextString(m) }
+func (*FakeKeyValue) ProtoMessage()    {}
+
+type FakeResource struct {
+	Attributes []*FakeKeyValue `protobuf:"bytes,1,rep,name=attributes"`
+}
+
+func (m *FakeResource) Reset()         { *m = FakeResource{} }
+func (m *FakeResource) String() string { return proto.CompactTextString(m) }
+func (*FakeResource) ProtoMessage()    {}
+
+type FakeScope struct {
+	Name    string `protobuf:"bytes,1,opt,name=name"`
+	Version string `protobuf:"bytes,2,opt,name=version"`
+}
+
+func (m *FakeScope) Reset()         { *m = FakeScope{} }
+func (m *FakeScope) String() string { return proto.CompactTextString(m) }
+func (*FakeScope) ProtoMessage()    {}
+
+type FakeDataPoint struct {
+	StartTimeUnixNano uint64          `protobuf:"fixed64,2,opt,name=start_time_unix_nano"`
+	TimeUnixNano      uint64          `protobuf:"fixed64,3,opt,name=time_unix_nano"`
+	AsDouble          *float64        `protobuf:"fixed64,4,opt,name=as_double"`
+	AsInt             *int64          `protobuf:"fixed64,6,opt,name=as_int"`
+	Attributes        []*FakeKeyValue `protobuf:"bytes,7,rep,name=attributes"`
+}
+
+func (m *FakeDataPoint) Reset()         { *m = FakeDataPoint{} }
+func (m *FakeDataPoint) String() string { return proto.CompactTextString(m) }
+func (*FakeDataPoint) ProtoMessage()    {}
+
+// FakeData is either a Gauge or a Sum.
+type FakeData struct {
+	DataPoints             []*FakeDataPoint `protobuf:"bytes,1,rep,name=data_points"`
+	AggregationTemporality int32            `protobuf:"varint,2,opt,name=aggregation_temporality"`
+	IsMonotonic            bool             `protobuf:"varint,3,opt,name=is_monotonic"`
+}
+
+func (m *FakeData) Reset()         { *m = FakeData{} }
+func (m *FakeData) String() string { return proto.CompactTextString(m) }
+func (*FakeData) ProtoMessage()    {}
+
+type FakeMetric struct {
+	Name        string    `protobuf:"bytes,1,opt,name=name"`
+	Description string    `protobuf:"bytes,2,opt,name=description"`
+	Unit        string    `protobuf:"bytes,3,opt,name=unit"`
+	Gauge       *FakeData `protobuf:"bytes,5,opt,name=gauge"`
+	Sum         *FakeData `protobuf:"bytes,7,opt,name=sum"`
+}
+
+func (m *FakeMetric) Reset()         { *m = FakeMetric{} }
+func (m *FakeMetric) String() string { return proto.CompactTextString(m) }
+func (*FakeMetric) ProtoMessage()    {}
+
+type FakeLogRecord struct {
+	TimeUnixNano         uint64          `protobuf:"fixed64,1,opt,name=time_unix_nano"`
+	SeverityNumber       int32           `protobuf:"varint,2,opt,name=severity_number"`
+	SeverityText         string          `protobuf:"bytes,3,opt,name=severity_text"`
+	Body                 *FakeAnyValue   `protobuf:"bytes,5,opt,name=body"`
+	Attributes           []*FakeKeyValue `protobuf:"bytes,6,rep,name=attributes"`
+	ObservedTimeUnixNano uint64          `protobuf:"fixed64,11,opt,name=observed_time_unix_nano"`
+}
+
+func (m *FakeLogRecord) Reset()         { *m = FakeLogRecord{} }
+func (m *FakeLogRecord) String() string { return proto.CompactTextString(m) }
+func (*FakeLogRecord) ProtoMessage()    {}
+
+type FakeScopeMetrics struct {
+	Scope   *FakeScope    `protobuf:"bytes,1,opt,name=scope"`
+	Metrics []*FakeMetric `protobuf:"bytes,2,rep,name=metrics"`
+}
+
+func (m *FakeScopeMetrics) Reset()         { *m = FakeScopeMetrics{} }
+func (m *FakeScopeMetrics) String() string { return proto.CompactTextString(m) }
+func (*FakeScopeMetrics) ProtoMessage()    {}
+
+type FakeResourceMetrics struct {
+	Resource     *FakeResource       `protobuf:"bytes,1,opt,name=resource"`
+	ScopeMetrics []*FakeScopeMetrics `protobuf:"bytes,2,rep,name=scope_metrics"`
+}
+
+func (m *FakeResourceMetrics) Reset()         { *m = FakeResourceMetrics{} }
+func (m *FakeResourceMetrics) String() string { return proto.CompactTextString(m) }
+func (*FakeResourceMetrics) ProtoMessage()    {}
+
+type FakeMetricsRequest struct {
+	ResourceMetrics []*FakeResourceMetrics `protobuf:"bytes,1,rep,name=resource_metrics"`
+}
+
+func (m *FakeMetricsRequest) Reset()         { *m = FakeMetricsRequest{} }
+func (m *FakeMetricsRequest) String() string { return proto.CompactTextString(m) }
+func (*FakeMetricsRequest) ProtoMessage()    {}
+
+type FakeScopeLogs struct {
+	Scope      *FakeScope       `protobuf:"bytes,1,opt,name=scope"`
+	LogRecords []*FakeLogRecord `protobuf:"bytes,2,rep,name=log_records"`
+}
+
+func (m *FakeScopeLogs) Reset()         { *m = FakeScopeLogs{} }
+func (m *FakeScopeLogs) String() string { return proto.CompactTextString(m) }
+func (*FakeScopeLogs) ProtoMessage()    {}
+
+type FakeResourceLogs struct {
+	Resource  *FakeResource    `protobuf:"bytes,1,opt,name=resource"`
+	ScopeLogs []*FakeScopeLogs `protobuf:"bytes,2,rep,name=scope_logs"`
+}
+
+func (m *FakeResourceLogs) Reset()         { *m = FakeResourceLogs{} }
+func (m *FakeResourceLogs) String() string { return proto.CompactTextString(m) }
+func (*FakeResourceLogs) ProtoMessage()    {}
+
+type FakeLogsRequest struct {
+	ResourceLogs []*FakeResourceLogs `protobuf:"bytes,1,rep,name=resource_logs"`
+}
+
+func (m *FakeLogsRequest) Reset()         { *m = FakeLogsRequest{} }
+func (m *FakeLogsRequest) String() string { return proto.CompactTextString(m) }
+func (*FakeLogsRequest) ProtoMessage()    {}
+
+// FakeAttributes returns the string and int attributes as strings, by key.
+func FakeAttributes(attributes []*FakeKeyValue) map[string]string {
+	result := make(map[string]string, len(attributes))
+	for _, attribute := range attributes {
+		if attribute.Value == nil {
+			continue
+		}
+		if attribute.Value.StringValue != nil {
+			result[attribute.Key] = *attribute.Value.StringValue
+		} else if attribute.Value.IntValue != nil {
+			result[attribute.Key] = strconv.FormatInt(*attribute.Value.IntValue, 10)
+		}
+	}
+	return result
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"math"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/heapster/version"
+)
+
+// Field numbers of the OTLP v1 messages, see https://github.com/open-telemetry/opentelemetry-proto.
+const (
+	// ExportMetricsServiceRequest and ExportLogsServiceRequest
+	FieldRequestResourceData = 1
+
+	// ResourceMetrics and ResourceLogs
+	FieldResourceDataResource = 1
+	FieldResourceDataScopes   = 2
+
+	// ScopeMetrics and ScopeLogs
+	FieldScopeDataScope   = 1
+	FieldScopeDataRecords = 2
+
+	// Metric
+	FieldMetricName        = 1
+	FieldMetricDescription = 2
+	FieldMetricUnit        = 3
+	FieldMetricGauge       = 5
+	FieldMetricSum         = 7
+
+	// Gauge and Sum
+	FieldDataPoints             = 1
+	FieldSumTemporality         = 2
+	FieldSumIsMonotonic         = 3
+	AggregationTemporalityDelta = 1
+	AggregationTemporalityCumul = 2
+
+	// NumberDataPoint
+	FieldPointStartTime  = 2
+	FieldPointTime       = 3
+	FieldPointAsDouble   = 4
+	FieldPointAsInt      = 6
+	FieldPointAttributes = 7
+
+	// LogRecord
+	FieldLogTime           = 1
+	FieldLogSeverityNumber = 2
+	FieldLogSeverityText   = 3
+	FieldLogBody           = 5
+	FieldLogAttributes     = 6
+	FieldLogObservedTime   = 11
+	SeverityNumberInfo     = 9
+	SeverityNumberWarn     = 13
+)
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+
+	// Name of the instrumentation scope of all the data sent by Heapster.
+	scopeName = "k8s.io/heapster"
+)
+
+// Message builds the protobuf encoding of an OTLP message. Fields are appended in the order
+// of the calls, and default values are not omitted.
+type Message struct {
+	buf proto.Buffer
+}
+
+func NewMessage() *Message {
+	return &Message{}
+}
+
+func (m *Message) Bytes() []byte {
+	return m.buf.Bytes()
+}
+
+func (m *Message) tag(field, wireType int) {
+	m.buf.EncodeVarint(uint64(field<<3 | wireType))
+}
+
+func (m *Message) String(field int, value string) {
+	m.tag(field, wireBytes)
+	m.buf.EncodeStringBytes(value)
+}
+
+func (m *Message) Varint(field int, value uint64) {
+	m.tag(field, wireVarint)
+	m.buf.EncodeVarint(value)
+}
+
+func (m *Message) Fixed64(field int, value uint64) {
+	m.tag(field, wireFixed64)
+	m.buf.EncodeFixed64(value)
+}
+
+func (m *Message) Double(field int, value float64) {
+	m.Fixed64(field, math.Float64bits(value))
+}
+
+func (m *Message) Message(field int, value *Message) {
+	m.tag(field, wireBytes)
+	m.buf.EncodeRawBytes(value.Bytes())
+}
+
+// StringValue returns an AnyValue holding the string.
+func StringValue(value string) *Message {
+	m := NewMessage()
+	m.String(1, value)
+	return m
+}
+
+// IntValue returns an AnyValue holding the integer.
+func IntValue(value int64) *Message {
+	m := NewMessage()
+	m.Varint(3, uint64(value))
+	return m
+}
+
+// KeyValue returns an attribute.
+func KeyValue(key string, value *Message) *Message {
+	m := NewMessage()
+	m.String(1, key)
+	m.Message(2, value)
+	return m
+}
+
+// StringAttributes appends the attributes in the order of their keys to the given field of the message.
+func StringAttributes(m *Message, field int, attributes map[string]string) {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		m.Message(field, KeyValue(key, StringValue(attributes[key])))
+	}
+}
+
+// Resource returns a Resource with the given attributes.
+func Resource(attributes map[string]string) *Message {
+	m := NewMessage()
+	StringAttributes(m, 1, attributes)
+	return m
+}
+
+// Scope returns the InstrumentationScope of Heapster.
+func Scope() *Message {
+	m := NewMessage()
+	m.String(1, scopeName)
+	m.String(2, version.HeapsterVersion)
+	return m
+}
@@ -0,0 +1,174 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riemann
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/riemann/riemann-go-client"
+)
+
+const (
+	defaultFlushInterval = 10 * time.Second
+	defaultQueueSize     = 10000
+)
+
+// Batcher sends events to Riemann from a single goroutine, in batches of
+// BatchSize or every FlushInterval, whichever comes first. Events wait in a
+// bounded queue: when Riemann can not keep up, Add blocks so that the exporter
+// is held back instead of memory growing.
+type Batcher struct {
+	config  RiemannConfig
+	client  riemanngo.Client
+	queue   chan riemanngo.Event
+	flushes chan chan int
+	// events dropped since the last flush, only used by the run goroutine
+	dropped int
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewBatcher starts a batcher sending through client. A nil client is
+// connected on the first send, and reconnected after every failed one.
+func NewBatcher(config RiemannConfig, client riemanngo.Client) *Batcher {
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultQueueSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1
+	}
+	b := &Batcher{
+		config:  config,
+		client:  client,
+		queue:   make(chan riemanngo.Event, config.QueueSize),
+		flushes: make(chan chan int),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Add queues events, blocking while the queue is full. Events added after Stop are dropped.
+func (b *Batcher) Add(events ...riemanngo.Event) {
+	for _, event := range events {
+		select {
+		case b.queue <- event:
+		case <-b.stop:
+			glog.Warningf("Riemann batcher stopped, dropping %d events", len(events))
+			return
+		}
+	}
+}
+
+// Flush sends all the queued events and returns once they were sent, with an error
+// when some of the events added since the previous flush were dropped.
+func (b *Batcher) Flush() error {
+	done := make(chan int)
+	select {
+	case b.flushes <- done:
+		if dropped := <-done; dropped > 0 {
+			return fmt.Errorf("dropped %d events since the previous flush", dropped)
+		}
+		return nil
+	case <-b.stopped:
+		return fmt.Errorf("riemann batcher stopped")
+	}
+}
+
+// Stop sends the queued events and closes the connection.
+func (b *Batcher) Stop() {
+	close(b.stop)
+	<-b.stopped
+}
+
+func (b *Batcher) run() {
+	ticker := time.NewTicker(b.config.FlushInterval)
+	defer ticker.Stop()
+	defer close(b.stopped)
+
+	var events []riemanngo.Event
+	for {
+		select {
+		case event := <-b.queue:
+			events = append(events, event)
+			if len(events) >= b.config.BatchSize {
+				b.send(events)
+				events = nil
+			}
+		case <-ticker.C:
+			b.send(events)
+			events = nil
+		case done := <-b.flushes:
+			b.send(b.drain(events))
+			events = nil
+			done <- b.dropped
+			b.dropped = 0
+		case <-b.stop:
+			b.send(b.drain(events))
+			if b.client != nil {
+				b.client.Close()
+			}
+			return
+		}
+	}
+}
+
+// drain appends the events waiting in the queue to events.
+func (b *Batcher) drain(events []riemanngo.Event) []riemanngo.Event {
+	for {
+		select {
+		case event := <-b.queue:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// send sends events in batches of at most BatchSize. A batch which can not be
+// sent is dropped and the client reconnected for the next one.
+func (b *Batcher) send(events []riemanngo.Event) {
+	for len(events) > 0 {
+		n := len(events)
+		if n > b.config.BatchSize {
+			n = b.config.BatchSize
+		}
+		batch := events[:n]
+		events = events[n:]
+
+		if b.client == nil {
+			client, err := GetRiemannClient(b.config)
+			if err != nil {
+				glog.Warningf("Riemann sink not connected, dropping %d events: %v", len(batch), err)
+				b.dropped += len(batch)
+				continue
+			}
+			b.client = client
+		}
+		if err := SendData(b.client, batch); err != nil {
+			glog.Warningf("Error sending events to Riemann, dropping %d events: %v", len(batch), err)
+			b.dropped += len(batch)
+			b.client.Close()
+			// client will reconnect later
+			b.client = nil
+		}
+	}
+}
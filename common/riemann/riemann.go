@@ -15,8 +15,8 @@
 package riemann
 
 import (
+	"fmt"
 	"net/url"
-	"runtime"
 	"strconv"
 	"sync"
 	"time"
@@ -27,11 +27,22 @@ import (
 
 // Used to store the Riemann configuration specified in the Heapster cli
 type RiemannConfig struct {
-	Host      string
-	Ttl       float32
-	State     string
-	Tags      []string
-	BatchSize int
+	Host          string
+	Ttl           float32
+	State         string
+	Tags          []string
+	BatchSize     int
+	FlushInterval time.Duration
+	QueueSize     int
+	// TLS connection, with an optional client certificate and CA.
+	Tls      bool
+	TlsCert  string
+	TlsKey   string
+	TlsCA    string
+	Insecure bool
+	// Rules deriving the state of the events, evaluated in order.
+	MetricStateRules []MetricStateRule
+	EventStateRules  []EventStateRule
 }
 
 // contains the riemann client, the riemann configuration, and a RWMutex
@@ -45,11 +56,13 @@ type RiemannSink struct {
 func CreateRiemannSink(uri *url.URL) (*RiemannSink, error) {
 	// Default configuration
 	c := RiemannConfig{
-		Host:      "riemann-heapster:5555",
-		Ttl:       60.0,
-		State:     "",
-		Tags:      make([]string, 0),
-		BatchSize: 1000,
+		Host:          "riemann-heapster:5555",
+		Ttl:           60.0,
+		State:         "",
+		Tags:          make([]string, 0),
+		BatchSize:     1000,
+		FlushInterval: defaultFlushInterval,
+		QueueSize:     defaultQueueSize,
 	}
 	// check host
 	if len(uri.Host) > 0 {
@@ -72,10 +85,77 @@ func CreateRiemannSink(uri *url.URL) (*RiemannSink, error) {
 		}
 		c.BatchSize = batchSize
 	}
+	// check flush interval
+	if len(options["flushinterval"]) > 0 {
+		var flushInterval, err = time.ParseDuration(options["flushinterval"][0])
+		if err != nil {
+			return nil, err
+		}
+		c.FlushInterval = flushInterval
+	}
+	// check queue size
+	if len(options["queuesize"]) > 0 {
+		var queueSize, err = strconv.Atoi(options["queuesize"][0])
+		if err != nil {
+			return nil, err
+		}
+		c.QueueSize = queueSize
+	}
 	// check state
 	if len(options["state"]) > 0 {
 		c.State = options["state"][0]
 	}
+	// check state rules
+	for _, rule := range options["metricstate"] {
+		r, err := ParseMetricStateRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		c.MetricStateRules = append(c.MetricStateRules, r)
+	}
+	for _, rule := range options["eventstate"] {
+		r, err := ParseEventStateRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		c.EventStateRules = append(c.EventStateRules, r)
+	}
+	// check tls
+	if len(options["tls"]) > 0 {
+		var tls, err = strconv.ParseBool(options["tls"][0])
+		if err != nil {
+			return nil, err
+		}
+		c.Tls = tls
+	}
+	if len(options["tlscert"]) > 0 {
+		c.TlsCert = options["tlscert"][0]
+	}
+	if len(options["tlskey"]) > 0 {
+		c.TlsKey = options["tlskey"][0]
+	}
+	if len(options["tlsca"]) > 0 {
+		c.TlsCA = options["tlsca"][0]
+	}
+	if len(options["insecure"]) > 0 {
+		var insecure, err = strconv.ParseBool(options["insecure"][0])
+		if err != nil {
+			return nil, err
+		}
+		c.Insecure = insecure
+	}
+	if (c.TlsCert == "") != (c.TlsKey == "") {
+		return nil, fmt.Errorf("tlscert and tlskey must be set together")
+	}
+	if !c.Tls && (c.TlsCert != "" || c.TlsCA != "" || c.Insecure) {
+		return nil, fmt.Errorf("tlscert, tlskey, tlsca and insecure require tls=true")
+	}
+	if c.Tls {
+		// fail on startup rather than on every connection
+		if _, err := newTlsConfig(c); err != nil {
+			return nil, err
+		}
+	}
 	// check tags
 	if len(options["tags"]) > 0 {
 		c.Tags = options["tags"]
@@ -101,8 +181,16 @@ func CreateRiemannSink(uri *url.URL) (*RiemannSink, error) {
 // Receives a sink, connect the riemann client.
 func GetRiemannClient(config RiemannConfig) (riemanngo.Client, error) {
 	glog.Infof("Connect Riemann client...")
-	client := riemanngo.NewTcpClient(config.Host)
-	runtime.SetFinalizer(client, func(c riemanngo.Client) { c.Close() })
+	var client riemanngo.Client
+	if config.Tls {
+		tlsClient, err := newTlsClient(config)
+		if err != nil {
+			return nil, err
+		}
+		client = tlsClient
+	} else {
+		client = riemanngo.NewTcpClient(config.Host)
+	}
 	// 5 seconds timeout
 	err := client.Connect(5)
 	if err != nil {
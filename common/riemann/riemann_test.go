@@ -0,0 +1,354 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riemann
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/golang/protobuf/proto"
+	"github.com/riemann/riemann-go-client"
+	"github.com/riemann/riemann-go-client/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRiemannClient struct {
+	sync.Mutex
+	batches [][]*proto.Event
+	block   chan struct{}
+	fail    bool
+}
+
+func (client *fakeRiemannClient) Connect(timeout int32) error {
+	return nil
+}
+
+func (client *fakeRiemannClient) Close() error {
+	return nil
+}
+
+func (client *fakeRiemannClient) Send(e *proto.Msg) (*proto.Msg, error) {
+	if client.block != nil {
+		<-client.block
+	}
+	client.Lock()
+	defer client.Unlock()
+	if client.fail {
+		return nil, fmt.Errorf("connection reset")
+	}
+	client.batches = append(client.batches, e.Events)
+	return &proto.Msg{Ok: pb.Bool(true)}, nil
+}
+
+func (client *fakeRiemannClient) sizes() []int {
+	client.Lock()
+	defer client.Unlock()
+	var sizes []int
+	for _, batch := range client.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func newEvents(n int) []riemanngo.Event {
+	events := make([]riemanngo.Event, n)
+	for i := range events {
+		events[i] = riemanngo.Event{Service: "service1", Metric: 10}
+	}
+	return events
+}
+
+func TestCreateRiemannSink(t *testing.T) {
+	uri, err := url.Parse("riemann://riemann:5555?batchsize=10&flushinterval=5s&queuesize=100&state=ok" +
+		"&metricstate=cpu/node_utilization>=0.9:critical&eventstate=BackOff:warning")
+	require.NoError(t, err)
+	sink, err := CreateRiemannSink(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "riemann:5555", sink.Config.Host)
+	assert.Equal(t, 10, sink.Config.BatchSize)
+	assert.Equal(t, 5*time.Second, sink.Config.FlushInterval)
+	assert.Equal(t, 100, sink.Config.QueueSize)
+	assert.Equal(t, []MetricStateRule{{Metric: "cpu/node_utilization", Op: ">=", Threshold: 0.9, State: "critical"}}, sink.Config.MetricStateRules)
+	assert.Equal(t, []EventStateRule{{Reason: "BackOff", State: "warning"}}, sink.Config.EventStateRules)
+	assert.False(t, sink.Config.Tls)
+}
+
+func TestCreateRiemannSinkInvalid(t *testing.T) {
+	for _, query := range []string{
+		"flushinterval=5",
+		"queuesize=many",
+		"metricstate=cpu/usage>1:broken",
+		"metricstate=cpu/usage:critical",
+		"eventstate=critical",
+		"tlscert=/etc/cert.pem",
+		"tls=true&tlscert=/etc/cert.pem",
+		"tls=true&tlsca=/nonexistent/ca.pem",
+	} {
+		uri, err := url.Parse("riemann://riemann:5555?" + query)
+		require.NoError(t, err)
+		_, err = CreateRiemannSink(uri)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestParseMetricStateRule(t *testing.T) {
+	rule, err := ParseMetricStateRule("memory/usage<=1e9:ok")
+	require.NoError(t, err)
+	assert.Equal(t, MetricStateRule{Metric: "memory/usage", Op: "<=", Threshold: 1e9, State: "ok"}, rule)
+	assert.True(t, rule.Matches("memory/usage", int64(1000)))
+	assert.False(t, rule.Matches("memory/usage", float32(2e9)))
+	assert.False(t, rule.Matches("memory/limit", int64(1000)))
+
+	rule, err = ParseMetricStateRule("restart_count!=0:warning")
+	require.NoError(t, err)
+	assert.True(t, rule.Matches("restart_count", int64(3)))
+	assert.False(t, rule.Matches("restart_count", int64(0)))
+
+	_, err = ParseMetricStateRule("cpu/usage>high:critical")
+	assert.Error(t, err)
+	_, err = ParseMetricStateRule(">0.9:critical")
+	assert.Error(t, err)
+}
+
+func TestBatcherBatchSize(t *testing.T) {
+	client := &fakeRiemannClient{}
+	batcher := NewBatcher(RiemannConfig{BatchSize: 10, FlushInterval: time.Hour}, client)
+	defer batcher.Stop()
+
+	batcher.Add(newEvents(25)...)
+	batcher.Flush()
+	assert.Equal(t, []int{10, 10, 5}, client.sizes())
+}
+
+func TestBatcherFlushInterval(t *testing.T) {
+	client := &fakeRiemannClient{}
+	batcher := NewBatcher(RiemannConfig{BatchSize: 1000, FlushInterval: 10 * time.Millisecond}, client)
+	defer batcher.Stop()
+
+	batcher.Add(newEvents(3)...)
+	for i := 0; i < 100 && len(client.sizes()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, []int{3}, client.sizes())
+}
+
+func TestBatcherBackpressure(t *testing.T) {
+	client := &fakeRiemannClient{block: make(chan struct{})}
+	batcher := NewBatcher(RiemannConfig{BatchSize: 1, QueueSize: 2, FlushInterval: time.Hour}, client)
+
+	added := make(chan struct{})
+	go func() {
+		// one event in flight, two queued, the last one waits
+		batcher.Add(newEvents(4)...)
+		close(added)
+	}()
+	select {
+	case <-added:
+		t.Fatal("Add did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(client.block)
+	<-added
+	batcher.Stop()
+	assert.Equal(t, []int{1, 1, 1, 1}, client.sizes())
+}
+
+func TestBatcherFlushReportsDroppedEvents(t *testing.T) {
+	client := &fakeRiemannClient{fail: true}
+	batcher := NewBatcher(RiemannConfig{BatchSize: 10, FlushInterval: time.Hour}, client)
+	defer batcher.Stop()
+
+	batcher.Add(newEvents(15)...)
+	err := batcher.Flush()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dropped 15 events")
+	// the count starts over after every flush
+	assert.NoError(t, batcher.Flush())
+}
+
+func TestBatcherStop(t *testing.T) {
+	client := &fakeRiemannClient{}
+	batcher := NewBatcher(RiemannConfig{BatchSize: 1000, FlushInterval: time.Hour}, client)
+	batcher.Add(newEvents(5)...)
+	batcher.Stop()
+	assert.Equal(t, []int{5}, client.sizes())
+	// events added after stop are dropped
+	batcher.Add(newEvents(5)...)
+	batcher.Flush()
+	assert.Equal(t, []int{5}, client.sizes())
+}
+
+// writeCert generates a key pair signed by parent, or self signed without
+// one, and writes them as PEM files in dir.
+func writeCert(t *testing.T, dir, name string, template *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".pem"), certPem, 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPem, 0600))
+
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	require.NoError(t, err)
+	cert.Leaf, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// writeTlsCerts writes a CA, a server and a client certificate to dir, and returns the
+// server configuration which requires a client certificate signed by the CA.
+func writeTlsCerts(t *testing.T, dir string) *tls.Config {
+	notAfter := time.Now().Add(time.Hour)
+	ca := writeCert(t, dir, "ca", &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "ca"}, NotAfter: notAfter,
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}, nil)
+	server := writeCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "localhost"}, NotAfter: notAfter,
+		DNSNames: []string{"localhost"}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+	writeCert(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "heapster"}, NotAfter: notAfter,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	return &tls.Config{
+		Certificates: []tls.Certificate{server},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+}
+
+func TestTlsClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "riemann")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", writeTlsCerts(t, dir))
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan *proto.Msg, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var length uint32
+		if binary.Read(conn, binary.BigEndian, &length) != nil {
+			return
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return
+		}
+		msg := &proto.Msg{}
+		pb.Unmarshal(data, msg)
+		received <- msg
+
+		response, _ := pb.Marshal(&proto.Msg{Ok: pb.Bool(true)})
+		b := new(bytes.Buffer)
+		binary.Write(b, binary.BigEndian, uint32(len(response)))
+		b.Write(response)
+		conn.Write(b.Bytes())
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	uri, err := url.Parse("riemann://localhost:" + port + "?tls=true" +
+		"&tlscert=" + filepath.Join(dir, "client.pem") +
+		"&tlskey=" + filepath.Join(dir, "client-key.pem") +
+		"&tlsca=" + filepath.Join(dir, "ca.pem"))
+	require.NoError(t, err)
+	sink, err := CreateRiemannSink(uri)
+	require.NoError(t, err)
+	require.NotNil(t, sink.Client)
+	defer sink.Client.Close()
+
+	require.NoError(t, SendData(sink.Client, newEvents(1)))
+	msg := <-received
+	require.Len(t, msg.Events, 1)
+	assert.Equal(t, "service1", msg.Events[0].GetService())
+}
+
+func TestTlsClientSendTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "riemann")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", writeTlsCerts(t, dir))
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// The server completes the handshake but never acknowledges the message.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+		<-done
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	client, err := newTlsClient(RiemannConfig{
+		Host:    "localhost:" + port,
+		TlsCert: filepath.Join(dir, "client.pem"),
+		TlsKey:  filepath.Join(dir, "client-key.pem"),
+		TlsCA:   filepath.Join(dir, "ca.pem"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(1))
+	defer client.Close()
+
+	start := time.Now()
+	_, err = client.Send(&proto.Msg{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out after 1s waiting for the acknowledgement")
+	assert.True(t, time.Since(start) < 3*time.Second)
+}
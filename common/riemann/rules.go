@@ -0,0 +1,146 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riemann
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Riemann states which can be set by the rules.
+var validStates = map[string]bool{"ok": true, "warning": true, "critical": true}
+
+// MetricStateRule sets the state of the events of a metric whose value
+// compares to a threshold, e.g. `cpu/node_utilization>0.9:critical`.
+type MetricStateRule struct {
+	Metric    string
+	Op        string
+	Threshold float64
+	State     string
+}
+
+// EventStateRule sets the state of the Kubernetes events with a given reason,
+// e.g. `BackOff:critical`.
+type EventStateRule struct {
+	Reason string
+	State  string
+}
+
+// Comparison operators, longest first so that `>=` is not read as `>`.
+var operators = []string{">=", "<=", "==", "!=", ">", "<"}
+
+func parseState(rule, state string) (string, error) {
+	if !validStates[state] {
+		return "", fmt.Errorf("invalid state %q in rule %q, must be one of ok, warning or critical", state, rule)
+	}
+	return state, nil
+}
+
+// ParseMetricStateRule parses a rule of the form `<metric><op><threshold>:<state>`.
+func ParseMetricStateRule(rule string) (MetricStateRule, error) {
+	sep := strings.LastIndex(rule, ":")
+	if sep < 0 {
+		return MetricStateRule{}, fmt.Errorf("missing state in rule %q", rule)
+	}
+	state, err := parseState(rule, rule[sep+1:])
+	if err != nil {
+		return MetricStateRule{}, err
+	}
+	expr := rule[:sep]
+	pos := strings.IndexAny(expr, "<>=!")
+	if pos <= 0 {
+		return MetricStateRule{}, fmt.Errorf("missing metric or comparison in rule %q", rule)
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(expr[pos:], op) {
+			threshold, err := strconv.ParseFloat(expr[pos+len(op):], 64)
+			if err != nil {
+				return MetricStateRule{}, fmt.Errorf("invalid threshold in rule %q - %v", rule, err)
+			}
+			return MetricStateRule{Metric: expr[:pos], Op: op, Threshold: threshold, State: state}, nil
+		}
+	}
+	return MetricStateRule{}, fmt.Errorf("invalid comparison in rule %q", rule)
+}
+
+// ParseEventStateRule parses a rule of the form `<reason>:<state>`.
+func ParseEventStateRule(rule string) (EventStateRule, error) {
+	sep := strings.LastIndex(rule, ":")
+	if sep <= 0 {
+		return EventStateRule{}, fmt.Errorf("rule %q must be of the form <reason>:<state>", rule)
+	}
+	state, err := parseState(rule, rule[sep+1:])
+	if err != nil {
+		return EventStateRule{}, err
+	}
+	return EventStateRule{Reason: rule[:sep], State: state}, nil
+}
+
+// Matches returns whether the rule applies to the value of the metric.
+func (r MetricStateRule) Matches(metric string, value interface{}) bool {
+	if metric != r.Metric {
+		return false
+	}
+	var v float64
+	switch value := value.(type) {
+	case int64:
+		v = float64(value)
+	case int:
+		v = float64(value)
+	case float32:
+		v = float64(value)
+	case float64:
+		v = value
+	default:
+		return false
+	}
+	switch r.Op {
+	case ">":
+		return v > r.Threshold
+	case ">=":
+		return v >= r.Threshold
+	case "<":
+		return v < r.Threshold
+	case "<=":
+		return v <= r.Threshold
+	case "==":
+		return v == r.Threshold
+	case "!=":
+		return v != r.Threshold
+	}
+	return false
+}
+
+// MetricState returns the state of the first rule matching the metric value,
+// or the configured default state.
+func (c RiemannConfig) MetricState(metric string, value interface{}) string {
+	for _, rule := range c.MetricStateRules {
+		if rule.Matches(metric, value) {
+			return rule.State
+		}
+	}
+	return c.State
+}
+
+// EventState returns the state of the first rule matching the event reason.
+func (c RiemannConfig) EventState(reason string) (string, bool) {
+	for _, rule := range c.EventStateRules {
+		if rule.Reason == reason {
+			return rule.State, true
+		}
+	}
+	return "", false
+}
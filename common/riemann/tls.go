@@ -0,0 +1,163 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package riemann
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	pb "github.com/golang/protobuf/proto"
+	"github.com/riemann/riemann-go-client/proto"
+)
+
+// tlsClient is a riemanngo.Client speaking the Riemann protocol over TLS. The
+// vendored TlsClient requires a client certificate and trusts nothing but that
+// certificate, so it can not talk to a server signed by a separate CA.
+type tlsClient struct {
+	addr   string
+	config *tls.Config
+	conn   net.Conn
+	// timeout bounds the write of a message and the read of its acknowledgement.
+	timeout time.Duration
+	sync.Mutex
+}
+
+// newTlsConfig builds the TLS configuration from the sink configuration. The
+// client certificate is optional; the system roots are used without a CA.
+func newTlsConfig(config RiemannConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.Insecure}
+	if host, _, err := net.SplitHostPort(config.Host); err == nil {
+		tlsConfig.ServerName = host
+	}
+	if config.TlsCert != "" || config.TlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(config.TlsCert, config.TlsKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate - %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if config.TlsCA != "" {
+		ca, err := ioutil.ReadFile(config.TlsCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA certificate - %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", config.TlsCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+func newTlsClient(config RiemannConfig) (*tlsClient, error) {
+	tlsConfig, err := newTlsConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &tlsClient{addr: config.Host, config: tlsConfig}, nil
+}
+
+// Connect dials the server and completes the TLS handshake within timeout seconds.
+// The same timeout applies to every Send.
+func (c *tlsClient) Connect(timeout int32) error {
+	c.Lock()
+	defer c.Unlock()
+	c.timeout = time.Duration(timeout) * time.Second
+	dialer := &net.Dialer{Timeout: c.timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", c.addr, c.config)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+// setDeadline bounds the next network operation by the connect timeout.
+func (c *tlsClient) setDeadline() error {
+	if c.timeout <= 0 {
+		return nil
+	}
+	return c.conn.SetDeadline(time.Now().Add(c.timeout))
+}
+
+// timeoutError replaces a timeout by an error naming the operation that expired.
+func (c *tlsClient) timeoutError(op string, err error) error {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return fmt.Errorf("timed out after %v %s %s", c.timeout, op, c.addr)
+	}
+	return err
+}
+
+// Send writes a length prefixed message and reads the acknowledgement, each within
+// the connect timeout.
+func (c *tlsClient) Send(message *proto.Msg) (*proto.Msg, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.conn == nil {
+		return nil, fmt.Errorf("not connected to %s", c.addr)
+	}
+	data, err := pb.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	b := new(bytes.Buffer)
+	binary.Write(b, binary.BigEndian, uint32(len(data)))
+	b.Write(data)
+	if err := c.setDeadline(); err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(b.Bytes()); err != nil {
+		return nil, c.timeoutError("writing to", err)
+	}
+	if err := c.setDeadline(); err != nil {
+		return nil, err
+	}
+	var length uint32
+	if err := binary.Read(c.conn, binary.BigEndian, &length); err != nil {
+		return nil, c.timeoutError("waiting for the acknowledgement of", err)
+	}
+	response := make([]byte, length)
+	if _, err := io.ReadFull(c.conn, response); err != nil {
+		return nil, c.timeoutError("waiting for the acknowledgement of", err)
+	}
+	msg := &proto.Msg{}
+	if err := pb.Unmarshal(response, msg); err != nil {
+		return nil, err
+	}
+	if !msg.GetOk() {
+		return msg, fmt.Errorf("riemann rejected the events: %s", msg.GetError())
+	}
+	return msg, nil
+}
+
+func (c *tlsClient) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
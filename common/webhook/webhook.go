@@ -0,0 +1,321 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/common/httputil"
+	"k8s.io/heapster/version"
+)
+
+const (
+	DefaultTimeout      = 10 * time.Second
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = time.Second
+	DefaultMaxBatchSize = 1000
+	DefaultClusterName  = "default"
+	DefaultContentType  = "application/json"
+)
+
+type WebhookConfig struct {
+	// Every batch is posted to all the URLs.
+	URLs []string
+	// Headers added to all the requests.
+	Headers     map[string]string
+	Username    string
+	Password    string
+	BearerToken string
+	Gzip        bool
+	InsecureSsl bool
+	// Renders the request bodies instead of JSON.
+	Template     *template.Template
+	ContentType  string
+	Timeout      time.Duration
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// The maximum number of metrics or events per request.
+	MaxBatchSize int
+	ClusterName  string
+}
+
+// templateFuncs are available in the user templates, e.g. `{{json .Labels}}`.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// NewTemplate parses a user template, with the template functions available.
+func NewTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the webhook template - %v", err)
+	}
+	return tmpl, nil
+}
+
+// BuildConfig parses the sink URI. The URI itself is the first URL the batches
+// are posted to, e.g. `https://collector.example.com/ingest?url=https://backup.example.com/ingest`.
+func BuildConfig(uri *url.URL) (*WebhookConfig, error) {
+	config := WebhookConfig{
+		Headers:      make(map[string]string),
+		ContentType:  DefaultContentType,
+		Timeout:      DefaultTimeout,
+		MaxRetries:   DefaultMaxRetries,
+		RetryBackoff: DefaultRetryBackoff,
+		MaxBatchSize: DefaultMaxBatchSize,
+		ClusterName:  DefaultClusterName,
+		Password:     os.Getenv("WEBHOOK_PASSWORD"),
+		BearerToken:  os.Getenv("WEBHOOK_BEARER_TOKEN"),
+	}
+
+	opts := uri.Query()
+	if uri.Host != "" {
+		base := *uri
+		base.RawQuery = ""
+		config.URLs = append(config.URLs, base.String())
+	}
+	config.URLs = append(config.URLs, opts["url"]...)
+	if len(config.URLs) == 0 {
+		return nil, fmt.Errorf("no webhook url specified")
+	}
+	for _, u := range config.URLs {
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return nil, fmt.Errorf("invalid webhook url %q, must be an http or https URL", u)
+		}
+	}
+
+	for _, header := range opts["header"] {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid header %q, must be Name:Value", header)
+		}
+		config.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	if len(opts["username"]) >= 1 {
+		config.Username = opts["username"][0]
+	}
+	if len(opts["password"]) >= 1 {
+		config.Password = opts["password"][0]
+	}
+	if len(opts["bearer_token"]) >= 1 {
+		config.BearerToken = opts["bearer_token"][0]
+	}
+	if config.Username != "" && len(opts["bearer_token"]) >= 1 {
+		return nil, fmt.Errorf("`username` and `bearer_token` are mutually exclusive")
+	}
+	if len(opts["gzip"]) >= 1 {
+		gzip, err := strconv.ParseBool(opts["gzip"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `gzip` flag - %v", err)
+		}
+		config.Gzip = gzip
+	}
+	if len(opts["insecuressl"]) >= 1 {
+		insecure, err := strconv.ParseBool(opts["insecuressl"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `insecuressl` flag - %v", err)
+		}
+		config.InsecureSsl = insecure
+	}
+	if len(opts["template"]) >= 1 {
+		path := opts["template"][0]
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the webhook template - %v", err)
+		}
+		tmpl, err := NewTemplate(path, string(text))
+		if err != nil {
+			return nil, err
+		}
+		config.Template = tmpl
+	}
+	if len(opts["content_type"]) >= 1 {
+		config.ContentType = opts["content_type"][0]
+	}
+	if len(opts["timeout"]) >= 1 {
+		timeout, err := time.ParseDuration(opts["timeout"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `timeout` flag - %v", err)
+		}
+		config.Timeout = timeout
+	}
+	if len(opts["max_retries"]) >= 1 {
+		maxRetries, err := strconv.Atoi(opts["max_retries"][0])
+		if err != nil || maxRetries < 0 {
+			return nil, fmt.Errorf("invalid `max_retries` flag %q", opts["max_retries"][0])
+		}
+		config.MaxRetries = maxRetries
+	}
+	if len(opts["retry_backoff"]) >= 1 {
+		backoff, err := time.ParseDuration(opts["retry_backoff"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `retry_backoff` flag - %v", err)
+		}
+		config.RetryBackoff = backoff
+	}
+	if len(opts["max_batch_size"]) >= 1 {
+		maxBatchSize, err := strconv.Atoi(opts["max_batch_size"][0])
+		if err != nil || maxBatchSize <= 0 {
+			return nil, fmt.Errorf("invalid `max_batch_size` flag %q", opts["max_batch_size"][0])
+		}
+		config.MaxBatchSize = maxBatchSize
+	}
+	if len(opts["cluster_name"]) >= 1 {
+		config.ClusterName = opts["cluster_name"][0]
+	}
+	return &config, nil
+}
+
+// WebhookClient posts payloads to the configured URLs.
+type WebhookClient interface {
+	// Send renders the payload and posts it to every URL, returning the first error.
+	Send(payload interface{}) error
+}
+
+type webhookClient struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+func NewClient(config WebhookConfig) WebhookClient {
+	return &webhookClient{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: config.InsecureSsl},
+			},
+		},
+	}
+}
+
+// render encodes the payload as JSON or with the template, compressed if enabled.
+func (c *webhookClient) render(payload interface{}) ([]byte, error) {
+	var body bytes.Buffer
+	var w io.Writer = &body
+	var zw *gzip.Writer
+	if c.config.Gzip {
+		zw = gzip.NewWriter(&body)
+		w = zw
+	}
+	if c.config.Template != nil {
+		if err := c.config.Template.Execute(w, payload); err != nil {
+			return nil, fmt.Errorf("failed to render the webhook template - %v", err)
+		}
+	} else if err := json.NewEncoder(w).Encode(payload); err != nil {
+		return nil, err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return body.Bytes(), nil
+}
+
+func (c *webhookClient) Send(payload interface{}) error {
+	body, err := c.render(payload)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, u := range c.config.URLs {
+		if err := c.post(u, body); err != nil {
+			glog.Warningf("Failed to post to webhook %s: %v", u, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// HTTP status codes of failed requests that are retried.
+var retryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// post sends the body until it succeeds, fails with an error that is not
+// retryable, or the retries are exhausted. The delay between attempts doubles every time.
+func (c *webhookClient) post(u string, body []byte) error {
+	backoff := c.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := c.postOnce(u, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= c.config.MaxRetries {
+			return err
+		}
+		glog.V(4).Infof("Retrying the post to %s in %v: %v", u, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (c *webhookClient) postOnce(u string, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", c.config.ContentType)
+	req.Header.Set("User-Agent", fmt.Sprintf("heapster/%v", version.HeapsterVersion))
+	if c.config.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if c.config.Username != "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	} else if c.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.BearerToken)
+	}
+	for name, value := range c.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// Network errors are retried.
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+	return retryableStatusCodes[resp.StatusCode], fmt.Errorf("request failed - %q, response: %q", resp.Status, httputil.ErrorBody(resp.Body))
+}
@@ -0,0 +1,178 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServer struct {
+	sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	// statuses returned to the next requests, then 200
+	statuses []int
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	reader := r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, _ = gzip.NewReader(r.Body)
+	}
+	body, _ := ioutil.ReadAll(reader)
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+	if len(s.statuses) > 0 {
+		w.WriteHeader(s.statuses[0])
+		s.statuses = s.statuses[1:]
+	}
+}
+
+func buildConfig(t *testing.T, uri string) *WebhookConfig {
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	config, err := BuildConfig(u)
+	require.NoError(t, err)
+	return config
+}
+
+func TestBuildConfig(t *testing.T) {
+	config := buildConfig(t, "https://collector.example.com/ingest?url=http://backup:8080/ingest"+
+		"&header=X-Env:prod&username=heapster&password=secret&gzip=true&max_retries=5&retry_backoff=2s"+
+		"&max_batch_size=100&timeout=5s&cluster_name=prod&content_type=text/plain")
+	assert.Equal(t, []string{"https://collector.example.com/ingest", "http://backup:8080/ingest"}, config.URLs)
+	assert.Equal(t, map[string]string{"X-Env": "prod"}, config.Headers)
+	assert.Equal(t, "heapster", config.Username)
+	assert.Equal(t, "secret", config.Password)
+	assert.True(t, config.Gzip)
+	assert.Equal(t, 5, config.MaxRetries)
+	assert.Equal(t, 2*time.Second, config.RetryBackoff)
+	assert.Equal(t, 100, config.MaxBatchSize)
+	assert.Equal(t, 5*time.Second, config.Timeout)
+	assert.Equal(t, "prod", config.ClusterName)
+	assert.Equal(t, "text/plain", config.ContentType)
+	assert.Nil(t, config.Template)
+
+	config = buildConfig(t, "?url=http://collector/ingest")
+	assert.Equal(t, []string{"http://collector/ingest"}, config.URLs)
+	assert.Equal(t, DefaultMaxBatchSize, config.MaxBatchSize)
+	assert.Equal(t, DefaultContentType, config.ContentType)
+}
+
+func TestBuildConfigInvalid(t *testing.T) {
+	for _, uri := range []string{
+		"",
+		"?url=collector:8080",
+		"ftp://collector/ingest",
+		"http://collector?header=X-Env",
+		"http://collector?username=a&bearer_token=b",
+		"http://collector?max_batch_size=0",
+		"http://collector?template=/nonexistent.tmpl",
+	} {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		_, err = BuildConfig(u)
+		assert.Error(t, err, uri)
+	}
+}
+
+func TestSendJson(t *testing.T) {
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	backup := &fakeServer{}
+	backupServer := httptest.NewServer(backup)
+	defer backupServer.Close()
+
+	config := buildConfig(t, server.URL+"/ingest?url="+backupServer.URL+"&bearer_token=token&header=X-Env:prod&gzip=true")
+	client := NewClient(*config)
+	require.NoError(t, client.Send(map[string]int{"metrics": 1}))
+
+	for _, s := range []*fakeServer{fake, backup} {
+		require.Equal(t, 1, len(s.requests))
+		r := s.requests[0]
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "prod", r.Header.Get("X-Env"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "{\"metrics\":1}\n", s.bodies[0])
+	}
+	assert.Equal(t, "/ingest", fake.requests[0].URL.Path)
+}
+
+func TestSendTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhook")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "payload.tmpl")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{{range .Items}}{{.Name}} {{json .Labels}}
+{{end}}`), 0600))
+
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := buildConfig(t, server.URL+"?template="+path+"&content_type=text/plain&username=heapster&password=secret")
+	client := NewClient(*config)
+	type item struct {
+		Name   string
+		Labels map[string]string
+	}
+	require.NoError(t, client.Send(struct{ Items []item }{[]item{{"a", map[string]string{"k": "v"}}, {"b", nil}}}))
+	require.Equal(t, 1, len(fake.requests))
+	assert.Equal(t, "a {\"k\":\"v\"}\nb null\n", fake.bodies[0])
+	assert.Equal(t, "text/plain", fake.requests[0].Header.Get("Content-Type"))
+	username, password, ok := fake.requests[0].BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "heapster", username)
+	assert.Equal(t, "secret", password)
+}
+
+func TestSendRetries(t *testing.T) {
+	fake := &fakeServer{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := buildConfig(t, server.URL+"?retry_backoff=1ms")
+	client := NewClient(*config)
+	require.NoError(t, client.Send("event"))
+	assert.Equal(t, 3, len(fake.requests))
+
+	// client errors are not retried
+	fake.statuses = []int{http.StatusBadRequest}
+	assert.Error(t, client.Send("event"))
+	assert.Equal(t, 4, len(fake.requests))
+
+	// the retries are exhausted
+	fake.statuses = []int{500, 500, 500}
+	config = buildConfig(t, server.URL+"?retry_backoff=1ms&max_retries=2")
+	assert.Error(t, NewClient(*config).Send("event"))
+	assert.Equal(t, 7, len(fake.requests))
+}
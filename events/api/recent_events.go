@@ -0,0 +1,181 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/events/sinks/recent"
+)
+
+const (
+	// Longest time a request waits for new events.
+	maxEventsWait = 5 * time.Minute
+	// How often a comment is sent on an idle event stream, to keep the connection open.
+	eventStreamHeartbeat = 15 * time.Second
+)
+
+type RecentEventsResponse struct {
+	Events []recent.StoredEvent `json:"events"`
+	// Id of the last event stored, from which the next request can continue.
+	LastId int64 `json:"lastId"`
+}
+
+// RegisterRecentEvents serves the events kept by the sink:
+//   - /api/v1/events returns the events matching the query, waiting for them up to
+//     the `wait` duration if there are none yet.
+//   - /api/v1/events/stream streams the matching events as server-sent events.
+func RegisterRecentEvents(mux *http.ServeMux, sink *recent.RecentEventsSink) {
+	mux.HandleFunc("/api/v1/events", func(w http.ResponseWriter, r *http.Request) {
+		serveEvents(sink, w, r)
+	})
+	mux.HandleFunc("/api/v1/events/stream", func(w http.ResponseWriter, r *http.Request) {
+		serveEventStream(sink, w, r)
+	})
+}
+
+func parseQuery(r *http.Request) (recent.Query, time.Duration, error) {
+	params := r.URL.Query()
+	query := recent.Query{
+		Namespace: params.Get("namespace"),
+		Kind:      params.Get("kind"),
+		Name:      params.Get("name"),
+		Reason:    params.Get("reason"),
+		Type:      params.Get("type"),
+	}
+	var wait time.Duration
+	var err error
+	if value := params.Get("start"); value != "" {
+		if query.Start, err = time.Parse(time.RFC3339, value); err != nil {
+			return query, wait, fmt.Errorf("failed to parse `start` - %v", err)
+		}
+	}
+	if value := params.Get("end"); value != "" {
+		if query.End, err = time.Parse(time.RFC3339, value); err != nil {
+			return query, wait, fmt.Errorf("failed to parse `end` - %v", err)
+		}
+	}
+	if value := params.Get("after"); value != "" {
+		if query.After, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return query, wait, fmt.Errorf("failed to parse `after` - %v", err)
+		}
+	}
+	if value := params.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 0 {
+			return query, wait, fmt.Errorf("invalid `limit` %q", value)
+		}
+	}
+	if value := params.Get("wait"); value != "" {
+		if wait, err = time.ParseDuration(value); err != nil {
+			return query, wait, fmt.Errorf("failed to parse `wait` - %v", err)
+		}
+		if wait > maxEventsWait {
+			wait = maxEventsWait
+		}
+	}
+	return query, wait, nil
+}
+
+func serveEvents(sink *recent.RecentEventsSink, w http.ResponseWriter, r *http.Request) {
+	query, wait, err := parseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, lastId := sink.Query(query)
+	if len(events) == 0 && wait > 0 {
+		timeout := time.NewTimer(wait)
+		defer timeout.Stop()
+	wait_loop:
+		for len(events) == 0 {
+			select {
+			case <-sink.Stored(lastId):
+				events, lastId = sink.Query(query)
+			case <-timeout.C:
+				break wait_loop
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(RecentEventsResponse{Events: events, LastId: lastId}); err != nil {
+		glog.Warningf("Failed to write the events: %v", err)
+	}
+}
+
+func serveEventStream(sink *recent.RecentEventsSink, w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	query, _, err := parseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Set by the clients reconnecting.
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		if query.After, err = strconv.ParseInt(value, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("failed to parse Last-Event-ID - %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		events, lastId := sink.Query(query)
+		for _, event := range events {
+			data, err := json.Marshal(event.Event)
+			if err != nil {
+				glog.Warningf("Failed to encode event %s: %v", event.Event.UID, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: event\ndata: %s\n\n", event.Id, data); err != nil {
+				return
+			}
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+		// The limit only applies to the events stored before the request.
+		query.After, query.Limit = lastId, 0
+
+		select {
+		case <-sink.Stored(lastId):
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/heapster/events/core"
+	"k8s.io/heapster/events/sinks/recent"
+)
+
+func newRecentEventsServer() (*httptest.Server, *recent.RecentEventsSink) {
+	sink := recent.NewRecentEventsSink(10)
+	mux := http.NewServeMux()
+	RegisterRecentEvents(mux, sink)
+	return httptest.NewServer(mux), sink
+}
+
+func exportEvent(sink *recent.RecentEventsSink, name, reason string) {
+	sink.ExportEvents(&core.EventBatch{Events: []*kube_api.Event{{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "ns", Name: name},
+		InvolvedObject: kube_api.ObjectReference{Kind: "Pod", Namespace: "ns", Name: name},
+		Reason:         reason,
+		LastTimestamp:  metav1.Now(),
+	}}})
+}
+
+func getEvents(t *testing.T, url string) RecentEventsResponse {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result RecentEventsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return result
+}
+
+func TestGetEvents(t *testing.T) {
+	server, sink := newRecentEventsServer()
+	defer server.Close()
+	exportEvent(sink, "a", "BackOff")
+	exportEvent(sink, "b", "Failed")
+	exportEvent(sink, "c", "BackOff")
+
+	result := getEvents(t, server.URL+"/api/v1/events?reason=BackOff")
+	assert.Equal(t, int64(3), result.LastId)
+	if assert.Len(t, result.Events, 2) {
+		assert.Equal(t, "a", result.Events[0].Event.Name)
+		assert.Equal(t, int64(3), result.Events[1].Id)
+	}
+
+	result = getEvents(t, server.URL+"/api/v1/events?after=2&start="+time.Now().Add(-time.Hour).Format(time.RFC3339))
+	if assert.Len(t, result.Events, 1) {
+		assert.Equal(t, "c", result.Events[0].Event.Name)
+	}
+
+	for _, query := range []string{"start=yesterday", "after=x", "limit=-1", "wait=1"} {
+		resp, err := http.Get(server.URL + "/api/v1/events?" + query)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func TestLongPoll(t *testing.T) {
+	server, sink := newRecentEventsServer()
+	defer server.Close()
+	exportEvent(sink, "a", "BackOff")
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		exportEvent(sink, "b", "Failed")
+		time.Sleep(200 * time.Millisecond)
+		exportEvent(sink, "c", "BackOff")
+	}()
+	start := time.Now()
+	result := getEvents(t, server.URL+"/api/v1/events?after=1&reason=BackOff&wait=10s")
+	assert.True(t, time.Since(start) < 5*time.Second)
+	if assert.Len(t, result.Events, 1) {
+		assert.Equal(t, "c", result.Events[0].Event.Name)
+	}
+	assert.Equal(t, int64(3), result.LastId)
+
+	// Times out without new events.
+	result = getEvents(t, server.URL+"/api/v1/events?after=3&wait=100ms")
+	assert.Empty(t, result.Events)
+	assert.Equal(t, int64(3), result.LastId)
+}
+
+func TestEventStream(t *testing.T) {
+	server, sink := newRecentEventsServer()
+	defer server.Close()
+	exportEvent(sink, "a", "BackOff")
+	exportEvent(sink, "b", "BackOff")
+
+	request, _ := http.NewRequest("GET", server.URL+"/api/v1/events/stream?reason=BackOff", nil)
+	request.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	exportEvent(sink, "c", "Failed")
+	exportEvent(sink, "d", "BackOff")
+	reader := bufio.NewReader(resp.Body)
+	for _, expected := range []struct {
+		id   string
+		name string
+	}{{"2", "b"}, {"4", "d"}} {
+		lines := []string{}
+		for {
+			line, err := reader.ReadString('\n')
+			require.NoError(t, err)
+			if line == "\n" {
+				break
+			}
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+		require.Len(t, lines, 3)
+		assert.Equal(t, "id: "+expected.id, lines[0])
+		assert.Equal(t, "event: event", lines[1])
+		var event kube_api.Event
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &event))
+		assert.Equal(t, expected.name, event.Name)
+	}
+}
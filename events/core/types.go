@@ -25,6 +25,9 @@ type EventBatch struct {
 	Timestamp time.Time
 	// List of events included in the batch.
 	Events []*kube_api.Event
+	// Position of the source after the last event of the batch, from which it can
+	// resume once the batch was exported. Empty when the source can not resume.
+	ResourceVersion string
 }
 
 // A place from where the events should be scraped.
@@ -34,6 +37,24 @@ type EventSource interface {
 	GetNewEvents() *EventBatch
 }
 
+// An EventSource notified of the batches exported by all the sinks, e.g. to resume
+// after the last exported event when restarted.
+type AcknowledgingEventSource interface {
+	EventSource
+	// Called once all the sinks exported the batch, with an error when some sink failed
+	// to take or to deliver it. The batches are acknowledged in order.
+	Ack(*EventBatch, error)
+}
+
+// An EventSource which can drop the events read since the last acknowledged batch and
+// read them again, e.g. when an eventer replica takes over from another one.
+type ResumableEventSource interface {
+	EventSource
+	// Resumes from the last batch acknowledged by any replica. Returns false, and keeps the
+	// events read so far, when there is nothing to resume from, e.g. without a checkpoint.
+	Resume() bool
+}
+
 type EventSink interface {
 	Name() string
 
@@ -44,3 +65,18 @@ type EventSink interface {
 	// Stops the sink at earliest convenience.
 	Stop()
 }
+
+// An EventSink which reports the events it failed to deliver, so that the batch is
+// not acknowledged to the source.
+type CheckedEventSink interface {
+	EventSink
+	// Exports the batch like ExportEvents, and returns an error when some of its events
+	// were not delivered.
+	ExportEventsChecked(*EventBatch) error
+}
+
+// Transforms the batches between the source and the sinks, e.g. to filter or enrich the events.
+type EventProcessor interface {
+	Name() string
+	Process(*EventBatch) (*EventBatch, error)
+}
@@ -21,30 +21,56 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
 	"k8s.io/apiserver/pkg/util/logs"
+	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/common/kubernetes"
+	"k8s.io/heapster/common/leaderelection"
 	"k8s.io/heapster/events/api"
+	"k8s.io/heapster/events/core"
 	"k8s.io/heapster/events/manager"
+	"k8s.io/heapster/events/processors"
 	"k8s.io/heapster/events/sinks"
+	"k8s.io/heapster/events/sinks/recent"
 	"k8s.io/heapster/events/sources"
 	"k8s.io/heapster/version"
 )
 
 var (
-	argFrequency   = flag.Duration("frequency", 30*time.Second, "The resolution at which Eventer pushes events to sinks")
-	argMaxProcs    = flag.Int("max_procs", 0, "max number of CPUs that can be used simultaneously. Less than 1 for default (number of cores)")
-	argSources     flags.Uris
-	argSinks       flags.Uris
-	argVersion     bool
-	argHealthzIP   = flag.String("healthz-ip", "0.0.0.0", "ip eventer health check service uses")
-	argHealthzPort = flag.Uint("healthz-port", 8084, "port eventer health check listens on")
+	argFrequency         = flag.Duration("frequency", 30*time.Second, "The resolution at which Eventer pushes events to sinks")
+	argMaxProcs          = flag.Int("max_procs", 0, "max number of CPUs that can be used simultaneously. Less than 1 for default (number of cores)")
+	argSources           flags.Uris
+	argSinks             flags.Uris
+	argVersion           bool
+	argHealthzIP         = flag.String("healthz-ip", "0.0.0.0", "ip eventer health check service uses")
+	argHealthzPort       = flag.Uint("healthz-port", 8084, "port eventer health check listens on")
+	argDedupMode         = flag.String("dedup_mode", "", "Collapse the updates of an event: 'updates' exports its first occurrence then its count at most once per dedup_interval, 'summary' exports its latest state once per dedup_interval. Disabled when empty")
+	argDedupInterval     = flag.Duration("dedup_interval", 5*time.Minute, "How often the updates of a deduplicated event are exported")
+	argIncludeNamespaces = flag.String("include_namespaces", "", "comma separated namespaces of the events to export, all when empty")
+	argExcludeNamespaces = flag.String("exclude_namespaces", "", "comma separated namespaces of the events not to export")
+	argIncludeTypes      = flag.String("include_types", "", "comma separated types of the events to export, e.g. Warning, all when empty")
+	argExcludeTypes      = flag.String("exclude_types", "", "comma separated types of the events not to export")
+	argIncludeReasons    = flag.String("include_reasons", "", "comma separated reasons of the events to export, all when empty")
+	argExcludeReasons    = flag.String("exclude_reasons", "", "comma separated reasons of the events not to export")
+	argIncludeKinds      = flag.String("include_kinds", "", "comma separated kinds of the objects involved in the events to export, all when empty")
+	argExcludeKinds      = flag.String("exclude_kinds", "", "comma separated kinds of the objects involved in the events not to export")
+	argEnrich            = flag.Bool("enrich", false, "whether to add the labels and the owner workload of the involved object to the labels of the events")
+	argLeaderElect       = flag.Bool("leader_elect", false, "whether to elect a leader among the eventer replicas, only the leader exports events")
+	argLeaderNamespace   = flag.String("leader_elect_namespace", "", "namespace of the ConfigMap holding the leader lease, the namespace of the pod or kube-system when empty")
+	argLeaderName        = flag.String("leader_elect_name", "eventer", "name of the ConfigMap holding the leader lease")
+	argLeaseDuration     = flag.Duration("leader_elect_lease_duration", leaderelection.DefaultLeaseDuration, "how long the standbys wait after the last renewal of the lease before taking it")
+	argRenewDeadline     = flag.Duration("leader_elect_renew_deadline", leaderelection.DefaultRenewDeadline, "how long the leader retries to renew the lease before it stops exporting")
+	argRetryPeriod       = flag.Duration("leader_elect_retry_period", leaderelection.DefaultRetryPeriod, "how often the lease is renewed or tried to be acquired")
 )
 
 func main() {
@@ -71,17 +97,14 @@ func main() {
 		glog.Fatal(err)
 	}
 
-	// sources
-	if len(argSources) != 1 {
-		glog.Fatal("Wrong number of sources specified")
-	}
+	// sources, one per cluster
 	sourceFactory := sources.NewSourceFactory()
 	sources, err := sourceFactory.BuildAll(argSources)
 	if err != nil {
 		glog.Fatalf("Failed to create sources: %v", err)
 	}
-	if len(sources) != 1 {
-		glog.Fatal("Requires exactly 1 source")
+	if len(sources) != len(argSources) {
+		glog.Fatal("Failed to create all the sources")
 	}
 
 	// sinks
@@ -93,24 +116,145 @@ func main() {
 
 	for _, sink := range sinkList {
 		glog.Infof("Starting with %s sink", sink.Name())
+		if recentEventsSink, ok := sink.(*recent.RecentEventsSink); ok {
+			api.RegisterRecentEvents(http.DefaultServeMux, recentEventsSink)
+		}
+	}
+	// The sources share the sinks, and acknowledge the batches they exported.
+	acks := []func(*core.EventBatch, error){}
+	for _, source := range sources {
+		var ack func(*core.EventBatch, error)
+		if source, ok := source.(core.AcknowledgingEventSource); ok {
+			ack = source.Ack
+		}
+		acks = append(acks, ack)
 	}
-	sinkManager, err := sinks.NewEventSinkManager(sinkList, sinks.DefaultSinkExportEventsTimeout, sinks.DefaultSinkStopTimeout)
+	sinkManagers, err := sinks.NewSharedEventSinkManagers(sinkList, sinks.DefaultSinkExportEventsTimeout,
+		sinks.DefaultSinkStopTimeout, acks)
 	if err != nil {
 		glog.Fatalf("Failed to create sink manager: %v", err)
 	}
 
-	// main manager
-	manager, err := manager.NewManager(sources[0], sinkManager, *argFrequency)
-	if err != nil {
-		glog.Fatalf("Failed to create main manager: %v", err)
+	// main managers, a leader exports the events of all the clusters
+	var isLeader func() bool
+	electorDone := make(chan struct{})
+	if *argLeaderElect {
+		elector := createLeaderElectorOrDie(&argSources[0].Val)
+		go func() {
+			elector.Run(quitChannel)
+			close(electorDone)
+		}()
+		isLeader = elector.IsLeader
+	} else {
+		close(electorDone)
+	}
+	managers := []manager.Manager{}
+	for i, source := range sources {
+		eventProcessors := createEventProcessorsOrDie(&argSources[i].Val)
+		m, err := manager.NewManagerWithLeaderElection(source, eventProcessors, sinkManagers[i], *argFrequency, isLeader)
+		if err != nil {
+			glog.Fatalf("Failed to create main manager: %v", err)
+		}
+		managers = append(managers, m)
 	}
 
-	manager.Start()
+	for _, m := range managers {
+		m.Start()
+	}
 	glog.Infof("Starting eventer")
 
 	go startHTTPServer()
 
-	<-quitChannel
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	glog.Infof("Stopping eventer")
+	for _, m := range managers {
+		m.Stop()
+	}
+	// Releases the lease, so that a standby takes over right away.
+	close(quitChannel)
+	<-electorDone
+}
+
+func createLeaderElectorOrDie(kubernetesUrl *url.URL) *leaderelection.LeaderElector {
+	kubeConfig, err := kubernetes.GetKubeClientConfig(kubernetesUrl)
+	if err != nil {
+		glog.Fatalf("Failed to get client config for leader election: %v", err)
+	}
+	kubeClient := kube_client.NewForConfigOrDie(kubeConfig)
+
+	namespace := *argLeaderNamespace
+	if namespace == "" {
+		namespace = os.Getenv("POD_NAMESPACE")
+	}
+	if namespace == "" {
+		namespace = "kube-system"
+	}
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		if identity, err = os.Hostname(); err != nil {
+			glog.Fatalf("Failed to get the identity for leader election: %v", err)
+		}
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Client:        kubeClient.CoreV1(),
+		Namespace:     namespace,
+		Name:          *argLeaderName,
+		Identity:      identity,
+		LeaseDuration: *argLeaseDuration,
+		RenewDeadline: *argRenewDeadline,
+		RetryPeriod:   *argRetryPeriod,
+	})
+	if err != nil {
+		glog.Fatalf("Failed to create leader elector: %v", err)
+	}
+	glog.Infof("Electing a leader with ConfigMap %s/%s as %s", namespace, *argLeaderName, identity)
+	return elector
+}
+
+func createEventProcessorsOrDie(kubernetesUrl *url.URL) []core.EventProcessor {
+	eventProcessors := []core.EventProcessor{}
+
+	namespaces := processors.FilterRule{Include: splitList(*argIncludeNamespaces), Exclude: splitList(*argExcludeNamespaces)}
+	types := processors.FilterRule{Include: splitList(*argIncludeTypes), Exclude: splitList(*argExcludeTypes)}
+	reasons := processors.FilterRule{Include: splitList(*argIncludeReasons), Exclude: splitList(*argExcludeReasons)}
+	kinds := processors.FilterRule{Include: splitList(*argIncludeKinds), Exclude: splitList(*argExcludeKinds)}
+	if !namespaces.IsEmpty() || !types.IsEmpty() || !reasons.IsEmpty() || !kinds.IsEmpty() {
+		eventProcessors = append(eventProcessors, processors.NewEventFilter(namespaces, types, reasons, kinds))
+	}
+
+	if *argEnrich {
+		enricher, err := processors.NewEventEnricher(kubernetesUrl)
+		if err != nil {
+			glog.Fatalf("Failed to create enricher: %v", err)
+		}
+		eventProcessors = append(eventProcessors, enricher)
+	}
+
+	if *argDedupMode != "" {
+		deduplicator, err := processors.NewEventDeduplicator(*argDedupMode, *argDedupInterval)
+		if err != nil {
+			glog.Fatalf("Failed to create deduplicator: %v", err)
+		}
+		eventProcessors = append(eventProcessors, deduplicator)
+	}
+
+	if clusterName := kubernetes.GetClusterName(kubernetesUrl); clusterName != "" {
+		eventProcessors = append(eventProcessors, processors.NewEventClusterLabeler(clusterName))
+	}
+	return eventProcessors
+}
+
+func splitList(list string) []string {
+	result := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
 }
 
 func startHTTPServer() {
@@ -132,6 +276,15 @@ func validateFlags() error {
 			api.MaxEventsScrapeDelay, *argFrequency)
 	}
 
+	if *argLeaderElect {
+		// A new leader resumes from the checkpoint saved by the previous one.
+		for _, source := range argSources {
+			if len(source.Val.Query()["checkpoint_file"]) > 0 {
+				return fmt.Errorf("leader election needs a checkpoint shared by the replicas, use checkpoint_configmap instead of checkpoint_file")
+			}
+		}
+	}
+
 	return nil
 }
 
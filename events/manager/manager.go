@@ -32,12 +32,24 @@ var (
 			Help:      "Last time of eventer housekeep since unix epoch in seconds.",
 		})
 
+	// The time spent in a processor in milliseconds.
+	processorDuration = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Namespace: "eventer",
+			Subsystem: "processor",
+			Name:      "duration_milliseconds",
+			Help:      "The time spent in a processor in milliseconds.",
+		},
+		[]string{"processor"},
+	)
+
 	// Time of latest scrape operation
 	LatestScrapeTime = time.Now()
 )
 
 func init() {
 	prometheus.MustRegister(lastHousekeepTimestamp)
+	prometheus.MustRegister(processorDuration)
 }
 
 type Manager interface {
@@ -46,18 +58,35 @@ type Manager interface {
 }
 
 type realManager struct {
-	source    core.EventSource
-	sink      core.EventSink
-	frequency time.Duration
-	stopChan  chan struct{}
+	source     core.EventSource
+	processors []core.EventProcessor
+	sink       core.EventSink
+	frequency  time.Duration
+	stopChan   chan struct{}
+	// Whether this replica exports events, nil if it always does.
+	isLeader func() bool
+	leading  bool
+}
+
+func NewManager(source core.EventSource, processors []core.EventProcessor, sink core.EventSink,
+	frequency time.Duration) (Manager, error) {
+	return NewManagerWithLeaderElection(source, processors, sink, frequency, nil)
 }
 
-func NewManager(source core.EventSource, sink core.EventSink, frequency time.Duration) (Manager, error) {
+// NewManagerWithLeaderElection creates a manager which only exports events while isLeader
+// returns true. Otherwise the events are read and dropped, so that the replica is ready to
+// take over. On becoming the leader a core.ResumableEventSource is resumed from the last
+// batch exported by the previous leader, and the events read before are dropped. The events
+// of a source which can not resume are exported.
+func NewManagerWithLeaderElection(source core.EventSource, processors []core.EventProcessor, sink core.EventSink,
+	frequency time.Duration, isLeader func() bool) (Manager, error) {
 	manager := realManager{
-		source:    source,
-		sink:      sink,
-		frequency: frequency,
-		stopChan:  make(chan struct{}),
+		source:     source,
+		processors: processors,
+		sink:       sink,
+		frequency:  frequency,
+		stopChan:   make(chan struct{}),
+		isLeader:   isLeader,
 	}
 
 	return &manager, nil
@@ -99,6 +128,42 @@ func (rm *realManager) housekeep() {
 	// No parallelism. Assumes that the events are pushed to Heapster. Add parallelism
 	// when this stops to be true.
 	events := rm.source.GetNewEvents()
+	if rm.isLeader != nil {
+		leading := rm.isLeader()
+		becameLeader := leading && !rm.leading
+		rm.leading = leading
+		if !leading {
+			glog.V(2).Infof("Not the leader, dropping %d events", len(events.Events))
+			return
+		}
+		if resumable, ok := rm.source.(core.ResumableEventSource); ok && becameLeader {
+			if resumable.Resume() {
+				glog.Infof("Became the leader, resuming the event source, dropping %d events", len(events.Events))
+				return
+			}
+			glog.Infof("Became the leader, the event source has no checkpoint to resume from")
+		}
+	}
+	for _, p := range rm.processors {
+		processed, err := process(p, events)
+		if err != nil {
+			glog.Errorf("Error in processor %s: %v", p.Name(), err)
+			return
+		}
+		glog.V(2).Infof("Processor %s turned %d events into %d", p.Name(), len(events.Events), len(processed.Events))
+		events = processed
+	}
 	glog.V(0).Infof("Exporting %d events", len(events.Events))
 	rm.sink.ExportEvents(events)
 }
+
+func process(p core.EventProcessor, events *core.EventBatch) (*core.EventBatch, error) {
+	startTime := time.Now()
+	defer func() {
+		processorDuration.
+			WithLabelValues(p.Name()).
+			Observe(float64(time.Since(startTime)) / float64(time.Millisecond))
+	}()
+
+	return p.Process(events)
+}
@@ -15,6 +15,7 @@
 package manager
 
 import (
+	"sync"
 	"testing"
 	"time"
 
@@ -32,7 +33,7 @@ func TestFlow(t *testing.T) {
 	source := util.NewDummySource(batch)
 	sink := util.NewDummySink("sink", time.Millisecond)
 
-	manager, _ := NewManager(source, sink, time.Second)
+	manager, _ := NewManager(source, nil, sink, time.Second)
 	manager.Start()
 
 	// 4-5 cycles
@@ -43,3 +44,126 @@ func TestFlow(t *testing.T) {
 		t.Fatalf("Wrong number of exports executed: %d", sink.GetExportCount())
 	}
 }
+
+type countingProcessor struct {
+	lock  sync.Mutex
+	count int
+}
+
+func (p *countingProcessor) Name() string {
+	return "counting"
+}
+
+func (p *countingProcessor) Process(batch *core.EventBatch) (*core.EventBatch, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.count++
+	return batch, nil
+}
+
+func TestFlowWithProcessors(t *testing.T) {
+	batch := &core.EventBatch{
+		Timestamp: time.Now(),
+		Events:    []*kube_api.Event{},
+	}
+
+	source := util.NewDummySource(batch)
+	sink := util.NewDummySink("sink", time.Millisecond)
+	processor := &countingProcessor{}
+
+	manager, _ := NewManager(source, []core.EventProcessor{processor, processor}, sink, time.Second)
+	manager.Start()
+
+	// 2-3 cycles
+	time.Sleep(time.Millisecond * 2500)
+	manager.Stop()
+
+	processor.lock.Lock()
+	defer processor.lock.Unlock()
+	if processor.count != 2*sink.GetExportCount() || processor.count < 4 {
+		t.Fatalf("Wrong number of processing for %d exports: %d", sink.GetExportCount(), processor.count)
+	}
+}
+
+type resumableSource struct {
+	core.EventSource
+	lock    sync.Mutex
+	resumed int
+	// Whether the source has a checkpoint to resume from.
+	checkpointed bool
+}
+
+func (s *resumableSource) Resume() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.resumed++
+	return s.checkpointed
+}
+
+func TestFlowWithLeaderElection(t *testing.T) {
+	batch := &core.EventBatch{
+		Timestamp: time.Now(),
+		Events:    []*kube_api.Event{},
+	}
+
+	source := &resumableSource{EventSource: util.NewDummySource(batch), checkpointed: true}
+	sink := util.NewDummySink("sink", time.Millisecond)
+	var lock sync.Mutex
+	leader := false
+
+	manager, _ := NewManagerWithLeaderElection(source, nil, sink, time.Second, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return leader
+	})
+	manager.Start()
+
+	// 2-3 cycles as standby
+	time.Sleep(time.Millisecond * 2500)
+	if sink.GetExportCount() != 0 {
+		t.Fatalf("Standby exported %d times", sink.GetExportCount())
+	}
+
+	lock.Lock()
+	leader = true
+	lock.Unlock()
+	// 3 cycles as leader, the first one resumes the source
+	time.Sleep(time.Millisecond * 3000)
+	manager.Stop()
+
+	source.lock.Lock()
+	defer source.lock.Unlock()
+	if source.resumed != 1 {
+		t.Fatalf("Source resumed %d times", source.resumed)
+	}
+	if sink.GetExportCount() < 1 || sink.GetExportCount() > 3 {
+		t.Fatalf("Wrong number of exports executed: %d", sink.GetExportCount())
+	}
+}
+
+func TestBecomeLeaderWithoutCheckpoint(t *testing.T) {
+	batch := &core.EventBatch{
+		Timestamp: time.Now(),
+		Events:    []*kube_api.Event{{}},
+	}
+
+	source := &resumableSource{EventSource: util.NewDummySource(batch)}
+	sink := util.NewDummySink("sink", time.Millisecond)
+	leader := false
+	manager, _ := NewManagerWithLeaderElection(source, nil, sink, time.Second, func() bool { return leader })
+	rm := manager.(*realManager)
+
+	rm.housekeep()
+	leader = true
+	// The events read when becoming the leader are exported, there is no checkpoint to
+	// read them again from.
+	rm.housekeep()
+	rm.housekeep()
+
+	if source.resumed != 1 {
+		t.Fatalf("Source resumed %d times", source.resumed)
+	}
+	if sink.GetExportCount() != 2 {
+		t.Fatalf("Wrong number of exports executed: %d", sink.GetExportCount())
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	kube_api "k8s.io/api/core/v1"
+	"k8s.io/heapster/events/core"
+)
+
+// Label added to the events with the name of their cluster.
+const LabelCluster = "cluster"
+
+// EventClusterLabeler adds the name of the cluster to the labels of the events, when
+// the eventer reads several clusters.
+type EventClusterLabeler struct {
+	clusterName string
+}
+
+func (this *EventClusterLabeler) Name() string {
+	return "cluster_labeler"
+}
+
+func (this *EventClusterLabeler) Process(batch *core.EventBatch) (*core.EventBatch, error) {
+	for _, event := range batch.Events {
+		this.label(event)
+	}
+	return batch, nil
+}
+
+func (this *EventClusterLabeler) label(event *kube_api.Event) {
+	labels := make(map[string]string, len(event.Labels)+1)
+	for key, value := range event.Labels {
+		labels[key] = value
+	}
+	labels[LabelCluster] = this.clusterName
+	event.Labels = labels
+}
+
+func NewEventClusterLabeler(clusterName string) *EventClusterLabeler {
+	return &EventClusterLabeler{clusterName: clusterName}
+}
@@ -0,0 +1,43 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/heapster/events/core"
+)
+
+func TestClusterLabeler(t *testing.T) {
+	labels := map[string]string{"app": "web"}
+	batch := &core.EventBatch{
+		Timestamp: time.Now(),
+		Events: []*kube_api.Event{
+			{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: labels}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+		},
+	}
+
+	result, err := NewEventClusterLabeler("east").Process(batch)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "web", LabelCluster: "east"}, result.Events[0].Labels)
+	assert.Equal(t, map[string]string{LabelCluster: "east"}, result.Events[1].Labels)
+	// The labels of the event are copied.
+	assert.Equal(t, map[string]string{"app": "web"}, labels)
+}
@@ -0,0 +1,157 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kube_api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/heapster/events/core"
+)
+
+const (
+	// Export the first occurrence of an event, then its latest count at most once per interval.
+	DedupModeUpdates = "updates"
+	// Export the latest state of an event once per window in which it occurred.
+	DedupModeSummary = "summary"
+
+	// How long an event with nothing left to export is remembered. Longer than
+	// the time to live of the events in the apiserver, one hour by default.
+	dedupRetention = 2 * time.Hour
+)
+
+var (
+	suppressedEvents = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "eventer",
+			Subsystem: "deduplicator",
+			Name:      "suppressed_events_total_number",
+			Help:      "The number of event updates collapsed into a later export.",
+		})
+)
+
+func init() {
+	prometheus.MustRegister(suppressedEvents)
+}
+
+type dedupEntry struct {
+	// When the event was last exported, or when its window started in summary mode.
+	exported time.Time
+	lastSeen time.Time
+	// Latest update of the event not exported yet.
+	pending *kube_api.Event
+	// Order in which the pending updates arrived, and the resource version from
+	// which the source has to resume for them to be exported.
+	pendingSeq    uint64
+	resumeVersion string
+}
+
+// EventDeduplicator collapses the updates of the events by UID, so that an event
+// repeating with an incrementing count is not exported on every update.
+type EventDeduplicator struct {
+	mode     string
+	interval time.Duration
+	entries  map[types.UID]*dedupEntry
+	seq      uint64
+	// Resource version of the previous batch.
+	lastVersion string
+}
+
+func (this *EventDeduplicator) Name() string {
+	return "deduplicator"
+}
+
+// Process is called with the batches in order, and uses their timestamps as the current time.
+func (this *EventDeduplicator) Process(batch *core.EventBatch) (*core.EventBatch, error) {
+	now := batch.Timestamp
+	result := &core.EventBatch{
+		Timestamp: batch.Timestamp,
+		Events:    []*kube_api.Event{},
+	}
+	for _, event := range batch.Events {
+		entry, found := this.entries[event.UID]
+		if !found && this.mode == DedupModeUpdates {
+			this.entries[event.UID] = &dedupEntry{exported: now, lastSeen: now}
+			result.Events = append(result.Events, event)
+			continue
+		}
+		if !found {
+			entry = &dedupEntry{exported: now}
+			this.entries[event.UID] = entry
+		}
+		entry.lastSeen = now
+		if entry.pending == nil && this.mode == DedupModeSummary && now.Sub(entry.exported) >= this.interval {
+			// A new window starts with the first update after a quiet one.
+			entry.exported = now
+		}
+		if entry.pending == nil {
+			this.seq++
+			entry.pendingSeq = this.seq
+			entry.resumeVersion = this.lastVersion
+		} else {
+			suppressedEvents.Inc()
+		}
+		entry.pending = event
+	}
+
+	var flushed []*dedupEntry
+	for uid, entry := range this.entries {
+		if entry.pending != nil && now.Sub(entry.exported) >= this.interval {
+			flushed = append(flushed, entry)
+		} else if entry.pending == nil && now.Sub(entry.lastSeen) > dedupRetention {
+			delete(this.entries, uid)
+		}
+	}
+	sort.Slice(flushed, func(i, j int) bool { return flushed[i].pendingSeq < flushed[j].pendingSeq })
+	for _, entry := range flushed {
+		result.Events = append(result.Events, entry.pending)
+		entry.pending = nil
+		entry.exported = now
+	}
+
+	// Resume from before the oldest update not exported yet.
+	result.ResourceVersion = batch.ResourceVersion
+	var oldest *dedupEntry
+	for _, entry := range this.entries {
+		if entry.pending != nil && (oldest == nil || entry.pendingSeq < oldest.pendingSeq) {
+			oldest = entry
+		}
+	}
+	if oldest != nil {
+		result.ResourceVersion = oldest.resumeVersion
+	}
+	this.lastVersion = batch.ResourceVersion
+	return result, nil
+}
+
+// NewEventDeduplicator returns a deduplicator in the given mode, exporting the updates
+// of an event at most once per interval.
+func NewEventDeduplicator(mode string, interval time.Duration) (*EventDeduplicator, error) {
+	if mode != DedupModeUpdates && mode != DedupModeSummary {
+		return nil, fmt.Errorf("unknown deduplication mode %q, must be %q or %q", mode, DedupModeUpdates, DedupModeSummary)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("deduplication interval must be positive, supplied %s", interval)
+	}
+	return &EventDeduplicator{
+		mode:     mode,
+		interval: interval,
+		entries:  make(map[types.UID]*dedupEntry),
+	}, nil
+}
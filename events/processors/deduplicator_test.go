@@ -0,0 +1,119 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/heapster/events/core"
+)
+
+func newEvent(uid string, count int32) *kube_api.Event {
+	return &kube_api.Event{
+		ObjectMeta: metav1.ObjectMeta{UID: types.UID(uid)},
+		Count:      count,
+	}
+}
+
+func batchAt(timestamp time.Time, resourceVersion string, events ...*kube_api.Event) *core.EventBatch {
+	return &core.EventBatch{
+		Timestamp:       timestamp,
+		Events:          events,
+		ResourceVersion: resourceVersion,
+	}
+}
+
+func counts(batch *core.EventBatch) map[types.UID]int32 {
+	result := map[types.UID]int32{}
+	for _, event := range batch.Events {
+		result[event.UID] = event.Count
+	}
+	return result
+}
+
+func TestDeduplicateUpdates(t *testing.T) {
+	deduplicator, err := NewEventDeduplicator(DedupModeUpdates, time.Minute)
+	assert.NoError(t, err)
+	now := time.Now()
+
+	result, _ := deduplicator.Process(batchAt(now, "1", newEvent("a", 1), newEvent("a", 2), newEvent("b", 1)))
+	assert.Equal(t, map[types.UID]int32{"a": 1, "b": 1}, counts(result))
+	// The update of a is not exported yet.
+	assert.Equal(t, "", result.ResourceVersion)
+
+	result, _ = deduplicator.Process(batchAt(now.Add(30*time.Second), "2", newEvent("a", 3)))
+	assert.Empty(t, result.Events)
+	assert.Equal(t, "", result.ResourceVersion)
+
+	result, _ = deduplicator.Process(batchAt(now.Add(time.Minute), "3", newEvent("b", 2)))
+	assert.Equal(t, map[types.UID]int32{"a": 3, "b": 2}, counts(result))
+	assert.Equal(t, "3", result.ResourceVersion)
+
+	result, _ = deduplicator.Process(batchAt(now.Add(90*time.Second), "4", newEvent("c", 1)))
+	assert.Equal(t, map[types.UID]int32{"c": 1}, counts(result))
+	assert.Equal(t, "4", result.ResourceVersion)
+}
+
+func TestDeduplicateSummary(t *testing.T) {
+	deduplicator, err := NewEventDeduplicator(DedupModeSummary, time.Minute)
+	assert.NoError(t, err)
+	now := time.Now()
+
+	result, _ := deduplicator.Process(batchAt(now, "1", newEvent("a", 1), newEvent("a", 2)))
+	assert.Empty(t, result.Events)
+	assert.Equal(t, "", result.ResourceVersion)
+
+	result, _ = deduplicator.Process(batchAt(now.Add(30*time.Second), "2", newEvent("b", 1)))
+	assert.Empty(t, result.Events)
+
+	result, _ = deduplicator.Process(batchAt(now.Add(time.Minute), "3", newEvent("a", 5)))
+	assert.Equal(t, map[types.UID]int32{"a": 5}, counts(result))
+	// b arrived after the first batch.
+	assert.Equal(t, "1", result.ResourceVersion)
+
+	result, _ = deduplicator.Process(batchAt(now.Add(90*time.Second), "4"))
+	assert.Equal(t, map[types.UID]int32{"b": 1}, counts(result))
+	assert.Equal(t, "4", result.ResourceVersion)
+
+	// A window starts with the first update after a quiet one.
+	result, _ = deduplicator.Process(batchAt(now.Add(10*time.Minute), "5", newEvent("a", 6)))
+	assert.Empty(t, result.Events)
+	assert.Equal(t, "4", result.ResourceVersion)
+	result, _ = deduplicator.Process(batchAt(now.Add(11*time.Minute), "6"))
+	assert.Equal(t, map[types.UID]int32{"a": 6}, counts(result))
+}
+
+func TestDeduplicatorForgetsOldEvents(t *testing.T) {
+	deduplicator, _ := NewEventDeduplicator(DedupModeUpdates, time.Minute)
+	now := time.Now()
+
+	deduplicator.Process(batchAt(now, "1", newEvent("a", 1)))
+	deduplicator.Process(batchAt(now.Add(3*time.Hour), "2"))
+	assert.Empty(t, deduplicator.entries)
+	result, _ := deduplicator.Process(batchAt(now.Add(3*time.Hour), "3", newEvent("a", 2)))
+	assert.Equal(t, map[types.UID]int32{"a": 2}, counts(result))
+}
+
+func TestInvalidDeduplicator(t *testing.T) {
+	_, err := NewEventDeduplicator("all", time.Minute)
+	assert.Error(t, err)
+	_, err = NewEventDeduplicator(DedupModeUpdates, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/golang/glog"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	kube_api "k8s.io/api/core/v1"
+	kubeapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kube_client "k8s.io/client-go/kubernetes"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	kube_config "k8s.io/heapster/common/kubernetes"
+	"k8s.io/heapster/events/core"
+)
+
+const (
+	// Labels added to the events with the workload owning the involved object.
+	LabelWorkloadKind = "workload_kind"
+	LabelWorkloadName = "workload_name"
+)
+
+// EventEnricher adds the labels of the involved object to the labels of the events, along
+// with the kind and name of the workload owning it. Pods, nodes, replica sets and jobs are
+// looked up.
+type EventEnricher struct {
+	podLister        v1listers.PodLister
+	nodeLister       v1listers.NodeLister
+	replicaSetLister appsv1listers.ReplicaSetLister
+	jobLister        batchv1listers.JobLister
+}
+
+func (this *EventEnricher) Name() string {
+	return "enricher"
+}
+
+func (this *EventEnricher) Process(batch *core.EventBatch) (*core.EventBatch, error) {
+	for _, event := range batch.Events {
+		this.enrich(event)
+	}
+	return batch, nil
+}
+
+func (this *EventEnricher) enrich(event *kube_api.Event) {
+	involved := event.InvolvedObject
+	object, err := this.getObject(involved.Kind, involved.Namespace, involved.Name)
+	if err != nil {
+		// The object is often deleted already, e.g. after a failed pod.
+		glog.V(4).Infof("Failed to get %s %s/%s: %v", involved.Kind, involved.Namespace, involved.Name, err)
+		return
+	}
+	if object == nil {
+		return
+	}
+	labels := make(map[string]string, len(event.Labels)+len(object.GetLabels())+2)
+	for key, value := range object.GetLabels() {
+		labels[key] = value
+	}
+	if kind, name := this.workload(involved.Kind, object); kind != "" {
+		labels[LabelWorkloadKind] = kind
+		labels[LabelWorkloadName] = name
+	}
+	// The labels of the event take precedence.
+	for key, value := range event.Labels {
+		labels[key] = value
+	}
+	event.Labels = labels
+}
+
+// getObject returns nil for the kinds which are not looked up.
+func (this *EventEnricher) getObject(kind, namespace, name string) (metav1.Object, error) {
+	switch kind {
+	case "Pod":
+		return this.podLister.Pods(namespace).Get(name)
+	case "Node":
+		return this.nodeLister.Get(name)
+	case "ReplicaSet":
+		return this.replicaSetLister.ReplicaSets(namespace).Get(name)
+	case "Job":
+		return this.jobLister.Jobs(namespace).Get(name)
+	}
+	return nil, nil
+}
+
+// workload returns the top-level controller of the object, following replica sets to
+// their deployment and jobs to their cron job.
+func (this *EventEnricher) workload(kind string, object metav1.Object) (string, string) {
+	owner := metav1.GetControllerOf(object)
+	if owner == nil {
+		if kind == "Pod" || kind == "Node" {
+			return "", ""
+		}
+		return kind, object.GetName()
+	}
+	if owner.Kind == "ReplicaSet" || owner.Kind == "Job" {
+		parent, err := this.getObject(owner.Kind, object.GetNamespace(), owner.Name)
+		if err == nil && parent != nil {
+			return this.workload(owner.Kind, parent)
+		}
+		if err != nil && !kubeapierrors.IsNotFound(err) {
+			glog.Warningf("Failed to get %s %s/%s: %v", owner.Kind, object.GetNamespace(), owner.Name, err)
+		}
+	}
+	return owner.Kind, owner.Name
+}
+
+func newStore(client rest.Interface, resource string, objType runtime.Object) cache.Indexer {
+	lw := cache.NewListWatchFromClient(client, resource, kube_api.NamespaceAll, fields.Everything())
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	reflector := cache.NewReflector(lw, objType, store, time.Hour)
+	go reflector.Run(wait.NeverStop)
+	return store
+}
+
+func NewEventEnricher(url *url.URL) (*EventEnricher, error) {
+	kubeConfig, err := kube_config.GetKubeClientConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	kubeClient, err := kube_client.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EventEnricher{
+		podLister:        v1listers.NewPodLister(newStore(kubeClient.CoreV1().RESTClient(), "pods", &kube_api.Pod{})),
+		nodeLister:       v1listers.NewNodeLister(newStore(kubeClient.CoreV1().RESTClient(), "nodes", &kube_api.Node{})),
+		replicaSetLister: appsv1listers.NewReplicaSetLister(newStore(kubeClient.AppsV1().RESTClient(), "replicasets", &appsv1.ReplicaSet{})),
+		jobLister:        batchv1listers.NewJobLister(newStore(kubeClient.BatchV1().RESTClient(), "jobs", &batchv1.Job{})),
+	}, nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	batchv1listers "k8s.io/client-go/listers/batch/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/heapster/events/core"
+)
+
+func controlledBy(kind, name string) []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+}
+
+func newIndexer(objects ...interface{}) cache.Indexer {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, object := range objects {
+		indexer.Add(object)
+	}
+	return indexer
+}
+
+func newTestEnricher() *EventEnricher {
+	return &EventEnricher{
+		podLister: v1listers.NewPodLister(newIndexer(
+			&kube_api.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns", Name: "web-1", Labels: map[string]string{"app": "web"},
+				OwnerReferences: controlledBy("ReplicaSet", "web-abc")}},
+			&kube_api.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns", Name: "backup-1",
+				OwnerReferences: controlledBy("Job", "backup-123")}},
+			&kube_api.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns", Name: "db-0", Labels: map[string]string{"app": "db"},
+				OwnerReferences: controlledBy("StatefulSet", "db")}},
+			&kube_api.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "standalone"}})),
+		nodeLister: v1listers.NewNodeLister(newIndexer(
+			&kube_api.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"zone": "a"}}})),
+		replicaSetLister: appsv1listers.NewReplicaSetLister(newIndexer(
+			&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns", Name: "web-abc",
+				OwnerReferences: controlledBy("Deployment", "web")}})),
+		jobLister: batchv1listers.NewJobLister(newIndexer(
+			&batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns", Name: "backup-123",
+				OwnerReferences: controlledBy("CronJob", "backup")}})),
+	}
+}
+
+func involving(kind, namespace, name string) *kube_api.Event {
+	return &kube_api.Event{
+		InvolvedObject: kube_api.ObjectReference{Kind: kind, Namespace: namespace, Name: name},
+	}
+}
+
+func TestEnricher(t *testing.T) {
+	enricher := newTestEnricher()
+	labelled := involving("Pod", "ns", "web-1")
+	labelled.Labels = map[string]string{"app": "event"}
+	batch := &core.EventBatch{
+		Timestamp: time.Now(),
+		Events: []*kube_api.Event{
+			involving("Pod", "ns", "web-1"),
+			involving("Pod", "ns", "backup-1"),
+			involving("Pod", "ns", "db-0"),
+			involving("Pod", "ns", "standalone"),
+			involving("Pod", "ns", "deleted"),
+			involving("Node", "", "node-1"),
+			involving("ReplicaSet", "ns", "web-abc"),
+			involving("Service", "ns", "web"),
+			labelled,
+		},
+	}
+
+	result, err := enricher.Process(batch)
+	assert.NoError(t, err)
+	labels := []map[string]string{}
+	for _, event := range result.Events {
+		labels = append(labels, event.Labels)
+	}
+	assert.Equal(t, []map[string]string{
+		{"app": "web", LabelWorkloadKind: "Deployment", LabelWorkloadName: "web"},
+		{LabelWorkloadKind: "CronJob", LabelWorkloadName: "backup"},
+		{"app": "db", LabelWorkloadKind: "StatefulSet", LabelWorkloadName: "db"},
+		{},
+		nil,
+		{"zone": "a"},
+		{LabelWorkloadKind: "Deployment", LabelWorkloadName: "web"},
+		nil,
+		{"app": "event", LabelWorkloadKind: "Deployment", LabelWorkloadName: "web"},
+	}, labels)
+}
+
+func TestEnricherWithDeletedOwner(t *testing.T) {
+	enricher := newTestEnricher()
+	enricher.replicaSetLister = appsv1listers.NewReplicaSetLister(newIndexer())
+	event := involving("Pod", "ns", "web-1")
+
+	enricher.Process(&core.EventBatch{Events: []*kube_api.Event{event}})
+	assert.Equal(t, "ReplicaSet", event.Labels[LabelWorkloadKind])
+	assert.Equal(t, "web-abc", event.Labels[LabelWorkloadName])
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	kube_api "k8s.io/api/core/v1"
+	"k8s.io/heapster/events/core"
+)
+
+var (
+	filteredEvents = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "eventer",
+			Subsystem: "filter",
+			Name:      "dropped_events_total_number",
+			Help:      "The number of events dropped by the filter.",
+		})
+)
+
+func init() {
+	prometheus.MustRegister(filteredEvents)
+}
+
+// FilterRule selects the values of an event field to export. All the values which are
+// not excluded are exported when Include is empty.
+type FilterRule struct {
+	Include []string
+	Exclude []string
+}
+
+func (r FilterRule) IsEmpty() bool {
+	return len(r.Include) == 0 && len(r.Exclude) == 0
+}
+
+type filterRule struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+func newFilterRule(rule FilterRule) filterRule {
+	result := filterRule{include: map[string]bool{}, exclude: map[string]bool{}}
+	for _, value := range rule.Include {
+		result.include[value] = true
+	}
+	for _, value := range rule.Exclude {
+		result.exclude[value] = true
+	}
+	return result
+}
+
+func (r filterRule) matches(value string) bool {
+	return (len(r.include) == 0 || r.include[value]) && !r.exclude[value]
+}
+
+// EventFilter drops the events by namespace, type, reason and kind of the involved object.
+type EventFilter struct {
+	namespaces filterRule
+	types      filterRule
+	reasons    filterRule
+	kinds      filterRule
+}
+
+func (this *EventFilter) Name() string {
+	return "filter"
+}
+
+func (this *EventFilter) Process(batch *core.EventBatch) (*core.EventBatch, error) {
+	result := &core.EventBatch{
+		Timestamp:       batch.Timestamp,
+		Events:          []*kube_api.Event{},
+		ResourceVersion: batch.ResourceVersion,
+	}
+	for _, event := range batch.Events {
+		if this.namespaces.matches(event.Namespace) &&
+			this.types.matches(event.Type) &&
+			this.reasons.matches(event.Reason) &&
+			this.kinds.matches(event.InvolvedObject.Kind) {
+			result.Events = append(result.Events, event)
+		}
+	}
+	filteredEvents.Add(float64(len(batch.Events) - len(result.Events)))
+	return result, nil
+}
+
+func NewEventFilter(namespaces, types, reasons, kinds FilterRule) *EventFilter {
+	return &EventFilter{
+		namespaces: newFilterRule(namespaces),
+		types:      newFilterRule(types),
+		reasons:    newFilterRule(reasons),
+		kinds:      newFilterRule(kinds),
+	}
+}
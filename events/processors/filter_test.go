@@ -0,0 +1,68 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/heapster/events/core"
+)
+
+func TestFilter(t *testing.T) {
+	filter := NewEventFilter(
+		FilterRule{Exclude: []string{"kube-system"}},
+		FilterRule{Include: []string{kube_api.EventTypeWarning}},
+		FilterRule{Exclude: []string{"BackOff"}},
+		FilterRule{Include: []string{"Pod", "Node"}})
+	event := func(namespace, eventType, reason, kind string) *kube_api.Event {
+		return &kube_api.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: namespace, Name: reason},
+			Type:           eventType,
+			Reason:         reason,
+			InvolvedObject: kube_api.ObjectReference{Kind: kind},
+		}
+	}
+	batch := &core.EventBatch{
+		Timestamp:       time.Now(),
+		ResourceVersion: "10",
+		Events: []*kube_api.Event{
+			event("default", kube_api.EventTypeWarning, "Failed", "Pod"),
+			event("kube-system", kube_api.EventTypeWarning, "Failed", "Pod"),
+			event("default", kube_api.EventTypeNormal, "Pulled", "Pod"),
+			event("default", kube_api.EventTypeWarning, "BackOff", "Pod"),
+			event("default", kube_api.EventTypeWarning, "NodeNotReady", "Node"),
+			event("default", kube_api.EventTypeWarning, "FailedCreate", "ReplicaSet"),
+		},
+	}
+
+	result, err := filter.Process(batch)
+	assert.NoError(t, err)
+	assert.Equal(t, batch.Timestamp, result.Timestamp)
+	assert.Equal(t, "10", result.ResourceVersion)
+	if assert.Len(t, result.Events, 2) {
+		assert.Equal(t, "Failed", result.Events[0].Reason)
+		assert.Equal(t, "NodeNotReady", result.Events[1].Reason)
+	}
+	assert.Len(t, batch.Events, 6)
+}
+
+func TestFilterRuleIsEmpty(t *testing.T) {
+	assert.True(t, FilterRule{}.IsEmpty())
+	assert.False(t, FilterRule{Exclude: []string{"kube-system"}}.IsEmpty())
+}
@@ -15,6 +15,7 @@
 package elasticsearch
 
 import (
+	"fmt"
 	"net/url"
 	"sync"
 	"time"
@@ -37,6 +38,7 @@ type elasticSearchSink struct {
 	esSvc     esCommon.ElasticSearchService
 	saveData  SaveDataFunc
 	flushData func() error
+	stopData  func() error
 	sync.RWMutex
 }
 
@@ -50,6 +52,11 @@ type EsSinkPoint struct {
 	Message                  string
 	Reason                   string
 	Type                     string
+	Action                   string      `json:",omitempty"`
+	ReportingController      string      `json:",omitempty"`
+	ReportingInstance        string      `json:",omitempty"`
+	Related                  interface{} `json:",omitempty"`
+	Series                   interface{} `json:",omitempty"`
 	EventTags                map[string]string
 }
 
@@ -64,11 +71,21 @@ func eventToPoint(event *kube_api.Event, clusterName string) (*EsSinkPoint, erro
 		Metadata:                 event.ObjectMeta,
 		InvolvedObject:           event.InvolvedObject,
 		Source:                   event.Source,
+		Action:                   event.Action,
+		ReportingController:      event.ReportingController,
+		ReportingInstance:        event.ReportingInstance,
 		EventTags: map[string]string{
 			"eventID":      string(event.UID),
 			"cluster_name": clusterName,
 		},
 	}
+	// A nil pointer in an interface would not be omitted.
+	if event.Related != nil {
+		point.Related = event.Related
+	}
+	if event.Series != nil {
+		point.Series = event.Series
+	}
 	if event.InvolvedObject.Kind == "Pod" {
 		point.EventTags[core.LabelPodId.Key] = string(event.InvolvedObject.UID)
 		point.EventTags[core.LabelPodName.Key] = event.InvolvedObject.Name
@@ -78,22 +95,37 @@ func eventToPoint(event *kube_api.Event, clusterName string) (*EsSinkPoint, erro
 }
 
 func (sink *elasticSearchSink) ExportEvents(eventBatch *event_core.EventBatch) {
+	sink.ExportEventsChecked(eventBatch)
+}
+
+// ExportEventsChecked exports the batch and returns an error when some of its events
+// were not indexed.
+func (sink *elasticSearchSink) ExportEventsChecked(eventBatch *event_core.EventBatch) error {
 	sink.Lock()
 	defer sink.Unlock()
+	failed := 0
 	for _, event := range eventBatch.Events {
 		point, err := eventToPoint(event, sink.esSvc.ClusterName)
 		if err != nil {
 			glog.Warningf("Failed to convert event to point: %v", err)
+			failed++
+			continue
 		}
 		err = sink.saveData(point.LastOccurrenceTimestamp, []interface{}{*point})
 		if err != nil {
 			glog.Warningf("Failed to export data to ElasticSearch sink: %v", err)
+			failed++
 		}
 	}
 	err := sink.flushData()
 	if err != nil {
 		glog.Warningf("Failed to flushing data to ElasticSearch sink: %v", err)
+		return err
 	}
+	if failed > 0 {
+		return fmt.Errorf("failed to export %d of %d events", failed, len(eventBatch.Events))
+	}
+	return nil
 }
 
 func (sink *elasticSearchSink) Name() string {
@@ -101,7 +133,12 @@ func (sink *elasticSearchSink) Name() string {
 }
 
 func (sink *elasticSearchSink) Stop() {
-	// nothing needs to be done.
+	if sink.stopData == nil {
+		return
+	}
+	if err := sink.stopData(); err != nil {
+		glog.Warningf("Failed to flush data to ElasticSearch sink on stop: %v", err)
+	}
 }
 
 func NewElasticSearchSink(uri *url.URL) (event_core.EventSink, error) {
@@ -119,6 +156,9 @@ func NewElasticSearchSink(uri *url.URL) (event_core.EventSink, error) {
 	esSink.flushData = func() error {
 		return esSvc.FlushData()
 	}
+	esSink.stopData = func() error {
+		return esSvc.Stop()
+	}
 
 	glog.V(2).Info("ElasticSearch sink setup successfully")
 	return &esSink, nil
@@ -114,3 +114,20 @@ func TestStoreMultipleDataInput(t *testing.T) {
 
 	FakeESSink = fakeESSink{}
 }
+
+func TestEventsApiFields(t *testing.T) {
+	event := &kube_api.Event{
+		Message:             "event",
+		Action:              "Pulling",
+		ReportingController: "kubelet",
+		ReportingInstance:   "kubelet-node-1",
+		Related:             &kube_api.ObjectReference{Kind: "Node", Name: "node-1"},
+		Series:              &kube_api.EventSeries{Count: 5, State: kube_api.EventSeriesStateOngoing},
+	}
+	point, err := eventToPoint(event, "default")
+	assert.NoError(t, err)
+	data, err := json.Marshal(point)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Action":"Pulling","ReportingController":"kubelet","ReportingInstance":"kubelet-node-1",`+
+		`"Related":{"kind":"Node","name":"node-1"},"Series":{"count":5,"lastObservedTime":null,"state":"Ongoing"}`)
+}
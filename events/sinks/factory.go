@@ -20,12 +20,18 @@ import (
 	"k8s.io/heapster/common/flags"
 	"k8s.io/heapster/events/core"
 	"k8s.io/heapster/events/sinks/elasticsearch"
+	"k8s.io/heapster/events/sinks/file"
 	"k8s.io/heapster/events/sinks/gcl"
 	"k8s.io/heapster/events/sinks/honeycomb"
 	"k8s.io/heapster/events/sinks/influxdb"
 	"k8s.io/heapster/events/sinks/kafka"
 	"k8s.io/heapster/events/sinks/log"
+	"k8s.io/heapster/events/sinks/notify"
+	"k8s.io/heapster/events/sinks/otlp"
+	"k8s.io/heapster/events/sinks/prometheus"
+	"k8s.io/heapster/events/sinks/recent"
 	"k8s.io/heapster/events/sinks/riemann"
+	"k8s.io/heapster/events/sinks/webhook"
 
 	"github.com/golang/glog"
 )
@@ -35,6 +41,8 @@ type SinkFactory struct {
 
 func (this *SinkFactory) Build(uri flags.Uri) (core.EventSink, error) {
 	switch uri.Key {
+	case "file":
+		return file.NewFileSink(&uri.Val)
 	case "gcl":
 		return gcl.CreateGCLSink(&uri.Val)
 	case "log":
@@ -45,10 +53,20 @@ func (this *SinkFactory) Build(uri flags.Uri) (core.EventSink, error) {
 		return elasticsearch.NewElasticSearchSink(&uri.Val)
 	case "kafka":
 		return kafka.NewKafkaSink(&uri.Val)
+	case "otlp":
+		return otlp.CreateOtlpSink(&uri.Val)
 	case "riemann":
 		return riemann.CreateRiemannSink(&uri.Val)
+	case "prometheus":
+		return prometheus.CreatePrometheusSink()
+	case "recent":
+		return recent.CreateRecentEventsSink(&uri.Val)
 	case "honeycomb":
 		return honeycomb.NewHoneycombSink(&uri.Val)
+	case "webhook":
+		return webhook.CreateWebhookSink(&uri.Val)
+	case "notify":
+		return notify.CreateNotifySink(&uri.Val)
 	default:
 		return nil, fmt.Errorf("Sink not recognized: %s", uri.Key)
 	}
@@ -0,0 +1,155 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	file_common "k8s.io/heapster/common/file"
+	"k8s.io/heapster/events/core"
+)
+
+// Columns of the CSV segments.
+var csvHeader = []string{
+	"first_timestamp",
+	"last_timestamp",
+	"namespace",
+	"name",
+	"uid",
+	"type",
+	"reason",
+	"object_kind",
+	"object_name",
+	"object_uid",
+	"count",
+	"source_component",
+	"source_host",
+	"message",
+}
+
+type fileSink struct {
+	writer *file_common.RotatingWriter
+	format string
+}
+
+func (sink *fileSink) Name() string {
+	return "File Sink"
+}
+
+func (sink *fileSink) Stop() {
+	if err := sink.writer.Close(); err != nil {
+		glog.Errorf("Failed to close the file sink: %v", err)
+	}
+}
+
+func (sink *fileSink) ExportEvents(eventBatch *core.EventBatch) {
+	if len(eventBatch.Events) == 0 {
+		return
+	}
+	var data []byte
+	var err error
+	if sink.format == file_common.FormatCSV {
+		data, err = encodeCSV(eventBatch.Events)
+	} else {
+		data, err = encodeJSON(eventBatch.Events)
+	}
+	if err != nil {
+		glog.Errorf("Failed to encode events: %v", err)
+		return
+	}
+	if err := sink.writer.Write(data); err != nil {
+		glog.Errorf("Failed to write events: %v", err)
+	}
+}
+
+// encodeJSON writes every event as a line holding its API object.
+func encodeJSON(events []*kube_api.Event) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+func formatTime(t metav1.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func encodeCSV(events []*kube_api.Event) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	for _, event := range events {
+		writer.Write([]string{
+			formatTime(event.FirstTimestamp),
+			formatTime(event.LastTimestamp),
+			event.Namespace,
+			event.Name,
+			string(event.UID),
+			event.Type,
+			event.Reason,
+			event.InvolvedObject.Kind,
+			event.InvolvedObject.Name,
+			string(event.InvolvedObject.UID),
+			strconv.Itoa(int(event.Count)),
+			event.Source.Component,
+			event.Source.Host,
+			event.Message,
+		})
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+func csvHeaderLine() []byte {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write(csvHeader)
+	writer.Flush()
+	return buffer.Bytes()
+}
+
+func NewFileSink(uri *url.URL) (core.EventSink, error) {
+	config, err := file_common.BuildConfig(uri, "events")
+	if err != nil {
+		return nil, err
+	}
+	var header []byte
+	if config.Format == file_common.FormatCSV {
+		header = csvHeaderLine()
+	}
+	writer, err := file_common.NewRotatingWriter(*config, header)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("created file sink writing %s segments to %s", config.Format, config.Dir)
+	return &fileSink{
+		writer: writer,
+		format: config.Format,
+	}, nil
+}
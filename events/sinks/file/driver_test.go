@@ -0,0 +1,85 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/heapster/events/core"
+)
+
+func testEvents() []*kube_api.Event {
+	first := metav1.NewTime(time.Date(2017, 5, 1, 9, 0, 0, 0, time.UTC))
+	last := metav1.NewTime(time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC))
+	return []*kube_api.Event{
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "pod1.abc", Namespace: "default", UID: "uid1"},
+			InvolvedObject: kube_api.ObjectReference{Kind: "Pod", Name: "pod1", UID: "pod-uid"},
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container, again",
+			Type:           kube_api.EventTypeWarning,
+			Count:          2,
+			FirstTimestamp: first,
+			LastTimestamp:  last,
+			Source:         kube_api.EventSource{Component: "kubelet", Host: "node1"},
+		},
+	}
+}
+
+func TestEncodeCSV(t *testing.T) {
+	data, err := encodeCSV(testEvents())
+	require.NoError(t, err)
+	assert.Equal(t,
+		"first_timestamp,last_timestamp,namespace,name,uid,type,reason,object_kind,object_name,object_uid,count,source_component,source_host,message\n",
+		string(csvHeaderLine()))
+	assert.Equal(t,
+		`2017-05-01T09:00:00Z,2017-05-01T10:00:00Z,default,pod1.abc,uid1,Warning,BackOff,Pod,pod1,pod-uid,2,kubelet,node1,"Back-off restarting failed container, again"`+"\n",
+		string(data))
+}
+
+func TestExportEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "heapster-file-sink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	uri, _ := url.Parse(dir)
+	sink, err := NewFileSink(uri)
+	require.NoError(t, err)
+	sink.ExportEvents(&core.EventBatch{Timestamp: time.Now(), Events: testEvents()})
+	sink.Stop()
+
+	segments, err := sink.(*fileSink).writer.Segments()
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	assert.True(t, strings.HasSuffix(segments[0], ".ndjson"), segments[0])
+	data, err := ioutil.ReadFile(segments[0])
+	require.NoError(t, err)
+
+	event := &kube_api.Event{}
+	require.NoError(t, json.Unmarshal(data, event))
+	assert.Equal(t, "pod1.abc", event.Name)
+	assert.Equal(t, "BackOff", event.Reason)
+	assert.Equal(t, int32(2), event.Count)
+}
@@ -40,10 +40,14 @@ type exportedData struct {
 	Type            string `json:"type"`
 	Reason          string `json:"reason"`
 	Message         string `json:"message"`
+	Action          string `json:"action,omitempty"`
+	Reporting       string `json:"reporting.controller,omitempty"`
+	RelatedKind     string `json:"related.kind,omitempty"`
+	RelatedName     string `json:"related.name,omitempty"`
 }
 
 func getExportedData(e *kube_api.Event) *exportedData {
-	return &exportedData{
+	data := &exportedData{
 		Namespace:       e.InvolvedObject.Namespace,
 		Kind:            e.InvolvedObject.Kind,
 		Name:            e.InvolvedObject.Name,
@@ -54,7 +58,14 @@ func getExportedData(e *kube_api.Event) *exportedData {
 		Reason:          e.Reason,
 		Type:            e.Type,
 		Message:         e.Message,
+		Action:          e.Action,
+		Reporting:       e.ReportingController,
 	}
+	if e.Related != nil {
+		data.RelatedKind = e.Related.Kind
+		data.RelatedName = e.Related.Name
+	}
+	return data
 }
 
 func (sink *honeycombSink) ExportEvents(eventBatch *event_core.EventBatch) {
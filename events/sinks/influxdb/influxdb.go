@@ -187,7 +187,8 @@ func (sink *influxdbSink) createDatabase() error {
 		sink.client = client
 	}
 
-	if sink.dbExists {
+	// Buckets of the InfluxDB 2.x API are not created by Heapster.
+	if sink.dbExists || sink.c.Version == 2 {
 		return nil
 	}
 
@@ -244,6 +245,10 @@ func CreateInfluxdbSink(uri *url.URL) (core.EventSink, error) {
 		return nil, err
 	}
 	sink := newSink(*config)
-	glog.Infof("created influxdb sink with options: host:%s user:%s db:%s", config.Host, config.User, config.DbName)
+	if config.Version == 2 {
+		glog.Infof("created influxdb sink with options: host:%s org:%s bucket:%s", config.Host, config.Organization, config.Bucket)
+	} else {
+		glog.Infof("created influxdb sink with options: host:%s user:%s db:%s", config.Host, config.User, config.DbName)
+	}
 	return sink, nil
 }
@@ -16,6 +16,7 @@ package kafka
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/url"
 	"sync"
 	"time"
@@ -68,20 +69,34 @@ func eventToPoint(event *kube_api.Event) (*KafkaSinkPoint, error) {
 }
 
 func (sink *kafkaSink) ExportEvents(eventBatch *event_core.EventBatch) {
+	sink.ExportEventsChecked(eventBatch)
+}
+
+// ExportEventsChecked produces the batch and returns an error when some of its events
+// were not produced.
+func (sink *kafkaSink) ExportEventsChecked(eventBatch *event_core.EventBatch) error {
 	sink.Lock()
 	defer sink.Unlock()
 
+	failed := 0
 	for _, event := range eventBatch.Events {
 		point, err := eventToPoint(event)
 		if err != nil {
 			glog.Warningf("Failed to convert event to point: %v", err)
+			failed++
+			continue
 		}
 
 		err = sink.ProduceKafkaMessage(*point)
 		if err != nil {
 			glog.Errorf("Failed to produce event message: %s", err)
+			failed++
 		}
 	}
+	if failed > 0 {
+		return fmt.Errorf("failed to produce %d of %d events", failed, len(eventBatch.Events))
+	}
+	return nil
 }
 
 func NewKafkaSink(uri *url.URL) (event_core.EventSink, error) {
@@ -21,6 +21,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	kube_api "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kafka_common "k8s.io/heapster/common/kafka"
 	event_core "k8s.io/heapster/events/core"
 )
 
@@ -45,6 +46,10 @@ func (client *fakeKafkaClient) ProduceKafkaMessage(msgData interface{}) error {
 	return nil
 }
 
+func (client *fakeKafkaClient) ProduceKafkaMessages(messages []kafka_common.KafkaMessage) error {
+	return nil
+}
+
 func (client *fakeKafkaClient) Name() string {
 	return "Apache Kafka Sink"
 }
@@ -15,6 +15,7 @@
 package sinks
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -47,10 +48,16 @@ func init() {
 
 type sinkHolder struct {
 	sink              core.EventSink
-	eventBatchChannel chan *core.EventBatch
+	eventBatchChannel chan exportRequest
 	stopChannel       chan bool
 }
 
+type exportRequest struct {
+	batch *core.EventBatch
+	// Called once the sink exported the batch, with the error of a checked sink.
+	done func(error)
+}
+
 // Sink Manager - a special sink that distributes data to other sinks. It pushes data
 // only to these sinks that completed their previous exports. Data that could not be
 // pushed in the defined time is dropped and not retried.
@@ -59,22 +66,50 @@ type sinkManager struct {
 	exportEventsTimeout time.Duration
 	// Should be larger than exportEventsTimeout, although it is not a hard requirement.
 	stopTimeout time.Duration
+	// Called with every batch once the sinks exported it, and with the error of the
+	// sinks which failed to, may be nil.
+	ack func(*core.EventBatch, error)
+	// Closed once the last batch exported is acknowledged. ExportEvents is not called
+	// concurrently.
+	acked chan struct{}
+	// Shared by the managers of the same sinks, which are stopped once.
+	stopOnce *sync.Once
 }
 
 func NewEventSinkManager(sinks []core.EventSink, exportEventsTimeout, stopTimeout time.Duration) (core.EventSink, error) {
+	return NewAcknowledgingEventSinkManager(sinks, exportEventsTimeout, stopTimeout, nil)
+}
+
+// NewAcknowledgingEventSinkManager returns a sink manager calling ack with every batch, in
+// order, once all the sinks exported it. Batches which some sink could not take in time, or
+// which a core.CheckedEventSink failed to deliver, are acknowledged with an error.
+func NewAcknowledgingEventSinkManager(sinks []core.EventSink, exportEventsTimeout, stopTimeout time.Duration,
+	ack func(*core.EventBatch, error)) (core.EventSink, error) {
+	managers, err := NewSharedEventSinkManagers(sinks, exportEventsTimeout, stopTimeout, []func(*core.EventBatch, error){ack})
+	if err != nil {
+		return nil, err
+	}
+	return managers[0], nil
+}
+
+// NewSharedEventSinkManagers returns a sink manager for each of the acks, e.g. for each
+// source, which export to the same sinks. A sink exports a single batch at a time, and
+// stopping any of the managers stops the sinks.
+func NewSharedEventSinkManagers(sinks []core.EventSink, exportEventsTimeout, stopTimeout time.Duration,
+	acks []func(*core.EventBatch, error)) ([]core.EventSink, error) {
 	sinkHolders := []sinkHolder{}
 	for _, sink := range sinks {
 		sh := sinkHolder{
 			sink:              sink,
-			eventBatchChannel: make(chan *core.EventBatch),
+			eventBatchChannel: make(chan exportRequest),
 			stopChannel:       make(chan bool),
 		}
 		sinkHolders = append(sinkHolders, sh)
 		go func(sh sinkHolder) {
 			for {
 				select {
-				case data := <-sh.eventBatchChannel:
-					export(sh.sink, data)
+				case request := <-sh.eventBatchChannel:
+					request.done(export(sh.sink, request.batch))
 				case isStop := <-sh.stopChannel:
 					glog.V(2).Infof("Stop received: %s", sh.sink.Name())
 					if isStop {
@@ -85,32 +120,82 @@ func NewEventSinkManager(sinks []core.EventSink, exportEventsTimeout, stopTimeou
 			}
 		}(sh)
 	}
-	return &sinkManager{
-		sinkHolders:         sinkHolders,
-		exportEventsTimeout: exportEventsTimeout,
-		stopTimeout:         stopTimeout,
-	}, nil
+	stopOnce := &sync.Once{}
+	managers := []core.EventSink{}
+	for _, ack := range acks {
+		managers = append(managers, &sinkManager{
+			sinkHolders:         sinkHolders,
+			exportEventsTimeout: exportEventsTimeout,
+			stopTimeout:         stopTimeout,
+			ack:                 ack,
+			stopOnce:            stopOnce,
+		})
+	}
+	return managers, nil
 }
 
 // Guarantees that the export will complete in exportEventsTimeout.
 func (this *sinkManager) ExportEvents(data *core.EventBatch) {
 	var wg sync.WaitGroup
+	// Done once all the sinks which took the batch exported it.
+	var exported sync.WaitGroup
+	var lock sync.Mutex
+	// The first sink failing to take or to deliver the batch.
+	var exportErr error
+	fail := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if exportErr == nil {
+			exportErr = err
+		}
+	}
 	for _, sh := range this.sinkHolders {
 		wg.Add(1)
+		exported.Add(1)
 		go func(sh sinkHolder, wg *sync.WaitGroup) {
 			defer wg.Done()
 			glog.V(2).Infof("Pushing events to: %s", sh.sink.Name())
+			done := func(err error) {
+				if err != nil {
+					fail(fmt.Errorf("%s failed to deliver the batch: %v", sh.sink.Name(), err))
+				}
+				exported.Done()
+			}
 			select {
-			case sh.eventBatchChannel <- data:
+			case sh.eventBatchChannel <- exportRequest{batch: data, done: done}:
 				glog.V(2).Infof("Data events completed: %s", sh.sink.Name())
 				// everything ok
 			case <-time.After(this.exportEventsTimeout):
 				glog.Warningf("Failed to events data to sink: %s", sh.sink.Name())
+				fail(fmt.Errorf("%s did not take the batch in time", sh.sink.Name()))
+				exported.Done()
 			}
 		}(sh, &wg)
 	}
 	// Wait for all pushes to complete or timeout.
 	wg.Wait()
+
+	if this.ack == nil {
+		return
+	}
+	// The batches are acknowledged in the order of their export.
+	previous := this.acked
+	acked := make(chan struct{})
+	this.acked = acked
+	go func() {
+		defer close(acked)
+		exported.Wait()
+		if previous != nil {
+			<-previous
+		}
+		lock.Lock()
+		err := exportErr
+		lock.Unlock()
+		if err != nil {
+			glog.Warningf("Batch of %d events not exported: %v", len(data.Events), err)
+		}
+		this.ack(data, err)
+	}()
 }
 
 func (this *sinkManager) Name() string {
@@ -118,6 +203,10 @@ func (this *sinkManager) Name() string {
 }
 
 func (this *sinkManager) Stop() {
+	this.stopOnce.Do(this.stop)
+}
+
+func (this *sinkManager) stop() {
 	for _, sh := range this.sinkHolders {
 		glog.V(2).Infof("Running stop for: %s", sh.sink.Name())
 
@@ -135,12 +224,20 @@ func (this *sinkManager) Stop() {
 	}
 }
 
-func export(s core.EventSink, data *core.EventBatch) {
+func export(s core.EventSink, data *core.EventBatch) error {
 	startTime := time.Now()
 	defer func() {
 		exporterDuration.
 			WithLabelValues(s.Name()).
 			Observe(float64(time.Since(startTime)) / float64(time.Millisecond))
 	}()
+	if checked, ok := s.(core.CheckedEventSink); ok {
+		err := checked.ExportEventsChecked(data)
+		if err != nil {
+			glog.Warningf("Failed to deliver events to sink %s: %v", s.Name(), err)
+		}
+		return err
+	}
 	s.ExportEvents(data)
+	return nil
 }
@@ -15,6 +15,7 @@
 package sinks
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -112,3 +113,128 @@ func TestStop(t *testing.T) {
 	assert.Equal(t, true, sink1.IsStopped())
 	assert.Equal(t, true, sink2.IsStopped())
 }
+
+func TestAckAfterAllExports(t *testing.T) {
+	timeout := 3 * time.Second
+
+	sink1 := util.NewDummySink("s1", 100*time.Millisecond)
+	sink2 := util.NewDummySink("s2", time.Second)
+	acked := make(chan *core.EventBatch, 1)
+	manager, _ := NewAcknowledgingEventSinkManager([]core.EventSink{sink1, sink2}, timeout, timeout,
+		func(batch *core.EventBatch, err error) {
+			if err == nil {
+				acked <- batch
+			}
+		})
+
+	batch := &core.EventBatch{Timestamp: time.Now(), ResourceVersion: "12"}
+	manager.ExportEvents(batch)
+	select {
+	case ackedBatch := <-acked:
+		assert.Equal(t, batch, ackedBatch)
+		assert.Equal(t, 1, sink1.GetExportCount())
+		assert.Equal(t, 1, sink2.GetExportCount())
+	case <-time.After(2 * timeout):
+		t.Fatal("batch not acknowledged")
+	}
+}
+
+func TestNoAckWhenExportNotInTime(t *testing.T) {
+	timeout := time.Second
+
+	sink1 := util.NewDummySink("s1", 100*time.Millisecond)
+	sink2 := util.NewDummySink("s2", 5*time.Second)
+	acked := make(chan *core.EventBatch, 2)
+	manager, _ := NewAcknowledgingEventSinkManager([]core.EventSink{sink1, sink2}, timeout, timeout,
+		func(batch *core.EventBatch, err error) {
+			if err == nil {
+				acked <- batch
+			}
+		})
+
+	manager.ExportEvents(&core.EventBatch{Timestamp: time.Now(), ResourceVersion: "12"})
+	// The second sink is still exporting the first batch.
+	manager.ExportEvents(&core.EventBatch{Timestamp: time.Now(), ResourceVersion: "13"})
+	time.Sleep(6 * time.Second)
+
+	assert.Equal(t, 1, len(acked))
+	assert.Equal(t, "12", (<-acked).ResourceVersion)
+}
+
+// failingSink is a checked sink failing to deliver the batches with the given resource version.
+type failingSink struct {
+	*util.DummySink
+	failedVersion string
+}
+
+func (sink *failingSink) ExportEventsChecked(batch *core.EventBatch) error {
+	sink.ExportEvents(batch)
+	if batch.ResourceVersion == sink.failedVersion {
+		return fmt.Errorf("failed to deliver %s", batch.ResourceVersion)
+	}
+	return nil
+}
+
+func TestNoAckWhenDeliveryFailed(t *testing.T) {
+	timeout := time.Second
+
+	sink1 := util.NewDummySink("s1", 100*time.Millisecond)
+	sink2 := &failingSink{DummySink: util.NewDummySink("s2", 100*time.Millisecond), failedVersion: "12"}
+	acked := make(chan *core.EventBatch, 2)
+	failed := make(chan error, 2)
+	manager, _ := NewAcknowledgingEventSinkManager([]core.EventSink{sink1, sink2}, timeout, timeout,
+		func(batch *core.EventBatch, err error) {
+			acked <- batch
+			failed <- err
+		})
+
+	manager.ExportEvents(&core.EventBatch{Timestamp: time.Now(), ResourceVersion: "12"})
+	manager.ExportEvents(&core.EventBatch{Timestamp: time.Now(), ResourceVersion: "13"})
+	time.Sleep(time.Second)
+
+	// Both batches are acknowledged in order, the first one with the error.
+	assert.Equal(t, 2, sink2.GetExportCount())
+	assert.Equal(t, 2, len(acked))
+	assert.Equal(t, "12", (<-acked).ResourceVersion)
+	assert.Error(t, <-failed)
+	assert.Equal(t, "13", (<-acked).ResourceVersion)
+	assert.NoError(t, <-failed)
+}
+
+func TestSharedManagers(t *testing.T) {
+	timeout := 3 * time.Second
+
+	sink := util.NewDummySink("s1", 100*time.Millisecond)
+	acked1 := make(chan *core.EventBatch, 1)
+	acked2 := make(chan *core.EventBatch, 1)
+	managers, _ := NewSharedEventSinkManagers([]core.EventSink{sink}, timeout, timeout,
+		[]func(*core.EventBatch, error){
+			func(batch *core.EventBatch, err error) { acked1 <- batch },
+			func(batch *core.EventBatch, err error) { acked2 <- batch },
+		})
+	assert.Len(t, managers, 2)
+
+	// Both managers export to the same sink, and acknowledge their own batches.
+	batch1 := &core.EventBatch{Timestamp: time.Now(), ResourceVersion: "12"}
+	batch2 := &core.EventBatch{Timestamp: time.Now(), ResourceVersion: "40"}
+	managers[0].ExportEvents(batch1)
+	managers[1].ExportEvents(batch2)
+	for _, acked := range []struct {
+		channel chan *core.EventBatch
+		batch   *core.EventBatch
+	}{{acked1, batch1}, {acked2, batch2}} {
+		select {
+		case ackedBatch := <-acked.channel:
+			assert.Equal(t, acked.batch, ackedBatch)
+		case <-time.After(2 * timeout):
+			t.Fatal("batch not acknowledged")
+		}
+	}
+	assert.Equal(t, 2, sink.GetExportCount())
+
+	// Stopping the second manager does nothing, the sink is stopped already.
+	managers[0].Stop()
+	managers[1].Stop()
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, sink.IsStopped())
+}
@@ -0,0 +1,181 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+const (
+	ReceiverSlack   = "slack"
+	ReceiverTeams   = "teams"
+	ReceiverWebhook = "webhook"
+
+	defaultGroupWait      = 30 * time.Second
+	defaultRepeatInterval = time.Hour
+)
+
+// Event fields by which the rules select and group the events.
+var eventFields = map[string]bool{"namespace": true, "kind": true, "name": true, "reason": true, "type": true}
+
+var defaultGroupBy = []string{"namespace", "kind", "name", "reason"}
+
+// Duration is a time.Duration read from a string such as `30s`.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+	return nil
+}
+
+// Config is read from the YAML or JSON file given to the sink.
+type Config struct {
+	Receivers []ReceiverConfig `json:"receivers"`
+	Rules     []RuleConfig     `json:"rules"`
+}
+
+// ReceiverConfig describes an endpoint receiving the notifications.
+type ReceiverConfig struct {
+	Name string `json:"name"`
+	// One of slack, teams or webhook.
+	Type string `json:"type"`
+	// Environment variables are expanded in the URL and the headers, e.g. for tokens.
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Template of the message text for slack and teams, and of the whole request body
+	// for webhook. Webhooks receive the notification as JSON by default.
+	Template string `json:"template"`
+	// Maximum number of notifications sent per minute, the others are dropped. Unlimited when 0.
+	RateLimit int `json:"rateLimit"`
+}
+
+// RuleConfig selects the events to notify. The events match when all the fields listed
+// match one of their patterns, e.g. `prod-*`.
+type RuleConfig struct {
+	Name       string   `json:"name"`
+	Receivers  []string `json:"receivers"`
+	Namespaces []string `json:"namespaces"`
+	Kinds      []string `json:"kinds"`
+	Names      []string `json:"names"`
+	Reasons    []string `json:"reasons"`
+	Types      []string `json:"types"`
+	// Fields of the events notified together, namespace, kind, name and reason by default.
+	GroupBy []string `json:"groupBy"`
+	// How long the events of a new group are collected before the group is notified.
+	GroupWait *Duration `json:"groupWait"`
+	// Minimum time between two notifications of a group.
+	RepeatInterval *Duration `json:"repeatInterval"`
+}
+
+func validatePatterns(field string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid %s pattern %q - %v", field, pattern, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	receivers := map[string]bool{}
+	for i := range c.Receivers {
+		receiver := &c.Receivers[i]
+		if receiver.Name == "" || receivers[receiver.Name] {
+			return fmt.Errorf("receivers must have distinct names, got %q", receiver.Name)
+		}
+		receivers[receiver.Name] = true
+		if receiver.Type != ReceiverSlack && receiver.Type != ReceiverTeams && receiver.Type != ReceiverWebhook {
+			return fmt.Errorf("unknown type %q of receiver %s", receiver.Type, receiver.Name)
+		}
+		receiver.URL = os.ExpandEnv(receiver.URL)
+		if receiver.URL == "" {
+			return fmt.Errorf("missing url of receiver %s", receiver.Name)
+		}
+		for name, value := range receiver.Headers {
+			receiver.Headers[name] = os.ExpandEnv(value)
+		}
+	}
+	if len(c.Rules) == 0 {
+		return fmt.Errorf("no rule configured")
+	}
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("rules must have a name")
+		}
+		if len(rule.Receivers) == 0 {
+			return fmt.Errorf("rule %s has no receiver", rule.Name)
+		}
+		for _, receiver := range rule.Receivers {
+			if !receivers[receiver] {
+				return fmt.Errorf("unknown receiver %q of rule %s", receiver, rule.Name)
+			}
+		}
+		for field, patterns := range map[string][]string{
+			"namespace": rule.Namespaces, "kind": rule.Kinds, "name": rule.Names, "reason": rule.Reasons, "type": rule.Types} {
+			if err := validatePatterns(field, patterns); err != nil {
+				return fmt.Errorf("rule %s: %v", rule.Name, err)
+			}
+		}
+		if len(rule.GroupBy) == 0 {
+			rule.GroupBy = defaultGroupBy
+		}
+		for _, field := range rule.GroupBy {
+			if !eventFields[field] {
+				return fmt.Errorf("rule %s can not group by %q", rule.Name, field)
+			}
+		}
+		if rule.GroupWait == nil {
+			rule.GroupWait = &Duration{defaultGroupWait}
+		}
+		if rule.RepeatInterval == nil {
+			rule.RepeatInterval = &Duration{defaultRepeatInterval}
+		}
+	}
+	return nil
+}
+
+// LoadConfig reads and validates the configuration file.
+func LoadConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the notification config - %v", err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the notification config - %v", err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "notify")
+	require.NoError(t, err)
+	_, err = file.WriteString(content)
+	require.NoError(t, err)
+	file.Close()
+	return file.Name()
+}
+
+func TestLoadConfig(t *testing.T) {
+	os.Setenv("NOTIFY_TEST_TOKEN", "secret")
+	defer os.Unsetenv("NOTIFY_TEST_TOKEN")
+	file := writeConfig(t, `
+receivers:
+- name: oncall
+  type: slack
+  url: https://hooks.example.com/$NOTIFY_TEST_TOKEN
+  rateLimit: 5
+- name: pager
+  type: webhook
+  url: https://pager.example.com/events
+  headers:
+    Authorization: Bearer ${NOTIFY_TEST_TOKEN}
+rules:
+- name: oom
+  receivers: [oncall, pager]
+  namespaces: [prod-*]
+  types: [Warning]
+  reasons: [OOMKilling, FailedMount]
+  groupBy: [namespace, reason]
+  groupWait: 1m
+  repeatInterval: 2h
+- name: evictions
+  receivers: [oncall]
+  reasons: [Evicted]
+`)
+	defer os.Remove(file)
+
+	config, err := LoadConfig(file)
+	require.NoError(t, err)
+	assert.Equal(t, "https://hooks.example.com/secret", config.Receivers[0].URL)
+	assert.Equal(t, 5, config.Receivers[0].RateLimit)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer secret"}, config.Receivers[1].Headers)
+
+	oom := config.Rules[0]
+	assert.Equal(t, []string{"oncall", "pager"}, oom.Receivers)
+	assert.Equal(t, []string{"prod-*"}, oom.Namespaces)
+	assert.Equal(t, []string{"OOMKilling", "FailedMount"}, oom.Reasons)
+	assert.Equal(t, []string{"namespace", "reason"}, oom.GroupBy)
+	assert.Equal(t, time.Minute, oom.GroupWait.Duration)
+	assert.Equal(t, 2*time.Hour, oom.RepeatInterval.Duration)
+
+	evictions := config.Rules[1]
+	assert.Equal(t, defaultGroupBy, evictions.GroupBy)
+	assert.Equal(t, defaultGroupWait, evictions.GroupWait.Duration)
+	assert.Equal(t, defaultRepeatInterval, evictions.RepeatInterval.Duration)
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	receivers := `
+receivers:
+- name: oncall
+  type: slack
+  url: https://hooks.example.com/
+`
+	for _, content := range []string{
+		`rules: [`,
+		receivers,
+		receivers + `- name: oncall
+  type: teams
+  url: https://teams.example.com/
+`,
+		`receivers: [{name: oncall, type: irc, url: "irc://example.com"}]`,
+		`receivers: [{name: oncall, type: slack}]`,
+		receivers + `rules: [{receivers: [oncall]}]`,
+		receivers + `rules: [{name: oom}]`,
+		receivers + `rules: [{name: oom, receivers: [pager]}]`,
+		receivers + `rules: [{name: oom, receivers: [oncall], namespaces: ["prod-["]}]`,
+		receivers + `rules: [{name: oom, receivers: [oncall], groupBy: [message]}]`,
+		receivers + `rules: [{name: oom, receivers: [oncall], groupWait: soon}]`,
+	} {
+		file := writeConfig(t, content)
+		_, err := LoadConfig(file)
+		assert.Error(t, err, content)
+		os.Remove(file)
+	}
+	_, err := LoadConfig("/nonexistent/notify.yaml")
+	assert.Error(t, err)
+}
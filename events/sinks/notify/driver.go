@@ -0,0 +1,383 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	kube_api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	webhook_common "k8s.io/heapster/common/webhook"
+	"k8s.io/heapster/events/core"
+	"k8s.io/heapster/events/processors"
+)
+
+const (
+	// The number of latest events listed in a notification.
+	maxNotifiedEvents = 10
+
+	defaultTextTemplate = `[{{.Cluster}}] {{.Rule}}: {{.Count}} event(s) for{{range $field, $value := .Group}} {{$field}}={{$value}}{{end}}
+{{range .Events}}
+{{.Type}} {{.Reason}} {{.InvolvedObject.Kind}} {{.InvolvedObject.Namespace}}/{{.InvolvedObject.Name}} (x{{.Count}}): {{.Message}}{{end}}`
+)
+
+var (
+	notifications = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "eventer",
+			Subsystem: "notifier",
+			Name:      "notifications_total_number",
+			Help:      "The number of notifications by receiver and result: sent, failed or rate_limited.",
+		},
+		[]string{"receiver", "result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(notifications)
+}
+
+// Notification is passed to the templates, and sent as JSON to the webhook receivers without template.
+type Notification struct {
+	Cluster string `json:"cluster"`
+	Rule    string `json:"rule"`
+	// Values of the fields by which the events are grouped.
+	Group map[string]string `json:"group"`
+	// Number of events of the group since the previous notification.
+	Count int `json:"count"`
+	// The latest of these events.
+	Events []*kube_api.Event `json:"events"`
+}
+
+type receiver struct {
+	config ReceiverConfig
+	client webhook_common.WebhookClient
+	// Renders the message text of slack and teams receivers.
+	text *template.Template
+	// Times of the notifications sent in the last minute.
+	sent []time.Time
+}
+
+// allow returns whether the rate limit allows to send a notification.
+func (r *receiver) allow(now time.Time) bool {
+	for len(r.sent) > 0 && now.Sub(r.sent[0]) >= time.Minute {
+		r.sent = r.sent[1:]
+	}
+	if r.config.RateLimit > 0 && len(r.sent) >= r.config.RateLimit {
+		return false
+	}
+	r.sent = append(r.sent, now)
+	return true
+}
+
+func (r *receiver) payload(notification *Notification) (interface{}, error) {
+	if r.config.Type == ReceiverWebhook {
+		return notification, nil
+	}
+	var text bytes.Buffer
+	if err := r.text.Execute(&text, notification); err != nil {
+		return nil, fmt.Errorf("failed to render the notification - %v", err)
+	}
+	if r.config.Type == ReceiverSlack {
+		return map[string]interface{}{"text": text.String()}, nil
+	}
+	return map[string]interface{}{
+		"@type":    "MessageCard",
+		"@context": "https://schema.org/extensions",
+		"summary":  fmt.Sprintf("%s: %d event(s)", notification.Rule, notification.Count),
+		"title":    notification.Rule,
+		"text":     text.String(),
+	}, nil
+}
+
+// group collects the events of a rule with the same values of the grouped fields.
+type group struct {
+	rule *RuleConfig
+	// Cluster of the events, when the eventer reads several clusters.
+	cluster string
+	values  map[string]string
+	// Events not notified yet, in the order they were received, and their positions by UID.
+	pending   []*kube_api.Event
+	positions map[types.UID]int
+	// When the first pending event was received, and when the group was last notified.
+	firstPending time.Time
+	notified     time.Time
+}
+
+func (g *group) add(event *kube_api.Event, now time.Time) {
+	if position, found := g.positions[event.UID]; found {
+		// Keep the latest update of the event.
+		g.pending[position] = event
+		return
+	}
+	if len(g.pending) == 0 {
+		g.firstPending = now
+	}
+	g.positions[event.UID] = len(g.pending)
+	g.pending = append(g.pending, event)
+}
+
+func (g *group) due(now time.Time) bool {
+	return len(g.pending) > 0 &&
+		now.Sub(g.firstPending) >= g.rule.GroupWait.Duration &&
+		(g.notified.IsZero() || now.Sub(g.notified) >= g.rule.RepeatInterval.Duration)
+}
+
+type notifySink struct {
+	lock        sync.Mutex
+	clusterName string
+	rules       []RuleConfig
+	receivers   map[string]*receiver
+	groups      map[string]*group
+}
+
+func (sink *notifySink) Name() string {
+	return "Notify Sink"
+}
+
+func (sink *notifySink) Stop() {
+	// nothing needs to be done.
+}
+
+func fieldValue(event *kube_api.Event, field string) string {
+	switch field {
+	case "namespace":
+		if event.InvolvedObject.Namespace != "" {
+			return event.InvolvedObject.Namespace
+		}
+		return event.Namespace
+	case "kind":
+		return event.InvolvedObject.Kind
+	case "name":
+		return event.InvolvedObject.Name
+	case "reason":
+		return event.Reason
+	case "type":
+		return event.Type
+	}
+	return ""
+}
+
+func matchesAny(value string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(rule *RuleConfig, event *kube_api.Event) bool {
+	return matchesAny(fieldValue(event, "namespace"), rule.Namespaces) &&
+		matchesAny(fieldValue(event, "kind"), rule.Kinds) &&
+		matchesAny(fieldValue(event, "name"), rule.Names) &&
+		matchesAny(fieldValue(event, "reason"), rule.Reasons) &&
+		matchesAny(fieldValue(event, "type"), rule.Types)
+}
+
+// delivery is a rendered notification waiting to be sent to a receiver.
+type delivery struct {
+	rule    string
+	payload interface{}
+}
+
+// ExportEvents groups the matching events, and notifies the groups which are due.
+// The notifications are only sent when batches are exported, at the eventer frequency.
+// They are rendered under the lock and sent after it is released, concurrently to the
+// receivers and in order to each receiver.
+func (sink *notifySink) ExportEvents(eventBatch *core.EventBatch) {
+	deliveries := sink.group(eventBatch)
+
+	var wg sync.WaitGroup
+	for name, pending := range deliveries {
+		wg.Add(1)
+		go func(name string, pending []delivery) {
+			defer wg.Done()
+			sink.send(name, pending)
+		}(name, pending)
+	}
+	wg.Wait()
+}
+
+// group adds the events to their groups, and returns the notifications of the groups
+// which are due by receiver.
+func (sink *notifySink) group(eventBatch *core.EventBatch) map[string][]delivery {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	now := eventBatch.Timestamp
+	deliveries := map[string][]delivery{}
+
+	for _, event := range eventBatch.Events {
+		for i := range sink.rules {
+			rule := &sink.rules[i]
+			if !matches(rule, event) {
+				continue
+			}
+			cluster := event.Labels[processors.LabelCluster]
+			values := make(map[string]string, len(rule.GroupBy))
+			fields := []string{cluster, rule.Name}
+			for _, field := range rule.GroupBy {
+				values[field] = fieldValue(event, field)
+				fields = append(fields, values[field])
+			}
+			key := strings.Join(fields, "\x00")
+			g, found := sink.groups[key]
+			if !found {
+				g = &group{rule: rule, cluster: cluster, values: values, positions: map[types.UID]int{}}
+				sink.groups[key] = g
+			}
+			g.add(event, now)
+		}
+	}
+
+	keys := make([]string, 0, len(sink.groups))
+	for key := range sink.groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		g := sink.groups[key]
+		if g.due(now) {
+			sink.notify(g, now, deliveries)
+		} else if len(g.pending) == 0 && now.Sub(g.notified) >= g.rule.RepeatInterval.Duration {
+			delete(sink.groups, key)
+		}
+	}
+	return deliveries
+}
+
+// notify renders the notification of the group for each of its receivers, adds them
+// to deliveries and empties the group.
+func (sink *notifySink) notify(g *group, now time.Time, deliveries map[string][]delivery) {
+	events := g.pending
+	if len(events) > maxNotifiedEvents {
+		events = events[len(events)-maxNotifiedEvents:]
+	}
+	cluster := g.cluster
+	if cluster == "" {
+		cluster = sink.clusterName
+	}
+	notification := &Notification{
+		Cluster: cluster,
+		Rule:    g.rule.Name,
+		Group:   g.values,
+		Count:   len(g.pending),
+		Events:  events,
+	}
+	for _, name := range g.rule.Receivers {
+		r := sink.receivers[name]
+		if !r.allow(now) {
+			glog.Warningf("Dropped a notification of rule %s to %s, rate limit exceeded", g.rule.Name, name)
+			notifications.WithLabelValues(name, "rate_limited").Inc()
+			continue
+		}
+		payload, err := r.payload(notification)
+		if err != nil {
+			glog.Errorf("Failed to send a notification of rule %s to %s: %v", g.rule.Name, name, err)
+			notifications.WithLabelValues(name, "failed").Inc()
+			continue
+		}
+		deliveries[name] = append(deliveries[name], delivery{rule: g.rule.Name, payload: payload})
+	}
+	g.pending = nil
+	g.positions = map[types.UID]int{}
+	g.notified = now
+}
+
+// send sends the notifications to the receiver, in order. It is called without the
+// lock, the receivers are not modified after the sink is created.
+func (sink *notifySink) send(name string, deliveries []delivery) {
+	r := sink.receivers[name]
+	for _, d := range deliveries {
+		if err := r.client.Send(d.payload); err != nil {
+			glog.Errorf("Failed to send a notification of rule %s to %s: %v", d.rule, name, err)
+			notifications.WithLabelValues(name, "failed").Inc()
+			continue
+		}
+		notifications.WithLabelValues(name, "sent").Inc()
+	}
+}
+
+func newNotifySink(config *Config, clusterName string) (*notifySink, error) {
+	receivers := make(map[string]*receiver, len(config.Receivers))
+	for _, receiverConfig := range config.Receivers {
+		webhookConfig := webhook_common.WebhookConfig{
+			URLs:         []string{receiverConfig.URL},
+			Headers:      receiverConfig.Headers,
+			ContentType:  webhook_common.DefaultContentType,
+			Timeout:      webhook_common.DefaultTimeout,
+			MaxRetries:   webhook_common.DefaultMaxRetries,
+			RetryBackoff: webhook_common.DefaultRetryBackoff,
+		}
+		r := &receiver{config: receiverConfig}
+		if receiverConfig.Type == ReceiverWebhook {
+			if receiverConfig.Template != "" {
+				tmpl, err := webhook_common.NewTemplate(receiverConfig.Name, receiverConfig.Template)
+				if err != nil {
+					return nil, err
+				}
+				webhookConfig.Template = tmpl
+			}
+		} else {
+			text := receiverConfig.Template
+			if text == "" {
+				text = defaultTextTemplate
+			}
+			tmpl, err := webhook_common.NewTemplate(receiverConfig.Name, text)
+			if err != nil {
+				return nil, err
+			}
+			r.text = tmpl
+		}
+		r.client = webhook_common.NewClient(webhookConfig)
+		receivers[receiverConfig.Name] = r
+	}
+	return &notifySink{
+		clusterName: clusterName,
+		rules:       config.Rules,
+		receivers:   receivers,
+		groups:      make(map[string]*group),
+	}, nil
+}
+
+func CreateNotifySink(uri *url.URL) (core.EventSink, error) {
+	opts := uri.Query()
+	if len(opts["config"]) == 0 {
+		return nil, fmt.Errorf("missing `config` flag, the notification config file")
+	}
+	config, err := LoadConfig(opts["config"][0])
+	if err != nil {
+		return nil, err
+	}
+	clusterName := webhook_common.DefaultClusterName
+	if len(opts["cluster_name"]) > 0 {
+		clusterName = opts["cluster_name"][0]
+	}
+	glog.Infof("created notify sink with %d rules", len(config.Rules))
+	return newNotifySink(config, clusterName)
+}
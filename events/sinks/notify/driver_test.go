@@ -0,0 +1,237 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	webhook_common "k8s.io/heapster/common/webhook"
+	"k8s.io/heapster/events/core"
+	"k8s.io/heapster/events/processors"
+)
+
+func newEvent(uid, namespace, name, reason string, count int32) *kube_api.Event {
+	return &kube_api.Event{
+		ObjectMeta:     metav1.ObjectMeta{UID: types.UID(uid), Namespace: namespace},
+		InvolvedObject: kube_api.ObjectReference{Kind: "Pod", Namespace: namespace, Name: name},
+		Reason:         reason,
+		Message:        "message of " + name,
+		Type:           kube_api.EventTypeWarning,
+		Count:          count,
+	}
+}
+
+// newFakeSink returns a sink with a rule grouping the events by namespace and reason,
+// and fake clients by receiver.
+func newFakeSink(t *testing.T, receivers ...ReceiverConfig) (*notifySink, map[string]*webhook_common.FakeWebhookClient) {
+	names := []string{}
+	for _, receiver := range receivers {
+		names = append(names, receiver.Name)
+	}
+	config := &Config{
+		Receivers: receivers,
+		Rules: []RuleConfig{{
+			Name:           "oom",
+			Receivers:      names,
+			Namespaces:     []string{"prod-*"},
+			Types:          []string{kube_api.EventTypeWarning},
+			Reasons:        []string{"OOMKilling", "FailedMount"},
+			GroupBy:        []string{"namespace", "reason"},
+			GroupWait:      &Duration{time.Minute},
+			RepeatInterval: &Duration{time.Hour},
+		}},
+	}
+	require.NoError(t, config.validate())
+	sink, err := newNotifySink(config, "test")
+	require.NoError(t, err)
+	clients := map[string]*webhook_common.FakeWebhookClient{}
+	for name, r := range sink.receivers {
+		clients[name] = webhook_common.NewFakeWebhookClient()
+		r.client = clients[name]
+	}
+	return sink, clients
+}
+
+func export(sink *notifySink, timestamp time.Time, events ...*kube_api.Event) {
+	sink.ExportEvents(&core.EventBatch{Timestamp: timestamp, Events: events})
+}
+
+func TestGroupAndRepeat(t *testing.T) {
+	sink, clients := newFakeSink(t, ReceiverConfig{Name: "pager", Type: ReceiverWebhook, URL: "http://pager"})
+	client := clients["pager"]
+	now := time.Now()
+
+	export(sink, now,
+		newEvent("a", "prod-web", "web-1", "OOMKilling", 1),
+		newEvent("b", "prod-web", "web-2", "OOMKilling", 1),
+		newEvent("c", "prod-db", "db-0", "FailedMount", 1),
+		newEvent("d", "dev", "web-1", "OOMKilling", 1),
+		newEvent("e", "prod-web", "web-1", "BackOff", 1))
+	// Waiting for the group to fill.
+	assert.Empty(t, client.Payloads)
+
+	export(sink, now.Add(30*time.Second), newEvent("a", "prod-web", "web-1", "OOMKilling", 2))
+	export(sink, now.Add(time.Minute))
+	require.Len(t, client.Payloads, 2)
+	notification := client.Payloads[1].(*Notification)
+	assert.Equal(t, "test", notification.Cluster)
+	assert.Equal(t, "oom", notification.Rule)
+	assert.Equal(t, map[string]string{"namespace": "prod-web", "reason": "OOMKilling"}, notification.Group)
+	assert.Equal(t, 2, notification.Count)
+	if assert.Len(t, notification.Events, 2) {
+		assert.Equal(t, int32(2), notification.Events[0].Count)
+		assert.Equal(t, "web-2", notification.Events[1].InvolvedObject.Name)
+	}
+	assert.Equal(t, "prod-db", client.Payloads[0].(*Notification).Group["namespace"])
+
+	// Notified again after the repeat interval only.
+	export(sink, now.Add(10*time.Minute), newEvent("a", "prod-web", "web-1", "OOMKilling", 3))
+	export(sink, now.Add(30*time.Minute))
+	assert.Len(t, client.Payloads, 2)
+	export(sink, now.Add(61*time.Minute))
+	require.Len(t, client.Payloads, 3)
+	assert.Equal(t, 1, client.Payloads[2].(*Notification).Count)
+
+	// The idle groups are forgotten.
+	export(sink, now.Add(3*time.Hour))
+	assert.Empty(t, sink.groups)
+}
+
+func TestGroupByCluster(t *testing.T) {
+	sink, clients := newFakeSink(t, ReceiverConfig{Name: "pager", Type: ReceiverWebhook, URL: "http://pager"})
+	now := time.Now()
+
+	east := newEvent("a", "prod-web", "web-1", "OOMKilling", 1)
+	east.Labels = map[string]string{processors.LabelCluster: "east"}
+	west := newEvent("b", "prod-web", "web-1", "OOMKilling", 1)
+	west.Labels = map[string]string{processors.LabelCluster: "west"}
+	export(sink, now, east, west)
+	export(sink, now.Add(time.Minute))
+
+	// The events of each cluster are notified apart.
+	require.Len(t, clients["pager"].Payloads, 2)
+	clusters := []string{}
+	for _, payload := range clients["pager"].Payloads {
+		notification := payload.(*Notification)
+		assert.Equal(t, 1, notification.Count)
+		clusters = append(clusters, notification.Cluster)
+	}
+	assert.Equal(t, []string{"east", "west"}, clusters)
+}
+
+func TestRateLimit(t *testing.T) {
+	sink, clients := newFakeSink(t, ReceiverConfig{Name: "pager", Type: ReceiverWebhook, URL: "http://pager", RateLimit: 2})
+	now := time.Now()
+
+	export(sink, now,
+		newEvent("a", "prod-1", "web", "OOMKilling", 1),
+		newEvent("b", "prod-2", "web", "OOMKilling", 1),
+		newEvent("c", "prod-3", "web", "OOMKilling", 1))
+	export(sink, now.Add(time.Minute))
+	assert.Len(t, clients["pager"].Payloads, 2)
+
+	export(sink, now.Add(2*time.Minute), newEvent("d", "prod-4", "web", "OOMKilling", 1))
+	export(sink, now.Add(3*time.Minute))
+	assert.Len(t, clients["pager"].Payloads, 3)
+}
+
+func TestFailedNotification(t *testing.T) {
+	sink, clients := newFakeSink(t, ReceiverConfig{Name: "pager", Type: ReceiverWebhook, URL: "http://pager"})
+	clients["pager"].Err = errors.New("unavailable")
+	now := time.Now()
+
+	export(sink, now, newEvent("a", "prod-1", "web", "OOMKilling", 1))
+	export(sink, now.Add(time.Minute))
+	assert.Len(t, clients["pager"].Payloads, 1)
+	// Not retried before the repeat interval.
+	export(sink, now.Add(2*time.Minute))
+	assert.Len(t, clients["pager"].Payloads, 1)
+}
+
+// blockingClient blocks the sends until released.
+type blockingClient struct {
+	*webhook_common.FakeWebhookClient
+	sending chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingClient) Send(payload interface{}) error {
+	c.sending <- struct{}{}
+	<-c.release
+	return c.FakeWebhookClient.Send(payload)
+}
+
+func TestSendOutsideTheLock(t *testing.T) {
+	sink, clients := newFakeSink(t,
+		ReceiverConfig{Name: "pager", Type: ReceiverWebhook, URL: "http://pager"},
+		ReceiverConfig{Name: "slow", Type: ReceiverWebhook, URL: "http://slow"})
+	slow := &blockingClient{FakeWebhookClient: clients["slow"], sending: make(chan struct{}), release: make(chan struct{})}
+	sink.receivers["slow"].client = slow
+	now := time.Now()
+
+	export(sink, now, newEvent("a", "prod-1", "web", "OOMKilling", 1))
+	exported := make(chan struct{})
+	go func() {
+		export(sink, now.Add(time.Minute))
+		close(exported)
+	}()
+	<-slow.sending
+	// The lock is released while the notifications are sent.
+	require.True(t, sink.lock.TryLock())
+	sink.lock.Unlock()
+	close(slow.release)
+	<-exported
+	assert.Len(t, clients["pager"].Payloads, 1)
+	assert.Len(t, clients["slow"].Payloads, 1)
+}
+
+func TestChatPayloads(t *testing.T) {
+	sink, clients := newFakeSink(t,
+		ReceiverConfig{Name: "slack", Type: ReceiverSlack, URL: "http://slack"},
+		ReceiverConfig{Name: "teams", Type: ReceiverTeams, URL: "http://teams", Template: "{{.Count}} {{.Group.reason}} in {{.Group.namespace}}"})
+	now := time.Now()
+
+	export(sink, now, newEvent("a", "prod-web", "web-1", "OOMKilling", 3))
+	export(sink, now.Add(time.Minute))
+
+	require.Len(t, clients["slack"].Payloads, 1)
+	slack, _ := json.Marshal(clients["slack"].Payloads[0])
+	var message map[string]string
+	require.NoError(t, json.Unmarshal(slack, &message))
+	lines := strings.Split(message["text"], "\n")
+	assert.Equal(t, []string{
+		"[test] oom: 1 event(s) for namespace=prod-web reason=OOMKilling",
+		"",
+		"Warning OOMKilling Pod prod-web/web-1 (x3): message of web-1",
+	}, lines)
+
+	require.Len(t, clients["teams"].Payloads, 1)
+	assert.Equal(t, map[string]interface{}{
+		"@type":    "MessageCard",
+		"@context": "https://schema.org/extensions",
+		"summary":  "oom: 1 event(s)",
+		"title":    "oom",
+		"text":     "1 OOMKilling in prod-web",
+	}, clients["teams"].Payloads[0])
+}
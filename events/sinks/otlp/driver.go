@@ -0,0 +1,159 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	kube_api "k8s.io/api/core/v1"
+	otlp_common "k8s.io/heapster/common/otlp"
+	"k8s.io/heapster/events/core"
+	"k8s.io/heapster/events/processors"
+)
+
+type otlpSink struct {
+	client      otlp_common.OtlpClient
+	clusterName string
+}
+
+func (sink *otlpSink) Name() string {
+	return "OTLP Sink"
+}
+
+func (sink *otlpSink) Stop() {
+	sink.client.Stop()
+}
+
+func (sink *otlpSink) ExportEvents(eventBatch *core.EventBatch) {
+	if len(eventBatch.Events) == 0 {
+		return
+	}
+	start := time.Now()
+	if err := sink.client.ExportLogs(sink.encodeBatch(eventBatch)); err != nil {
+		glog.Errorf("Failed to export events to the OTLP collector: %v", err)
+		return
+	}
+	glog.V(4).Infof("Exported %d events over OTLP in %s", len(eventBatch.Events), time.Since(start))
+}
+
+// resource identifies the namespace of an event, the batch may hold the events of several clusters.
+type resource struct {
+	cluster   string
+	namespace string
+}
+
+// encodeBatch returns the ExportLogsServiceRequest holding a ResourceLogs per cluster and namespace.
+func (sink *otlpSink) encodeBatch(eventBatch *core.EventBatch) []byte {
+	byNamespace := make(map[resource][]*kube_api.Event)
+	for _, event := range eventBatch.Events {
+		namespace := resource{cluster: event.Labels[processors.LabelCluster], namespace: event.Namespace}
+		if namespace.cluster == "" {
+			namespace.cluster = sink.clusterName
+		}
+		byNamespace[namespace] = append(byNamespace[namespace], event)
+	}
+	namespaces := make([]resource, 0, len(byNamespace))
+	for namespace := range byNamespace {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		if namespaces[i].cluster != namespaces[j].cluster {
+			return namespaces[i].cluster < namespaces[j].cluster
+		}
+		return namespaces[i].namespace < namespaces[j].namespace
+	})
+
+	request := otlp_common.NewMessage()
+	for _, namespace := range namespaces {
+		attributes := map[string]string{}
+		if namespace.namespace != "" {
+			attributes["k8s.namespace.name"] = namespace.namespace
+		}
+		if namespace.cluster != "" {
+			attributes["k8s.cluster.name"] = namespace.cluster
+		}
+
+		scopeLogs := otlp_common.NewMessage()
+		scopeLogs.Message(otlp_common.FieldScopeDataScope, otlp_common.Scope())
+		for _, event := range byNamespace[namespace] {
+			scopeLogs.Message(otlp_common.FieldScopeDataRecords, encodeEvent(event, eventBatch.Timestamp))
+		}
+
+		resourceLogs := otlp_common.NewMessage()
+		resourceLogs.Message(otlp_common.FieldResourceDataResource, otlp_common.Resource(attributes))
+		resourceLogs.Message(otlp_common.FieldResourceDataScopes, scopeLogs)
+		request.Message(otlp_common.FieldRequestResourceData, resourceLogs)
+	}
+	return request.Bytes()
+}
+
+// encodeEvent returns the LogRecord of the event. The message is the body of the record, and
+// the other fields of the event are attributes.
+func encodeEvent(event *kube_api.Event, observed time.Time) *otlp_common.Message {
+	record := otlp_common.NewMessage()
+	if !event.LastTimestamp.IsZero() {
+		record.Fixed64(otlp_common.FieldLogTime, uint64(event.LastTimestamp.UnixNano()))
+	}
+	if event.Type == kube_api.EventTypeWarning {
+		record.Varint(otlp_common.FieldLogSeverityNumber, otlp_common.SeverityNumberWarn)
+	} else {
+		record.Varint(otlp_common.FieldLogSeverityNumber, otlp_common.SeverityNumberInfo)
+	}
+	if event.Type != "" {
+		record.String(otlp_common.FieldLogSeverityText, event.Type)
+	}
+	record.Message(otlp_common.FieldLogBody, otlp_common.StringValue(event.Message))
+
+	attributes := map[string]string{
+		"k8s.event.name":         event.Name,
+		"k8s.event.uid":          string(event.UID),
+		"k8s.event.reason":       event.Reason,
+		"k8s.event.source":       event.Source.Component,
+		"k8s.node.name":          event.Source.Host,
+		"k8s.object.kind":        event.InvolvedObject.Kind,
+		"k8s.object.name":        event.InvolvedObject.Name,
+		"k8s.object.uid":         string(event.InvolvedObject.UID),
+		"k8s.object.fieldpath":   event.InvolvedObject.FieldPath,
+		"k8s.object.api_version": event.InvolvedObject.APIVersion,
+	}
+	for key, value := range attributes {
+		if value == "" {
+			delete(attributes, key)
+		}
+	}
+	otlp_common.StringAttributes(record, otlp_common.FieldLogAttributes, attributes)
+	record.Message(otlp_common.FieldLogAttributes, otlp_common.KeyValue("k8s.event.count", otlp_common.IntValue(int64(event.Count))))
+	record.Fixed64(otlp_common.FieldLogObservedTime, uint64(observed.UnixNano()))
+	return record
+}
+
+func CreateOtlpSink(uri *url.URL) (core.EventSink, error) {
+	config, err := otlp_common.BuildConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+	client, err := otlp_common.NewClient(*config)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("created OTLP sink exporting to %s over %s", config.Endpoint, config.Protocol)
+	return &otlpSink{
+		client:      client,
+		clusterName: config.ClusterName,
+	}, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	otlp_common "k8s.io/heapster/common/otlp"
+	"k8s.io/heapster/events/core"
+	"k8s.io/heapster/events/processors"
+)
+
+func TestExportEvents(t *testing.T) {
+	client := otlp_common.NewFakeOtlpClient()
+	sink := &otlpSink{client: client, clusterName: "prod"}
+
+	now := time.Unix(2000, 0)
+	last := time.Unix(1500, 0)
+	sink.ExportEvents(&core.EventBatch{
+		Timestamp: now,
+		Events: []*kube_api.Event{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "event1", Namespace: "ns2", UID: "uid1"},
+				InvolvedObject: kube_api.ObjectReference{
+					Kind: "Pod",
+					Name: "pod1",
+					UID:  "pod-uid",
+				},
+				Reason:        "BackOff",
+				Message:       "Back-off restarting failed container",
+				Type:          kube_api.EventTypeWarning,
+				Count:         3,
+				LastTimestamp: metav1.NewTime(last),
+				Source:        kube_api.EventSource{Component: "kubelet", Host: "node1"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "event2", Namespace: "ns1"},
+				Reason:     "Scheduled",
+				Message:    "Successfully assigned",
+				Type:       kube_api.EventTypeNormal,
+				Count:      1,
+			},
+		},
+	})
+
+	require.Len(t, client.LogsRequests, 1)
+	request := &otlp_common.FakeLogsRequest{}
+	require.NoError(t, proto.Unmarshal(client.LogsRequests[0], request))
+	require.Len(t, request.ResourceLogs, 2)
+
+	assert.Equal(t, map[string]string{"k8s.cluster.name": "prod", "k8s.namespace.name": "ns1"},
+		otlp_common.FakeAttributes(request.ResourceLogs[0].Resource.Attributes))
+	normal := request.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, int32(otlp_common.SeverityNumberInfo), normal.SeverityNumber)
+	assert.Zero(t, normal.TimeUnixNano)
+
+	assert.Equal(t, map[string]string{"k8s.cluster.name": "prod", "k8s.namespace.name": "ns2"},
+		otlp_common.FakeAttributes(request.ResourceLogs[1].Resource.Attributes))
+	require.Len(t, request.ResourceLogs[1].ScopeLogs, 1)
+	require.Len(t, request.ResourceLogs[1].ScopeLogs[0].LogRecords, 1)
+	warning := request.ResourceLogs[1].ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, uint64(last.UnixNano()), warning.TimeUnixNano)
+	assert.Equal(t, uint64(now.UnixNano()), warning.ObservedTimeUnixNano)
+	assert.Equal(t, int32(otlp_common.SeverityNumberWarn), warning.SeverityNumber)
+	assert.Equal(t, "Warning", warning.SeverityText)
+	assert.Equal(t, "Back-off restarting failed container", *warning.Body.StringValue)
+	assert.Equal(t, map[string]string{
+		"k8s.event.name":   "event1",
+		"k8s.event.uid":    "uid1",
+		"k8s.event.reason": "BackOff",
+		"k8s.event.source": "kubelet",
+		"k8s.event.count":  "3",
+		"k8s.node.name":    "node1",
+		"k8s.object.kind":  "Pod",
+		"k8s.object.name":  "pod1",
+		"k8s.object.uid":   "pod-uid",
+	}, otlp_common.FakeAttributes(warning.Attributes))
+}
+
+func TestExportEventsByCluster(t *testing.T) {
+	client := otlp_common.NewFakeOtlpClient()
+	sink := &otlpSink{client: client, clusterName: "default"}
+
+	sink.ExportEvents(&core.EventBatch{
+		Timestamp: time.Now(),
+		Events: []*kube_api.Event{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "event1", Namespace: "ns1", Labels: map[string]string{processors.LabelCluster: "west"}},
+				Message:    "m1",
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "event2", Namespace: "ns1", Labels: map[string]string{processors.LabelCluster: "east"}},
+				Message:    "m2",
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "event3", Namespace: "ns1"},
+				Message:    "m3",
+			},
+		},
+	})
+
+	require.Len(t, client.LogsRequests, 1)
+	request := &otlp_common.FakeLogsRequest{}
+	require.NoError(t, proto.Unmarshal(client.LogsRequests[0], request))
+	require.Len(t, request.ResourceLogs, 3)
+	for i, cluster := range []string{"default", "east", "west"} {
+		assert.Equal(t, map[string]string{"k8s.cluster.name": cluster, "k8s.namespace.name": "ns1"},
+			otlp_common.FakeAttributes(request.ResourceLogs[i].Resource.Attributes))
+		assert.Len(t, request.ResourceLogs[i].ScopeLogs[0].LogRecords, 1)
+	}
+}
+
+func TestExportEventsError(t *testing.T) {
+	client := otlp_common.NewFakeOtlpClient()
+	client.Err = assert.AnError
+	sink := &otlpSink{client: client}
+	sink.ExportEvents(&core.EventBatch{Timestamp: time.Now(), Events: []*kube_api.Event{{Message: "m"}}})
+	assert.Len(t, client.LogsRequests, 1)
+
+	sink.Stop()
+	assert.True(t, client.Stopped)
+}
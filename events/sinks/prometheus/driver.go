@@ -0,0 +1,71 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/heapster/common/eventmetrics"
+	"k8s.io/heapster/events/core"
+	"k8s.io/heapster/events/processors"
+)
+
+var (
+	eventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "eventer",
+			Subsystem: "events",
+			Name:      "total",
+			Help:      "The number of occurrences of the events.",
+		},
+		[]string{"cluster", "namespace", "involved_object_kind", "reason", "type"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(eventsTotal)
+}
+
+// PrometheusSink counts the occurrences of the events, exposed with the eventer
+// metrics at /metrics.
+type PrometheusSink struct {
+	counter *eventmetrics.Counter
+	events  *prometheus.CounterVec
+}
+
+func (this *PrometheusSink) Name() string {
+	return "PrometheusSink"
+}
+
+func (this *PrometheusSink) Stop() {
+	// Do nothing.
+}
+
+func (this *PrometheusSink) ExportEvents(batch *core.EventBatch) {
+	for _, event := range batch.Events {
+		if occurrences := this.counter.Add(event, batch.Timestamp); occurrences > 0 {
+			key := eventmetrics.KeyOf(event)
+			cluster := event.Labels[processors.LabelCluster]
+			this.events.WithLabelValues(cluster, key.Namespace, key.Kind, key.Reason, key.Type).Add(float64(occurrences))
+		}
+	}
+	this.counter.Prune(batch.Timestamp)
+}
+
+func CreatePrometheusSink() (*PrometheusSink, error) {
+	return &PrometheusSink{
+		counter: eventmetrics.NewCounter(),
+		events:  eventsTotal,
+	}, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/heapster/events/core"
+	"k8s.io/heapster/events/processors"
+)
+
+func newEvent(uid, namespace, reason string, count int32) *kube_api.Event {
+	return &kube_api.Event{
+		ObjectMeta:     metav1.ObjectMeta{UID: types.UID(uid), Namespace: namespace},
+		InvolvedObject: kube_api.ObjectReference{Kind: "Pod"},
+		Reason:         reason,
+		Type:           kube_api.EventTypeWarning,
+		Count:          count,
+	}
+}
+
+func counterValue(t *testing.T, vec *prometheus.CounterVec, labels ...string) float64 {
+	metric := &dto.Metric{}
+	assert.NoError(t, vec.WithLabelValues(labels...).Write(metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestExportEvents(t *testing.T) {
+	sink, _ := CreatePrometheusSink()
+	sink.events = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_events_total"},
+		[]string{"cluster", "namespace", "involved_object_kind", "reason", "type"})
+
+	sink.ExportEvents(&core.EventBatch{
+		Timestamp: time.Now(),
+		Events: []*kube_api.Event{
+			newEvent("a", "ns1", "BackOff", 1),
+			newEvent("b", "ns2", "BackOff", 1),
+			newEvent("c", "ns1", "OOMKilling", 1),
+		},
+	})
+	sink.ExportEvents(&core.EventBatch{
+		Timestamp: time.Now(),
+		Events: []*kube_api.Event{
+			newEvent("a", "ns1", "BackOff", 4),
+			newEvent("c", "ns1", "OOMKilling", 1),
+		},
+	})
+
+	assert.Equal(t, 4.0, counterValue(t, sink.events, "", "ns1", "Pod", "BackOff", "Warning"))
+	assert.Equal(t, 1.0, counterValue(t, sink.events, "", "ns2", "Pod", "BackOff", "Warning"))
+	assert.Equal(t, 1.0, counterValue(t, sink.events, "", "ns1", "Pod", "OOMKilling", "Warning"))
+}
+
+func TestExportEventsByCluster(t *testing.T) {
+	sink, _ := CreatePrometheusSink()
+	sink.events = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_events_total"},
+		[]string{"cluster", "namespace", "involved_object_kind", "reason", "type"})
+
+	prod := newEvent("a", "ns1", "BackOff", 2)
+	prod.Labels = map[string]string{processors.LabelCluster: "prod"}
+	staging := newEvent("b", "ns1", "BackOff", 1)
+	staging.Labels = map[string]string{processors.LabelCluster: "staging"}
+	sink.ExportEvents(&core.EventBatch{Timestamp: time.Now(), Events: []*kube_api.Event{prod, staging}})
+
+	assert.Equal(t, 1.0, counterValue(t, sink.events, "prod", "ns1", "Pod", "BackOff", "Warning"))
+	assert.Equal(t, 1.0, counterValue(t, sink.events, "staging", "ns1", "Pod", "BackOff", "Warning"))
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recent
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	kube_api "k8s.io/api/core/v1"
+	"k8s.io/heapster/events/core"
+)
+
+const defaultSize = 10000
+
+// StoredEvent is an event kept by the sink, with its position in the sink.
+type StoredEvent struct {
+	// Increases with every event stored, so that the events after a given one can be queried.
+	Id    int64           `json:"id"`
+	Event *kube_api.Event `json:"event"`
+}
+
+// Query selects the stored events. Empty fields match all the events.
+type Query struct {
+	Namespace string
+	Kind      string
+	Name      string
+	Reason    string
+	Type      string
+	// Time range of the last occurrence of the events, the end excluded.
+	Start time.Time
+	End   time.Time
+	// Only the events stored after the one with this id are returned.
+	After int64
+	// Maximum number of events returned, the latest ones. Unlimited when 0.
+	Limit int
+}
+
+func (q *Query) matches(event *kube_api.Event) bool {
+	if (q.Namespace != "" && event.InvolvedObject.Namespace != q.Namespace && event.Namespace != q.Namespace) ||
+		(q.Kind != "" && event.InvolvedObject.Kind != q.Kind) ||
+		(q.Name != "" && event.InvolvedObject.Name != q.Name) ||
+		(q.Reason != "" && event.Reason != q.Reason) ||
+		(q.Type != "" && event.Type != q.Type) {
+		return false
+	}
+	occurred := lastOccurrence(event)
+	return (q.Start.IsZero() || !occurred.Before(q.Start)) && (q.End.IsZero() || occurred.Before(q.End))
+}
+
+func lastOccurrence(event *kube_api.Event) time.Time {
+	if event.Series != nil && !event.Series.LastObservedTime.IsZero() {
+		return event.Series.LastObservedTime.Time
+	}
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	return event.EventTime.Time
+}
+
+// RecentEventsSink keeps the latest events in memory, for the eventer API.
+type RecentEventsSink struct {
+	// Requests can come from other threads.
+	lock sync.RWMutex
+	// Ring buffer of the events, the oldest at next once full.
+	events []StoredEvent
+	next   int
+	lastId int64
+	// Closed and replaced when events are stored.
+	stored chan struct{}
+}
+
+func (this *RecentEventsSink) Name() string {
+	return "RecentEventsSink"
+}
+
+func (this *RecentEventsSink) Stop() {
+	// Do nothing.
+}
+
+func (this *RecentEventsSink) ExportEvents(batch *core.EventBatch) {
+	if len(batch.Events) == 0 {
+		return
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for _, event := range batch.Events {
+		this.lastId++
+		stored := StoredEvent{Id: this.lastId, Event: event}
+		if len(this.events) < cap(this.events) {
+			this.events = append(this.events, stored)
+		} else {
+			this.events[this.next] = stored
+			this.next = (this.next + 1) % len(this.events)
+		}
+	}
+	close(this.stored)
+	this.stored = make(chan struct{})
+}
+
+// Query returns the stored events matching the query, oldest first, along with the id
+// of the last event stored.
+func (this *RecentEventsSink) Query(query Query) ([]StoredEvent, int64) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	result := []StoredEvent{}
+	for i := range this.events {
+		stored := this.events[(this.next+i)%len(this.events)]
+		if stored.Id > query.After && query.matches(stored.Event) {
+			result = append(result, stored)
+		}
+	}
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[len(result)-query.Limit:]
+	}
+	return result, this.lastId
+}
+
+// Stored returns a channel closed once events are stored after the given id.
+func (this *RecentEventsSink) Stored(after int64) <-chan struct{} {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	if this.lastId > after {
+		closed := make(chan struct{})
+		close(closed)
+		return closed
+	}
+	return this.stored
+}
+
+func NewRecentEventsSink(size int) *RecentEventsSink {
+	return &RecentEventsSink{
+		events: make([]StoredEvent, 0, size),
+		stored: make(chan struct{}),
+	}
+}
+
+func CreateRecentEventsSink(uri *url.URL) (*RecentEventsSink, error) {
+	size := defaultSize
+	opts := uri.Query()
+	if len(opts["size"]) > 0 {
+		var err error
+		if size, err = strconv.Atoi(opts["size"][0]); err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid `size` flag %q", opts["size"][0])
+		}
+	}
+	return NewRecentEventsSink(size), nil
+}
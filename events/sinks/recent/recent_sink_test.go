@@ -0,0 +1,128 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recent
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/heapster/events/core"
+)
+
+var now = time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func newEvent(name, reason string, minute int) *kube_api.Event {
+	return &kube_api.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "ns", Name: name},
+		InvolvedObject: kube_api.ObjectReference{Kind: "Pod", Namespace: "ns", Name: name},
+		Reason:         reason,
+		Type:           kube_api.EventTypeWarning,
+		LastTimestamp:  metav1.NewTime(now.Add(time.Duration(minute) * time.Minute)),
+	}
+}
+
+func names(events []StoredEvent) []string {
+	result := []string{}
+	for _, event := range events {
+		result = append(result, event.Event.Name)
+	}
+	return result
+}
+
+func TestRingBuffer(t *testing.T) {
+	sink := NewRecentEventsSink(3)
+	sink.ExportEvents(&core.EventBatch{Events: []*kube_api.Event{newEvent("a", "BackOff", 0), newEvent("b", "BackOff", 1)}})
+	events, lastId := sink.Query(Query{})
+	assert.Equal(t, []string{"a", "b"}, names(events))
+	assert.Equal(t, int64(2), lastId)
+
+	sink.ExportEvents(&core.EventBatch{Events: []*kube_api.Event{newEvent("c", "BackOff", 2), newEvent("d", "BackOff", 3)}})
+	events, lastId = sink.Query(Query{})
+	assert.Equal(t, []string{"b", "c", "d"}, names(events))
+	assert.Equal(t, int64(4), lastId)
+	assert.Equal(t, int64(2), events[0].Id)
+
+	events, _ = sink.Query(Query{After: 3})
+	assert.Equal(t, []string{"d"}, names(events))
+	events, _ = sink.Query(Query{Limit: 2})
+	assert.Equal(t, []string{"c", "d"}, names(events))
+}
+
+func TestQuery(t *testing.T) {
+	sink := NewRecentEventsSink(10)
+	other := newEvent("e", "Killing", 4)
+	other.Type = kube_api.EventTypeNormal
+	node := &kube_api.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "default", Name: "node-1"},
+		InvolvedObject: kube_api.ObjectReference{Kind: "Node", Name: "node-1"},
+		Reason:         "NodeNotReady",
+		EventTime:      metav1.NewMicroTime(now),
+	}
+	sink.ExportEvents(&core.EventBatch{Events: []*kube_api.Event{
+		newEvent("a", "BackOff", 0), newEvent("b", "Failed", 1), newEvent("c", "BackOff", 2), other, node}})
+
+	for _, tc := range []struct {
+		query    Query
+		expected []string
+	}{
+		{Query{Namespace: "ns"}, []string{"a", "b", "c", "e"}},
+		{Query{Namespace: "default"}, []string{"node-1"}},
+		{Query{Kind: "Node"}, []string{"node-1"}},
+		{Query{Kind: "Pod", Name: "b"}, []string{"b"}},
+		{Query{Reason: "BackOff"}, []string{"a", "c"}},
+		{Query{Type: kube_api.EventTypeNormal}, []string{"e"}},
+		{Query{Start: now.Add(time.Minute), End: now.Add(4 * time.Minute)}, []string{"b", "c"}},
+		{Query{End: now.Add(time.Minute)}, []string{"a", "node-1"}},
+	} {
+		events, _ := sink.Query(tc.query)
+		assert.Equal(t, tc.expected, names(events), "%+v", tc.query)
+	}
+}
+
+func TestStored(t *testing.T) {
+	sink := NewRecentEventsSink(10)
+	stored := sink.Stored(0)
+	select {
+	case <-stored:
+		t.Fatal("no event stored yet")
+	default:
+	}
+	sink.ExportEvents(&core.EventBatch{Events: []*kube_api.Event{newEvent("a", "BackOff", 0)}})
+	select {
+	case <-stored:
+	default:
+		t.Fatal("event stored")
+	}
+	select {
+	case <-sink.Stored(0):
+	default:
+		t.Fatal("event stored after id 0")
+	}
+}
+
+func TestCreateRecentEventsSink(t *testing.T) {
+	uri, _ := url.Parse("?size=5")
+	sink, err := CreateRecentEventsSink(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, cap(sink.events))
+
+	uri, _ = url.Parse("?size=0")
+	_, err = CreateRecentEventsSink(uri)
+	assert.Error(t, err)
+}
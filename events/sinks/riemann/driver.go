@@ -18,7 +18,6 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-	"sync"
 
 	"github.com/golang/glog"
 	"github.com/riemann/riemann-go-client"
@@ -27,11 +26,10 @@ import (
 	"k8s.io/heapster/events/core"
 )
 
-// contains the riemann client, the riemann configuration, and a RWMutex
+// contains the riemann batcher and the riemann configuration
 type RiemannSink struct {
-	client riemanngo.Client
-	config riemannCommon.RiemannConfig
-	sync.RWMutex
+	batcher *riemannCommon.Batcher
+	config  riemannCommon.RiemannConfig
 }
 
 // creates a Riemann sink. Returns a riemannSink
@@ -42,8 +40,8 @@ func CreateRiemannSink(uri *url.URL) (core.EventSink, error) {
 		return nil, err
 	}
 	rs := &RiemannSink{
-		client: sink.Client,
-		config: sink.Config,
+		batcher: riemannCommon.NewBatcher(sink.Config, sink.Client),
+		config:  sink.Config,
 	}
 	return rs, nil
 }
@@ -54,10 +52,15 @@ func (sink *RiemannSink) Name() string {
 }
 
 func (sink *RiemannSink) Stop() {
-	sink.client.Close()
+	sink.batcher.Stop()
 }
 
-func getEventState(event *kube_api.Event) string {
+// getEventState returns the state of the first rule matching the event
+// reason, or one derived from the event type.
+func getEventState(config riemannCommon.RiemannConfig, event *kube_api.Event) string {
+	if state, found := config.EventState(event.Reason); found {
+		return state
+	}
 	switch event.Type {
 	case "Normal":
 		return "ok"
@@ -95,37 +98,15 @@ func appendEvent(events []riemanngo.Event, sink *RiemannSink, event *kube_api.Ev
 		},
 		Metric: event.Count,
 		Ttl:    sink.config.Ttl,
-		State:  getEventState(event),
+		State:  getEventState(sink.config, event),
 		Tags:   sink.config.Tags,
 	}
 
 	events = append(events, riemannEvent)
-	if len(events) >= sink.config.BatchSize {
-		err := riemannCommon.SendData(sink.client, events)
-		if err != nil {
-			glog.Warningf("Error sending events to Riemann: %v", err)
-			// client will reconnect later
-			sink.client = nil
-		}
-		events = nil
-	}
 	return events
 }
 
 func (sink *RiemannSink) ExportEvents(eventBatch *core.EventBatch) {
-	sink.Lock()
-	defer sink.Unlock()
-
-	if sink.client == nil {
-		// the client could be nil here, so we reconnect
-		client, err := riemannCommon.GetRiemannClient(sink.config)
-		if err != nil {
-			glog.Warningf("Riemann sink not connected: %v", err)
-			return
-		}
-		sink.client = client
-	}
-
 	var events []riemanngo.Event
 
 	for _, event := range eventBatch.Events {
@@ -134,13 +115,13 @@ func (sink *RiemannSink) ExportEvents(eventBatch *core.EventBatch) {
 		events = appendEvent(events, sink, event, timestamp)
 	}
 
-	if len(events) > 0 {
-		err := riemannCommon.SendData(sink.client, events)
-		if err != nil {
-			glog.Warningf("Error sending events to Riemann: %v", err)
-			// client will reconnect later
-			sink.client = nil
-		}
-		events = nil
-	}
+	// Queue the events, the batcher sends them to Riemann
+	sink.batcher.Add(events...)
+}
+
+// ExportEventsChecked queues the batch like ExportEvents, then flushes the batcher and
+// returns an error when some of the events were dropped.
+func (sink *RiemannSink) ExportEventsChecked(eventBatch *core.EventBatch) error {
+	sink.ExportEvents(eventBatch)
+	return sink.batcher.Flush()
 }
@@ -35,6 +35,7 @@ type fakeRiemannClient struct {
 type fakeRiemannSink struct {
 	core.EventSink
 	fakeRiemannClient *fakeRiemannClient
+	batcher           *riemannCommon.Batcher
 }
 
 func NewFakeRiemannClient() *fakeRiemannClient {
@@ -69,12 +70,14 @@ func NewFakeSink() fakeRiemannSink {
 		BatchSize: 1000,
 	}
 
+	batcher := riemannCommon.NewBatcher(c, riemannClient)
 	return fakeRiemannSink{
 		&RiemannSink{
-			client: riemannClient,
-			config: c,
+			batcher: batcher,
+			config:  c,
 		},
 		riemannClient,
+		batcher,
 	}
 }
 
@@ -82,6 +85,7 @@ func TestStoreDataEmptyInput(t *testing.T) {
 	fakeSink := NewFakeSink()
 	dataBatch := core.EventBatch{}
 	fakeSink.ExportEvents(&dataBatch)
+	fakeSink.batcher.Flush()
 	assert.Equal(t, 0, len(fakeSink.fakeRiemannClient.events))
 }
 
@@ -135,6 +139,7 @@ func TestStoreMultipleDataInput(t *testing.T) {
 		},
 	}
 	fakeSink.ExportEvents(&data)
+	fakeSink.batcher.Flush()
 	// expect msg string
 	assert.Equal(t, 3, len(fakeSink.fakeRiemannClient.events))
 	timeValue := timestamp.Unix()
@@ -296,3 +301,16 @@ func TestStoreMultipleDataInput(t *testing.T) {
 		}
 	}
 }
+
+func TestGetEventState(t *testing.T) {
+	c := riemannCommon.RiemannConfig{
+		EventStateRules: []riemannCommon.EventStateRule{
+			{Reason: "BackOff", State: "critical"},
+			{Reason: "Pulled", State: "warning"},
+		},
+	}
+	assert.Equal(t, "critical", getEventState(c, &kube_api.Event{Reason: "BackOff", Type: "Warning"}))
+	assert.Equal(t, "warning", getEventState(c, &kube_api.Event{Reason: "Pulled", Type: "Normal"}))
+	assert.Equal(t, "ok", getEventState(c, &kube_api.Event{Reason: "Scheduled", Type: "Normal"}))
+	assert.Equal(t, "warning", getEventState(c, &kube_api.Event{Reason: "Failed", Type: "Warning"}))
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/golang/glog"
+	kube_api "k8s.io/api/core/v1"
+	webhook_common "k8s.io/heapster/common/webhook"
+	"k8s.io/heapster/events/core"
+)
+
+// EventsPayload is the body of a request, encoded as JSON or passed to the
+// template. The events keep the fields and the JSON encoding of the Kubernetes API.
+type EventsPayload struct {
+	Cluster   string            `json:"cluster"`
+	Timestamp time.Time         `json:"timestamp"`
+	Events    []*kube_api.Event `json:"events"`
+}
+
+type webhookSink struct {
+	client       webhook_common.WebhookClient
+	clusterName  string
+	maxBatchSize int
+}
+
+func (sink *webhookSink) Name() string {
+	return "Webhook Sink"
+}
+
+func (sink *webhookSink) Stop() {
+	// nothing needs to be done.
+}
+
+func (sink *webhookSink) ExportEvents(eventBatch *core.EventBatch) {
+	sink.ExportEventsChecked(eventBatch)
+}
+
+// ExportEventsChecked sends the batch and returns an error when some of its events
+// were not accepted by the webhook.
+func (sink *webhookSink) ExportEventsChecked(eventBatch *core.EventBatch) error {
+	start := time.Now()
+	failed := 0
+	events := eventBatch.Events
+	for len(events) > 0 {
+		n := len(events)
+		if n > sink.maxBatchSize {
+			n = sink.maxBatchSize
+		}
+		payload := &EventsPayload{
+			Cluster:   sink.clusterName,
+			Timestamp: eventBatch.Timestamp.UTC(),
+			Events:    events[:n],
+		}
+		if err := sink.client.Send(payload); err != nil {
+			glog.Errorf("Failed to export %d events to the webhook: %v", n, err)
+			failed += n
+		}
+		events = events[n:]
+	}
+	glog.V(4).Infof("Exported %d events to the webhook in %s", len(eventBatch.Events), time.Since(start))
+	if failed > 0 {
+		return fmt.Errorf("failed to export %d of %d events to the webhook", failed, len(eventBatch.Events))
+	}
+	return nil
+}
+
+func CreateWebhookSink(uri *url.URL) (core.EventSink, error) {
+	config, err := webhook_common.BuildConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("created webhook sink posting to %v", config.URLs)
+	return &webhookSink{
+		client:       webhook_common.NewClient(*config),
+		clusterName:  config.ClusterName,
+		maxBatchSize: config.MaxBatchSize,
+	}, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	webhook_common "k8s.io/heapster/common/webhook"
+	"k8s.io/heapster/events/core"
+)
+
+func TestExportEvents(t *testing.T) {
+	client := webhook_common.NewFakeWebhookClient()
+	sink := &webhookSink{client: client, clusterName: "test", maxBatchSize: 2}
+
+	timestamp := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	var events []*kube_api.Event
+	for _, reason := range []string{"Scheduled", "Pulled", "BackOff"} {
+		events = append(events, &kube_api.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "default", Name: "redis." + reason},
+			InvolvedObject: kube_api.ObjectReference{Kind: "Pod", Name: "redis"},
+			Reason:         reason,
+			Count:          1,
+		})
+	}
+	sink.ExportEvents(&core.EventBatch{Timestamp: timestamp, Events: events})
+
+	require.Equal(t, 2, len(client.Payloads))
+	assert.Equal(t, &EventsPayload{Cluster: "test", Timestamp: timestamp, Events: events[:2]}, client.Payloads[0])
+	assert.Equal(t, &EventsPayload{Cluster: "test", Timestamp: timestamp, Events: events[2:]}, client.Payloads[1])
+
+	data, err := json.Marshal(client.Payloads[1])
+	require.NoError(t, err)
+	decoded := struct {
+		Cluster string
+		Events  []map[string]interface{}
+	}{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "test", decoded.Cluster)
+	assert.Equal(t, "BackOff", decoded.Events[0]["reason"])
+	assert.Equal(t, "redis", decoded.Events[0]["involvedObject"].(map[string]interface{})["name"])
+}
+
+func TestExportEventsCheckedReportsFailures(t *testing.T) {
+	client := webhook_common.NewFakeWebhookClient()
+	client.Err = fmt.Errorf("503 Service Unavailable")
+	sink := &webhookSink{client: client, clusterName: "test", maxBatchSize: 2}
+
+	events := []*kube_api.Event{{Reason: "Scheduled"}, {Reason: "Pulled"}, {Reason: "BackOff"}}
+	err := sink.ExportEventsChecked(&core.EventBatch{Timestamp: time.Now(), Events: events})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to export 3 of 3 events")
+
+	client.Err = nil
+	assert.NoError(t, sink.ExportEventsChecked(&core.EventBatch{Timestamp: time.Now(), Events: events}))
+}
+
+func TestCreateWebhookSink(t *testing.T) {
+	uri, err := url.Parse("http://collector:8080/events")
+	require.NoError(t, err)
+	sink, err := CreateWebhookSink(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "Webhook Sink", sink.Name())
+
+	uri, err = url.Parse("collector:8080")
+	require.NoError(t, err)
+	_, err = CreateWebhookSink(uri)
+	assert.Error(t, err)
+}
@@ -16,10 +16,12 @@ package sources
 
 import (
 	"fmt"
+	"net/url"
 
 	"github.com/golang/glog"
 
 	"k8s.io/heapster/common/flags"
+	kube_config "k8s.io/heapster/common/kubernetes"
 	"k8s.io/heapster/events/core"
 	kube "k8s.io/heapster/events/sources/kubernetes"
 )
@@ -32,14 +34,25 @@ func (this *SourceFactory) Build(uri flags.Uri) (core.EventSource, error) {
 	case "kubernetes":
 		src, err := kube.NewKubernetesSource(&uri.Val)
 		return src, err
+	case "kubernetes.events_api":
+		src, err := kube.NewEventsApiSource(&uri.Val)
+		return src, err
 	default:
 		return nil, fmt.Errorf("Source not recognized: %s", uri.Key)
 	}
 }
 
+// BuildAll builds a source for each of the uris, one per cluster.
 func (this *SourceFactory) BuildAll(uris flags.Uris) ([]core.EventSource, error) {
-	if len(uris) != 1 {
-		return nil, fmt.Errorf("Only one source is supported")
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("No source specified")
+	}
+	urls := []*url.URL{}
+	for i := range uris {
+		urls = append(urls, &uris[i].Val)
+	}
+	if err := kube_config.ValidateClusterNames(urls); err != nil {
+		return nil, err
 	}
 	result := []core.EventSource{}
 	for _, uri := range uris {
@@ -0,0 +1,131 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	kubeapi "k8s.io/api/core/v1"
+	kubeapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubev1core "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// Key of the resource version in the checkpoint ConfigMap.
+const checkpointKey = "resourceVersion"
+
+// checkpointStore persists the resource version of the last exported event.
+type checkpointStore interface {
+	// Load returns the saved resource version, or an empty string if there is none.
+	Load() (string, error)
+	Save(resourceVersion string) error
+	String() string
+}
+
+// fileCheckpointStore keeps the resource version in a local file, replaced atomically.
+type fileCheckpointStore struct {
+	path string
+}
+
+func (s *fileCheckpointStore) Load() (string, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (s *fileCheckpointStore) Save(resourceVersion string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(resourceVersion + "\n"); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *fileCheckpointStore) String() string {
+	return "file " + s.path
+}
+
+// configMapCheckpointStore keeps the resource version in a ConfigMap, created if missing.
+type configMapCheckpointStore struct {
+	client    kubev1core.ConfigMapInterface
+	namespace string
+	name      string
+}
+
+func (s *configMapCheckpointStore) Load() (string, error) {
+	configMap, err := s.client.Get(s.name, metav1.GetOptions{})
+	if kubeapierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return configMap.Data[checkpointKey], nil
+}
+
+func (s *configMapCheckpointStore) Save(resourceVersion string) error {
+	configMap, err := s.client.Get(s.name, metav1.GetOptions{})
+	if kubeapierrors.IsNotFound(err) {
+		_, err = s.client.Create(&kubeapi.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: s.namespace, Name: s.name},
+			Data:       map[string]string{checkpointKey: resourceVersion},
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[checkpointKey] = resourceVersion
+	_, err = s.client.Update(configMap)
+	return err
+}
+
+func (s *configMapCheckpointStore) String() string {
+	return fmt.Sprintf("ConfigMap %s/%s", s.namespace, s.name)
+}
+
+// newConfigMapCheckpointStore returns a store for a ConfigMap given as <namespace>/<name>.
+func newConfigMapCheckpointStore(client kubev1core.ConfigMapsGetter, configMap string) (*configMapCheckpointStore, error) {
+	parts := strings.Split(configMap, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid checkpoint ConfigMap %q, must be <namespace>/<name>", configMap)
+	}
+	return &configMapCheckpointStore{
+		client:    client.ConfigMaps(parts[0]),
+		namespace: parts[0],
+		name:      parts[1],
+	}, nil
+}
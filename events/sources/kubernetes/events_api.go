@@ -0,0 +1,112 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"net/url"
+
+	kubeapi "k8s.io/api/core/v1"
+	eventsapi "k8s.io/api/events/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubewatch "k8s.io/apimachinery/pkg/watch"
+	kubeclient "k8s.io/client-go/kubernetes"
+	eventsclient "k8s.io/client-go/kubernetes/typed/events/v1beta1"
+)
+
+// NewEventsApiSource returns a source watching the events.k8s.io API, in which the newer
+// controllers report the series, the reporting controller and the related object of
+// their events. The events are converted to core/v1 events, which hold the same fields.
+func NewEventsApiSource(uri *url.URL) (*KubernetesEventSource, error) {
+	return newSourceForUri(uri, func(kubeClient kubeclient.Interface) eventClient {
+		return &eventsApiClient{client: kubeClient.EventsV1beta1().Events(kubeapi.NamespaceAll)}
+	})
+}
+
+type eventsApiClient struct {
+	client eventsclient.EventInterface
+}
+
+func (c *eventsApiClient) List(opts metav1.ListOptions) (*kubeapi.EventList, error) {
+	events, err := c.client.List(opts)
+	if err != nil {
+		return nil, err
+	}
+	result := &kubeapi.EventList{
+		ListMeta: events.ListMeta,
+		Items:    make([]kubeapi.Event, 0, len(events.Items)),
+	}
+	for i := range events.Items {
+		result.Items = append(result.Items, *fromEventsApi(&events.Items[i]))
+	}
+	return result, nil
+}
+
+func (c *eventsApiClient) Watch(opts metav1.ListOptions) (kubewatch.Interface, error) {
+	watcher, err := c.client.Watch(opts)
+	if err != nil {
+		return nil, err
+	}
+	return kubewatch.Filter(watcher, func(in kubewatch.Event) (kubewatch.Event, bool) {
+		if event, ok := in.Object.(*eventsapi.Event); ok {
+			in.Object = fromEventsApi(event)
+		}
+		return in, true
+	}), nil
+}
+
+// fromEventsApi converts the event, filling in the deprecated fields from the new ones
+// for the sinks which only export these.
+func fromEventsApi(event *eventsapi.Event) *kubeapi.Event {
+	result := &kubeapi.Event{
+		ObjectMeta:          event.ObjectMeta,
+		InvolvedObject:      event.Regarding,
+		Related:             event.Related,
+		Reason:              event.Reason,
+		Message:             event.Note,
+		Type:                event.Type,
+		Action:              event.Action,
+		Source:              event.DeprecatedSource,
+		FirstTimestamp:      event.DeprecatedFirstTimestamp,
+		LastTimestamp:       event.DeprecatedLastTimestamp,
+		Count:               event.DeprecatedCount,
+		EventTime:           event.EventTime,
+		ReportingController: event.ReportingController,
+		ReportingInstance:   event.ReportingInstance,
+	}
+	if result.Source.Component == "" {
+		result.Source.Component = event.ReportingController
+	}
+	if result.FirstTimestamp.IsZero() {
+		result.FirstTimestamp = metav1.NewTime(event.EventTime.Time)
+	}
+	if result.LastTimestamp.IsZero() {
+		result.LastTimestamp = metav1.NewTime(event.EventTime.Time)
+	}
+	if event.Series != nil {
+		result.Series = &kubeapi.EventSeries{
+			Count:            event.Series.Count,
+			LastObservedTime: event.Series.LastObservedTime,
+			State:            kubeapi.EventSeriesState(event.Series.State),
+		}
+		result.Count = event.Series.Count
+		if !event.Series.LastObservedTime.IsZero() {
+			result.LastTimestamp = metav1.NewTime(event.Series.LastObservedTime.Time)
+		}
+	}
+	if result.Count == 0 {
+		result.Count = 1
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	kubeapi "k8s.io/api/core/v1"
+	eventsapi "k8s.io/api/events/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubewatch "k8s.io/apimachinery/pkg/watch"
+	eventsclient "k8s.io/client-go/kubernetes/typed/events/v1beta1"
+)
+
+type fakeEventsApiClient struct {
+	eventsclient.EventInterface
+	events  eventsapi.EventList
+	watcher *kubewatch.FakeWatcher
+}
+
+func (c *fakeEventsApiClient) List(opts metav1.ListOptions) (*eventsapi.EventList, error) {
+	return &c.events, nil
+}
+
+func (c *fakeEventsApiClient) Watch(opts metav1.ListOptions) (kubewatch.Interface, error) {
+	return c.watcher, nil
+}
+
+func newEventsApiEvent() *eventsapi.Event {
+	eventTime := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	return &eventsapi.Event{
+		ObjectMeta:          metav1.ObjectMeta{Namespace: "ns", Name: "web-1.abc", UID: "a", ResourceVersion: "10"},
+		EventTime:           metav1.NewMicroTime(eventTime),
+		ReportingController: "kubelet",
+		ReportingInstance:   "kubelet-node-1",
+		Action:              "Pulling",
+		Reason:              "BackOff",
+		Regarding:           kubeapi.ObjectReference{Kind: "Pod", Namespace: "ns", Name: "web-1"},
+		Related:             &kubeapi.ObjectReference{Kind: "Node", Name: "node-1"},
+		Note:                "Back-off pulling image",
+		Type:                kubeapi.EventTypeWarning,
+	}
+}
+
+func TestFromEventsApi(t *testing.T) {
+	event := newEventsApiEvent()
+	result := fromEventsApi(event)
+
+	assert.Equal(t, event.ObjectMeta, result.ObjectMeta)
+	assert.Equal(t, event.Regarding, result.InvolvedObject)
+	assert.Equal(t, event.Related, result.Related)
+	assert.Equal(t, "Back-off pulling image", result.Message)
+	assert.Equal(t, "BackOff", result.Reason)
+	assert.Equal(t, kubeapi.EventTypeWarning, result.Type)
+	assert.Equal(t, "Pulling", result.Action)
+	assert.Equal(t, "kubelet", result.ReportingController)
+	assert.Equal(t, "kubelet-node-1", result.ReportingInstance)
+	assert.Equal(t, kubeapi.EventSource{Component: "kubelet"}, result.Source)
+	assert.Equal(t, event.EventTime, result.EventTime)
+	assert.True(t, event.EventTime.Time.Equal(result.FirstTimestamp.Time))
+	assert.True(t, event.EventTime.Time.Equal(result.LastTimestamp.Time))
+	assert.Equal(t, int32(1), result.Count)
+	assert.Nil(t, result.Series)
+}
+
+func TestFromEventsApiSeries(t *testing.T) {
+	event := newEventsApiEvent()
+	lastObserved := event.EventTime.Add(time.Minute)
+	event.Series = &eventsapi.EventSeries{
+		Count:            5,
+		LastObservedTime: metav1.NewMicroTime(lastObserved),
+		State:            eventsapi.EventSeriesStateOngoing,
+	}
+	event.DeprecatedSource = kubeapi.EventSource{Component: "kubelet", Host: "node-1"}
+	result := fromEventsApi(event)
+
+	assert.Equal(t, int32(5), result.Count)
+	assert.True(t, lastObserved.Equal(result.LastTimestamp.Time))
+	assert.True(t, event.EventTime.Time.Equal(result.FirstTimestamp.Time))
+	assert.Equal(t, &kubeapi.EventSeries{
+		Count:            5,
+		LastObservedTime: event.Series.LastObservedTime,
+		State:            kubeapi.EventSeriesStateOngoing,
+	}, result.Series)
+	assert.Equal(t, event.DeprecatedSource, result.Source)
+}
+
+func TestEventsApiSource(t *testing.T) {
+	listed := newEventsApiEvent()
+	client := &fakeEventsApiClient{
+		events: eventsapi.EventList{
+			ListMeta: metav1.ListMeta{ResourceVersion: "10"},
+			Items:    []eventsapi.Event{*listed},
+		},
+		watcher: kubewatch.NewFake(),
+	}
+	source := newKubernetesSource(&eventsApiClient{client: client})
+	defer close(source.stopChannel)
+	go source.watch()
+
+	watched := newEventsApiEvent()
+	watched.UID, watched.ResourceVersion, watched.Note = "b", "11", "Started"
+	client.watcher.Add(watched)
+	batch := waitForEvents(t, source, 1)
+	assert.Equal(t, "Started", batch.Events[0].Message)
+	assert.Equal(t, "11", batch.ResourceVersion)
+
+	events, err := source.eventClient.List(metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "10", events.ResourceVersion)
+	assert.Equal(t, []kubeapi.Event{*fromEventsApi(listed)}, events.Items)
+}
@@ -15,17 +15,22 @@
 package kubernetes
 
 import (
+	"fmt"
 	"net/url"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
 
 	kubeapi "k8s.io/api/core/v1"
+	kubeapierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	kubewatch "k8s.io/apimachinery/pkg/watch"
 	kubeclient "k8s.io/client-go/kubernetes"
-	kubev1core "k8s.io/client-go/kubernetes/typed/core/v1"
 	kubeconfig "k8s.io/heapster/common/kubernetes"
 	"k8s.io/heapster/events/core"
 )
@@ -33,6 +38,14 @@ import (
 const (
 	// Number of object pointers. Big enough so it won't be hit anytime soon with reasonable GetNewEvents frequency.
 	LocalEventsBufferSize = 100000
+
+	// What happens to the events watched while the buffer is full.
+	OverflowDrop  = "drop"
+	OverflowBlock = "block"
+	OverflowSpill = "spill"
+	// How long the buffered events are remembered, to skip them when relisting. Longer
+	// than the time to live of the events in the apiserver, one hour by default.
+	seenEventsRetention = 2 * time.Hour
 )
 
 var (
@@ -58,22 +71,115 @@ var (
 			Name:      "duration_milliseconds",
 			Help:      "Time spent scraping events in milliseconds.",
 		})
+	relistsNum = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "eventer",
+			Subsystem: "scraper",
+			Name:      "relists_total_number",
+			Help:      "The number of times the events were relisted because the watch could not resume.",
+		})
+	droppedEventsNum = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "eventer",
+			Subsystem: "scraper",
+			Name:      "dropped_events_total_number",
+			Help:      "The number of events dropped because the buffer, or the spill file, was full.",
+		})
+	spilledEventsNum = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "eventer",
+			Subsystem: "scraper",
+			Name:      "spilled_events_total_number",
+			Help:      "The number of events written to the spill file because the buffer was full.",
+		})
+	watchPausesNum = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "eventer",
+			Subsystem: "scraper",
+			Name:      "watch_pauses_total_number",
+			Help:      "The number of times the watch was paused because the buffer was full.",
+		})
+	bufferedEvents = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "eventer",
+			Subsystem: "scraper",
+			Name:      "buffered_events",
+			Help:      "The number of events waiting to be exported, in the buffer or the spill file, by cluster.",
+		},
+		[]string{"cluster"},
+	)
+	bufferSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "eventer",
+			Subsystem: "scraper",
+			Name:      "buffer_size",
+			Help:      "The number of events the buffer can hold, by cluster.",
+		},
+		[]string{"cluster"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(lastEventTimestamp)
 	prometheus.MustRegister(totalEventsNum)
 	prometheus.MustRegister(scrapEventsDuration)
+	prometheus.MustRegister(relistsNum)
+	prometheus.MustRegister(droppedEventsNum)
+	prometheus.MustRegister(spilledEventsNum)
+	prometheus.MustRegister(watchPausesNum)
+	prometheus.MustRegister(bufferedEvents)
+	prometheus.MustRegister(bufferSize)
+}
+
+// eventClient lists and watches the events as core/v1 events.
+type eventClient interface {
+	List(opts metav1.ListOptions) (*kubeapi.EventList, error)
+	Watch(opts metav1.ListOptions) (kubewatch.Interface, error)
+}
+
+type seenEvent struct {
+	count int32
+	seen  time.Time
 }
 
-// Implements core.EventSource interface.
+// Implements core.AcknowledgingEventSource and core.ResumableEventSource interfaces.
 type KubernetesEventSource struct {
 	// Large local buffer, periodically read.
 	localEventsBuffer chan *kubeapi.Event
+	// One of OverflowDrop, OverflowBlock and OverflowSpill.
+	overflow string
+	// Signaled when the buffer is read.
+	drainedChannel chan struct{}
 
 	stopChannel chan struct{}
+	// Signaled to resume from the checkpoint.
+	resumeChannel chan struct{}
+
+	eventClient eventClient
+
+	// Guards the writes to the buffer along with the fields below.
+	lock sync.Mutex
+	// Resource version of the watch after the last event written to the buffer.
+	queuedVersion string
+	// Count of the events written to the buffer, by UID.
+	seenEvents map[types.UID]seenEvent
+	// Events written while the buffer is full, in OverflowSpill mode. While it is not
+	// empty, the events and the moves of the watch are written to it to keep them in order.
+	spill *spillQueue
+	// Number of records the spill file can hold before events are dropped, 0 if unlimited.
+	spillMaxSize int
+	// Number of events in the buffer and the spill file, labeled with the cluster.
+	bufferedGauge prometheus.Gauge
 
-	eventClient kubev1core.EventInterface
+	// Where the resource version of the exported events is saved, may be nil.
+	checkpoint checkpointStore
+	ackLock    sync.Mutex
+	// Creation time of the last acknowledged batch and the resource version saved.
+	lastAck      time.Time
+	savedVersion string
+	// Set when a batch was not exported. The checkpoint is kept before its events
+	// until the watch resumes from the checkpoint, e.g. when the eventer restarts.
+	unexported bool
 }
 
 func (this *KubernetesEventSource) GetNewEvents() *core.EventBatch {
@@ -86,6 +192,8 @@ func (this *KubernetesEventSource) GetNewEvents() *core.EventBatch {
 		Timestamp: time.Now(),
 		Events:    []*kubeapi.Event{},
 	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
 	// Get all data from the buffer.
 event_loop:
 	for {
@@ -96,24 +204,317 @@ event_loop:
 			break event_loop
 		}
 	}
+	if this.spill != nil && this.spill.Len() > 0 {
+		this.readSpill(&result)
+	}
+	result.ResourceVersion = this.queuedVersion
+	this.bufferedGauge.Set(float64(this.buffered()))
+	select {
+	case this.drainedChannel <- struct{}{}:
+	default:
+	}
+	for uid, event := range this.seenEvents {
+		if startTime.Sub(event.seen) > seenEventsRetention {
+			delete(this.seenEvents, uid)
+		}
+	}
 
 	totalEventsNum.Add(float64(len(result.Events)))
 
 	return &result
 }
 
+// Ack saves the resource version of the batch, from which the watch resumes when
+// the eventer restarts. Once a batch was not exported, the later ones are not saved.
+func (this *KubernetesEventSource) Ack(batch *core.EventBatch, err error) {
+	this.ackLock.Lock()
+	defer this.ackLock.Unlock()
+	if batch.Timestamp.Before(this.lastAck) {
+		// a later batch was already acknowledged
+		return
+	}
+	this.lastAck = batch.Timestamp
+	if err != nil {
+		if this.checkpoint != nil && !this.unexported {
+			glog.Warningf("Keeping the events checkpoint at %s until the watch resumes from it, a batch was not exported: %v",
+				this.savedVersion, err)
+		}
+		this.unexported = true
+		return
+	}
+	if this.unexported || this.checkpoint == nil || batch.ResourceVersion == "" || batch.ResourceVersion == this.savedVersion {
+		return
+	}
+	if err := this.checkpoint.Save(batch.ResourceVersion); err != nil {
+		glog.Errorf("Failed to save the events checkpoint to %v: %v", this.checkpoint, err)
+		return
+	}
+	this.savedVersion = batch.ResourceVersion
+	glog.V(4).Infof("Saved the events checkpoint %s to %v", batch.ResourceVersion, this.checkpoint)
+}
+
+// readSpill moves up to a buffer of spilled events to the batch.
+func (this *KubernetesEventSource) readSpill(batch *core.EventBatch) {
+	records, err := this.spill.Read(cap(this.localEventsBuffer))
+	for _, record := range records {
+		if record.Event != nil {
+			batch.Events = append(batch.Events, record.Event)
+		}
+		if record.ResourceVersion != "" {
+			this.queuedVersion = record.ResourceVersion
+		}
+	}
+	if err != nil {
+		glog.Errorf("Failed to read the spilled events from %v, dropping %d events: %v", this.spill, this.spill.Len(), err)
+		droppedEventsNum.Add(float64(this.spill.Len()))
+		if err := this.spill.Clear(); err != nil {
+			glog.Errorf("Failed to clear the spilled events in %v: %v", this.spill, err)
+		}
+	}
+}
+
+// buffered returns the number of events waiting to be read.
+func (this *KubernetesEventSource) buffered() int {
+	buffered := len(this.localEventsBuffer)
+	if this.spill != nil {
+		buffered += this.spill.Len()
+	}
+	return buffered
+}
+
+// push writes the event to the buffer, moving the position of the watch to
+// resourceVersion unless it is empty. When the buffer is full, the event is dropped,
+// spilled or push waits for the buffer to be read, depending on the overflow mode.
+func (this *KubernetesEventSource) push(event *kubeapi.Event, resourceVersion string) {
+	if this.tryPush(event, resourceVersion) {
+		return
+	}
+	// Pausing the watch, which stops reading from the apiserver until the buffer is read.
+	glog.Warningf("Event buffer full, pausing the watch")
+	watchPausesNum.Inc()
+	for {
+		select {
+		case <-this.drainedChannel:
+		case <-this.stopChannel:
+			return
+		}
+		if this.tryPush(event, resourceVersion) {
+			glog.Infof("Event buffer read, resuming the watch")
+			return
+		}
+	}
+}
+
+// tryPush returns false if the event must wait for the buffer to be read.
+func (this *KubernetesEventSource) tryPush(event *kubeapi.Event, resourceVersion string) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	defer func() {
+		this.bufferedGauge.Set(float64(this.buffered()))
+	}()
+	if this.spill != nil && this.spill.Len() > 0 {
+		this.spillEvent(event, resourceVersion)
+		return true
+	}
+	select {
+	case this.localEventsBuffer <- event:
+		// Ok, buffer not full.
+		if resourceVersion != "" {
+			this.queuedVersion = resourceVersion
+		}
+		this.seenEvents[event.UID] = seenEvent{count: event.Count, seen: time.Now()}
+		return true
+	default:
+	}
+	switch this.overflow {
+	case OverflowBlock:
+		return false
+	case OverflowSpill:
+		this.spillEvent(event, resourceVersion)
+	default:
+		// Buffer full, need to drop the event.
+		glog.Errorf("Event buffer full, dropping event")
+		droppedEventsNum.Inc()
+	}
+	return true
+}
+
+func (this *KubernetesEventSource) spillEvent(event *kubeapi.Event, resourceVersion string) {
+	if this.spill.Len() == 0 {
+		glog.Warningf("Event buffer full, spilling events to %v", this.spill)
+	}
+	if this.spillMaxSize > 0 && this.spill.Len() >= this.spillMaxSize {
+		glog.Errorf("Event buffer and spill %v full, dropping event", this.spill)
+		droppedEventsNum.Inc()
+		return
+	}
+	if err := this.spill.Write(spillRecord{ResourceVersion: resourceVersion, Event: event}); err != nil {
+		glog.Errorf("Failed to spill event to %v, dropping it: %v", this.spill, err)
+		droppedEventsNum.Inc()
+		return
+	}
+	spilledEventsNum.Inc()
+	this.seenEvents[event.UID] = seenEvent{count: event.Count, seen: time.Now()}
+}
+
+// seen returns whether the event was written to the buffer with the same count.
+func (this *KubernetesEventSource) seen(event *kubeapi.Event) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	seen, found := this.seenEvents[event.UID]
+	return found && seen.count >= event.Count
+}
+
+func (this *KubernetesEventSource) setQueuedVersion(resourceVersion string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.spill != nil && this.spill.Len() > 0 {
+		// After the spilled events.
+		if err := this.spill.Write(spillRecord{ResourceVersion: resourceVersion}); err != nil {
+			glog.Errorf("Failed to spill the resource version to %v: %v", this.spill, err)
+		}
+		return
+	}
+	this.queuedVersion = resourceVersion
+}
+
+// exported returns whether the event was last modified at or before the saved
+// checkpoint, and so was exported, e.g. by the eventer before it restarted.
+func (this *KubernetesEventSource) exported(event *kubeapi.Event) bool {
+	this.ackLock.Lock()
+	savedVersion := this.savedVersion
+	this.ackLock.Unlock()
+	return resourceVersionAtOrBefore(event.ResourceVersion, savedVersion)
+}
+
+// resourceVersionAtOrBefore returns whether the resource version a is not later than b.
+// Resource versions are compared as the integers etcd uses, other ones are never ordered.
+func resourceVersionAtOrBefore(a, b string) bool {
+	va, err := strconv.ParseUint(a, 10, 64)
+	if err != nil {
+		return false
+	}
+	vb, err := strconv.ParseUint(b, 10, 64)
+	if err != nil {
+		return false
+	}
+	return va <= vb
+}
+
+// relist lists the events and returns the resource version to watch from. When
+// the watch could not resume, the events which were neither seen nor exported
+// before the checkpoint are written to the buffer, oldest first, since they may
+// have been missed.
+func (this *KubernetesEventSource) relist(missed bool) (string, error) {
+	events, err := this.eventClient.List(metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	if missed {
+		relistsNum.Inc()
+		var unseen []*kubeapi.Event
+		for i := range events.Items {
+			if event := &events.Items[i]; !this.seen(event) && !this.exported(event) {
+				unseen = append(unseen, event)
+			}
+		}
+		sort.SliceStable(unseen, func(i, j int) bool {
+			return unseen[i].LastTimestamp.Before(&unseen[j].LastTimestamp)
+		})
+		glog.Warningf("Relisted %d events, %d of which were not exported yet", len(events.Items), len(unseen))
+		for _, event := range unseen {
+			this.push(event, "")
+		}
+	}
+	// Do not write old events otherwise.
+	this.setQueuedVersion(events.ResourceVersion)
+	return events.ResourceVersion, nil
+}
+
+func isExpired(err error) bool {
+	return kubeapierrors.IsGone(err) || kubeapierrors.IsResourceExpired(err)
+}
+
+// How a watch ended.
+type watchEnd int
+
+const (
+	watchClosed watchEnd = iota
+	// The resource version is too old to resume from.
+	watchExpired
+	watchStopped
+	watchResumed
+)
+
+// loadCheckpoint returns the saved resource version, empty if there is none.
+func (this *KubernetesEventSource) loadCheckpoint() string {
+	if this.checkpoint == nil {
+		return ""
+	}
+	resourceVersion, err := this.checkpoint.Load()
+	if err != nil {
+		glog.Errorf("Failed to load the events checkpoint from %v: %v", this.checkpoint, err)
+		return ""
+	}
+	if resourceVersion != "" {
+		glog.Infof("Resuming the events watch from %s", resourceVersion)
+		this.setQueuedVersion(resourceVersion)
+	}
+	this.ackLock.Lock()
+	defer this.ackLock.Unlock()
+	if resourceVersion != "" {
+		this.savedVersion = resourceVersion
+	}
+	// The events of the batches not exported are watched again.
+	this.unexported = false
+	return resourceVersion
+}
+
+// Resume drops the buffered events and watches again from the saved checkpoint, e.g.
+// when the eventer becomes the leader and continues where the previous one stopped.
+// Without a checkpoint the buffered events are kept and false is returned.
+func (this *KubernetesEventSource) Resume() bool {
+	if this.checkpoint == nil {
+		return false
+	}
+	select {
+	case this.resumeChannel <- struct{}{}:
+	default:
+		// Already resuming.
+	}
+	return true
+}
+
+func (this *KubernetesEventSource) dropBuffered() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for len(this.localEventsBuffer) > 0 {
+		<-this.localEventsBuffer
+	}
+	if this.spill != nil {
+		if err := this.spill.Clear(); err != nil {
+			glog.Errorf("Failed to clear the spilled events in %v: %v", this.spill, err)
+		}
+	}
+	this.seenEvents = make(map[types.UID]seenEvent)
+	this.bufferedGauge.Set(0)
+}
+
 func (this *KubernetesEventSource) watch() {
+	resourceVersion := this.loadCheckpoint()
+	// Whether events may have been missed since resourceVersion.
+	missed := false
+
 	// Outer loop, for reconnections.
 	for {
-		events, err := this.eventClient.List(metav1.ListOptions{})
-		if err != nil {
-			glog.Errorf("Failed to load events: %v", err)
-			time.Sleep(time.Second)
-			continue
+		if resourceVersion == "" {
+			var err error
+			if resourceVersion, err = this.relist(missed); err != nil {
+				glog.Errorf("Failed to load events: %v", err)
+				time.Sleep(time.Second)
+				continue
+			}
 		}
-		// Do not write old events.
-
-		resourceVersion := events.ResourceVersion
 
 		watcher, err := this.eventClient.Watch(
 			metav1.ListOptions{
@@ -121,58 +522,91 @@ func (this *KubernetesEventSource) watch() {
 				ResourceVersion: resourceVersion})
 		if err != nil {
 			glog.Errorf("Failed to start watch for new events: %v", err)
+			if isExpired(err) {
+				resourceVersion, missed = "", true
+				continue
+			}
 			time.Sleep(time.Second)
 			continue
 		}
 
-		watchChannel := watcher.ResultChan()
-		// Inner loop, for update processing.
-	inner_loop:
-		for {
-			select {
-			case watchUpdate, ok := <-watchChannel:
-				if !ok {
-					glog.Errorf("Event watch channel closed")
-					break inner_loop
-				}
+		var end watchEnd
+		resourceVersion, end = this.processWatch(watcher, resourceVersion)
+		watcher.Stop()
+		switch end {
+		case watchStopped:
+			return
+		case watchExpired:
+			glog.Warningf("Event watch can not resume from %s, relisting", resourceVersion)
+			resourceVersion, missed = "", true
+		case watchResumed:
+			if this.checkpoint == nil {
+				// Nothing to resume from.
+				continue
+			}
+			this.dropBuffered()
+			resourceVersion, missed = this.loadCheckpoint(), true
+		}
+	}
+}
 
-				if watchUpdate.Type == kubewatch.Error {
-					if status, ok := watchUpdate.Object.(*metav1.Status); ok {
-						glog.Errorf("Error during watch: %#v", status)
-						break inner_loop
-					}
-					glog.Errorf("Received unexpected error: %#v", watchUpdate.Object)
-					break inner_loop
-				}
+// processWatch writes the watched events to the buffer until the watch ends. It returns
+// the resource version to resume from, and how the watch ended.
+func (this *KubernetesEventSource) processWatch(watcher kubewatch.Interface, resourceVersion string) (string, watchEnd) {
+	watchChannel := watcher.ResultChan()
+	// Inner loop, for update processing.
+	for {
+		select {
+		case watchUpdate, ok := <-watchChannel:
+			if !ok {
+				glog.Errorf("Event watch channel closed")
+				return resourceVersion, watchClosed
+			}
 
-				if event, ok := watchUpdate.Object.(*kubeapi.Event); ok {
-					switch watchUpdate.Type {
-					case kubewatch.Added, kubewatch.Modified:
-						select {
-						case this.localEventsBuffer <- event:
-							// Ok, buffer not full.
-						default:
-							// Buffer full, need to drop the event.
-							glog.Errorf("Event buffer full, dropping event")
-						}
-					case kubewatch.Deleted:
-						// Deleted events are silently ignored.
-					default:
-						glog.Warningf("Unknown watchUpdate.Type: %#v", watchUpdate.Type)
+			if watchUpdate.Type == kubewatch.Error {
+				if status, ok := watchUpdate.Object.(*metav1.Status); ok {
+					glog.Errorf("Error during watch: %#v", status)
+					if isExpired(&kubeapierrors.StatusError{ErrStatus: *status}) {
+						return resourceVersion, watchExpired
 					}
-				} else {
-					glog.Errorf("Wrong object received: %v", watchUpdate)
+					return resourceVersion, watchClosed
 				}
+				glog.Errorf("Received unexpected error: %#v", watchUpdate.Object)
+				return resourceVersion, watchClosed
+			}
 
-			case <-this.stopChannel:
-				glog.Infof("Event watching stopped")
-				return
+			if event, ok := watchUpdate.Object.(*kubeapi.Event); ok {
+				switch watchUpdate.Type {
+				case kubewatch.Added, kubewatch.Modified:
+					this.push(event, event.ResourceVersion)
+				case kubewatch.Deleted:
+					// Deleted events are silently ignored.
+				default:
+					glog.Warningf("Unknown watchUpdate.Type: %#v", watchUpdate.Type)
+				}
+				resourceVersion = event.ResourceVersion
+			} else {
+				glog.Errorf("Wrong object received: %v", watchUpdate)
 			}
+
+		case <-this.resumeChannel:
+			glog.Infof("Resuming the event watch from the checkpoint")
+			return resourceVersion, watchResumed
+
+		case <-this.stopChannel:
+			glog.Infof("Event watching stopped")
+			return resourceVersion, watchStopped
 		}
 	}
 }
 
 func NewKubernetesSource(uri *url.URL) (*KubernetesEventSource, error) {
+	return newSourceForUri(uri, func(kubeClient kubeclient.Interface) eventClient {
+		return kubeClient.CoreV1().Events(kubeapi.NamespaceAll)
+	})
+}
+
+func newSourceForUri(uri *url.URL, newEventClient func(kubeclient.Interface) eventClient) (*KubernetesEventSource, error) {
 	kubeConfig, err := kubeconfig.GetKubeClientConfig(uri)
 	if err != nil {
 		return nil, err
@@ -181,12 +615,74 @@ func NewKubernetesSource(uri *url.URL) (*KubernetesEventSource, error) {
 	if err != nil {
 		return nil, err
 	}
-	eventClient := kubeClient.CoreV1().Events(kubeapi.NamespaceAll)
-	result := KubernetesEventSource{
+	result := newKubernetesSource(newEventClient(kubeClient))
+
+	opts := uri.Query()
+	if len(opts["checkpoint_file"]) > 0 && len(opts["checkpoint_configmap"]) > 0 {
+		return nil, fmt.Errorf("only one of `checkpoint_file` and `checkpoint_configmap` can be set")
+	}
+	if len(opts["checkpoint_file"]) > 0 {
+		result.checkpoint = &fileCheckpointStore{path: opts["checkpoint_file"][0]}
+	}
+	if len(opts["checkpoint_configmap"]) > 0 {
+		store, err := newConfigMapCheckpointStore(kubeClient.CoreV1(), opts["checkpoint_configmap"][0])
+		if err != nil {
+			return nil, err
+		}
+		result.checkpoint = store
+	}
+
+	size := LocalEventsBufferSize
+	if len(opts["buffer_size"]) > 0 {
+		size, err = strconv.Atoi(opts["buffer_size"][0])
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid `buffer_size` flag %q", opts["buffer_size"][0])
+		}
+	}
+	result.localEventsBuffer = make(chan *kubeapi.Event, size)
+	cluster := kubeconfig.GetClusterName(uri)
+	bufferSize.WithLabelValues(cluster).Set(float64(size))
+	result.bufferedGauge = bufferedEvents.WithLabelValues(cluster)
+	if len(opts["buffer_overflow"]) > 0 {
+		result.overflow = opts["buffer_overflow"][0]
+	}
+	switch result.overflow {
+	case OverflowDrop, OverflowBlock:
+		if len(opts["spill_file"]) > 0 {
+			return nil, fmt.Errorf("`spill_file` requires `buffer_overflow=%s`", OverflowSpill)
+		}
+		if len(opts["spill_max_size"]) > 0 {
+			return nil, fmt.Errorf("`spill_max_size` requires `buffer_overflow=%s`", OverflowSpill)
+		}
+	case OverflowSpill:
+		if len(opts["spill_file"]) == 0 {
+			return nil, fmt.Errorf("`buffer_overflow=%s` requires `spill_file`", OverflowSpill)
+		}
+		if len(opts["spill_max_size"]) > 0 {
+			result.spillMaxSize, err = strconv.Atoi(opts["spill_max_size"][0])
+			if err != nil || result.spillMaxSize < 0 {
+				return nil, fmt.Errorf("invalid `spill_max_size` flag %q", opts["spill_max_size"][0])
+			}
+		}
+		if result.spill, err = newSpillQueue(opts["spill_file"][0]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid `buffer_overflow` flag %q", result.overflow)
+	}
+	go result.watch()
+	return result, nil
+}
+
+func newKubernetesSource(eventClient eventClient) *KubernetesEventSource {
+	return &KubernetesEventSource{
 		localEventsBuffer: make(chan *kubeapi.Event, LocalEventsBufferSize),
+		overflow:          OverflowDrop,
+		drainedChannel:    make(chan struct{}, 1),
 		stopChannel:       make(chan struct{}),
+		resumeChannel:     make(chan struct{}, 1),
 		eventClient:       eventClient,
+		seenEvents:        make(map[types.UID]seenEvent),
+		bufferedGauge:     bufferedEvents.WithLabelValues(""),
 	}
-	go result.watch()
-	return &result, nil
 }
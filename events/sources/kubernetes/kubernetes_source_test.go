@@ -0,0 +1,523 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kubeapi "k8s.io/api/core/v1"
+	kubeapierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kubewatch "k8s.io/apimachinery/pkg/watch"
+	kubev1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/heapster/events/core"
+)
+
+type fakeEventClient struct {
+	kubev1core.EventInterface
+	lock          sync.Mutex
+	events        kubeapi.EventList
+	lists         int
+	watchVersions []string
+	watchers      chan *kubewatch.FakeWatcher
+}
+
+func newFakeEventClient(resourceVersion string, events ...kubeapi.Event) *fakeEventClient {
+	return &fakeEventClient{
+		events: kubeapi.EventList{
+			ListMeta: metav1.ListMeta{ResourceVersion: resourceVersion},
+			Items:    events,
+		},
+		watchers: make(chan *kubewatch.FakeWatcher),
+	}
+}
+
+func (c *fakeEventClient) List(opts metav1.ListOptions) (*kubeapi.EventList, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lists++
+	return &c.events, nil
+}
+
+func (c *fakeEventClient) Watch(opts metav1.ListOptions) (kubewatch.Interface, error) {
+	c.lock.Lock()
+	c.watchVersions = append(c.watchVersions, opts.ResourceVersion)
+	c.lock.Unlock()
+	return <-c.watchers, nil
+}
+
+func (c *fakeEventClient) calls() (int, []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lists, append([]string{}, c.watchVersions...)
+}
+
+func newEvent(uid string, count int32, resourceVersion string) *kubeapi.Event {
+	return &kubeapi.Event{
+		ObjectMeta: metav1.ObjectMeta{UID: types.UID(uid), ResourceVersion: resourceVersion},
+		Count:      count,
+	}
+}
+
+// startWatch starts watching and returns the first watcher.
+func startWatch(source *KubernetesEventSource, client *fakeEventClient) *kubewatch.FakeWatcher {
+	go source.watch()
+	watcher := kubewatch.NewFake()
+	client.watchers <- watcher
+	return watcher
+}
+
+// waitForEvents reads batches until count events are read.
+func waitForEvents(t *testing.T, source *KubernetesEventSource, count int) *core.EventBatch {
+	result := &core.EventBatch{}
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		batch := source.GetNewEvents()
+		result.Timestamp = batch.Timestamp
+		result.ResourceVersion = batch.ResourceVersion
+		result.Events = append(result.Events, batch.Events...)
+		if len(result.Events) >= count {
+			return result
+		}
+	}
+	t.Fatalf("expected %d events, got %d", count, len(result.Events))
+	return nil
+}
+
+func TestCheckpointKeptBeforeUnexportedBatch(t *testing.T) {
+	store, cleanup := tempCheckpoint(t)
+	defer cleanup()
+	source := newKubernetesSource(newFakeEventClient(""))
+	source.checkpoint = store
+
+	now := time.Now()
+	source.Ack(&core.EventBatch{Timestamp: now, ResourceVersion: "20"}, nil)
+	source.Ack(&core.EventBatch{Timestamp: now.Add(time.Minute), ResourceVersion: "30"}, fmt.Errorf("failed"))
+	source.Ack(&core.EventBatch{Timestamp: now.Add(2 * time.Minute), ResourceVersion: "40"}, nil)
+
+	resourceVersion, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "20", resourceVersion)
+
+	// Once the watch resumed from the checkpoint, the batches are saved again.
+	assert.Equal(t, "20", source.loadCheckpoint())
+	source.Ack(&core.EventBatch{Timestamp: now.Add(3 * time.Minute), ResourceVersion: "50"}, nil)
+	resourceVersion, err = store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "50", resourceVersion)
+}
+
+func tempCheckpoint(t *testing.T) (*fileCheckpointStore, func()) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(t, err)
+	return &fileCheckpointStore{path: filepath.Join(dir, "resourceVersion")}, func() { os.RemoveAll(dir) }
+}
+
+func TestWatchWithoutCheckpoint(t *testing.T) {
+	store, cleanup := tempCheckpoint(t)
+	defer cleanup()
+	client := newFakeEventClient("10", *newEvent("a", 1, "9"))
+	source := newKubernetesSource(client)
+	source.checkpoint = store
+	defer close(source.stopChannel)
+
+	watcher := startWatch(source, client)
+	watcher.Add(newEvent("b", 1, "11"))
+	batch := waitForEvents(t, source, 1)
+	assert.Equal(t, types.UID("b"), batch.Events[0].UID)
+	assert.Equal(t, "11", batch.ResourceVersion)
+
+	source.Ack(batch, nil)
+	resourceVersion, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "11", resourceVersion)
+
+	// The watch reconnects from the last event without relisting.
+	watcher.Stop()
+	client.watchers <- kubewatch.NewFake()
+	lists, watchVersions := client.calls()
+	assert.Equal(t, 1, lists)
+	assert.Equal(t, []string{"10", "11"}, watchVersions)
+}
+
+func TestWatchResumesFromCheckpoint(t *testing.T) {
+	store, cleanup := tempCheckpoint(t)
+	defer cleanup()
+	require.NoError(t, store.Save("42"))
+	client := newFakeEventClient("50", *newEvent("a", 1, "41"))
+	source := newKubernetesSource(client)
+	source.checkpoint = store
+	defer close(source.stopChannel)
+
+	watcher := startWatch(source, client)
+	watcher.Add(newEvent("b", 1, "43"))
+	batch := waitForEvents(t, source, 1)
+	assert.Equal(t, types.UID("b"), batch.Events[0].UID)
+	assert.Equal(t, "43", batch.ResourceVersion)
+
+	lists, watchVersions := client.calls()
+	assert.Equal(t, 0, lists)
+	assert.Equal(t, []string{"42"}, watchVersions)
+}
+
+func TestRelistWhenExpired(t *testing.T) {
+	store, cleanup := tempCheckpoint(t)
+	defer cleanup()
+	require.NoError(t, store.Save("42"))
+	client := newFakeEventClient("50",
+		*newEvent("a", 1, "43"),
+		*newEvent("b", 2, "44"),
+		*newEvent("c", 1, "45"))
+	source := newKubernetesSource(client)
+	source.checkpoint = store
+	defer close(source.stopChannel)
+
+	watcher := startWatch(source, client)
+	watcher.Add(newEvent("a", 1, "43"))
+	watcher.Add(newEvent("b", 1, "44"))
+	waitForEvents(t, source, 2)
+
+	watcher.Error(&metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   410,
+		Reason: metav1.StatusReasonExpired,
+	})
+	client.watchers <- kubewatch.NewFake()
+	// The event seen with the same count is skipped.
+	batch := waitForEvents(t, source, 2)
+	assert.Len(t, batch.Events, 2)
+	assert.Equal(t, types.UID("b"), batch.Events[0].UID)
+	assert.Equal(t, int32(2), batch.Events[0].Count)
+	assert.Equal(t, types.UID("c"), batch.Events[1].UID)
+	assert.Equal(t, "50", batch.ResourceVersion)
+
+	lists, watchVersions := client.calls()
+	assert.Equal(t, 1, lists)
+	assert.Equal(t, []string{"42", "50"}, watchVersions)
+}
+
+func TestRelistAfterRestartSkipsExportedEvents(t *testing.T) {
+	store, cleanup := tempCheckpoint(t)
+	defer cleanup()
+	// The eventer exported up to 44 before it restarted.
+	require.NoError(t, store.Save("44"))
+	client := newFakeEventClient("50",
+		*newEvent("a", 1, "43"),
+		*newEvent("b", 2, "44"),
+		*newEvent("c", 1, "45"))
+	source := newKubernetesSource(client)
+	source.checkpoint = store
+	defer close(source.stopChannel)
+
+	// The checkpoint expired while the eventer was down.
+	watcher := startWatch(source, client)
+	watcher.Error(&metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   410,
+		Reason: metav1.StatusReasonExpired,
+	})
+	client.watchers <- kubewatch.NewFake()
+	batch := waitForEvents(t, source, 1)
+	assert.Len(t, batch.Events, 1)
+	assert.Equal(t, types.UID("c"), batch.Events[0].UID)
+	assert.Equal(t, "50", batch.ResourceVersion)
+
+	lists, watchVersions := client.calls()
+	assert.Equal(t, 1, lists)
+	assert.Equal(t, []string{"44", "50"}, watchVersions)
+}
+
+func TestAckIgnoresOlderBatches(t *testing.T) {
+	store, cleanup := tempCheckpoint(t)
+	defer cleanup()
+	source := newKubernetesSource(newFakeEventClient(""))
+	source.checkpoint = store
+
+	now := time.Now()
+	source.Ack(&core.EventBatch{Timestamp: now, ResourceVersion: "20"}, nil)
+	source.Ack(&core.EventBatch{Timestamp: now.Add(-time.Minute), ResourceVersion: "10"}, nil)
+	source.Ack(&core.EventBatch{Timestamp: now.Add(time.Minute)}, nil)
+
+	resourceVersion, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "20", resourceVersion)
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	store, cleanup := tempCheckpoint(t)
+	defer cleanup()
+	client := newFakeEventClient("40")
+	source := newKubernetesSource(client)
+	source.checkpoint = store
+	defer close(source.stopChannel)
+
+	// A standby reads and drops the events.
+	watcher := startWatch(source, client)
+	watcher.Add(newEvent("a", 1, "41"))
+	watcher.Add(newEvent("b", 1, "43"))
+	waitForEvents(t, source, 2)
+
+	// The leader exported up to 42 before the standby took over.
+	require.NoError(t, store.Save("42"))
+	source.Resume()
+	watcher = kubewatch.NewFake()
+	client.watchers <- watcher
+	// The event already read by the standby is read again.
+	watcher.Add(newEvent("b", 1, "43"))
+	batch := waitForEvents(t, source, 1)
+	assert.Len(t, batch.Events, 1)
+	assert.Equal(t, types.UID("b"), batch.Events[0].UID)
+	assert.Equal(t, "43", batch.ResourceVersion)
+
+	lists, watchVersions := client.calls()
+	assert.Equal(t, 1, lists)
+	assert.Equal(t, []string{"40", "42"}, watchVersions)
+}
+
+func fillBuffer(source *KubernetesEventSource, watcher *kubewatch.FakeWatcher) {
+	watcher.Add(newEvent("a", 1, "11"))
+	watcher.Add(newEvent("b", 1, "12"))
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		source.lock.Lock()
+		full := len(source.localEventsBuffer) == cap(source.localEventsBuffer)
+		source.lock.Unlock()
+		if full {
+			return
+		}
+	}
+}
+
+func TestBufferOverflowDrop(t *testing.T) {
+	client := newFakeEventClient("10")
+	source := newKubernetesSource(client)
+	source.localEventsBuffer = make(chan *kubeapi.Event, 2)
+	defer close(source.stopChannel)
+
+	watcher := startWatch(source, client)
+	fillBuffer(source, watcher)
+	watcher.Add(newEvent("c", 1, "13"))
+	watcher.Add(newEvent("d", 1, "14"))
+	// The watch goes on after the dropped events.
+	batch := waitForEvents(t, source, 2)
+	assert.Equal(t, []types.UID{"a", "b"}, uids(batch))
+	watcher.Add(newEvent("e", 1, "15"))
+	batch = waitForEvents(t, source, 1)
+	assert.Equal(t, types.UID("e"), batch.Events[0].UID)
+	assert.Equal(t, "15", batch.ResourceVersion)
+}
+
+func TestBufferOverflowBlock(t *testing.T) {
+	client := newFakeEventClient("10")
+	source := newKubernetesSource(client)
+	source.localEventsBuffer = make(chan *kubeapi.Event, 2)
+	source.overflow = OverflowBlock
+	defer close(source.stopChannel)
+
+	watcher := startWatch(source, client)
+	fillBuffer(source, watcher)
+	added := make(chan struct{})
+	go func() {
+		watcher.Add(newEvent("c", 1, "13"))
+		watcher.Add(newEvent("d", 1, "14"))
+		close(added)
+	}()
+
+	// The watch is paused until the buffer is read.
+	batch := waitForEvents(t, source, 2)
+	assert.Equal(t, "12", batch.ResourceVersion)
+	<-added
+	batch = waitForEvents(t, source, 2)
+	assert.Equal(t, types.UID("c"), batch.Events[0].UID)
+	assert.Equal(t, types.UID("d"), batch.Events[1].UID)
+	assert.Equal(t, "14", batch.ResourceVersion)
+}
+
+func TestBufferOverflowSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	client := newFakeEventClient("10")
+	source := newKubernetesSource(client)
+	source.localEventsBuffer = make(chan *kubeapi.Event, 2)
+	source.overflow = OverflowSpill
+	source.spill, err = newSpillQueue(filepath.Join(dir, "events"))
+	require.NoError(t, err)
+	defer close(source.stopChannel)
+
+	watcher := startWatch(source, client)
+	fillBuffer(source, watcher)
+	for i, uid := range []string{"c", "d", "e"} {
+		watcher.Add(newEvent(uid, 1, strconv.Itoa(13+i)))
+	}
+	// The relisted events are spilled too, and the resource version of the list follows them.
+	client.lock.Lock()
+	client.events.Items = []kubeapi.Event{*newEvent("a", 1, "11"), *newEvent("f", 1, "16")}
+	client.events.ResourceVersion = "20"
+	client.lock.Unlock()
+	watcher.Error(&metav1.Status{
+		Status: metav1.StatusFailure,
+		Code:   410,
+		Reason: metav1.StatusReasonExpired,
+	})
+	watcher = kubewatch.NewFake()
+	client.watchers <- watcher
+
+	// The events are read in order, at most a buffer of spilled events at a time.
+	batch := waitForEvents(t, source, 4)
+	require.Len(t, batch.Events, 4)
+	assert.Equal(t, []types.UID{"a", "b", "c", "d"}, uids(batch))
+	assert.Equal(t, "14", batch.ResourceVersion)
+	batch = waitForEvents(t, source, 2)
+	assert.Equal(t, []types.UID{"e", "f"}, uids(batch))
+	assert.Equal(t, "15", batch.ResourceVersion)
+	batch = source.GetNewEvents()
+	assert.Empty(t, batch.Events)
+	assert.Equal(t, "20", batch.ResourceVersion)
+
+	// The buffer is used again once the spill file is empty.
+	assert.Equal(t, 0, source.spill.Len())
+	watcher.Add(newEvent("g", 1, "21"))
+	batch = waitForEvents(t, source, 1)
+	assert.Equal(t, "21", batch.ResourceVersion)
+	source.lock.Lock()
+	assert.Equal(t, 0, source.spill.Len())
+	source.lock.Unlock()
+}
+
+func metricValue(t *testing.T, metric prometheus.Metric) float64 {
+	value := &dto.Metric{}
+	require.NoError(t, metric.Write(value))
+	return value.GetGauge().GetValue() + value.GetCounter().GetValue()
+}
+
+func TestSpillMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	client := newFakeEventClient("10")
+	source := newKubernetesSource(client)
+	source.localEventsBuffer = make(chan *kubeapi.Event, 2)
+	source.overflow = OverflowSpill
+	source.spillMaxSize = 2
+	source.spill, err = newSpillQueue(filepath.Join(dir, "events"))
+	require.NoError(t, err)
+	defer close(source.stopChannel)
+
+	dropped := metricValue(t, droppedEventsNum)
+	watcher := startWatch(source, client)
+	fillBuffer(source, watcher)
+	for i, uid := range []string{"c", "d", "e"} {
+		watcher.Add(newEvent(uid, 1, strconv.Itoa(13+i)))
+	}
+	// The event received once the spill file is full is dropped.
+	for start := time.Now(); time.Since(start) < 5*time.Second && metricValue(t, droppedEventsNum) == dropped; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, dropped+1, metricValue(t, droppedEventsNum))
+	assert.Equal(t, 4.0, metricValue(t, source.bufferedGauge))
+
+	batch := waitForEvents(t, source, 4)
+	assert.Equal(t, []types.UID{"a", "b", "c", "d"}, uids(batch))
+	assert.Equal(t, "14", batch.ResourceVersion)
+	assert.Empty(t, source.GetNewEvents().Events)
+}
+
+func uids(batch *core.EventBatch) []types.UID {
+	result := []types.UID{}
+	for _, event := range batch.Events {
+		result = append(result, event.UID)
+	}
+	return result
+}
+
+func TestInvalidBufferOptions(t *testing.T) {
+	for _, options := range []string{
+		"buffer_size=0",
+		"buffer_size=x",
+		"buffer_overflow=wait",
+		"buffer_overflow=spill",
+		"spill_file=/tmp/events",
+		"spill_max_size=10",
+		"buffer_overflow=spill&spill_file=/tmp/events&spill_max_size=x",
+	} {
+		uri, err := url.Parse("http://localhost:8080?inClusterConfig=false&" + options)
+		require.NoError(t, err)
+		_, err = NewKubernetesSource(uri)
+		assert.Error(t, err, options)
+	}
+}
+
+type fakeConfigMapClient struct {
+	kubev1core.ConfigMapInterface
+	configMaps map[string]*kubeapi.ConfigMap
+}
+
+func (c *fakeConfigMapClient) ConfigMaps(namespace string) kubev1core.ConfigMapInterface {
+	return c
+}
+
+func (c *fakeConfigMapClient) Get(name string, options metav1.GetOptions) (*kubeapi.ConfigMap, error) {
+	configMap, found := c.configMaps[name]
+	if !found {
+		return nil, kubeapierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+	}
+	return configMap.DeepCopy(), nil
+}
+
+func (c *fakeConfigMapClient) Create(configMap *kubeapi.ConfigMap) (*kubeapi.ConfigMap, error) {
+	c.configMaps[configMap.Name] = configMap
+	return configMap, nil
+}
+
+func (c *fakeConfigMapClient) Update(configMap *kubeapi.ConfigMap) (*kubeapi.ConfigMap, error) {
+	c.configMaps[configMap.Name] = configMap
+	return configMap, nil
+}
+
+func TestConfigMapCheckpointStore(t *testing.T) {
+	client := &fakeConfigMapClient{configMaps: map[string]*kubeapi.ConfigMap{}}
+	store, err := newConfigMapCheckpointStore(client, "kube-system/eventer-checkpoint")
+	require.NoError(t, err)
+	assert.Equal(t, "ConfigMap kube-system/eventer-checkpoint", store.String())
+
+	resourceVersion, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "", resourceVersion)
+
+	assert.NoError(t, store.Save("10"))
+	assert.Equal(t, "kube-system", client.configMaps["eventer-checkpoint"].Namespace)
+	assert.NoError(t, store.Save("11"))
+	resourceVersion, err = store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "11", resourceVersion)
+
+	for _, configMap := range []string{"eventer-checkpoint", "kube-system/", "a/b/c"} {
+		_, err := newConfigMapCheckpointStore(client, configMap)
+		assert.Error(t, err, configMap)
+	}
+}
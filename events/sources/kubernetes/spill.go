@@ -0,0 +1,114 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+
+	kubeapi "k8s.io/api/core/v1"
+)
+
+// spillRecord is an event, or a move of the watch position without an event.
+type spillRecord struct {
+	ResourceVersion string         `json:"resourceVersion,omitempty"`
+	Event           *kubeapi.Event `json:"event,omitempty"`
+}
+
+// spillQueue is a FIFO of records in a local file, used when the buffer is full. It is
+// emptied by truncating the file once all its records are read. Not thread safe.
+type spillQueue struct {
+	path   string
+	writer *os.File
+	file   *os.File
+	reader *bufio.Reader
+	// Number of records written and not read yet.
+	size int
+}
+
+// newSpillQueue creates an empty queue, dropping the records spilled by a previous run.
+func newSpillQueue(path string) (*spillQueue, error) {
+	writer, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	return &spillQueue{
+		path:   path,
+		writer: writer,
+		file:   file,
+		reader: bufio.NewReader(file),
+	}, nil
+}
+
+func (q *spillQueue) Len() int {
+	return q.size
+}
+
+func (q *spillQueue) Write(record spillRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := q.writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	q.size++
+	return nil
+}
+
+// Read returns up to max records, oldest first.
+func (q *spillQueue) Read(max int) ([]spillRecord, error) {
+	records := []spillRecord{}
+	for len(records) < max && q.size > 0 {
+		line, err := q.reader.ReadBytes('\n')
+		if err != nil {
+			return records, err
+		}
+		q.size--
+		var record spillRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+	if q.size == 0 {
+		return records, q.Clear()
+	}
+	return records, nil
+}
+
+// Clear drops all the records.
+func (q *spillQueue) Clear() error {
+	q.size = 0
+	if err := q.writer.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := q.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	q.reader.Reset(q.file)
+	return nil
+}
+
+func (q *spillQueue) String() string {
+	return "file " + q.path
+}
@@ -93,6 +93,14 @@ var (
 		Key:         "volume_name",
 		Description: "The name of the volume.",
 	}
+	LabelPersistentVolumeClaimName = LabelDescriptor{
+		Key:         "pvc_name",
+		Description: "The name of the persistent volume claim backing the volume.",
+	}
+	LabelPersistentVolumeClaimNamespace = LabelDescriptor{
+		Key:         "pvc_namespace",
+		Description: "The namespace of the persistent volume claim backing the volume.",
+	}
 	LabelAcceleratorMake = LabelDescriptor{
 		Key:         "make",
 		Description: "Make of the accelerator (nvidia, amd, google etc.)",
@@ -105,6 +113,10 @@ var (
 		Key:         "accelerator_id",
 		Description: "ID of the accelerator",
 	}
+	LabelClusterName = LabelDescriptor{
+		Key:         "cluster",
+		Description: "The name of the cluster, set when Heapster collects several clusters.",
+	}
 )
 
 type LabelDescriptor struct {
@@ -121,6 +133,11 @@ var commonLabels = []LabelDescriptor{
 	LabelHostID,
 }
 
+// Set only when the cluster name is configured, e.g. when collecting several clusters.
+var clusterLabels = []LabelDescriptor{
+	LabelClusterName,
+}
+
 var containerLabels = []LabelDescriptor{
 	LabelContainerName,
 	LabelContainerBaseImage,
@@ -137,6 +154,12 @@ var metricLabels = []LabelDescriptor{
 	LabelResourceID,
 }
 
+var volumeLabels = []LabelDescriptor{
+	LabelVolumeName,
+	LabelPersistentVolumeClaimName,
+	LabelPersistentVolumeClaimNamespace,
+}
+
 var customMetricLabels = []LabelDescriptor{
 	LabelCustomMetricName,
 }
@@ -191,9 +214,23 @@ func MetricLabels() []LabelDescriptor {
 	return result
 }
 
+func VolumeLabels() []LabelDescriptor {
+	result := make([]LabelDescriptor, len(volumeLabels))
+	copy(result, volumeLabels)
+	return result
+}
+
+func ClusterLabels() []LabelDescriptor {
+	result := make([]LabelDescriptor, len(clusterLabels))
+	copy(result, clusterLabels)
+	return result
+}
+
 func SupportedLabels() []LabelDescriptor {
 	result := CommonLabels()
+	result = append(result, ClusterLabels()...)
 	result = append(result, PodLabels()...)
+	result = append(result, VolumeLabels()...)
 	return append(result, MetricLabels()...)
 }
 
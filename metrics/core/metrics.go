@@ -85,9 +85,15 @@ var LabeledMetrics = []Metric{
 	MetricFilesystemAvailable,
 	MetricFilesystemInodes,
 	MetricFilesystemInodesFree,
+	MetricFilesystemInodesUsed,
 	MetricAcceleratorMemoryTotal,
 	MetricAcceleratorMemoryUsed,
 	MetricAcceleratorDutyCycle,
+	MetricVolumeUsage,
+	MetricVolumeCapacity,
+	MetricVolumeAvailable,
+	MetricVolumeInodes,
+	MetricVolumeInodesFree,
 }
 
 var NodeAutoscalingMetrics = []Metric{
@@ -105,6 +111,15 @@ var NodeAutoscalingMetrics = []Metric{
 	MetricNodeEphemeralStorageReservation,
 }
 
+// Provided by the Kubelet summary API and the node status.
+var NodeStatusMetrics = []Metric{
+	MetricProcessLimit,
+	MetricProcessCount,
+	MetricNodeMemoryPressure,
+	MetricNodeDiskPressure,
+	MetricNodePIDPressure,
+}
+
 var CpuMetrics = []Metric{
 	MetricCpuLimit,
 	MetricCpuRequest,
@@ -122,6 +137,14 @@ var FilesystemMetrics = []Metric{
 	MetricFilesystemUsage,
 	MetricFilesystemInodes,
 	MetricFilesystemInodesFree,
+	MetricFilesystemInodesUsed,
+}
+var VolumeMetrics = []Metric{
+	MetricVolumeUsage,
+	MetricVolumeCapacity,
+	MetricVolumeAvailable,
+	MetricVolumeInodes,
+	MetricVolumeInodesFree,
 }
 var MemoryMetrics = []Metric{
 	MetricMemoryLimit,
@@ -187,8 +210,8 @@ func MetricFamilyForName(metricName string) MetricFamily {
 	return MetricFamilyGeneral
 }
 
-var AllMetrics = append(append(append(append(StandardMetrics, AdditionalMetrics...), RateMetrics...), LabeledMetrics...),
-	NodeAutoscalingMetrics...)
+var AllMetrics = append(append(append(append(append(StandardMetrics, AdditionalMetrics...), RateMetrics...), LabeledMetrics...),
+	NodeAutoscalingMetrics...), NodeStatusMetrics...)
 
 // Definition of Standard Metrics.
 var MetricUptime = Metric{
@@ -729,6 +752,56 @@ var MetricNodeEphemeralStorageReservation = Metric{
 	},
 }
 
+var MetricProcessLimit = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "process/limit",
+		Description: "Maximum number of processes (PIDs) on the node",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricProcessCount = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "process/count",
+		Description: "Number of processes running on the node",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricNodeMemoryPressure = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/memory_pressure",
+		Description: "Whether the node reports the MemoryPressure condition (1) or not (0)",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricNodeDiskPressure = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/disk_pressure",
+		Description: "Whether the node reports the DiskPressure condition (1) or not (0)",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricNodePIDPressure = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/pid_pressure",
+		Description: "Whether the node reports the PIDPressure condition (1) or not (0)",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
 // Labeled metrics
 
 var MetricFilesystemUsage = Metric{
@@ -890,6 +963,18 @@ var MetricFilesystemInodesFree = Metric{
 	},
 }
 
+// Reported only by the summary source, where it is the number of inodes consumed by the container.
+var MetricFilesystemInodesUsed = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "filesystem/inodes_used",
+		Description: "Number of inodes used on a filesystem",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		Labels:      metricLabels,
+	},
+}
+
 var MetricAcceleratorMemoryTotal = Metric{
 	MetricDescriptor: MetricDescriptor{
 		Name:        "accelerator/memory_total",
@@ -1001,6 +1086,63 @@ var MetricAcceleratorDutyCycle = Metric{
 	},
 }
 
+// Volume metrics are reported only by the summary source, keyed by volume name.
+
+var MetricVolumeUsage = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "volume/usage",
+		Description: "Total number of bytes consumed on a pod volume",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+		Labels:      volumeLabels,
+	},
+}
+
+var MetricVolumeCapacity = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "volume/capacity",
+		Description: "The total size of a pod volume in bytes",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+		Labels:      volumeLabels,
+	},
+}
+
+var MetricVolumeAvailable = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "volume/available",
+		Description: "The number of available bytes remaining on a pod volume",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+		Labels:      volumeLabels,
+	},
+}
+
+var MetricVolumeInodes = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "volume/inodes",
+		Description: "Total number of inodes on a pod volume",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		Labels:      volumeLabels,
+	},
+}
+
+var MetricVolumeInodesFree = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "volume/inodes_free",
+		Description: "Free number of inodes on a pod volume",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		Labels:      volumeLabels,
+	},
+}
+
 var MetricDiskIORead = Metric{
 	MetricDescriptor: MetricDescriptor{
 		Name:        "disk/io_read_bytes",
@@ -82,22 +82,37 @@ func main() {
 		glog.Fatal(err)
 	}
 
-	kubernetesUrl, err := getKubernetesAddress(opt.Sources)
-	if err != nil {
-		glog.Fatalf("Failed to get kubernetes address: %v", err)
-	}
-	sourceManager := createSourceManagerOrDie(opt.Sources)
-	sinkManager, metricSink, historicalSource := createAndInitSinksOrDie(opt.Sinks, opt.HistoricalSource, opt.SinkExportDataTimeout, opt.DisableMetricSink)
-
-	podLister, nodeLister := getListersOrDie(kubernetesUrl)
-	dataProcessors := createDataProcessorsOrDie(kubernetesUrl, podLister, labelCopier)
+	sourceManagers := createSourceManagersOrDie(opt.Sources, opt.ScrapeConcurrency)
+	multiCluster := len(sourceManagers) > 1
+	sinkManager, metricSink, historicalSource := createAndInitSinksOrDie(opt.Sinks, opt.HistoricalSource, opt.SinkExportDataTimeout, opt.DisableMetricSink, multiCluster)
+
+	// One processing chain per cluster, sharing the sinks. The metric sink and the APIs
+	// serve the first cluster.
+	var podLister v1listers.PodLister
+	var nodeLister v1listers.NodeLister
+	for i, sourceManager := range sourceManagers {
+		kubernetesUrl := &opt.Sources[i].Val
+		clusterPodLister, clusterNodeLister := getListersOrDie(kubernetesUrl)
+		dataProcessors := createDataProcessorsOrDie(kubernetesUrl, clusterPodLister, labelCopier)
+
+		sink := sinkManager
+		if i == 0 {
+			podLister, nodeLister = clusterPodLister, clusterNodeLister
+			if multiCluster && metricSink != nil {
+				sink, err = sinks.NewDataSinkManager([]core.DataSink{sinkManager, metricSink}, opt.SinkExportDataTimeout, sinks.DefaultSinkStopTimeout)
+				if err != nil {
+					glog.Fatalf("Failed to create sink manager: %v", err)
+				}
+			}
+		}
 
-	man, err := manager.NewManager(sourceManager, dataProcessors, sinkManager,
-		opt.MetricResolution, manager.DefaultScrapeOffset, manager.DefaultMaxParallelism)
-	if err != nil {
-		glog.Fatalf("Failed to create main manager: %v", err)
+		man, err := manager.NewManager(sourceManager, dataProcessors, sink,
+			opt.MetricResolution, manager.ClusterScrapeOffset(i, len(sourceManagers), opt.MetricResolution), manager.DefaultMaxParallelism)
+		if err != nil {
+			glog.Fatalf("Failed to create main manager: %v", err)
+		}
+		man.Start()
 	}
-	man.Start()
 
 	if opt.EnableAPIServer {
 		// Run API server in a separate goroutine
@@ -174,23 +189,27 @@ func startSecureServing(opt *options.HeapsterRunOptions, handler http.Handler, p
 	}
 }
 
-func createSourceManagerOrDie(src flags.Uris) core.MetricsSource {
-	if len(src) != 1 {
-		glog.Fatal("Wrong number of sources specified")
-	}
+// createSourceManagersOrDie creates a source manager for each source, i.e. for each cluster.
+func createSourceManagersOrDie(src flags.Uris, scrapeConcurrency int) []core.MetricsSource {
 	sourceFactory := sources.NewSourceFactory()
-	sourceProvider, err := sourceFactory.BuildAll(src)
+	sourceProviders, err := sourceFactory.BuildAll(src)
 	if err != nil {
 		glog.Fatalf("Failed to create source provide: %v", err)
 	}
-	sourceManager, err := sources.NewSourceManager(sourceProvider, sources.DefaultMetricsScrapeTimeout)
-	if err != nil {
-		glog.Fatalf("Failed to create source manager: %v", err)
+	sourceManagers := []core.MetricsSource{}
+	for _, sourceProvider := range sourceProviders {
+		sourceManager, err := sources.NewSourceManager(sourceProvider, sources.DefaultMetricsScrapeTimeout, scrapeConcurrency)
+		if err != nil {
+			glog.Fatalf("Failed to create source manager: %v", err)
+		}
+		sourceManagers = append(sourceManagers, sourceManager)
 	}
-	return sourceManager
+	return sourceManagers
 }
 
-func createAndInitSinksOrDie(sinkAddresses flags.Uris, historicalSource string, sinkExportDataTimeout time.Duration, disableMetricSink bool) (core.DataSink, *metricsink.MetricSink, core.HistoricalSource) {
+// createAndInitSinksOrDie returns a manager of the sinks, which leaves out the metric
+// sink with several clusters.
+func createAndInitSinksOrDie(sinkAddresses flags.Uris, historicalSource string, sinkExportDataTimeout time.Duration, disableMetricSink bool, multiCluster bool) (core.DataSink, *metricsink.MetricSink, core.HistoricalSource) {
 	sinksFactory := sinks.NewSinkFactory()
 	metricSink, sinkList, histSource := sinksFactory.BuildAll(sinkAddresses, historicalSource, disableMetricSink)
 	if metricSink == nil && !disableMetricSink {
@@ -202,6 +221,15 @@ func createAndInitSinksOrDie(sinkAddresses flags.Uris, historicalSource string,
 	for _, sink := range sinkList {
 		glog.Infof("Starting with %s", sink.Name())
 	}
+	if multiCluster && metricSink != nil {
+		sharedSinks := []core.DataSink{}
+		for _, sink := range sinkList {
+			if sink != core.DataSink(metricSink) {
+				sharedSinks = append(sharedSinks, sink)
+			}
+		}
+		sinkList = sharedSinks
+	}
 	sinkManager, err := sinks.NewDataSinkManager(sinkList, sinkExportDataTimeout, sinks.DefaultSinkStopTimeout)
 	if err != nil {
 		glog.Fatalf("Failed to create sink manager: %v", err)
@@ -259,6 +287,14 @@ func createDataProcessorsOrDie(kubernetesUrl *url.URL, podLister v1listers.PodLi
 		core.MetricMemoryLimit.Name,
 	}
 
+	volumeMetricsToAggregate := []string{
+		core.MetricVolumeUsage.Name,
+		core.MetricVolumeCapacity.Name,
+		core.MetricVolumeAvailable.Name,
+		core.MetricVolumeInodes.Name,
+		core.MetricVolumeInodesFree.Name,
+	}
+
 	metricsToAggregateForNode := []string{
 		core.MetricCpuRequest.Name,
 		core.MetricCpuLimit.Name,
@@ -271,7 +307,8 @@ func createDataProcessorsOrDie(kubernetesUrl *url.URL, podLister v1listers.PodLi
 	dataProcessors = append(dataProcessors,
 		processors.NewPodAggregator(),
 		&processors.NamespaceAggregator{
-			MetricsToAggregate: metricsToAggregate,
+			MetricsToAggregate:       metricsToAggregate,
+			VolumeMetricsToAggregate: volumeMetricsToAggregate,
 		},
 		&processors.NodeAggregator{
 			MetricsToAggregate: metricsToAggregateForNode,
@@ -285,6 +322,11 @@ func createDataProcessorsOrDie(kubernetesUrl *url.URL, podLister v1listers.PodLi
 		glog.Fatalf("Failed to create NodeAutoscalingEnricher: %v", err)
 	}
 	dataProcessors = append(dataProcessors, nodeAutoscalingEnricher)
+
+	// After the aggregators, to label the metric sets they add too.
+	if clusterName := kube_config.GetClusterName(kubernetesUrl); clusterName != "" {
+		dataProcessors = append(dataProcessors, &processors.ClusterLabeler{ClusterName: clusterName})
+	}
 	return dataProcessors
 }
 
@@ -313,17 +355,6 @@ func healthzChecker(metricSink *metricsink.MetricSink) healthz.HealthzChecker {
 	})
 }
 
-// Gets the address of the kubernetes source from the list of source URIs.
-// Possible kubernetes sources are: 'kubernetes' and 'kubernetes.summary_api'
-func getKubernetesAddress(args flags.Uris) (*url.URL, error) {
-	for _, uri := range args {
-		if strings.SplitN(uri.Key, ".", 2)[0] == "kubernetes" {
-			return &uri.Val, nil
-		}
-	}
-	return nil, fmt.Errorf("No kubernetes source found.")
-}
-
 func getPodLister(kubeClient *kube_client.Clientset) (v1listers.PodLister, error) {
 	lw := cache.NewListWatchFromClient(kubeClient.CoreV1().RESTClient(), "pods", kube_api.NamespaceAll, fields.Everything())
 	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
@@ -45,6 +45,16 @@ func init() {
 	prometheus.MustRegister(processorDuration)
 }
 
+// ClusterScrapeOffset returns the scrape offset of the i-th of several clusters. The offsets
+// are spread over the resolution, so that the clusters do not export to the shared sinks
+// at the same time.
+func ClusterScrapeOffset(i, clusters int, resolution time.Duration) time.Duration {
+	if clusters <= 1 || resolution <= DefaultScrapeOffset {
+		return DefaultScrapeOffset
+	}
+	return DefaultScrapeOffset + (resolution-DefaultScrapeOffset)*time.Duration(i)/time.Duration(clusters)
+}
+
 type Manager interface {
 	Start()
 	Stop()
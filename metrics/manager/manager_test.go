@@ -55,3 +55,21 @@ func TestThrottling(t *testing.T) {
 		t.Fatalf("Wrong number of exports executed: %d", sink.GetExportCount())
 	}
 }
+
+func TestClusterScrapeOffset(t *testing.T) {
+	resolution := time.Minute
+	if offset := ClusterScrapeOffset(0, 1, resolution); offset != DefaultScrapeOffset {
+		t.Fatalf("Wrong scrape offset of a single cluster: %v", offset)
+	}
+
+	expected := []time.Duration{5 * time.Second, 23333333333, 41666666666}
+	for i, want := range expected {
+		if offset := ClusterScrapeOffset(i, 3, resolution); offset != want {
+			t.Fatalf("Wrong scrape offset of cluster %d: %v, expected %v", i, offset, want)
+		}
+	}
+
+	if offset := ClusterScrapeOffset(1, 2, 5*time.Second); offset != DefaultScrapeOffset {
+		t.Fatalf("Wrong scrape offset at the minimum resolution: %v", offset)
+	}
+}
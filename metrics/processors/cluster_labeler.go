@@ -0,0 +1,37 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import "k8s.io/heapster/metrics/core"
+
+// ClusterLabeler adds the name of the cluster to all the metric sets, when Heapster
+// collects several clusters.
+type ClusterLabeler struct {
+	ClusterName string
+}
+
+func (this *ClusterLabeler) Name() string {
+	return "cluster_labeler"
+}
+
+func (this *ClusterLabeler) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels == nil {
+			metricSet.Labels = make(map[string]string)
+		}
+		metricSet.Labels[core.LabelClusterName.Key] = this.ClusterName
+	}
+	return batch, nil
+}
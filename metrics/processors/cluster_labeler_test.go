@@ -0,0 +1,47 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestClusterLabeler(t *testing.T) {
+	batch := core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.ClusterKey(): clusterMetricSet(),
+			core.NodeKey("node1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+					core.LabelNodename.Key:      "node1",
+				},
+			},
+			core.PodKey("ns1", "pod1"): {},
+		},
+	}
+	processor := ClusterLabeler{ClusterName: "east"}
+	result, err := processor.Process(&batch)
+	assert.NoError(t, err)
+	for key, metricSet := range result.MetricSets {
+		assert.Equal(t, "east", metricSet.Labels[core.LabelClusterName.Key], key)
+	}
+	assert.Equal(t, "node1", result.MetricSets[core.NodeKey("node1")].Labels[core.LabelNodename.Key])
+}
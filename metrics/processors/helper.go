@@ -46,3 +46,38 @@ func aggregate(src, dst *core.MetricSet, metricsToAggregate []string) error {
 	}
 	return nil
 }
+
+// aggregateVolumeClaims copies the per-claim volume metrics of src into dst. A claim can be mounted by
+// several pods, so values are deduplicated by claim name rather than summed.
+func aggregateVolumeClaims(src, dst *core.MetricSet, metricsToAggregate []string) {
+	for _, metricName := range metricsToAggregate {
+		for _, metric := range src.LabeledMetrics {
+			if metric.Name != metricName {
+				continue
+			}
+			claimName, found := metric.Labels[core.LabelPersistentVolumeClaimName.Key]
+			if !found {
+				continue
+			}
+			claimMetric := core.LabeledMetric{
+				Name: metric.Name,
+				Labels: map[string]string{
+					core.LabelPersistentVolumeClaimName.Key:      claimName,
+					core.LabelPersistentVolumeClaimNamespace.Key: metric.Labels[core.LabelPersistentVolumeClaimNamespace.Key],
+				},
+				MetricValue: metric.MetricValue,
+			}
+			replaced := false
+			for i, existing := range dst.LabeledMetrics {
+				if existing.Name == metric.Name && existing.Labels[core.LabelPersistentVolumeClaimName.Key] == claimName {
+					dst.LabeledMetrics[i] = claimMetric
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				dst.LabeledMetrics = append(dst.LabeledMetrics, claimMetric)
+			}
+		}
+	}
+}
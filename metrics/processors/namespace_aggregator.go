@@ -21,6 +21,8 @@ import (
 
 type NamespaceAggregator struct {
 	MetricsToAggregate []string
+	// Labeled volume metrics propagated to the namespace for every persistent volume claim.
+	VolumeMetricsToAggregate []string
 }
 
 func (this *NamespaceAggregator) Name() string {
@@ -54,6 +56,7 @@ func (this *NamespaceAggregator) Process(batch *core.DataBatch) (*core.DataBatch
 		if err := aggregate(metricSet, namespace, this.MetricsToAggregate); err != nil {
 			return nil, err
 		}
+		aggregateVolumeClaims(metricSet, namespace, this.VolumeMetricsToAggregate)
 
 	}
 	for key, val := range namespaces {
@@ -82,3 +82,76 @@ func TestNamespaceAggregate(t *testing.T) {
 	assert.True(t, found)
 	assert.Equal(t, int64(30), m3.IntValue)
 }
+
+func TestNamespaceAggregateVolumeClaims(t *testing.T) {
+	claimLabels := map[string]string{
+		core.LabelVolumeName.Key:                     "data",
+		core.LabelPersistentVolumeClaimName.Key:      "claim1",
+		core.LabelPersistentVolumeClaimNamespace.Key: "ns1",
+	}
+	batch := core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues: map[string]core.MetricValue{},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:   core.MetricVolumeUsage.Name,
+						Labels: claimLabels,
+						MetricValue: core.MetricValue{
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricGauge,
+							IntValue:   100,
+						},
+					},
+					{
+						Name:   core.MetricVolumeUsage.Name,
+						Labels: map[string]string{core.LabelVolumeName.Key: "scratch"},
+						MetricValue: core.MetricValue{
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricGauge,
+							IntValue:   5,
+						},
+					},
+				},
+			},
+			core.PodKey("ns1", "pod2"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues: map[string]core.MetricValue{},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:   core.MetricVolumeUsage.Name,
+						Labels: claimLabels,
+						MetricValue: core.MetricValue{
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricGauge,
+							IntValue:   100,
+						},
+					},
+				},
+			},
+		},
+	}
+	processor := NamespaceAggregator{
+		VolumeMetricsToAggregate: []string{core.MetricVolumeUsage.Name},
+	}
+	result, err := processor.Process(&batch)
+	assert.NoError(t, err)
+	namespace, found := result.MetricSets[core.NamespaceKey("ns1")]
+	assert.True(t, found)
+
+	// The claim is shared by both pods and the scratch volume has no claim.
+	assert.Equal(t, 1, len(namespace.LabeledMetrics))
+	usage := namespace.LabeledMetrics[0]
+	assert.Equal(t, core.MetricVolumeUsage.Name, usage.Name)
+	assert.Equal(t, "claim1", usage.Labels[core.LabelPersistentVolumeClaimName.Key])
+	assert.Equal(t, "ns1", usage.Labels[core.LabelPersistentVolumeClaimNamespace.Key])
+	assert.Equal(t, int64(100), usage.IntValue)
+}
@@ -137,6 +137,7 @@ func (this *PodBasedEnricher) addPodInfo(key string, podMs *core.MetricSet, pod
 		podMs.EntityCreateTime = pod.Status.StartTime.Time
 	}
 	this.labelCopier.Copy(pod.Labels, podMs.Labels)
+	addVolumeClaimInfo(podMs, pod)
 
 	// Add cpu/mem requests and limits to containers
 	for _, container := range pod.Spec.Containers {
@@ -169,6 +170,33 @@ func (this *PodBasedEnricher) addPodInfo(key string, podMs *core.MetricSet, pod
 	}
 }
 
+// addVolumeClaimInfo labels volume metrics with the persistent volume claim referenced in the pod spec,
+// for kubelets that don't report the claim in the summary API.
+func addVolumeClaimInfo(podMs *core.MetricSet, pod *kube_api.Pod) {
+	claims := make(map[string]string)
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claims[volume.Name] = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	if len(claims) == 0 {
+		return
+	}
+	for _, metric := range podMs.LabeledMetrics {
+		volumeName, found := metric.Labels[core.LabelVolumeName.Key]
+		if !found {
+			continue
+		}
+		if _, found := metric.Labels[core.LabelPersistentVolumeClaimName.Key]; found {
+			continue
+		}
+		if claimName, found := claims[volumeName]; found {
+			metric.Labels[core.LabelPersistentVolumeClaimName.Key] = claimName
+			metric.Labels[core.LabelPersistentVolumeClaimNamespace.Key] = pod.Namespace
+		}
+	}
+}
+
 func updateContainerResourcesAndLimits(metricSet *core.MetricSet, container kube_api.Container) {
 	requests := container.Resources.Requests
 
@@ -191,3 +191,72 @@ func checkLimits(t *testing.T, ms *core.MetricSet, cpu, mem int64, storage int64
 	assert.True(t, found)
 	assert.Equal(t, storage, storageVal.IntValue)
 }
+
+func TestPodEnricherVolumeClaims(t *testing.T) {
+	pod := kube_api.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pod1",
+			Namespace: "ns1",
+		},
+		Spec: kube_api.PodSpec{
+			Volumes: []kube_api.Volume{
+				{
+					Name: "data",
+					VolumeSource: kube_api.VolumeSource{
+						PersistentVolumeClaim: &kube_api.PersistentVolumeClaimVolumeSource{ClaimName: "claim1"},
+					},
+				},
+				{
+					Name: "scratch",
+					VolumeSource: kube_api.VolumeSource{
+						EmptyDir: &kube_api.EmptyDirVolumeSource{},
+					},
+				},
+			},
+		},
+	}
+
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	podLister := v1listers.NewPodLister(store)
+	store.Add(&pod)
+	labelCopier, err := util.NewLabelCopier(",", []string{}, []string{})
+	assert.NoError(t, err)
+
+	podBasedEnricher := PodBasedEnricher{
+		podLister:   podLister,
+		labelCopier: labelCopier,
+	}
+
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelPodName.Key:       "pod1",
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues: map[string]core.MetricValue{},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:   core.MetricVolumeUsage.Name,
+						Labels: map[string]string{core.LabelVolumeName.Key: "data"},
+					},
+					{
+						Name:   core.MetricVolumeUsage.Name,
+						Labels: map[string]string{core.LabelVolumeName.Key: "scratch"},
+					},
+				},
+			},
+		},
+	}
+	batch, err = podBasedEnricher.Process(batch)
+	assert.NoError(t, err)
+
+	podMs := batch.MetricSets[core.PodKey("ns1", "pod1")]
+	data := podMs.LabeledMetrics[0].Labels
+	assert.Equal(t, "claim1", data[core.LabelPersistentVolumeClaimName.Key])
+	assert.Equal(t, "ns1", data[core.LabelPersistentVolumeClaimNamespace.Key])
+	_, found := podMs.LabeledMetrics[1].Labels[core.LabelPersistentVolumeClaimName.Key]
+	assert.False(t, found)
+}
@@ -32,6 +32,7 @@ type elasticSearchSink struct {
 	esSvc     esCommon.ElasticSearchService
 	saveData  SaveDataFunc
 	flushData func() error
+	stopData  func() error
 	sync.RWMutex
 }
 
@@ -150,7 +151,12 @@ func (sink *elasticSearchSink) Name() string {
 }
 
 func (sink *elasticSearchSink) Stop() {
-	// nothing needs to be done.
+	if sink.stopData == nil {
+		return
+	}
+	if err := sink.stopData(); err != nil {
+		glog.Warningf("Failed to flush data to ElasticSearch sink on stop: %v", err)
+	}
 }
 
 func NewElasticSearchSink(uri *url.URL) (core.DataSink, error) {
@@ -168,6 +174,9 @@ func NewElasticSearchSink(uri *url.URL) (core.DataSink, error) {
 	esSink.flushData = func() error {
 		return esSvc.FlushData()
 	}
+	esSink.stopData = func() error {
+		return esSvc.Stop()
+	}
 
 	glog.V(2).Info("ElasticSearch sink setup successfully")
 	return &esSink, nil
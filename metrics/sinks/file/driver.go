@@ -0,0 +1,190 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	file_common "k8s.io/heapster/common/file"
+	"k8s.io/heapster/metrics/core"
+)
+
+// Columns of the CSV segments. The labels column holds the labels as a JSON object.
+var csvHeader = []string{"timestamp", "metric_set", "metric", "value", "labels"}
+
+// fileRecord is a line of the newline-delimited JSON segments. The labels are the labels of the
+// metric set and, for labeled metrics, the labels of the metric.
+type fileRecord struct {
+	Timestamp time.Time         `json:"timestamp"`
+	MetricSet string            `json:"metric_set"`
+	Metric    string            `json:"metric"`
+	Value     interface{}       `json:"value"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type fileSink struct {
+	writer *file_common.RotatingWriter
+	format string
+}
+
+func (sink *fileSink) Name() string {
+	return "File Sink"
+}
+
+func (sink *fileSink) Stop() {
+	if err := sink.writer.Close(); err != nil {
+		glog.Errorf("Failed to close the file sink: %v", err)
+	}
+}
+
+func (sink *fileSink) ExportData(dataBatch *core.DataBatch) {
+	records := batchToRecords(dataBatch)
+	if len(records) == 0 {
+		return
+	}
+	var data []byte
+	var err error
+	if sink.format == file_common.FormatCSV {
+		data, err = encodeCSV(records)
+	} else {
+		data, err = encodeJSON(records)
+	}
+	if err != nil {
+		glog.Errorf("Failed to encode metrics: %v", err)
+		return
+	}
+	if err := sink.writer.Write(data); err != nil {
+		glog.Errorf("Failed to write metrics: %v", err)
+	}
+}
+
+// batchToRecords returns a record per metric value, sorted by metric set and metric name.
+func batchToRecords(dataBatch *core.DataBatch) []fileRecord {
+	keys := make([]string, 0, len(dataBatch.MetricSets))
+	for key := range dataBatch.MetricSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	records := []fileRecord{}
+	for _, key := range keys {
+		metricSet := dataBatch.MetricSets[key]
+		names := make([]string, 0, len(metricSet.MetricValues))
+		for name := range metricSet.MetricValues {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value := metricSet.MetricValues[name]
+			records = append(records, fileRecord{
+				Timestamp: dataBatch.Timestamp,
+				MetricSet: key,
+				Metric:    name,
+				Value:     value.GetValue(),
+				Labels:    metricSet.Labels,
+			})
+		}
+		for _, metric := range metricSet.LabeledMetrics {
+			labels := make(map[string]string, len(metricSet.Labels)+len(metric.Labels))
+			for k, v := range metricSet.Labels {
+				labels[k] = v
+			}
+			for k, v := range metric.Labels {
+				labels[k] = v
+			}
+			records = append(records, fileRecord{
+				Timestamp: dataBatch.Timestamp,
+				MetricSet: key,
+				Metric:    metric.Name,
+				Value:     metric.GetValue(),
+				Labels:    labels,
+			})
+		}
+	}
+	return records
+}
+
+func encodeJSON(records []fileRecord) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+func encodeCSV(records []fileRecord) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	for _, record := range records {
+		labels, err := json.Marshal(record.Labels)
+		if err != nil {
+			return nil, err
+		}
+		var value string
+		switch v := record.Value.(type) {
+		case float64:
+			value = strconv.FormatFloat(v, 'g', -1, 64)
+		case int64:
+			value = strconv.FormatInt(v, 10)
+		}
+		writer.Write([]string{
+			record.Timestamp.UTC().Format(time.RFC3339Nano),
+			record.MetricSet,
+			record.Metric,
+			value,
+			string(labels),
+		})
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+func csvHeaderLine() []byte {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write(csvHeader)
+	writer.Flush()
+	return buffer.Bytes()
+}
+
+func NewFileSink(uri *url.URL) (core.DataSink, error) {
+	config, err := file_common.BuildConfig(uri, "metrics")
+	if err != nil {
+		return nil, err
+	}
+	var header []byte
+	if config.Format == file_common.FormatCSV {
+		header = csvHeaderLine()
+	}
+	writer, err := file_common.NewRotatingWriter(*config, header)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("created file sink writing %s segments to %s", config.Format, config.Dir)
+	return &fileSink{
+		writer: writer,
+		format: config.Format,
+	}, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/heapster/metrics/core"
+)
+
+func testBatch() *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC),
+		MetricSets: map[string]*core.MetricSet{
+			"node:n1": {
+				Labels: map[string]string{core.LabelNodename.Key: "n1"},
+				MetricValues: map[string]core.MetricValue{
+					"memory/usage":   {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 1024},
+					"cpu/usage_rate": {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 0.5},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        "filesystem/usage",
+						Labels:      map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 7},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	data, err := encodeJSON(batchToRecords(testBatch()))
+	require.NoError(t, err)
+	assert.Equal(t,
+		`{"timestamp":"2017-05-01T10:00:00Z","metric_set":"node:n1","metric":"cpu/usage_rate","value":0.5,"labels":{"nodename":"n1"}}`+"\n"+
+			`{"timestamp":"2017-05-01T10:00:00Z","metric_set":"node:n1","metric":"memory/usage","value":1024,"labels":{"nodename":"n1"}}`+"\n"+
+			`{"timestamp":"2017-05-01T10:00:00Z","metric_set":"node:n1","metric":"filesystem/usage","value":7,"labels":{"nodename":"n1","resource_id":"/dev/sda1"}}`+"\n",
+		string(data))
+}
+
+func TestEncodeCSV(t *testing.T) {
+	data, err := encodeCSV(batchToRecords(testBatch()))
+	require.NoError(t, err)
+	assert.Equal(t, "timestamp,metric_set,metric,value,labels\n", string(csvHeaderLine()))
+	assert.Equal(t,
+		`2017-05-01T10:00:00Z,node:n1,cpu/usage_rate,0.5,"{""nodename"":""n1""}"`+"\n"+
+			`2017-05-01T10:00:00Z,node:n1,memory/usage,1024,"{""nodename"":""n1""}"`+"\n"+
+			`2017-05-01T10:00:00Z,node:n1,filesystem/usage,7,"{""nodename"":""n1"",""resource_id"":""/dev/sda1""}"`+"\n",
+		string(data))
+}
+
+func TestExportData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "heapster-file-sink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	uri, _ := url.Parse(dir + "?format=csv")
+	sink, err := NewFileSink(uri)
+	require.NoError(t, err)
+	sink.ExportData(testBatch())
+	sink.ExportData(&core.DataBatch{Timestamp: time.Now(), MetricSets: map[string]*core.MetricSet{}})
+	sink.Stop()
+
+	segments, err := sink.(*fileSink).writer.Segments()
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	data, err := ioutil.ReadFile(segments[0])
+	require.NoError(t, err)
+	expected, _ := encodeCSV(batchToRecords(testBatch()))
+	assert.Equal(t, string(csvHeaderLine())+string(expected), string(data))
+}
@@ -29,9 +29,11 @@ import (
 )
 
 const (
-	DefaultHost   = "localhost"
-	DefaultPort   = 2003
-	DefaultPrefix = "kubernetes"
+	DefaultHost            = "localhost"
+	DefaultPort            = 2003
+	DefaultPicklePort      = 2004
+	DefaultPrefix          = "kubernetes"
+	DefaultPickleBatchSize = 500
 )
 
 type graphiteClient interface {
@@ -45,6 +47,8 @@ type graphiteMetric struct {
 	value     core.MetricValue
 	labels    map[string]string
 	timestamp int64
+	// Path format configured by the sink options, nil for the default hierarchy.
+	format *pathFormat
 }
 
 var escapeFieldReplacer = strings.NewReplacer(".", "_", "/", "_")
@@ -54,6 +58,15 @@ func escapeField(f string) string {
 }
 
 func (m *graphiteMetric) Path() string {
+	if m.format != nil {
+		if m.format.tagged {
+			return m.format.taggedPath(m.name, m.labels)
+		}
+		if tmpl, found := m.format.templates[m.labels[core.LabelMetricSetType.Key]]; found {
+			return tmpl.expand(m.name, m.labels)
+		}
+	}
+
 	var metricPath string
 	if resourceId, ok := m.labels["resourceId"]; ok {
 		nameParts := strings.Split(m.name, "/")
@@ -121,6 +134,7 @@ func (m *graphiteMetric) Metric() graphite.Metric {
 
 type Sink struct {
 	client graphiteClient
+	format *pathFormat
 	sync.RWMutex
 }
 
@@ -133,22 +147,46 @@ func NewGraphiteSink(uri *url.URL) (core.DataSink, error) {
 		host = DefaultHost
 	}
 	port := DefaultPort
+	if uri.Scheme == "pickle" {
+		port = DefaultPicklePort
+	}
 	if portString != "" {
 		if port, err = strconv.Atoi(portString); err != nil {
 			return nil, err
 		}
 	}
 
-	prefix := uri.Query().Get("prefix")
+	opts := uri.Query()
+	prefix := opts.Get("prefix")
 	if prefix == "" {
 		prefix = DefaultPrefix
 	}
 
+	format, err := getPathFormat(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if uri.Scheme == "pickle" {
+		batchSize := DefaultPickleBatchSize
+		if len(opts["batch_size"]) >= 1 {
+			batchSize, err = strconv.Atoi(opts["batch_size"][0])
+			if err != nil || batchSize <= 0 {
+				return nil, fmt.Errorf("invalid batch_size %q: must be a positive integer", opts["batch_size"][0])
+			}
+		}
+		client := newPickleClient(net.JoinHostPort(host, strconv.Itoa(port)), prefix, batchSize)
+		if err := client.Connect(); err != nil {
+			return nil, err
+		}
+		return &Sink{client: client, format: format}, nil
+	}
+
 	client, err := graphite.GraphiteFactory(uri.Scheme, host, port, prefix)
 	if err != nil {
 		return nil, err
 	}
-	return &Sink{client: client}, nil
+	return &Sink{client: client, format: format}, nil
 }
 
 func (s *Sink) Name() string {
@@ -167,6 +205,7 @@ func (s *Sink) ExportData(dataBatch *core.DataBatch) {
 				value:     metricValue,
 				labels:    metricSet.Labels,
 				timestamp: dataBatch.Timestamp.Unix(),
+				format:    s.format,
 			}
 			metrics = append(metrics, m.Metric())
 		}
@@ -184,6 +223,7 @@ func (s *Sink) ExportData(dataBatch *core.DataBatch) {
 					value:     metric.MetricValue,
 					labels:    labels,
 					timestamp: dataBatch.Timestamp.Unix(),
+					format:    s.format,
 				}
 				metrics = append(metrics, m.Metric())
 			}
@@ -15,11 +15,17 @@
 package graphite
 
 import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/url"
 	"testing"
 
 	"k8s.io/heapster/metrics/core"
 
+	"github.com/marpaia/graphite-golang"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var metricsTestCases = []struct {
@@ -119,3 +125,130 @@ func TestGraphitePathMetrics(t *testing.T) {
 		assert.Equal(t, c.value, m.Value)
 	}
 }
+
+func TestGraphiteTemplatePaths(t *testing.T) {
+	opts, err := url.ParseQuery("template_pod=k8s.{cluster}.{namespace_name}.{pod_name}.{metric}&template_node={metric}.by-node.{nodename}")
+	require.NoError(t, err)
+	format, err := getPathFormat(opts)
+	require.NoError(t, err)
+
+	pod := graphiteMetric{
+		name:  "filesystem/usage",
+		value: core.MetricValue{IntValue: 100, ValueType: core.ValueInt64},
+		labels: map[string]string{
+			"type":           "pod",
+			"namespace_name": "namespace",
+			"pod_name":       "pod.name",
+		},
+		format: format,
+	}
+	assert.Equal(t, "k8s.unknown.namespace.pod_name.filesystem.usage", pod.Path())
+
+	node := graphiteMetric{
+		name:   "cpu/usage",
+		labels: map[string]string{"type": "node", "nodename": "node1"},
+		format: format,
+	}
+	assert.Equal(t, "cpu.usage.by-node.node1", node.Path())
+
+	// Types without a template keep the default hierarchy.
+	namespace := graphiteMetric{
+		name:   "cpu/usage",
+		labels: map[string]string{"type": "ns", "namespace_name": "namespace"},
+		format: format,
+	}
+	assert.Equal(t, "namespaces.namespace.cpu.usage", namespace.Path())
+}
+
+func TestGraphiteTaggedPaths(t *testing.T) {
+	opts, err := url.ParseQuery("tagged=true")
+	require.NoError(t, err)
+	format, err := getPathFormat(opts)
+	require.NoError(t, err)
+
+	m := graphiteMetric{
+		name: "filesystem/usage",
+		labels: map[string]string{
+			"type":           "pod",
+			"namespace_name": "namespace",
+			"pod_name":       "pod-name-12345",
+			"labels":         "app:web;tier:front end",
+			"resource_id":    "/dev/sda1",
+			"hostname":       "",
+		},
+		format: format,
+	}
+	assert.Equal(t, "filesystem.usage;labels=app:web_tier:front_end;namespace_name=namespace;pod_name=pod-name-12345;resource_id=/dev/sda1;type=pod", m.Path())
+}
+
+func TestGraphiteInvalidPathOptions(t *testing.T) {
+	for _, options := range []string{
+		"tagged=maybe",
+		"template_pod=pods.{pod_name}",
+		"template_pod=pods.{}.{metric}",
+		"template_pods={metric}",
+		"template_pod={metric}.{pod_name",
+		"tagged=true&template_pod={metric}",
+	} {
+		opts, err := url.ParseQuery(options)
+		require.NoError(t, err)
+		_, err = getPathFormat(opts)
+		assert.Error(t, err, options)
+	}
+
+	format, err := getPathFormat(url.Values{})
+	assert.NoError(t, err)
+	assert.Nil(t, format)
+}
+
+func TestPickleClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	messages := make(chan []byte, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			header := make([]byte, 4)
+			if _, err := io.ReadFull(conn, header); err != nil {
+				close(messages)
+				return
+			}
+			message := make([]byte, binary.BigEndian.Uint32(header))
+			if _, err := io.ReadFull(conn, message); err != nil {
+				close(messages)
+				return
+			}
+			messages <- message
+		}
+	}()
+
+	client := newPickleClient(listener.Addr().String(), "k8s", 2)
+	require.NoError(t, client.Connect())
+	require.NoError(t, client.SendMetrics([]graphite.Metric{
+		graphite.NewMetric("a", "1", 1),
+		graphite.NewMetric("b", "2.5", 2),
+		graphite.NewMetric("c", "3", 3),
+	}))
+	require.NoError(t, client.Disconnect())
+
+	first := <-messages
+	second := <-messages
+	assert.Equal(t, []byte{
+		0x80, 2, ']', '(',
+		'X', 5, 0, 0, 0, 'k', '8', 's', '.', 'c',
+		'J', 3, 0, 0, 0,
+		'G', 0x40, 0x08, 0, 0, 0, 0, 0, 0,
+		0x86, 0x86,
+		'e', '.',
+	}, second)
+	// The first batch holds two metrics.
+	assert.Equal(t, 4+2*(1+4+5+1+4+1+8+2)+2, len(first))
+	_, more := <-messages
+	assert.False(t, more)
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphite
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	// Placeholder of the metric name in path templates.
+	metricPlaceholder = "metric"
+	// Replaces the placeholders of missing labels.
+	missingLabelValue = "unknown"
+	// Prefix of the options holding the path template of a MetricSet type.
+	templateOptionPrefix = "template_"
+)
+
+var (
+	placeholderRegexp = regexp.MustCompile(`\{([^{}]*)\}`)
+	// Characters that are not allowed in the tag names and values of Graphite tagged series.
+	tagNameReplacer  = strings.NewReplacer(";", "_", "!", "_", "^", "_", "=", "_", " ", "_", "~", "_")
+	tagValueReplacer = strings.NewReplacer(";", "_", "~", "_", " ", "_")
+)
+
+// pathFormat describes how metric paths are built when the default hierarchy is not used.
+type pathFormat struct {
+	// Send Graphite 1.1 tagged series with all the labels as tags.
+	tagged bool
+	// Path templates by MetricSet type.
+	templates map[string]*pathTemplate
+}
+
+// pathTemplate is a metric path with {label} placeholders, e.g. nodes.{nodename}.{metric}.
+type pathTemplate struct {
+	template string
+}
+
+func parsePathTemplate(template string) (*pathTemplate, error) {
+	hasMetric := false
+	for _, match := range placeholderRegexp.FindAllStringSubmatch(template, -1) {
+		if match[1] == "" {
+			return nil, fmt.Errorf("empty placeholder in path template %q", template)
+		}
+		if match[1] == metricPlaceholder {
+			hasMetric = true
+		}
+	}
+	if !hasMetric {
+		return nil, fmt.Errorf("path template %q has no {%s} placeholder", template, metricPlaceholder)
+	}
+	if strings.ContainsAny(placeholderRegexp.ReplaceAllString(template, ""), "{} ") {
+		return nil, fmt.Errorf("invalid path template %q", template)
+	}
+	return &pathTemplate{template: template}, nil
+}
+
+func (t *pathTemplate) expand(name string, labels map[string]string) string {
+	return placeholderRegexp.ReplaceAllStringFunc(t.template, func(placeholder string) string {
+		key := placeholder[1 : len(placeholder)-1]
+		if key == metricPlaceholder {
+			return strings.Replace(name, "/", ".", -1)
+		}
+		if value := labels[key]; value != "" {
+			return escapeField(value)
+		}
+		return missingLabelValue
+	})
+}
+
+// taggedPath returns the name of a tagged series, e.g. cpu.usage;nodename=node1;type=node.
+func (f *pathFormat) taggedPath(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key, value := range labels {
+		// Graphite does not accept empty tag values.
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys)+1)
+	parts = append(parts, strings.Replace(tagValueReplacer.Replace(name), "/", ".", -1))
+	for _, key := range keys {
+		parts = append(parts, tagNameReplacer.Replace(key)+"="+tagValueReplacer.Replace(labels[key]))
+	}
+	return strings.Join(parts, ";")
+}
+
+// getPathFormat parses the path options of the sink. It returns nil when the default hierarchy is used.
+func getPathFormat(opts url.Values) (*pathFormat, error) {
+	format := &pathFormat{
+		templates: make(map[string]*pathTemplate),
+	}
+	if len(opts["tagged"]) >= 1 {
+		tagged, err := strconv.ParseBool(opts["tagged"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tagged: %v", err)
+		}
+		format.tagged = tagged
+	}
+
+	metricSetTypes := map[string]bool{
+		core.MetricSetTypePodContainer:    true,
+		core.MetricSetTypeSystemContainer: true,
+		core.MetricSetTypePod:             true,
+		core.MetricSetTypeNamespace:       true,
+		core.MetricSetTypeNode:            true,
+		core.MetricSetTypeCluster:         true,
+	}
+	for option, values := range opts {
+		if !strings.HasPrefix(option, templateOptionPrefix) || len(values) < 1 {
+			continue
+		}
+		metricSetType := strings.TrimPrefix(option, templateOptionPrefix)
+		if !metricSetTypes[metricSetType] {
+			return nil, fmt.Errorf("unknown metric set type %q in option %s", metricSetType, option)
+		}
+		tmpl, err := parsePathTemplate(values[0])
+		if err != nil {
+			return nil, err
+		}
+		format.templates[metricSetType] = tmpl
+	}
+
+	if !format.tagged && len(format.templates) == 0 {
+		return nil, nil
+	}
+	if format.tagged && len(format.templates) > 0 {
+		return nil, fmt.Errorf("path templates can't be used with tagged series")
+	}
+	return format, nil
+}
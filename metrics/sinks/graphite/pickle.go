@@ -0,0 +1,131 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graphite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/marpaia/graphite-golang"
+)
+
+const pickleDialTimeout = 5 * time.Second
+
+// Opcodes of the pickle protocol 2 used to encode the metrics.
+const (
+	pickleProto      = 0x80
+	pickleEmptyList  = ']'
+	pickleMark       = '('
+	pickleAppends    = 'e'
+	pickleBinUnicode = 'X'
+	pickleBinInt     = 'J'
+	pickleBinFloat   = 'G'
+	pickleTuple2     = 0x86
+	pickleStop       = '.'
+)
+
+// pickleClient sends metrics to the pickle receiver of carbon, in messages of at most batchSize metrics.
+type pickleClient struct {
+	address   string
+	prefix    string
+	batchSize int
+	conn      net.Conn
+}
+
+func newPickleClient(address, prefix string, batchSize int) *pickleClient {
+	return &pickleClient{
+		address:   address,
+		prefix:    prefix,
+		batchSize: batchSize,
+	}
+}
+
+func (c *pickleClient) Connect() error {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	conn, err := net.DialTimeout("tcp", c.address, pickleDialTimeout)
+	if err != nil {
+		c.conn = nil
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *pickleClient) Disconnect() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *pickleClient) SendMetrics(metrics []graphite.Metric) error {
+	if c.conn == nil {
+		if err := c.Connect(); err != nil {
+			return err
+		}
+	}
+	for start := 0; start < len(metrics); start += c.batchSize {
+		end := start + c.batchSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		if _, err := c.conn.Write(c.encode(metrics[start:end])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode returns the message holding the pickled list of (path, (timestamp, value)) tuples,
+// prefixed by its length.
+func (c *pickleClient) encode(metrics []graphite.Metric) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0, 0, 0, 0})
+	buf.Write([]byte{pickleProto, 2, pickleEmptyList, pickleMark})
+	for _, metric := range metrics {
+		value, err := strconv.ParseFloat(metric.Value, 64)
+		if err != nil {
+			glog.V(4).Infof("Skipping metric %s with invalid value %q", metric.Name, metric.Value)
+			continue
+		}
+		name := metric.Name
+		if c.prefix != "" {
+			name = c.prefix + "." + name
+		}
+
+		buf.WriteByte(pickleBinUnicode)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(name)))
+		buf.WriteString(name)
+		buf.WriteByte(pickleBinInt)
+		binary.Write(&buf, binary.LittleEndian, int32(metric.Timestamp))
+		buf.WriteByte(pickleBinFloat)
+		binary.Write(&buf, binary.BigEndian, math.Float64bits(value))
+		buf.Write([]byte{pickleTuple2, pickleTuple2})
+	}
+	buf.Write([]byte{pickleAppends, pickleStop})
+
+	message := buf.Bytes()
+	binary.BigEndian.PutUint32(message, uint32(len(message)-4))
+	return message
+}
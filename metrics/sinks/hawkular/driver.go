@@ -77,7 +77,12 @@ func (h *hawkularSink) Stop() {
 
 func (h *hawkularSink) ExportData(db *core.DataBatch) {
 	totalCount := 0
-	h.runId++
+	// The batches of several clusters may be exported with the same timestamp, the
+	// cache expires after a number of collections rather than exports.
+	if !db.Timestamp.Equal(h.runTime) {
+		h.runId++
+		h.runTime = db.Timestamp
+	}
 	for _, ms := range db.MetricSets {
 		totalCount += len(ms.MetricValues)
 		totalCount += len(ms.LabeledMetrics)
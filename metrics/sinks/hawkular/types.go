@@ -17,6 +17,7 @@ package hawkular
 import (
 	"net/url"
 	"sync"
+	"time"
 
 	"github.com/hawkular/hawkular-client-go/metrics"
 	"k8s.io/heapster/metrics/core"
@@ -57,6 +58,7 @@ type hawkularSink struct {
 	// reg      map[string]uint64 // Hash of real definition
 	expReg   map[string]*expiringItem
 	runId    uint64
+	runTime  time.Time
 	cacheAge uint64
 
 	uri *url.URL
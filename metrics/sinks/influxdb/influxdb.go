@@ -254,7 +254,8 @@ func (sink *influxdbSink) createDatabase() error {
 		return err
 	}
 
-	if sink.dbExists {
+	// Buckets of the InfluxDB 2.x API are not created by Heapster.
+	if sink.dbExists || sink.c.Version == 2 {
 		return nil
 	}
 	q := influxdb.Query{
@@ -309,6 +310,10 @@ func CreateInfluxdbSink(uri *url.URL) (core.DataSink, error) {
 		return nil, err
 	}
 	sink := newSink(*config)
-	glog.Infof("created influxdb sink with options: host:%s user:%s db:%s", config.Host, config.User, config.DbName)
+	if config.Version == 2 {
+		glog.Infof("created influxdb sink with options: host:%s org:%s bucket:%s", config.Host, config.Organization, config.Bucket)
+	} else {
+		glog.Infof("created influxdb sink with options: host:%s user:%s db:%s", config.Host, config.User, config.DbName)
+	}
 	return sink, nil
 }
@@ -31,6 +31,9 @@ import (
 
 // Historical indicates that this sink supports being used as a HistoricalSource
 func (sink *influxdbSink) Historical() core.HistoricalSource {
+	if sink.c.Version == 2 {
+		return &fluxHistoricalSource{sink: sink}
+	}
 	return sink
 }
 
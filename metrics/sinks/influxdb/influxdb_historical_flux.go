@@ -0,0 +1,431 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	influxdb_common "k8s.io/heapster/common/influxdb"
+	"k8s.io/heapster/metrics/core"
+
+	"github.com/golang/glog"
+	influx_models "github.com/influxdata/influxdb/models"
+)
+
+// Queries without a start time cover all the data.
+const fluxEpoch = "1970-01-01T00:00:00Z"
+
+// fluxHistoricalSource implements HistoricalSource with Flux queries, for the InfluxDB 2.x API.
+// Every object of a request is queried by its own named yield, so a request is still answered
+// by a single query.
+type fluxHistoricalSource struct {
+	sink *influxdbSink
+}
+
+var fluxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+
+// fluxString quotes a Flux string literal.
+func fluxString(value string) string {
+	return `"` + fluxStringEscaper.Replace(value) + `"`
+}
+
+func fluxEquals(column, value string) string {
+	return fmt.Sprintf("r[%s] == %s", fluxString(column), fluxString(value))
+}
+
+func fluxTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// fluxRange restricts the query to the given interval.
+func fluxRange(start, end time.Time) string {
+	startStr := fluxEpoch
+	if !start.IsZero() {
+		startStr = fluxTime(start)
+	}
+	if end.IsZero() {
+		return fmt.Sprintf("range(start: %s)", startStr)
+	}
+	return fmt.Sprintf("range(start: %s, stop: %s)", startStr, fluxTime(end))
+}
+
+// keyToPredicate converts a HistoricalKey to a Flux predicate
+func (this *fluxHistoricalSource) keyToPredicate(key core.HistoricalKey) string {
+	typeSel := fluxEquals(core.LabelMetricSetType.Key, key.ObjectType)
+	switch key.ObjectType {
+	case core.MetricSetTypeNode:
+		return fmt.Sprintf("%s and %s", typeSel, fluxEquals(core.LabelNodename.Key, key.NodeName))
+	case core.MetricSetTypeSystemContainer:
+		return fmt.Sprintf("%s and %s and %s", typeSel, fluxEquals(core.LabelContainerName.Key, key.ContainerName), fluxEquals(core.LabelNodename.Key, key.NodeName))
+	case core.MetricSetTypeCluster:
+		return typeSel
+	case core.MetricSetTypeNamespace:
+		return fmt.Sprintf("%s and %s", typeSel, fluxEquals(core.LabelNamespaceName.Key, key.NamespaceName))
+	case core.MetricSetTypePod:
+		if key.PodId != "" {
+			return fmt.Sprintf("%s and %s", typeSel, fluxEquals(core.LabelPodId.Key, key.PodId))
+		}
+		return fmt.Sprintf("%s and %s and %s", typeSel, fluxEquals(core.LabelNamespaceName.Key, key.NamespaceName), fluxEquals(core.LabelPodName.Key, key.PodName))
+	case core.MetricSetTypePodContainer:
+		if key.PodId != "" {
+			return fmt.Sprintf("%s and %s and %s", typeSel, fluxEquals(core.LabelPodId.Key, key.PodId), fluxEquals(core.LabelContainerName.Key, key.ContainerName))
+		}
+		return fmt.Sprintf("%s and %s and %s and %s", typeSel, fluxEquals(core.LabelNamespaceName.Key, key.NamespaceName), fluxEquals(core.LabelPodName.Key, key.PodName), fluxEquals(core.LabelContainerName.Key, key.ContainerName))
+	}
+
+	// These are assigned by the API, so it shouldn't be possible to reach this unless things are really broken
+	panic(fmt.Sprintf("Unknown metric type %q", key.ObjectType))
+}
+
+// composeSelection creates the Flux pipeline selecting the values of the metric for the given object,
+// merged into a single table.
+func (this *fluxHistoricalSource) composeSelection(metricName string, labels map[string]string, key core.HistoricalKey, start, end time.Time) string {
+	seriesName, fieldName := this.sink.metricToSeriesAndField(metricName)
+
+	preds := []string{
+		fluxEquals("_measurement", seriesName),
+		fluxEquals("_field", fieldName),
+		this.keyToPredicate(key),
+	}
+	labelNames := make([]string, 0, len(labels))
+	for k := range labels {
+		labelNames = append(labelNames, k)
+	}
+	sort.Strings(labelNames)
+	for _, k := range labelNames {
+		preds = append(preds, fluxEquals(k, labels[k]))
+	}
+
+	// Grouping by the range bounds merges all the series while keeping the bounds through aggregations.
+	return fmt.Sprintf("from(bucket: %s)\n  |> %s\n  |> filter(fn: (r) => %s)\n  |> group(columns: [\"_start\", \"_stop\"])",
+		fluxString(this.sink.c.Bucket), fluxRange(start, end), strings.Join(preds, " and "))
+}
+
+// composeRawQuery creates the Flux query to fetch the given metric values
+func (this *fluxHistoricalSource) composeRawQuery(metricName string, labels map[string]string, metricKeys []core.HistoricalKey, start, end time.Time) string {
+	queries := make([]string, len(metricKeys))
+	for i, key := range metricKeys {
+		queries[i] = fmt.Sprintf("%s\n  |> keep(columns: [\"_time\", \"_value\"])\n  |> sort(columns: [\"_time\"])\n  |> yield(name: %s)",
+			this.composeSelection(metricName, labels, key, start, end), fluxString(rawResultName(i)))
+	}
+	return strings.Join(queries, "\n\n")
+}
+
+func rawResultName(keyIndex int) string {
+	return fmt.Sprintf("k%d", keyIndex)
+}
+
+func aggregationResultName(keyIndex, aggregationIndex int) string {
+	return fmt.Sprintf("k%d_a%d", keyIndex, aggregationIndex)
+}
+
+// aggregationCall converts an aggregation name into the equivalent Flux aggregation. With a bucket
+// size the values are aggregated per window, otherwise over the whole interval.
+func (this *fluxHistoricalSource) aggregationCall(aggregationName core.AggregationType, bucketSize time.Duration) string {
+	var fn, quantile string
+	switch aggregationName {
+	case core.AggregationTypeAverage:
+		fn = "mean"
+	case core.AggregationTypeMaximum:
+		fn = "max"
+	case core.AggregationTypeMinimum:
+		fn = "min"
+	case core.AggregationTypeMedian:
+		fn = "median"
+	case core.AggregationTypeCount:
+		fn = "count"
+	case core.AggregationTypePercentile50:
+		quantile = "0.5"
+	case core.AggregationTypePercentile95:
+		quantile = "0.95"
+	case core.AggregationTypePercentile99:
+		quantile = "0.99"
+	default:
+		// This should have been checked by the API level, so something's seriously wrong here
+		panic(fmt.Sprintf("Unknown aggregation type %q", aggregationName))
+	}
+
+	if bucketSize == 0 {
+		// Selectors like max keep the time of the selected point, so it is dropped to report all
+		// the aggregations at the start of the interval.
+		if quantile != "" {
+			return fmt.Sprintf("quantile(q: %s)\n  |> keep(columns: [\"_start\", \"_value\"])", quantile)
+		}
+		return fmt.Sprintf("%s()\n  |> keep(columns: [\"_start\", \"_value\"])", fn)
+	}
+	if quantile != "" {
+		fn = fmt.Sprintf("(column, tables=<-) => tables |> quantile(q: %s, column: column)", quantile)
+	}
+	return fmt.Sprintf("aggregateWindow(every: %dns, fn: %s, timeSrc: \"_start\", createEmpty: false)", bucketSize.Nanoseconds(), fn)
+}
+
+// composeAggregateQuery creates the Flux query to fetch the given aggregation values
+func (this *fluxHistoricalSource) composeAggregateQuery(metricName string, labels map[string]string, aggregations []core.AggregationType, metricKeys []core.HistoricalKey, start, end time.Time, bucketSize time.Duration) string {
+	queries := make([]string, 0, len(metricKeys)*len(aggregations))
+	for i, key := range metricKeys {
+		selection := this.composeSelection(metricName, labels, key, start, end)
+		for j, agg := range aggregations {
+			queries = append(queries, fmt.Sprintf("%s\n  |> %s\n  |> yield(name: %s)",
+				selection, this.aggregationCall(agg, bucketSize), fluxString(aggregationResultName(i, j))))
+		}
+	}
+	return strings.Join(queries, "\n\n")
+}
+
+// runQuery executes the given Flux query and groups the records by result name.
+func (this *fluxHistoricalSource) runQuery(query string) (map[string][]influxdb_common.FluxRecord, error) {
+	this.sink.RLock()
+	defer this.sink.RUnlock()
+
+	// ensure we have a valid client handle before attempting to use it
+	if err := this.sink.ensureClient(); err != nil {
+		glog.Errorf("Unable to ensure InfluxDB client is present: %v", err)
+		return nil, fmt.Errorf("unable to run query: unable to connect to database")
+	}
+	client, ok := this.sink.client.(influxdb_common.FluxClient)
+	if !ok {
+		return nil, fmt.Errorf("unable to run query: the InfluxDB client does not support Flux")
+	}
+
+	glog.V(4).Infof("Executing query %q against bucket %q", query, this.sink.c.Bucket)
+
+	records, err := client.QueryFlux(query)
+	if err != nil {
+		glog.Errorf("Unable to perform query %q against bucket %q: %v", query, this.sink.c.Bucket, err)
+		return nil, err
+	}
+
+	results := make(map[string][]influxdb_common.FluxRecord)
+	for _, record := range records {
+		results[record["result"]] = append(results[record["result"]], record)
+	}
+	return results, nil
+}
+
+// recordTime returns the time of a record. Aggregations over the whole interval are reported at
+// its start.
+func recordTime(record influxdb_common.FluxRecord) string {
+	if ts := record["_time"]; ts != "" {
+		return ts
+	}
+	return record["_start"]
+}
+
+func (this *fluxHistoricalSource) getMetric(metricName string, labels map[string]string, metricKeys []core.HistoricalKey, start, end time.Time) (map[core.HistoricalKey][]core.TimestampedMetricValue, error) {
+	for _, key := range metricKeys {
+		if err := this.sink.checkSanitizedKey(&key); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := this.sink.checkSanitizedMetricName(metricName); err != nil {
+		return nil, err
+	}
+
+	if err := this.sink.checkSanitizedMetricLabels(labels); err != nil {
+		return nil, err
+	}
+
+	results, err := this.runQuery(this.composeRawQuery(metricName, labels, metricKeys, start, end))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[core.HistoricalKey][]core.TimestampedMetricValue, len(metricKeys))
+	for i, key := range metricKeys {
+		records := results[rawResultName(i)]
+		if len(records) < 1 {
+			return nil, fmt.Errorf("No results for metric %q describing %q", metricName, key.String())
+		}
+
+		// The rows are converted to the InfluxQL format to share the parsing of the values.
+		row := influx_models.Row{Name: metricName, Values: make([][]interface{}, len(records))}
+		for j, record := range records {
+			row.Values[j] = []interface{}{recordTime(record), json.Number(record["_value"])}
+		}
+		vals, err := this.sink.parseRawQueryRow(row)
+		if err != nil {
+			return nil, err
+		}
+		res[key] = vals
+	}
+
+	return res, nil
+}
+
+// GetMetric retrieves the given metric for one or more objects (specified by metricKeys) of
+// the same type, within the given time interval
+func (this *fluxHistoricalSource) GetMetric(metricName string, metricKeys []core.HistoricalKey, start, end time.Time) (map[core.HistoricalKey][]core.TimestampedMetricValue, error) {
+	return this.getMetric(metricName, nil, metricKeys, start, end)
+}
+
+// GetLabeledMetric retrieves the given labeled metric for one or more objects (specified by metricKeys) of
+// the same type, within the given time interval
+func (this *fluxHistoricalSource) GetLabeledMetric(metricName string, labels map[string]string, metricKeys []core.HistoricalKey, start, end time.Time) (map[core.HistoricalKey][]core.TimestampedMetricValue, error) {
+	return this.getMetric(metricName, labels, metricKeys, start, end)
+}
+
+func (this *fluxHistoricalSource) getAggregation(metricName string, labels map[string]string, aggregations []core.AggregationType, metricKeys []core.HistoricalKey, start, end time.Time, bucketSize time.Duration) (map[core.HistoricalKey][]core.TimestampedAggregationValue, error) {
+	for _, key := range metricKeys {
+		if err := this.sink.checkSanitizedKey(&key); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := this.sink.checkSanitizedMetricName(metricName); err != nil {
+		return nil, err
+	}
+
+	if err := this.sink.checkSanitizedMetricLabels(labels); err != nil {
+		return nil, err
+	}
+
+	// make it easy to look up where the different aggregations are in the list
+	aggregationLookup := make(map[core.AggregationType]int, len(aggregations))
+	for i, agg := range aggregations {
+		aggregationLookup[agg] = i + 1
+	}
+
+	results, err := this.runQuery(this.composeAggregateQuery(metricName, labels, aggregations, metricKeys, start, end, bucketSize))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[core.HistoricalKey][]core.TimestampedAggregationValue, len(metricKeys))
+	for i, key := range metricKeys {
+		// Every aggregation is a separate result, so the values are joined on their time into
+		// rows in the InfluxQL format to share the parsing of the values.
+		rows := map[string][]interface{}{}
+		times := []string{}
+		for j := range aggregations {
+			for _, record := range results[aggregationResultName(i, j)] {
+				ts := recordTime(record)
+				row, found := rows[ts]
+				if !found {
+					row = make([]interface{}, len(aggregations)+1)
+					row[0] = ts
+					rows[ts] = row
+					times = append(times, ts)
+				}
+				row[j+1] = json.Number(record["_value"])
+			}
+		}
+		if len(times) < 1 {
+			return nil, fmt.Errorf("No results for metric %q describing %q", metricName, key.String())
+		}
+		sort.Strings(times)
+
+		row := influx_models.Row{Name: metricName, Values: make([][]interface{}, len(times))}
+		for j, ts := range times {
+			row.Values[j] = rows[ts]
+		}
+		vals, err := this.sink.parseAggregateQueryRow(row, aggregationLookup, bucketSize)
+		if err != nil {
+			return nil, err
+		}
+		res[key] = vals
+	}
+
+	return res, nil
+}
+
+// GetAggregation fetches the given aggregations for one or more objects (specified by metricKeys) of
+// the same type, within the given time interval, calculated over a series of buckets
+func (this *fluxHistoricalSource) GetAggregation(metricName string, aggregations []core.AggregationType, metricKeys []core.HistoricalKey, start, end time.Time, bucketSize time.Duration) (map[core.HistoricalKey][]core.TimestampedAggregationValue, error) {
+	return this.getAggregation(metricName, nil, aggregations, metricKeys, start, end, bucketSize)
+}
+
+// GetLabeledAggregation fetches the given aggregations (on labeled metrics) for one or more objects
+// (specified by metricKeys) of the same type, within the given time interval, calculated over a series of buckets
+func (this *fluxHistoricalSource) GetLabeledAggregation(metricName string, labels map[string]string, aggregations []core.AggregationType, metricKeys []core.HistoricalKey, start, end time.Time, bucketSize time.Duration) (map[core.HistoricalKey][]core.TimestampedAggregationValue, error) {
+	return this.getAggregation(metricName, labels, aggregations, metricKeys, start, end, bucketSize)
+}
+
+// GetMetricNames retrieves the available metric names for the given object
+func (this *fluxHistoricalSource) GetMetricNames(metricKey core.HistoricalKey) ([]string, error) {
+	if err := this.sink.checkSanitizedKey(&metricKey); err != nil {
+		return nil, err
+	}
+	q := fmt.Sprintf("from(bucket: %s)\n  |> range(start: %s)\n  |> filter(fn: (r) => %s)\n  |> keep(columns: [\"_measurement\"])\n  |> group()\n  |> distinct(column: \"_measurement\")",
+		fluxString(this.sink.c.Bucket), fluxEpoch, this.keyToPredicate(metricKey))
+	return this.stringListQuery(q, "Unable to list available metrics")
+}
+
+// tagValuesQuery lists the values of the tag in the points matching the predicate.
+func (this *fluxHistoricalSource) tagValuesQuery(tag, predicate string) string {
+	q := fmt.Sprintf("import \"influxdata/influxdb/schema\"\n\nschema.tagValues(bucket: %s, tag: %s, start: %s",
+		fluxString(this.sink.c.Bucket), fluxString(tag), fluxEpoch)
+	if predicate != "" {
+		q += fmt.Sprintf(", predicate: (r) => %s", predicate)
+	}
+	return q + ")"
+}
+
+// GetNodes retrieves the list of nodes in the cluster
+func (this *fluxHistoricalSource) GetNodes() ([]string, error) {
+	return this.stringListQuery(this.tagValuesQuery(core.LabelNodename.Key, ""), "Unable to list all nodes")
+}
+
+// GetNamespaces retrieves the list of namespaces in the cluster
+func (this *fluxHistoricalSource) GetNamespaces() ([]string, error) {
+	return this.stringListQuery(this.tagValuesQuery(core.LabelNamespaceName.Key, ""), "Unable to list all namespaces")
+}
+
+// GetPodsFromNamespace retrieves the list of pods in a given namespace
+func (this *fluxHistoricalSource) GetPodsFromNamespace(namespace string) ([]string, error) {
+	if !nameAllowedChars.MatchString(namespace) {
+		return nil, fmt.Errorf("Invalid namespace name %q", namespace)
+	}
+	// Like with InfluxQL, the pods are found in the series of the uptime measurement
+	// (any measurement should work here, though)
+	pred := fmt.Sprintf("%s and %s and %s", fluxEquals("_measurement", core.MetricUptime.MetricDescriptor.Name),
+		fluxEquals(core.LabelNamespaceName.Key, namespace), fluxEquals(core.LabelMetricSetType.Key, core.MetricSetTypePod))
+	return this.stringListQuery(this.tagValuesQuery(core.LabelPodName.Key, pred), fmt.Sprintf("Unable to list pods in namespace %q", namespace))
+}
+
+// GetSystemContainersFromNode retrieves the list of free containers for a given node
+func (this *fluxHistoricalSource) GetSystemContainersFromNode(node string) ([]string, error) {
+	if !nameAllowedChars.MatchString(node) {
+		return nil, fmt.Errorf("Invalid node name %q", node)
+	}
+	pred := fmt.Sprintf("%s and %s and %s", fluxEquals("_measurement", core.MetricUptime.MetricDescriptor.Name),
+		fluxEquals(core.LabelNodename.Key, node), fluxEquals(core.LabelMetricSetType.Key, core.MetricSetTypeSystemContainer))
+	return this.stringListQuery(this.tagValuesQuery(core.LabelContainerName.Key, pred), fmt.Sprintf("Unable to list system containers on node %q", node))
+}
+
+// stringListQuery runs the given query, and returns the values of all the records as a string list
+func (this *fluxHistoricalSource) stringListQuery(q string, errStr string) ([]string, error) {
+	results, err := this.runQuery(q)
+	if err != nil {
+		return nil, errors.New(errStr)
+	}
+
+	res := []string{}
+	for _, records := range results {
+		for _, record := range records {
+			res = append(res, record["_value"])
+		}
+	}
+	if len(res) < 1 {
+		return nil, errors.New(errStr)
+	}
+	sort.Strings(res)
+	return res, nil
+}
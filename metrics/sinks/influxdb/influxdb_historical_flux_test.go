@@ -0,0 +1,164 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	influxdb_common "k8s.io/heapster/common/influxdb"
+	"k8s.io/heapster/metrics/core"
+)
+
+func newFluxSource() (*fluxHistoricalSource, *influxdb_common.FakeInfluxDBClient) {
+	client := influxdb_common.NewFakeInfluxDBClient()
+	config := influxdb_common.Config
+	config.Version = 2
+	config.Organization = "heapster"
+	config.Bucket = "k8s"
+	sink := &influxdbSink{
+		client:  client,
+		c:       config,
+		conChan: make(chan struct{}, config.Concurrency),
+	}
+	return sink.Historical().(*fluxHistoricalSource), client
+}
+
+func TestFluxRawQuery(t *testing.T) {
+	source, client := newFluxSource()
+	client.FluxRecords = []influxdb_common.FluxRecord{
+		{"result": "k0", "_time": "2017-10-01T00:00:00Z", "_value": "10"},
+		{"result": "k0", "_time": "2017-10-01T00:01:00Z", "_value": "20"},
+		{"result": "k1", "_time": "2017-10-01T00:00:00Z", "_value": "1.5"},
+	}
+	podKeys := []core.HistoricalKey{
+		{ObjectType: core.MetricSetTypePod, PodId: "aaaa"},
+		{ObjectType: core.MetricSetTypePod, NamespaceName: "cheese", PodName: "swiss"},
+	}
+	start := time.Date(2017, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	res, err := source.GetLabeledMetric("filesystem/usage", map[string]string{"resource_id": "/"}, podKeys, start, time.Time{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(client.FluxQueries))
+	assert.Equal(t, `from(bucket: "k8s")
+  |> range(start: 2017-10-01T00:00:00Z)
+  |> filter(fn: (r) => r["_measurement"] == "filesystem/usage" and r["_field"] == "value" and r["type"] == "pod" and r["pod_id"] == "aaaa" and r["resource_id"] == "/")
+  |> group(columns: ["_start", "_stop"])
+  |> keep(columns: ["_time", "_value"])
+  |> sort(columns: ["_time"])
+  |> yield(name: "k0")
+
+from(bucket: "k8s")
+  |> range(start: 2017-10-01T00:00:00Z)
+  |> filter(fn: (r) => r["_measurement"] == "filesystem/usage" and r["_field"] == "value" and r["type"] == "pod" and r["namespace_name"] == "cheese" and r["pod_name"] == "swiss" and r["resource_id"] == "/")
+  |> group(columns: ["_start", "_stop"])
+  |> keep(columns: ["_time", "_value"])
+  |> sort(columns: ["_time"])
+  |> yield(name: "k1")`, client.FluxQueries[0])
+
+	require.Equal(t, 2, len(res[podKeys[0]]))
+	assert.Equal(t, core.ValueInt64, res[podKeys[0]][1].ValueType)
+	assert.Equal(t, int64(20), res[podKeys[0]][1].IntValue)
+	assert.Equal(t, start.Add(time.Minute), res[podKeys[0]][1].Timestamp)
+	require.Equal(t, 1, len(res[podKeys[1]]))
+	assert.Equal(t, core.ValueFloat, res[podKeys[1]][0].ValueType)
+	assert.Equal(t, 1.5, res[podKeys[1]][0].FloatValue)
+}
+
+func TestFluxAggregationQuery(t *testing.T) {
+	source, client := newFluxSource()
+	client.FluxRecords = []influxdb_common.FluxRecord{
+		{"result": "k0_a0", "_time": "2017-10-01T00:05:00Z", "_value": "2.5"},
+		{"result": "k0_a0", "_time": "2017-10-01T00:00:00Z", "_value": "1.5"},
+		{"result": "k0_a1", "_time": "2017-10-01T00:00:00Z", "_value": "3"},
+		{"result": "k0_a1", "_time": "2017-10-01T00:05:00Z", "_value": "4"},
+	}
+	nodeKeys := []core.HistoricalKey{{ObjectType: core.MetricSetTypeNode, NodeName: "node1"}}
+	aggregations := []core.AggregationType{core.AggregationTypePercentile95, core.AggregationTypeCount}
+	end := time.Date(2017, 10, 1, 0, 10, 0, 0, time.UTC)
+
+	res, err := source.GetAggregation("cpu/usage_rate", aggregations, nodeKeys, time.Time{}, end, 5*time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(client.FluxQueries))
+	assert.Contains(t, client.FluxQueries[0], `|> range(start: 1970-01-01T00:00:00Z, stop: 2017-10-01T00:10:00Z)`)
+	assert.Contains(t, client.FluxQueries[0], `|> aggregateWindow(every: 300000000000ns, fn: (column, tables=<-) => tables |> quantile(q: 0.95, column: column), timeSrc: "_start", createEmpty: false)
+  |> yield(name: "k0_a0")`)
+	assert.Contains(t, client.FluxQueries[0], `|> aggregateWindow(every: 300000000000ns, fn: count, timeSrc: "_start", createEmpty: false)
+  |> yield(name: "k0_a1")`)
+
+	vals := res[nodeKeys[0]]
+	require.Equal(t, 2, len(vals))
+	assert.Equal(t, end.Add(-10*time.Minute), vals[0].Timestamp)
+	assert.Equal(t, uint64(3), *vals[0].Count)
+	assert.Equal(t, 1.5, vals[0].Aggregations[core.AggregationTypePercentile95].FloatValue)
+	assert.Equal(t, 5*time.Minute, vals[1].BucketSize)
+	assert.Equal(t, uint64(4), *vals[1].Count)
+	assert.Equal(t, core.ValueFloat, vals[1].Aggregations[core.AggregationTypePercentile95].ValueType)
+
+	// Without a bucket size the aggregations are reported at the start of the interval.
+	client.FluxQueries = nil
+	client.FluxRecords = []influxdb_common.FluxRecord{
+		{"result": "k0_a0", "_start": "1970-01-01T00:00:00Z", "_value": "7"},
+	}
+	res, err = source.GetAggregation("cpu/usage_rate", []core.AggregationType{core.AggregationTypeMaximum}, nodeKeys, time.Time{}, time.Time{}, 0)
+	require.NoError(t, err)
+	assert.Contains(t, client.FluxQueries[0], "|> max()\n  |> keep(columns: [\"_start\", \"_value\"])")
+	require.Equal(t, 1, len(res[nodeKeys[0]]))
+	assert.Equal(t, int64(7), res[nodeKeys[0]][0].Aggregations[core.AggregationTypeMaximum].IntValue)
+}
+
+func TestFluxMissingResponses(t *testing.T) {
+	source, _ := newFluxSource()
+	podKeys := []core.HistoricalKey{{ObjectType: core.MetricSetTypePod, NamespaceName: "cheese", PodName: "cheddar"}}
+	errStr := fmt.Sprintf("No results for metric %q describing %q", "cpu/usage_rate", podKeys[0].String())
+
+	_, err := source.GetMetric("cpu/usage_rate", podKeys, time.Time{}, time.Time{})
+	assert.EqualError(t, err, errStr)
+	_, err = source.GetAggregation("cpu/usage_rate", []core.AggregationType{core.AggregationTypeAverage}, podKeys, time.Time{}, time.Time{}, time.Minute)
+	assert.EqualError(t, err, errStr)
+	_, err = source.GetNodes()
+	assert.EqualError(t, err, "Unable to list all nodes")
+}
+
+func TestFluxListQueries(t *testing.T) {
+	source, client := newFluxSource()
+	client.FluxRecords = []influxdb_common.FluxRecord{
+		{"result": "_result", "_value": "swiss"},
+		{"result": "_result", "_value": "cheddar"},
+	}
+
+	pods, err := source.GetPodsFromNamespace("cheese")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cheddar", "swiss"}, pods)
+	assert.Equal(t, `import "influxdata/influxdb/schema"
+
+schema.tagValues(bucket: "k8s", tag: "pod_name", start: 1970-01-01T00:00:00Z, predicate: (r) => r["_measurement"] == "uptime" and r["namespace_name"] == "cheese" and r["type"] == "pod")`, client.FluxQueries[0])
+
+	_, err = source.GetMetricNames(core.HistoricalKey{ObjectType: core.MetricSetTypeCluster})
+	require.NoError(t, err)
+	assert.Contains(t, client.FluxQueries[1], `|> filter(fn: (r) => r["type"] == "cluster")`)
+
+	_, err = source.GetSystemContainersFromNode(`node"1`)
+	assert.Error(t, err)
+	_, err = source.GetMetric(`cpu"usage`, nil, time.Time{}, time.Time{})
+	assert.Error(t, err)
+}
+
+func TestFluxString(t *testing.T) {
+	assert.Equal(t, `"a\"b\\c\${d}"`, fluxString(`a"b\c${d}`))
+}
@@ -15,8 +15,9 @@
 package kafka
 
 import (
+	"fmt"
 	"net/url"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/golang/glog"
@@ -24,6 +25,11 @@ import (
 	"k8s.io/heapster/metrics/core"
 )
 
+const (
+	defaultBatch    = false
+	defaultEncoding = jsonEncoding
+)
+
 type KafkaSinkPoint struct {
 	MetricsName      string
 	MetricsValue     interface{}
@@ -33,7 +39,11 @@ type KafkaSinkPoint struct {
 
 type kafkaSink struct {
 	kafka_common.KafkaClient
-	sync.RWMutex
+	// Send a single message per MetricSet instead of one per metric.
+	batch bool
+	// Label whose value is the message key. Messages are not keyed if empty.
+	keyLabel string
+	encoder  messageEncoder
 }
 
 func (sink *kafkaSink) Name() string {
@@ -41,49 +51,94 @@ func (sink *kafkaSink) Name() string {
 }
 
 func (sink *kafkaSink) ExportData(dataBatch *core.DataBatch) {
-	sink.Lock()
-	defer sink.Unlock()
-
+	timestamp := dataBatch.Timestamp.UTC()
+	messages := []kafka_common.KafkaMessage{}
 	for _, metricSet := range dataBatch.MetricSets {
+		var key []byte
+		if value := metricSet.Labels[sink.keyLabel]; sink.keyLabel != "" && value != "" {
+			key = []byte(value)
+		}
+
+		points := make([]metricPoint, 0, len(metricSet.MetricValues)+len(metricSet.LabeledMetrics))
 		for metricName, metricValue := range metricSet.MetricValues {
-			point := KafkaSinkPoint{
-				MetricsName: metricName,
-				MetricsTags: metricSet.Labels,
-				MetricsValue: map[string]interface{}{
-					"value": metricValue.GetValue(),
-				},
-				MetricsTimestamp: dataBatch.Timestamp.UTC(),
-			}
-			err := sink.ProduceKafkaMessage(point)
-			if err != nil {
-				glog.Errorf("Failed to produce metric message: %s", err)
-			}
+			points = append(points, metricPoint{name: metricName, value: metricValue})
 		}
 		for _, metric := range metricSet.LabeledMetrics {
-			labels := make(map[string]string)
-			for k, v := range metricSet.Labels {
-				labels[k] = v
-			}
-			for k, v := range metric.Labels {
-				labels[k] = v
+			points = append(points, metricPoint{name: metric.Name, labels: metric.Labels, value: metric.MetricValue})
+		}
+		if len(points) == 0 {
+			continue
+		}
+
+		if sink.batch {
+			value, err := sink.encoder.EncodeBatch(timestamp, metricSet.Labels, points)
+			if err != nil {
+				glog.Errorf("Failed to encode metric message: %s", err)
+				continue
 			}
-			point := KafkaSinkPoint{
-				MetricsName: metric.Name,
-				MetricsTags: labels,
-				MetricsValue: map[string]interface{}{
-					"value": metric.GetValue(),
-				},
-				MetricsTimestamp: dataBatch.Timestamp.UTC(),
+			messages = append(messages, kafka_common.KafkaMessage{Key: key, Value: value})
+			continue
+		}
+
+		for _, point := range points {
+			tags := metricSet.Labels
+			if len(point.labels) > 0 {
+				tags = make(map[string]string, len(metricSet.Labels)+len(point.labels))
+				for k, v := range metricSet.Labels {
+					tags[k] = v
+				}
+				for k, v := range point.labels {
+					tags[k] = v
+				}
 			}
-			err := sink.ProduceKafkaMessage(point)
+			value, err := sink.encoder.EncodePoint(timestamp, tags, point)
 			if err != nil {
-				glog.Errorf("Failed to produce metric message: %s", err)
+				glog.Errorf("Failed to encode metric message: %s", err)
+				continue
 			}
+			messages = append(messages, kafka_common.KafkaMessage{Key: key, Value: value})
 		}
 	}
+
+	err := sink.ProduceKafkaMessages(messages)
+	if err != nil {
+		glog.Errorf("Failed to produce metric messages: %s", err)
+	}
 }
 
 func NewKafkaSink(uri *url.URL) (core.DataSink, error) {
+	opts := uri.Query()
+	var err error
+
+	batch := defaultBatch
+	if len(opts["batch"]) >= 1 {
+		batch, err = strconv.ParseBool(opts["batch"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse batch: %s", err)
+		}
+	}
+
+	keyLabel := ""
+	if len(opts["key_label"]) >= 1 {
+		keyLabel = opts["key_label"][0]
+	}
+
+	encoding := defaultEncoding
+	if len(opts["encoding"]) >= 1 {
+		encoding = opts["encoding"][0]
+	}
+	schemaID := -1
+	if len(opts["schema_id"]) >= 1 {
+		schemaID, err = strconv.Atoi(opts["schema_id"][0])
+		if err != nil || schemaID < 0 {
+			return nil, fmt.Errorf("failed to parse schema_id: %q is not a valid schema id", opts["schema_id"][0])
+		}
+	}
+	encoder, err := getEncoder(encoding, schemaID)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := kafka_common.NewKafkaClient(uri, kafka_common.TimeSeriesTopic)
 	if err != nil {
 		return nil, err
@@ -91,5 +146,8 @@ func NewKafkaSink(uri *url.URL) (core.DataSink, error) {
 
 	return &kafkaSink{
 		KafkaClient: client,
+		batch:       batch,
+		keyLabel:    keyLabel,
+		encoder:     encoder,
 	}, nil
 }
@@ -20,12 +20,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kafka_common "k8s.io/heapster/common/kafka"
 	"k8s.io/heapster/metrics/core"
 )
 
 type fakeKafkaClient struct {
-	points []KafkaSinkPoint
+	points   []KafkaSinkPoint
+	messages []kafka_common.KafkaMessage
 }
 
 type fakeKafkaSink struct {
@@ -34,7 +38,7 @@ type fakeKafkaSink struct {
 }
 
 func NewFakeKafkaClient() *fakeKafkaClient {
-	return &fakeKafkaClient{[]KafkaSinkPoint{}}
+	return &fakeKafkaClient{points: []KafkaSinkPoint{}}
 }
 
 func (client *fakeKafkaClient) ProduceKafkaMessage(msgData interface{}) error {
@@ -45,6 +49,17 @@ func (client *fakeKafkaClient) ProduceKafkaMessage(msgData interface{}) error {
 	return nil
 }
 
+func (client *fakeKafkaClient) ProduceKafkaMessages(messages []kafka_common.KafkaMessage) error {
+	for _, message := range messages {
+		client.messages = append(client.messages, message)
+		var point KafkaSinkPoint
+		if err := json.Unmarshal(message.Value, &point); err == nil && point.MetricsName != "" {
+			client.points = append(client.points, point)
+		}
+	}
+	return nil
+}
+
 func (client *fakeKafkaClient) Name() string {
 	return "Apache Kafka Sink"
 }
@@ -55,10 +70,17 @@ func (client *fakeKafkaClient) Stop() {
 
 // Returns a fake kafka sink.
 func NewFakeSink() fakeKafkaSink {
+	return newFakeSinkWithOptions(false, "", &jsonEncoder{})
+}
+
+func newFakeSinkWithOptions(batch bool, keyLabel string, encoder messageEncoder) fakeKafkaSink {
 	client := NewFakeKafkaClient()
 	return fakeKafkaSink{
 		&kafkaSink{
 			KafkaClient: client,
+			batch:       batch,
+			keyLabel:    keyLabel,
+			encoder:     encoder,
 		},
 		client,
 	}
@@ -191,3 +213,157 @@ func TestStoreMultipleDataInput(t *testing.T) {
 	}
 
 }
+
+func testPodMetricSet(podId string) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			"namespace_id":         "123",
+			core.LabelPodId.Key:    podId,
+			core.LabelNodename.Key: "node1",
+		},
+		MetricValues: map[string]core.MetricValue{
+			"cpu/usage": {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricCumulative,
+				IntValue:   123456,
+			},
+		},
+		LabeledMetrics: []core.LabeledMetric{
+			{
+				Name:   "filesystem/usage",
+				Labels: map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+				MetricValue: core.MetricValue{
+					ValueType:  core.ValueFloat,
+					MetricType: core.MetricGauge,
+					FloatValue: 1.5,
+				},
+			},
+		},
+	}
+}
+
+func TestStoreBatchedKeyedData(t *testing.T) {
+	fakeSink := newFakeSinkWithOptions(true, core.LabelPodId.Key, &jsonEncoder{})
+	timestamp := time.Unix(1500000000, 0)
+	data := core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			"pod1": testPodMetricSet("aaaa"),
+			"pod2": testPodMetricSet("bbbb"),
+			"node": {
+				Labels: map[string]string{core.LabelNodename.Key: "node1"},
+				MetricValues: map[string]core.MetricValue{
+					"cpu/usage": {ValueType: core.ValueInt64, IntValue: 1},
+				},
+			},
+		},
+	}
+	fakeSink.ExportData(&data)
+
+	messages := fakeSink.fakeProducer.messages
+	require.Equal(t, 3, len(messages))
+	keys := map[string]KafkaSinkBatch{}
+	for _, message := range messages {
+		var batch KafkaSinkBatch
+		require.NoError(t, json.Unmarshal(message.Value, &batch))
+		keys[string(message.Key)] = batch
+	}
+	// The node has no pod_id label, so its message is not keyed.
+	assert.Contains(t, keys, "")
+	assert.Contains(t, keys, "bbbb")
+	batch, found := keys["aaaa"]
+	require.True(t, found)
+	assert.Equal(t, timestamp.UTC(), batch.MetricsTimestamp)
+	assert.Equal(t, "aaaa", batch.MetricsTags[core.LabelPodId.Key])
+	require.Equal(t, 2, len(batch.Metrics))
+	assert.Equal(t, "cpu/usage", batch.Metrics[0].MetricsName)
+	assert.Nil(t, batch.Metrics[0].MetricsTags)
+	assert.Equal(t, "filesystem/usage", batch.Metrics[1].MetricsName)
+	assert.Equal(t, map[string]string{core.LabelResourceID.Key: "/dev/sda1"}, batch.Metrics[1].MetricsTags)
+	assert.Equal(t, map[string]interface{}{"value": 1.5}, batch.Metrics[1].MetricsValue)
+}
+
+func TestStoreKeyedPoints(t *testing.T) {
+	fakeSink := newFakeSinkWithOptions(false, core.LabelNodename.Key, &jsonEncoder{})
+	data := core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{"pod1": testPodMetricSet("aaaa")},
+	}
+	fakeSink.ExportData(&data)
+
+	require.Equal(t, 2, len(fakeSink.fakeProducer.messages))
+	for _, message := range fakeSink.fakeProducer.messages {
+		assert.Equal(t, "node1", string(message.Key))
+	}
+	for _, point := range fakeSink.fakeProducer.points {
+		if point.MetricsName == "filesystem/usage" {
+			assert.Equal(t, "/dev/sda1", point.MetricsTags[core.LabelResourceID.Key])
+			assert.Equal(t, "aaaa", point.MetricsTags[core.LabelPodId.Key])
+		}
+	}
+}
+
+// Mirrors the MetricBatch protobuf schema, with the value oneof flattened.
+type testProtoMetric struct {
+	Name       string            `protobuf:"bytes,1,opt,name=name"`
+	IntValue   *int64            `protobuf:"varint,2,opt,name=int_value"`
+	FloatValue *float64          `protobuf:"fixed64,3,opt,name=float_value"`
+	Tags       map[string]string `protobuf:"bytes,4,rep,name=tags" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *testProtoMetric) Reset()         { *m = testProtoMetric{} }
+func (m *testProtoMetric) String() string { return proto.CompactTextString(m) }
+func (*testProtoMetric) ProtoMessage()    {}
+
+type testProtoBatch struct {
+	TimestampMs int64              `protobuf:"varint,1,opt,name=timestamp_ms"`
+	Tags        map[string]string  `protobuf:"bytes,2,rep,name=tags" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Metrics     []*testProtoMetric `protobuf:"bytes,3,rep,name=metrics"`
+}
+
+func (m *testProtoBatch) Reset()         { *m = testProtoBatch{} }
+func (m *testProtoBatch) String() string { return proto.CompactTextString(m) }
+func (*testProtoBatch) ProtoMessage()    {}
+
+func TestProtobufEncoding(t *testing.T) {
+	fakeSink := newFakeSinkWithOptions(true, "", &protobufEncoder{})
+	data := core.DataBatch{
+		Timestamp:  time.Unix(1500000000, 5000000),
+		MetricSets: map[string]*core.MetricSet{"pod1": testPodMetricSet("aaaa")},
+	}
+	fakeSink.ExportData(&data)
+
+	require.Equal(t, 1, len(fakeSink.fakeProducer.messages))
+	batch := &testProtoBatch{}
+	require.NoError(t, proto.Unmarshal(fakeSink.fakeProducer.messages[0].Value, batch))
+	assert.Equal(t, int64(1500000000005), batch.TimestampMs)
+	assert.Equal(t, testPodMetricSet("aaaa").Labels, batch.Tags)
+	require.Equal(t, 2, len(batch.Metrics))
+	assert.Equal(t, "cpu/usage", batch.Metrics[0].Name)
+	require.NotNil(t, batch.Metrics[0].IntValue)
+	assert.Equal(t, int64(123456), *batch.Metrics[0].IntValue)
+	assert.Nil(t, batch.Metrics[0].FloatValue)
+	assert.Equal(t, "filesystem/usage", batch.Metrics[1].Name)
+	require.NotNil(t, batch.Metrics[1].FloatValue)
+	assert.Equal(t, 1.5, *batch.Metrics[1].FloatValue)
+	assert.Equal(t, map[string]string{core.LabelResourceID.Key: "/dev/sda1"}, batch.Metrics[1].Tags)
+}
+
+func TestAvroEncoding(t *testing.T) {
+	encoder, err := getEncoder(avroEncoding, 258)
+	require.NoError(t, err)
+	value, err := encoder.EncodePoint(time.Unix(0, int64(time.Millisecond)), map[string]string{"a": "b"},
+		metricPoint{name: "m", value: core.MetricValue{ValueType: core.ValueInt64, IntValue: 5}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0, 0, 0, 1, 2, // magic byte and schema id
+		2,                    // timestamp
+		2, 2, 'a', 2, 'b', 0, // tags
+		2, 2, 'm', 0, 10, 0, 0, // metrics
+	}, value)
+
+	_, err = getEncoder(avroEncoding, -1)
+	assert.Error(t, err)
+	_, err = getEncoder("xml", -1)
+	assert.Error(t, err)
+}
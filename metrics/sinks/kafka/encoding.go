@@ -0,0 +1,263 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	jsonEncoding     = "json"
+	protobufEncoding = "protobuf"
+	avroEncoding     = "avro"
+)
+
+// AvroSchema is the schema of the Avro encoded messages. It has to be registered in the schema
+// registry, and its id passed in the schema_id option.
+const AvroSchema = `{
+  "type": "record",
+  "name": "MetricBatch",
+  "namespace": "io.k8s.heapster",
+  "fields": [
+    {"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "tags", "type": {"type": "map", "values": "string"}},
+    {"name": "metrics", "type": {"type": "array", "items": {
+      "type": "record",
+      "name": "Metric",
+      "fields": [
+        {"name": "name", "type": "string"},
+        {"name": "value", "type": ["long", "double"]},
+        {"name": "tags", "type": {"type": "map", "values": "string"}}
+      ]
+    }}}
+  ]
+}`
+
+// Magic byte of the schema registry wire format.
+const avroMagicByte = 0
+
+// KafkaSinkBatch is the JSON message holding all the metrics of a MetricSet.
+type KafkaSinkBatch struct {
+	MetricsTimestamp time.Time
+	MetricsTags      map[string]string
+	Metrics          []KafkaSinkMetric
+}
+
+// KafkaSinkMetric is a single metric of a KafkaSinkBatch. The tags of labeled metrics are the
+// labels added to the tags of the batch.
+type KafkaSinkMetric struct {
+	MetricsName  string
+	MetricsValue interface{}
+	MetricsTags  map[string]string `json:",omitempty"`
+}
+
+// metricPoint is a metric value of a MetricSet, with the labels of labeled metrics.
+type metricPoint struct {
+	name   string
+	labels map[string]string
+	value  core.MetricValue
+}
+
+// messageEncoder serializes metrics into message values.
+type messageEncoder interface {
+	// EncodePoint encodes a single metric. The tags already include the labels of the point.
+	EncodePoint(timestamp time.Time, tags map[string]string, point metricPoint) ([]byte, error)
+	// EncodeBatch encodes all the metrics of a MetricSet.
+	EncodeBatch(timestamp time.Time, tags map[string]string, points []metricPoint) ([]byte, error)
+}
+
+func getEncoder(encoding string, schemaID int) (messageEncoder, error) {
+	switch encoding {
+	case jsonEncoding:
+		return &jsonEncoder{}, nil
+	case protobufEncoding:
+		return &protobufEncoder{}, nil
+	case avroEncoding:
+		if schemaID < 0 {
+			return nil, fmt.Errorf("schema_id has to be set for the avro encoding")
+		}
+		return &avroEncoder{schemaID: uint32(schemaID)}, nil
+	default:
+		return nil, fmt.Errorf("Encoding '%s' is illegal. Use json, protobuf or avro", encoding)
+	}
+}
+
+type jsonEncoder struct{}
+
+func (this *jsonEncoder) EncodePoint(timestamp time.Time, tags map[string]string, point metricPoint) ([]byte, error) {
+	return json.Marshal(KafkaSinkPoint{
+		MetricsName: point.name,
+		MetricsTags: tags,
+		MetricsValue: map[string]interface{}{
+			"value": point.value.GetValue(),
+		},
+		MetricsTimestamp: timestamp,
+	})
+}
+
+func (this *jsonEncoder) EncodeBatch(timestamp time.Time, tags map[string]string, points []metricPoint) ([]byte, error) {
+	batch := KafkaSinkBatch{
+		MetricsTimestamp: timestamp,
+		MetricsTags:      tags,
+		Metrics:          make([]KafkaSinkMetric, 0, len(points)),
+	}
+	for _, point := range points {
+		batch.Metrics = append(batch.Metrics, KafkaSinkMetric{
+			MetricsName: point.name,
+			MetricsValue: map[string]interface{}{
+				"value": point.value.GetValue(),
+			},
+			MetricsTags: point.labels,
+		})
+	}
+	return json.Marshal(batch)
+}
+
+// protobufEncoder writes the MetricBatch message described in docs/sink-configuration.md. Single
+// metrics are sent as batches of one metric.
+type protobufEncoder struct{}
+
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+)
+
+func protoTag(buf *proto.Buffer, field int, wireType int) {
+	buf.EncodeVarint(uint64(field<<3 | wireType))
+}
+
+func protoString(buf *proto.Buffer, field int, value string) {
+	protoTag(buf, field, protoWireBytes)
+	buf.EncodeStringBytes(value)
+}
+
+func protoMap(buf *proto.Buffer, field int, values map[string]string) {
+	for _, key := range sortedKeys(values) {
+		entry := proto.NewBuffer(nil)
+		protoString(entry, 1, key)
+		protoString(entry, 2, values[key])
+		protoTag(buf, field, protoWireBytes)
+		buf.EncodeRawBytes(entry.Bytes())
+	}
+}
+
+func (this *protobufEncoder) EncodePoint(timestamp time.Time, tags map[string]string, point metricPoint) ([]byte, error) {
+	point.labels = nil
+	return this.EncodeBatch(timestamp, tags, []metricPoint{point})
+}
+
+func (this *protobufEncoder) EncodeBatch(timestamp time.Time, tags map[string]string, points []metricPoint) ([]byte, error) {
+	buf := proto.NewBuffer(nil)
+	protoTag(buf, 1, protoWireVarint)
+	buf.EncodeVarint(uint64(toMillis(timestamp)))
+	protoMap(buf, 2, tags)
+	for _, point := range points {
+		metric := proto.NewBuffer(nil)
+		protoString(metric, 1, point.name)
+		if point.value.ValueType == core.ValueFloat {
+			protoTag(metric, 3, protoWireFixed64)
+			metric.EncodeFixed64(math.Float64bits(float64(point.value.FloatValue)))
+		} else {
+			protoTag(metric, 2, protoWireVarint)
+			metric.EncodeVarint(uint64(point.value.IntValue))
+		}
+		protoMap(metric, 4, point.labels)
+		protoTag(buf, 3, protoWireBytes)
+		buf.EncodeRawBytes(metric.Bytes())
+	}
+	return buf.Bytes(), nil
+}
+
+// avroEncoder writes AvroSchema records in the binary encoding, prefixed with the magic byte and
+// the schema id like the schema registry serializers do. Single metrics are sent as batches of
+// one metric.
+type avroEncoder struct {
+	schemaID uint32
+}
+
+func avroLong(buf []byte, value int64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(scratch[:], value)
+	return append(buf, scratch[:n]...)
+}
+
+func avroString(buf []byte, value string) []byte {
+	buf = avroLong(buf, int64(len(value)))
+	return append(buf, value...)
+}
+
+func avroMap(buf []byte, values map[string]string) []byte {
+	if len(values) > 0 {
+		buf = avroLong(buf, int64(len(values)))
+		for _, key := range sortedKeys(values) {
+			buf = avroString(buf, key)
+			buf = avroString(buf, values[key])
+		}
+	}
+	return avroLong(buf, 0)
+}
+
+func (this *avroEncoder) EncodePoint(timestamp time.Time, tags map[string]string, point metricPoint) ([]byte, error) {
+	point.labels = nil
+	return this.EncodeBatch(timestamp, tags, []metricPoint{point})
+}
+
+func (this *avroEncoder) EncodeBatch(timestamp time.Time, tags map[string]string, points []metricPoint) ([]byte, error) {
+	buf := []byte{avroMagicByte, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(buf[1:], this.schemaID)
+
+	buf = avroLong(buf, toMillis(timestamp))
+	buf = avroMap(buf, tags)
+	if len(points) > 0 {
+		buf = avroLong(buf, int64(len(points)))
+		for _, point := range points {
+			buf = avroString(buf, point.name)
+			if point.value.ValueType == core.ValueFloat {
+				buf = avroLong(buf, 1)
+				var scratch [8]byte
+				binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(float64(point.value.FloatValue)))
+				buf = append(buf, scratch[:]...)
+			} else {
+				buf = avroLong(buf, 0)
+				buf = avroLong(buf, point.value.IntValue)
+			}
+			buf = avroMap(buf, point.labels)
+		}
+	}
+	return avroLong(buf, 0), nil
+}
+
+func toMillis(timestamp time.Time) int64 {
+	return timestamp.UnixNano() / int64(time.Millisecond)
+}
+
+// sortedKeys makes the binary encodings deterministic.
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
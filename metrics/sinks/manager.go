@@ -49,11 +49,23 @@ var (
 		},
 		[]string{"exporter"},
 	)
+
+	// Number of batches dropped because the sink was still busy with an earlier export.
+	droppedBatches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "exporter",
+			Name:      "dropped_batches_count",
+			Help:      "Number of batches dropped because the sink was still busy with an earlier export.",
+		},
+		[]string{"exporter"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(lastExportTimestamp)
 	prometheus.MustRegister(exporterDuration)
+	prometheus.MustRegister(droppedBatches)
 }
 
 type sinkHolder struct {
@@ -116,6 +128,7 @@ func (this *sinkManager) ExportData(data *core.DataBatch) {
 				// everything ok
 			case <-time.After(this.exportDataTimeout):
 				glog.Warningf("Failed to push data to sink: %s", sh.sink.Name())
+				droppedBatches.WithLabelValues(sh.sink.Name()).Inc()
 			}
 		}(sh, &wg)
 	}
@@ -18,6 +18,7 @@ import (
 	"testing"
 	"time"
 
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 
 	"k8s.io/heapster/metrics/core"
@@ -129,3 +130,24 @@ func TestStop(t *testing.T) {
 	assert.Equal(t, true, sink1.IsStopped())
 	assert.Equal(t, true, sink2.IsStopped())
 }
+
+func TestDroppedBatchesCounted(t *testing.T) {
+	timeout := 100 * time.Millisecond
+
+	sink := util.NewDummySink("slow", 30*time.Second)
+	manager, _ := NewDataSinkManager([]core.DataSink{sink}, timeout, timeout)
+
+	batch := core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{},
+	}
+
+	// The first batch is taken, the sink is busy with it when the next ones are pushed.
+	manager.ExportData(&batch)
+	manager.ExportData(&batch)
+	manager.ExportData(&batch)
+
+	value := &dto.Metric{}
+	assert.NoError(t, droppedBatches.WithLabelValues("slow").Write(value))
+	assert.Equal(t, float64(2), value.GetCounter().GetValue())
+}
@@ -0,0 +1,209 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	otlp_common "k8s.io/heapster/common/otlp"
+	"k8s.io/heapster/metrics/core"
+)
+
+// Heapster labels that map to the Kubernetes resource attributes of OpenTelemetry. The other
+// labels of a metric set are sent as resource attributes with the same name.
+var resourceAttributes = map[string]string{
+	core.LabelNodename.Key:           "k8s.node.name",
+	core.LabelNamespaceName.Key:      "k8s.namespace.name",
+	core.LabelPodName.Key:            "k8s.pod.name",
+	core.LabelPodId.Key:              "k8s.pod.uid",
+	core.LabelContainerName.Key:      "k8s.container.name",
+	core.LabelContainerBaseImage.Key: "container.image.name",
+	core.LabelHostname.Key:           "host.name",
+	core.LabelHostID.Key:             "host.id",
+	core.LabelClusterName.Key:        clusterNameAttribute,
+}
+
+const clusterNameAttribute = "k8s.cluster.name"
+
+// Descriptions and units of the known metrics, by name.
+var metricDescriptors = make(map[string]core.MetricDescriptor)
+
+func init() {
+	for _, metric := range core.AllMetrics {
+		metricDescriptors[metric.Name] = metric.MetricDescriptor
+	}
+}
+
+// unit returns the UCUM unit of the metric.
+func unit(units core.UnitsType) string {
+	switch units {
+	case core.UnitsBytes:
+		return "By"
+	case core.UnitsMilliseconds:
+		return "ms"
+	case core.UnitsNanoseconds:
+		return "ns"
+	case core.UnitsMillicores:
+		return "{millicores}"
+	}
+	return "1"
+}
+
+type otlpSink struct {
+	client      otlp_common.OtlpClient
+	clusterName string
+}
+
+func (sink *otlpSink) Name() string {
+	return "OTLP Sink"
+}
+
+func (sink *otlpSink) Stop() {
+	sink.client.Stop()
+}
+
+func (sink *otlpSink) ExportData(dataBatch *core.DataBatch) {
+	if len(dataBatch.MetricSets) == 0 {
+		return
+	}
+	start := time.Now()
+	request := sink.encodeBatch(dataBatch)
+	if err := sink.client.ExportMetrics(request); err != nil {
+		glog.Errorf("Failed to export metrics to the OTLP collector: %v", err)
+		return
+	}
+	glog.V(4).Infof("Exported %d metric sets over OTLP in %s", len(dataBatch.MetricSets), time.Since(start))
+}
+
+// labeledPoint is a data point of a metric.
+type labeledPoint struct {
+	labels map[string]string
+	value  core.MetricValue
+}
+
+// encodeBatch returns the ExportMetricsServiceRequest holding a ResourceMetrics per metric set.
+func (sink *otlpSink) encodeBatch(dataBatch *core.DataBatch) []byte {
+	keys := make([]string, 0, len(dataBatch.MetricSets))
+	for key := range dataBatch.MetricSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	request := otlp_common.NewMessage()
+	for _, key := range keys {
+		request.Message(otlp_common.FieldRequestResourceData, sink.encodeMetricSet(dataBatch.MetricSets[key], dataBatch.Timestamp))
+	}
+	return request.Bytes()
+}
+
+func (sink *otlpSink) encodeMetricSet(metricSet *core.MetricSet, timestamp time.Time) *otlp_common.Message {
+	attributes := make(map[string]string, len(metricSet.Labels)+1)
+	for key, value := range metricSet.Labels {
+		if value == "" {
+			continue
+		}
+		if attribute, found := resourceAttributes[key]; found {
+			key = attribute
+		}
+		attributes[key] = value
+	}
+	if _, found := attributes[clusterNameAttribute]; !found && sink.clusterName != "" {
+		attributes[clusterNameAttribute] = sink.clusterName
+	}
+
+	// Labeled metrics with the same name are the data points of a single metric.
+	points := make(map[string][]labeledPoint, len(metricSet.MetricValues)+len(metricSet.LabeledMetrics))
+	for name, value := range metricSet.MetricValues {
+		points[name] = append(points[name], labeledPoint{value: value})
+	}
+	for _, metric := range metricSet.LabeledMetrics {
+		points[metric.Name] = append(points[metric.Name], labeledPoint{labels: metric.Labels, value: metric.MetricValue})
+	}
+	names := make([]string, 0, len(points))
+	for name := range points {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	scopeMetrics := otlp_common.NewMessage()
+	scopeMetrics.Message(otlp_common.FieldScopeDataScope, otlp_common.Scope())
+	for _, name := range names {
+		scopeMetrics.Message(otlp_common.FieldScopeDataRecords, encodeMetric(name, points[name], metricSet.CollectionStartTime, timestamp))
+	}
+
+	resourceMetrics := otlp_common.NewMessage()
+	resourceMetrics.Message(otlp_common.FieldResourceDataResource, otlp_common.Resource(attributes))
+	resourceMetrics.Message(otlp_common.FieldResourceDataScopes, scopeMetrics)
+	return resourceMetrics
+}
+
+// encodeMetric returns a Metric holding a cumulative Sum for cumulative metrics, a delta Sum
+// for delta metrics and a Gauge otherwise.
+func encodeMetric(name string, points []labeledPoint, startTime, timestamp time.Time) *otlp_common.Message {
+	metric := otlp_common.NewMessage()
+	metric.String(otlp_common.FieldMetricName, name)
+	if descriptor, found := metricDescriptors[name]; found {
+		metric.String(otlp_common.FieldMetricDescription, descriptor.Description)
+		metric.String(otlp_common.FieldMetricUnit, unit(descriptor.Units))
+	}
+
+	data := otlp_common.NewMessage()
+	for _, point := range points {
+		dataPoint := otlp_common.NewMessage()
+		if point.value.MetricType == core.MetricCumulative && !startTime.IsZero() {
+			dataPoint.Fixed64(otlp_common.FieldPointStartTime, uint64(startTime.UnixNano()))
+		}
+		dataPoint.Fixed64(otlp_common.FieldPointTime, uint64(timestamp.UnixNano()))
+		if point.value.ValueType == core.ValueFloat {
+			dataPoint.Double(otlp_common.FieldPointAsDouble, point.value.FloatValue)
+		} else {
+			dataPoint.Fixed64(otlp_common.FieldPointAsInt, uint64(point.value.IntValue))
+		}
+		otlp_common.StringAttributes(dataPoint, otlp_common.FieldPointAttributes, point.labels)
+		data.Message(otlp_common.FieldDataPoints, dataPoint)
+	}
+
+	switch points[0].value.MetricType {
+	case core.MetricCumulative:
+		data.Varint(otlp_common.FieldSumTemporality, otlp_common.AggregationTemporalityCumul)
+		data.Varint(otlp_common.FieldSumIsMonotonic, 1)
+		metric.Message(otlp_common.FieldMetricSum, data)
+	case core.MetricDelta:
+		data.Varint(otlp_common.FieldSumTemporality, otlp_common.AggregationTemporalityDelta)
+		metric.Message(otlp_common.FieldMetricSum, data)
+	default:
+		metric.Message(otlp_common.FieldMetricGauge, data)
+	}
+	return metric
+}
+
+func NewOtlpSink(uri *url.URL) (core.DataSink, error) {
+	config, err := otlp_common.BuildConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+	client, err := otlp_common.NewClient(*config)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("created OTLP sink exporting to %s over %s", config.Endpoint, config.Protocol)
+	return &otlpSink{
+		client:      client,
+		clusterName: config.ClusterName,
+	}, nil
+}
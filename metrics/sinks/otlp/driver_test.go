@@ -0,0 +1,201 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	otlp_common "k8s.io/heapster/common/otlp"
+	"k8s.io/heapster/metrics/core"
+)
+
+func decodeRequest(t *testing.T, client *otlp_common.FakeOtlpClient) *otlp_common.FakeMetricsRequest {
+	require.Len(t, client.MetricsRequests, 1)
+	request := &otlp_common.FakeMetricsRequest{}
+	require.NoError(t, proto.Unmarshal(client.MetricsRequests[0], request))
+	return request
+}
+
+func TestExportEmptyBatch(t *testing.T) {
+	client := otlp_common.NewFakeOtlpClient()
+	sink := &otlpSink{client: client}
+	sink.ExportData(&core.DataBatch{Timestamp: time.Now(), MetricSets: map[string]*core.MetricSet{}})
+	assert.Empty(t, client.MetricsRequests)
+}
+
+func TestExportMetrics(t *testing.T) {
+	client := otlp_common.NewFakeOtlpClient()
+	sink := &otlpSink{client: client, clusterName: "prod"}
+
+	start := time.Unix(1000, 0)
+	timestamp := time.Unix(2000, 0)
+	sink.ExportData(&core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			"namespace:ns1/pod:pod1": {
+				CollectionStartTime: start,
+				Labels: map[string]string{
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "pod1",
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsage.Name: {
+						MetricType: core.MetricCumulative,
+						ValueType:  core.ValueInt64,
+						IntValue:   123,
+					},
+					core.MetricMemoryUsage.Name: {
+						MetricType: core.MetricGauge,
+						ValueType:  core.ValueInt64,
+						IntValue:   456,
+					},
+					"custom/rate": {
+						MetricType: core.MetricGauge,
+						ValueType:  core.ValueFloat,
+						FloatValue: 1.5,
+					},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:   "filesystem/usage",
+						Labels: map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{
+							MetricType: core.MetricGauge,
+							ValueType:  core.ValueInt64,
+							IntValue:   10,
+						},
+					},
+					{
+						Name:   "filesystem/usage",
+						Labels: map[string]string{core.LabelResourceID.Key: "/dev/sda2"},
+						MetricValue: core.MetricValue{
+							MetricType: core.MetricGauge,
+							ValueType:  core.ValueInt64,
+							IntValue:   20,
+						},
+					},
+				},
+			},
+		},
+	})
+
+	request := decodeRequest(t, client)
+	require.Len(t, request.ResourceMetrics, 1)
+	resource := request.ResourceMetrics[0]
+	assert.Equal(t, map[string]string{
+		"k8s.cluster.name":   "prod",
+		"k8s.namespace.name": "ns1",
+		"k8s.pod.name":       "pod1",
+		"type":               "pod",
+	}, otlp_common.FakeAttributes(resource.Resource.Attributes))
+
+	require.Len(t, resource.ScopeMetrics, 1)
+	assert.Equal(t, "k8s.io/heapster", resource.ScopeMetrics[0].Scope.Name)
+	metrics := map[string]*otlp_common.FakeMetric{}
+	for _, metric := range resource.ScopeMetrics[0].Metrics {
+		metrics[metric.Name] = metric
+	}
+	require.Len(t, metrics, 4)
+
+	cpu := metrics[core.MetricCpuUsage.Name]
+	assert.Equal(t, "ns", cpu.Unit)
+	assert.Equal(t, core.MetricCpuUsage.Description, cpu.Description)
+	require.NotNil(t, cpu.Sum)
+	assert.Nil(t, cpu.Gauge)
+	assert.True(t, cpu.Sum.IsMonotonic)
+	assert.Equal(t, int32(otlp_common.AggregationTemporalityCumul), cpu.Sum.AggregationTemporality)
+	require.Len(t, cpu.Sum.DataPoints, 1)
+	assert.Equal(t, uint64(start.UnixNano()), cpu.Sum.DataPoints[0].StartTimeUnixNano)
+	assert.Equal(t, uint64(timestamp.UnixNano()), cpu.Sum.DataPoints[0].TimeUnixNano)
+	assert.Equal(t, int64(123), *cpu.Sum.DataPoints[0].AsInt)
+
+	memory := metrics[core.MetricMemoryUsage.Name]
+	assert.Equal(t, "By", memory.Unit)
+	require.NotNil(t, memory.Gauge)
+	assert.Equal(t, int64(456), *memory.Gauge.DataPoints[0].AsInt)
+	assert.Zero(t, memory.Gauge.DataPoints[0].StartTimeUnixNano)
+
+	rate := metrics["custom/rate"]
+	assert.Empty(t, rate.Unit)
+	require.NotNil(t, rate.Gauge)
+	assert.Equal(t, 1.5, *rate.Gauge.DataPoints[0].AsDouble)
+
+	filesystem := metrics["filesystem/usage"]
+	require.NotNil(t, filesystem.Gauge)
+	require.Len(t, filesystem.Gauge.DataPoints, 2)
+	assert.Equal(t, map[string]string{"resource_id": "/dev/sda1"}, otlp_common.FakeAttributes(filesystem.Gauge.DataPoints[0].Attributes))
+	assert.Equal(t, int64(10), *filesystem.Gauge.DataPoints[0].AsInt)
+	assert.Equal(t, map[string]string{"resource_id": "/dev/sda2"}, otlp_common.FakeAttributes(filesystem.Gauge.DataPoints[1].Attributes))
+	assert.Equal(t, int64(20), *filesystem.Gauge.DataPoints[1].AsInt)
+}
+
+func TestExportMetricSetsAsResources(t *testing.T) {
+	client := otlp_common.NewFakeOtlpClient()
+	sink := &otlpSink{client: client}
+
+	value := core.MetricValue{MetricType: core.MetricGauge, ValueType: core.ValueInt64, IntValue: 1}
+	sink.ExportData(&core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			"node:n1": {
+				Labels:       map[string]string{core.LabelNodename.Key: "n1", core.LabelHostID.Key: "h1"},
+				MetricValues: map[string]core.MetricValue{"m": value},
+			},
+			"node:n2": {
+				Labels:       map[string]string{core.LabelNodename.Key: "n2"},
+				MetricValues: map[string]core.MetricValue{"m": value},
+			},
+		},
+	})
+
+	request := decodeRequest(t, client)
+	require.Len(t, request.ResourceMetrics, 2)
+	assert.Equal(t, map[string]string{"k8s.node.name": "n1", "host.id": "h1"},
+		otlp_common.FakeAttributes(request.ResourceMetrics[0].Resource.Attributes))
+	assert.Equal(t, map[string]string{"k8s.node.name": "n2"},
+		otlp_common.FakeAttributes(request.ResourceMetrics[1].Resource.Attributes))
+}
+
+func TestExportClusterLabel(t *testing.T) {
+	client := otlp_common.NewFakeOtlpClient()
+	sink := &otlpSink{client: client, clusterName: "default"}
+
+	value := core.MetricValue{MetricType: core.MetricGauge, ValueType: core.ValueInt64, IntValue: 1}
+	sink.ExportData(&core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			"node:n1": {
+				Labels:       map[string]string{core.LabelNodename.Key: "n1", core.LabelClusterName.Key: "east"},
+				MetricValues: map[string]core.MetricValue{"m": value},
+			},
+			"node:n2": {
+				Labels:       map[string]string{core.LabelNodename.Key: "n2"},
+				MetricValues: map[string]core.MetricValue{"m": value},
+			},
+		},
+	})
+
+	request := decodeRequest(t, client)
+	require.Len(t, request.ResourceMetrics, 2)
+	assert.Equal(t, map[string]string{"k8s.node.name": "n1", "k8s.cluster.name": "east"},
+		otlp_common.FakeAttributes(request.ResourceMetrics[0].Resource.Attributes))
+	assert.Equal(t, map[string]string{"k8s.node.name": "n2", "k8s.cluster.name": "default"},
+		otlp_common.FakeAttributes(request.ResourceMetrics[1].Resource.Attributes))
+}
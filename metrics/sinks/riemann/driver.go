@@ -16,7 +16,6 @@ package riemann
 
 import (
 	"net/url"
-	"sync"
 
 	"github.com/golang/glog"
 	"github.com/riemann/riemann-go-client"
@@ -24,11 +23,10 @@ import (
 	"k8s.io/heapster/metrics/core"
 )
 
-// contains the riemann client, the riemann configuration, and a RWMutex
+// contains the riemann batcher and the riemann configuration
 type RiemannSink struct {
-	client riemanngo.Client
-	config riemannCommon.RiemannConfig
-	sync.RWMutex
+	batcher *riemannCommon.Batcher
+	config  riemannCommon.RiemannConfig
 }
 
 // creates a Riemann sink. Returns a riemannSink
@@ -39,8 +37,8 @@ func CreateRiemannSink(uri *url.URL) (core.DataSink, error) {
 		return nil, err
 	}
 	rs := &RiemannSink{
-		client: sink.Client,
-		config: sink.Config,
+		batcher: riemannCommon.NewBatcher(sink.Config, sink.Client),
+		config:  sink.Config,
 	}
 	return rs, nil
 }
@@ -51,12 +49,12 @@ func (sink *RiemannSink) Name() string {
 }
 
 func (sink *RiemannSink) Stop() {
-	// nothing needs to be done.
+	sink.batcher.Stop()
 }
 
 // Receives a list of riemanngo.Event, the sink, and parameters.
 // Creates a new event using the parameters and the sink config, and add it into the Event list.
-// Can send events if events is full
+// The state of the event is derived from the metric state rules.
 // Return the list.
 func appendEvent(events []riemanngo.Event, sink *RiemannSink, host, name string, value interface{}, labels map[string]string, timestamp int64) []riemanngo.Event {
 	event := riemanngo.Event{
@@ -67,38 +65,16 @@ func appendEvent(events []riemanngo.Event, sink *RiemannSink, host, name string,
 		Attributes:  labels,
 		Metric:      value,
 		Ttl:         sink.config.Ttl,
-		State:       sink.config.State,
+		State:       sink.config.MetricState(name, value),
 		Tags:        sink.config.Tags,
 	}
 	// state everywhere
 	events = append(events, event)
-	if len(events) >= sink.config.BatchSize {
-		err := riemannCommon.SendData(sink.client, events)
-		if err != nil {
-			glog.Warningf("Error sending events to Riemann: %v", err)
-			// client will reconnect later
-			sink.client = nil
-		}
-		events = nil
-	}
 	return events
 }
 
 // ExportData Send a collection of Timeseries to Riemann
 func (sink *RiemannSink) ExportData(dataBatch *core.DataBatch) {
-	sink.Lock()
-	defer sink.Unlock()
-
-	if sink.client == nil {
-		// the client could be nil here, so we reconnect
-		client, err := riemannCommon.GetRiemannClient(sink.config)
-		if err != nil {
-			glog.Warningf("Riemann sink not connected: %v", err)
-			return
-		}
-		sink.client = client
-	}
-
 	var events []riemanngo.Event
 
 	for _, metricSet := range dataBatch.MetricSets {
@@ -125,13 +101,6 @@ func (sink *RiemannSink) ExportData(dataBatch *core.DataBatch) {
 			}
 		}
 	}
-	// Send events to Riemann if events is not empty
-	if len(events) > 0 {
-		err := riemannCommon.SendData(sink.client, events)
-		if err != nil {
-			glog.Warningf("Error sending events to Riemann: %v", err)
-			// client will reconnect later
-			sink.client = nil
-		}
-	}
+	// Queue the events, the batcher sends them to Riemann
+	sink.batcher.Add(events...)
 }
@@ -33,6 +33,7 @@ type fakeRiemannClient struct {
 type fakeRiemannSink struct {
 	core.DataSink
 	fakeRiemannClient *fakeRiemannClient
+	batcher           *riemannCommon.Batcher
 }
 
 func NewFakeRiemannClient() *fakeRiemannClient {
@@ -67,12 +68,14 @@ func NewFakeSink() fakeRiemannSink {
 		BatchSize: 1000,
 	}
 
+	batcher := riemannCommon.NewBatcher(c, riemannClient)
 	return fakeRiemannSink{
 		&RiemannSink{
-			client: riemannClient,
-			config: c,
+			batcher: batcher,
+			config:  c,
 		},
 		riemannClient,
+		batcher,
 	}
 }
 
@@ -85,7 +88,6 @@ func TestAppendEvent(t *testing.T) {
 		BatchSize: 1000,
 	}
 	sink := &RiemannSink{
-		client: nil,
 		config: c,
 	}
 	var events []riemanngo.Event
@@ -119,34 +121,36 @@ func TestAppendEvent(t *testing.T) {
 	})
 }
 
-func TestAppendEventFull(t *testing.T) {
-	riemannClient := NewFakeRiemannClient()
+func TestAppendEventState(t *testing.T) {
 	c := riemannCommon.RiemannConfig{
-		Host:      "riemann-heapster:5555",
-		Ttl:       60.0,
-		State:     "",
-		Tags:      make([]string, 0),
-		BatchSize: 1000,
+		Ttl:   60.0,
+		State: "ok",
+		Tags:  make([]string, 0),
+		MetricStateRules: []riemannCommon.MetricStateRule{
+			{Metric: "cpu/node_utilization", Op: ">", Threshold: 0.9, State: "critical"},
+			{Metric: "cpu/node_utilization", Op: ">", Threshold: 0.75, State: "warning"},
+		},
 	}
-	fakeSink := RiemannSink{
-		client: riemannClient,
+	sink := &RiemannSink{
 		config: c,
 	}
-
 	var events []riemanngo.Event
-	for i := 0; i < 999; i++ {
-		events = appendEvent(events, &fakeSink, "riemann", "service1", 10, map[string]string{}, 1)
-	}
-	assert.Equal(t, 999, len(events))
-	// batch size = 1000
-	events = appendEvent(events, &fakeSink, "riemann", "service1", 10, map[string]string{}, 1)
-	assert.Equal(t, 0, len(events))
+	events = appendEvent(events, sink, "riemann", "cpu/node_utilization", float32(0.95), map[string]string{}, 1)
+	events = appendEvent(events, sink, "riemann", "cpu/node_utilization", float32(0.8), map[string]string{}, 1)
+	events = appendEvent(events, sink, "riemann", "cpu/node_utilization", float32(0.5), map[string]string{}, 1)
+	events = appendEvent(events, sink, "riemann", "memory/usage", int64(1000), map[string]string{}, 1)
+	assert.Equal(t, 4, len(events))
+	assert.Equal(t, "critical", events[0].State)
+	assert.Equal(t, "warning", events[1].State)
+	assert.Equal(t, "ok", events[2].State)
+	assert.Equal(t, "ok", events[3].State)
 }
 
 func TestStoreDataEmptyInput(t *testing.T) {
 	fakeSink := NewFakeSink()
 	dataBatch := core.DataBatch{}
 	fakeSink.ExportData(&dataBatch)
+	fakeSink.batcher.Flush()
 	assert.Equal(t, 0, len(fakeSink.fakeRiemannClient.events))
 }
 
@@ -263,6 +267,7 @@ func TestStoreMultipleDataInput(t *testing.T) {
 
 	timeValue := timestamp.Unix()
 	fakeSink.ExportData(&data)
+	fakeSink.batcher.Flush()
 
 	assert.Equal(t, 6, len(fakeSink.fakeRiemannClient.events))
 	var expectedEvents = []*proto.Event{
@@ -45,13 +45,15 @@ const (
 )
 
 type StackdriverSink struct {
-	project               string
-	clusterName           string
-	clusterLocation       string
-	heapsterZone          string
-	stackdriverClient     *sd_api.MetricClient
-	minInterval           time.Duration
-	lastExportTime        time.Time
+	project           string
+	clusterName       string
+	clusterLocation   string
+	heapsterZone      string
+	stackdriverClient *sd_api.MetricClient
+	minInterval       time.Duration
+	// Time of the last batch exported by cluster, the batches of several clusters
+	// may be exported to the same sink.
+	lastExportTime        map[string]time.Time
 	batchExportTimeoutSec int
 	initialDelaySec       int
 	useOldResourceModel   bool
@@ -125,11 +127,16 @@ func (sink *StackdriverSink) processMetrics(metricValues map[string]core.MetricV
 
 func (sink *StackdriverSink) ExportData(dataBatch *core.DataBatch) {
 	// Make sure we don't export metrics too often.
-	if dataBatch.Timestamp.Before(sink.lastExportTime.Add(sink.minInterval)) {
-		glog.V(2).Infof("Skipping batch from %s because there hasn't passed %s from last export time %s", dataBatch.Timestamp, sink.minInterval, sink.lastExportTime)
+	cluster := batchClusterName(dataBatch)
+	lastExportTime := sink.lastExportTime[cluster]
+	if dataBatch.Timestamp.Before(lastExportTime.Add(sink.minInterval)) {
+		glog.V(2).Infof("Skipping batch from %s because there hasn't passed %s from last export time %s", dataBatch.Timestamp, sink.minInterval, lastExportTime)
 		return
 	}
-	sink.lastExportTime = dataBatch.Timestamp
+	if sink.lastExportTime == nil {
+		sink.lastExportTime = make(map[string]time.Time)
+	}
+	sink.lastExportTime[cluster] = dataBatch.Timestamp
 
 	requests := []*monitoringpb.CreateTimeSeriesRequest{}
 	req := getReq(sink.project)
@@ -813,10 +820,28 @@ func (sink *StackdriverSink) TranslateMetric(timestamp time.Time, labels map[str
 	return nil
 }
 
+// batchClusterName returns the cluster label of the metric sets of the batch, empty
+// unless Heapster collects several clusters.
+func batchClusterName(dataBatch *core.DataBatch) string {
+	for _, metricSet := range dataBatch.MetricSets {
+		return metricSet.Labels[core.LabelClusterName.Key]
+	}
+	return ""
+}
+
+// resourceClusterName returns the cluster of the metric set when Heapster collects
+// several clusters, and the cluster of the sink otherwise.
+func (sink *StackdriverSink) resourceClusterName(labels map[string]string) string {
+	if cluster := labels[core.LabelClusterName.Key]; cluster != "" {
+		return cluster
+	}
+	return sink.clusterName
+}
+
 func (sink *StackdriverSink) legacyGetResourceLabels(labels map[string]string) map[string]string {
 	return map[string]string{
 		"project_id":     sink.project,
-		"cluster_name":   sink.clusterName,
+		"cluster_name":   sink.resourceClusterName(labels),
 		"zone":           sink.heapsterZone,
 		"instance_id":    labels[core.LabelHostID.Key],
 		"namespace_id":   labels[core.LabelPodNamespaceUID.Key],
@@ -829,7 +854,7 @@ func (sink *StackdriverSink) getContainerResourceLabels(labels map[string]string
 	return map[string]string{
 		"project_id":     sink.project,
 		"location":       sink.clusterLocation,
-		"cluster_name":   sink.clusterName,
+		"cluster_name":   sink.resourceClusterName(labels),
 		"namespace_name": labels[core.LabelNamespaceName.Key],
 		"pod_name":       labels[core.LabelPodName.Key],
 		"container_name": labels[core.LabelContainerName.Key],
@@ -840,7 +865,7 @@ func (sink *StackdriverSink) getPodResourceLabels(labels map[string]string) map[
 	return map[string]string{
 		"project_id":     sink.project,
 		"location":       sink.clusterLocation,
-		"cluster_name":   sink.clusterName,
+		"cluster_name":   sink.resourceClusterName(labels),
 		"namespace_name": labels[core.LabelNamespaceName.Key],
 		"pod_name":       labels[core.LabelPodName.Key],
 	}
@@ -850,7 +875,7 @@ func (sink *StackdriverSink) getNodeResourceLabels(labels map[string]string) map
 	return map[string]string{
 		"project_id":   sink.project,
 		"location":     sink.clusterLocation,
-		"cluster_name": sink.clusterName,
+		"cluster_name": sink.resourceClusterName(labels),
 		"node_name":    labels[core.LabelNodename.Key],
 	}
 }
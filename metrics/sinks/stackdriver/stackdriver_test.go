@@ -377,3 +377,18 @@ func TestTranslateMetricSet(t *testing.T) {
 	as.Equal(int64(6), containerEphemeralStorageRequest.GetInt64Value())
 	as.Equal(int64(7), containerEphemeralStorageLimit.GetInt64Value())
 }
+
+func TestResourceClusterName(t *testing.T) {
+	as := assert.New(t)
+	sink := &StackdriverSink{project: testProjectId, clusterName: "sink-cluster"}
+
+	as.Equal("sink-cluster", sink.getPodResourceLabels(podLabels)["cluster_name"])
+	labels := deepCopy(podLabels)
+	labels[core.LabelClusterName.Key] = "prod"
+	as.Equal("prod", sink.getPodResourceLabels(labels)["cluster_name"])
+	as.Equal("prod", sink.legacyGetResourceLabels(labels)["cluster_name"])
+
+	batch := &core.DataBatch{MetricSets: map[string]*core.MetricSet{"pod": {Labels: labels}}}
+	as.Equal("prod", batchClusterName(batch))
+	as.Equal("", batchClusterName(&core.DataBatch{}))
+}
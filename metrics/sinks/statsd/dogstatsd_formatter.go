@@ -0,0 +1,139 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+)
+
+// Metric types of the DogStatsD protocol.
+const (
+	dogstatsdGauge        = "g"
+	dogstatsdCount        = "c"
+	dogstatsdHistogram    = "h"
+	dogstatsdDistribution = "d"
+	dogstatsdTiming       = "ms"
+)
+
+// DogStatsD metric types by the name used in the sink options.
+var dogstatsdTypes = map[string]string{
+	"gauge":        dogstatsdGauge,
+	"count":        dogstatsdCount,
+	"histogram":    dogstatsdHistogram,
+	"distribution": dogstatsdDistribution,
+	"timing":       dogstatsdTiming,
+}
+
+// Cumulative metrics are sent as gauges by default, since counts are increments for DogStatsD.
+var defaultDogstatsdMetricTypes = map[core.MetricType]string{
+	core.MetricGauge:      dogstatsdGauge,
+	core.MetricCumulative: dogstatsdGauge,
+	core.MetricDelta:      dogstatsdCount,
+}
+
+type DogstatsdFormatter struct {
+	nameReplacer     *strings.Replacer
+	tagKeyReplacer   *strings.Replacer
+	tagValueReplacer *strings.Replacer
+	// DogStatsD type of each type of metric.
+	metricTypes map[core.MetricType]string
+	// Fraction of the counts, histograms, distributions and timings that are sent.
+	sampleRate float64
+	random     func() float64
+}
+
+// Format returns "<PREFIX><METRIC>:<VALUE>|<TYPE>[|@<SAMPLE_RATE>][|#<TAGS>]". An empty string is
+// returned for metrics that are skipped by the sampling.
+func (formatter *DogstatsdFormatter) Format(prefix string, name string, labels map[string]string, customizeLabel CustomizeLabel, metricValue core.MetricValue) (res string, err error) {
+	metricType, found := formatter.metricTypes[metricValue.MetricType]
+	if !found {
+		metricType = dogstatsdGauge
+	}
+	sampled := formatter.sampleRate < 1 && metricType != dogstatsdGauge
+	if sampled && formatter.random() >= formatter.sampleRate {
+		return "", nil
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%s%s:%v|%s",
+		formatter.nameReplacer.Replace(prefix),
+		formatter.nameReplacer.Replace(name),
+		metricValue.GetValue(),
+		metricType))
+	if sampled {
+		buffer.WriteString("|@")
+		buffer.WriteString(strconv.FormatFloat(formatter.sampleRate, 'g', -1, 64))
+	}
+
+	expandedLabels := expandUserLabels(labels)
+	keys := make([]string, 0, len(expandedLabels))
+	for k, v := range expandedLabels {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i == 0 {
+			buffer.WriteString("|#")
+		} else {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(fmt.Sprintf("%s:%s",
+			customizeLabel(formatter.tagKeyReplacer.Replace(k)),
+			formatter.tagValueReplacer.Replace(expandedLabels[k])))
+	}
+	return buffer.String(), nil
+}
+
+// ParseDogstatsdType returns the DogStatsD metric type named gauge, count, histogram,
+// distribution or timing.
+func ParseDogstatsdType(name string) (string, error) {
+	metricType, found := dogstatsdTypes[strings.ToLower(name)]
+	if !found {
+		return "", fmt.Errorf("unknown DogStatsD metric type %q, use gauge, count, histogram, distribution or timing", name)
+	}
+	return metricType, nil
+}
+
+// NewDogstatsdFormatter creates a formatter sending the metrics with the given DogStatsD types.
+// Types missing from metricTypes default to gauges for gauge and cumulative metrics, and to
+// counts for delta metrics.
+func NewDogstatsdFormatter(metricTypes map[core.MetricType]string, sampleRate float64) Formatter {
+	types := make(map[core.MetricType]string, len(defaultDogstatsdMetricTypes))
+	for metricType, dogstatsdType := range defaultDogstatsdMetricTypes {
+		types[metricType] = dogstatsdType
+	}
+	for metricType, dogstatsdType := range metricTypes {
+		types[metricType] = dogstatsdType
+	}
+	glog.V(2).Info("dogstatsd formatter is created")
+	return &DogstatsdFormatter{
+		nameReplacer:     strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_"),
+		tagKeyReplacer:   strings.NewReplacer(":", "_", "|", "_", "#", "_", ",", "_", "\n", "_"),
+		tagValueReplacer: strings.NewReplacer("|", "_", "#", "_", ",", "_", "\n", "_"),
+		metricTypes:      types,
+		sampleRate:       sampleRate,
+		random:           rand.Float64,
+	}
+}
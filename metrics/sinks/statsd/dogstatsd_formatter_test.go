@@ -0,0 +1,100 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/heapster/metrics/core"
+)
+
+var dogstatsdLabels = map[string]string{
+	"namespace_name":     "default",
+	"pod_name":           "pod|1",
+	core.LabelLabels.Key: "app:web,tier:frontend",
+	"empty":              "",
+}
+
+func TestDogstatsdFormatGauge(t *testing.T) {
+	formatter := NewDogstatsdFormatter(nil, 1)
+	msg, err := formatter.Format("k8s.", "memory/usage", dogstatsdLabels, DefaultLabelStyle, core.MetricValue{
+		MetricType: core.MetricGauge,
+		ValueType:  core.ValueInt64,
+		IntValue:   1000,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "k8s.memory/usage:1000|g|#app:web,namespace_name:default,pod_name:pod_1,tier:frontend", msg)
+}
+
+func TestDogstatsdFormatWithoutLabels(t *testing.T) {
+	formatter := NewDogstatsdFormatter(nil, 1)
+	msg, err := formatter.Format("", "cpu/usage_rate", nil, DefaultLabelStyle, core.MetricValue{
+		MetricType: core.MetricGauge,
+		ValueType:  core.ValueFloat,
+		FloatValue: 1.5,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "cpu/usage_rate:1.5|g", msg)
+}
+
+func TestDogstatsdMetricTypes(t *testing.T) {
+	cumulative := core.MetricValue{MetricType: core.MetricCumulative, ValueType: core.ValueInt64, IntValue: 5}
+	delta := core.MetricValue{MetricType: core.MetricDelta, ValueType: core.ValueInt64, IntValue: 2}
+
+	formatter := NewDogstatsdFormatter(nil, 1)
+	msg, err := formatter.Format("", "cpu/usage", nil, DefaultLabelStyle, cumulative)
+	assert.NoError(t, err)
+	assert.Equal(t, "cpu/usage:5|g", msg)
+	msg, err = formatter.Format("", "restarts", nil, DefaultLabelStyle, delta)
+	assert.NoError(t, err)
+	assert.Equal(t, "restarts:2|c", msg)
+
+	formatter = NewDogstatsdFormatter(map[core.MetricType]string{core.MetricDelta: dogstatsdDistribution}, 1)
+	msg, err = formatter.Format("", "restarts", map[string]string{"pod_name": "p"}, SnakeToLowerCamel, delta)
+	assert.NoError(t, err)
+	assert.Equal(t, "restarts:2|d|#podName:p", msg)
+}
+
+func TestDogstatsdSampleRate(t *testing.T) {
+	formatter := NewDogstatsdFormatter(nil, 0.25).(*DogstatsdFormatter)
+	random := 0.0
+	formatter.random = func() float64 { return random }
+	delta := core.MetricValue{MetricType: core.MetricDelta, ValueType: core.ValueInt64, IntValue: 2}
+	gauge := core.MetricValue{MetricType: core.MetricGauge, ValueType: core.ValueInt64, IntValue: 3}
+
+	msg, err := formatter.Format("", "restarts", nil, DefaultLabelStyle, delta)
+	assert.NoError(t, err)
+	assert.Equal(t, "restarts:2|c|@0.25", msg)
+
+	random = 0.5
+	msg, err = formatter.Format("", "restarts", nil, DefaultLabelStyle, delta)
+	assert.NoError(t, err)
+	assert.Empty(t, msg)
+
+	// Gauges are never sampled.
+	msg, err = formatter.Format("", "memory/usage", nil, DefaultLabelStyle, gauge)
+	assert.NoError(t, err)
+	assert.Equal(t, "memory/usage:3|g", msg)
+}
+
+func TestParseDogstatsdType(t *testing.T) {
+	metricType, err := ParseDogstatsdType("Histogram")
+	assert.NoError(t, err)
+	assert.Equal(t, "h", metricType)
+
+	_, err = ParseDogstatsdType("set")
+	assert.Error(t, err)
+}
@@ -28,6 +28,8 @@ const (
 	defaultHost             = "localhost:8125"
 	defaultNumMetricsPerMsg = 5
 	defaultProtocolType     = "etsystatsd"
+	// Payload of a UDP datagram fitting an Ethernet frame, used by default for DogStatsD.
+	defaultDogstatsdMaxPacketSize = 1432
 )
 
 type statsdSink struct {
@@ -45,6 +47,12 @@ type statsdConfig struct {
 	renameLabels     map[string]string
 	allowedLabels    map[string]string
 	customizeLabel   CustomizeLabel
+	// Metrics are packed in datagrams of up to maxPacketSize bytes instead of by
+	// numMetricsPerMsg when it is positive.
+	maxPacketSize int
+	// DogStatsD types of the metrics, and fraction of the non gauge metrics that are sent.
+	metricTypes map[core.MetricType]string
+	sampleRate  float64
 }
 
 func getConfig(uri *url.URL) (cfg statsdConfig, err error) {
@@ -56,6 +64,8 @@ func getConfig(uri *url.URL) (cfg statsdConfig, err error) {
 		renameLabels:     make(map[string]string),
 		allowedLabels:    make(map[string]string),
 		customizeLabel:   nil,
+		metricTypes:      make(map[core.MetricType]string),
+		sampleRate:       1,
 	}
 
 	if len(uri.Host) > 0 {
@@ -72,6 +82,42 @@ func getConfig(uri *url.URL) (cfg statsdConfig, err error) {
 	if len(opts["protocolType"]) >= 1 {
 		config.protocolType = strings.ToLower(opts["protocolType"][0])
 	}
+	if config.protocolType == "dogstatsd" {
+		config.maxPacketSize = defaultDogstatsdMaxPacketSize
+	}
+	if len(opts["maxPacketSize"]) >= 1 {
+		val, err := strconv.Atoi(opts["maxPacketSize"][0])
+		if err != nil {
+			return config, fmt.Errorf("failed to parse `maxPacketSize` field - %v", err)
+		}
+		if val < 0 {
+			return config, fmt.Errorf("maxPacketSize can't be negative : %d", val)
+		}
+		config.maxPacketSize = val
+	}
+	for option, metricType := range map[string]core.MetricType{
+		"gaugeType":      core.MetricGauge,
+		"cumulativeType": core.MetricCumulative,
+		"deltaType":      core.MetricDelta,
+	} {
+		if len(opts[option]) >= 1 {
+			dogstatsdType, err := ParseDogstatsdType(opts[option][0])
+			if err != nil {
+				return config, fmt.Errorf("failed to parse `%s` field - %v", option, err)
+			}
+			config.metricTypes[metricType] = dogstatsdType
+		}
+	}
+	if len(opts["sampleRate"]) >= 1 {
+		val, err := strconv.ParseFloat(opts["sampleRate"][0], 64)
+		if err != nil {
+			return config, fmt.Errorf("failed to parse `sampleRate` field - %v", err)
+		}
+		if val <= 0 || val > 1 {
+			return config, fmt.Errorf("sampleRate should be in (0, 1] : %v", val)
+		}
+		config.sampleRate = val
+	}
 	if len(opts["prefix"]) >= 1 {
 		config.prefix = opts["prefix"][0]
 	}
@@ -132,6 +178,10 @@ func (sink *statsdSink) ExportData(dataBatch *core.DataBatch) {
 				glog.Errorf("statsd metrics sink - failed to format metrics : %s", err.Error())
 				continue
 			}
+			if tmpstr == "" {
+				// Skipped by the sampling.
+				continue
+			}
 			metrics = append(metrics, tmpstr)
 		}
 		for _, metric := range metricSet.LabeledMetrics {
@@ -150,6 +200,9 @@ func (sink *statsdSink) ExportData(dataBatch *core.DataBatch) {
 				glog.Errorf("statsd metrics sink - failed to format labeled metrics : %v", err)
 				continue
 			}
+			if tmpstr == "" {
+				continue
+			}
 			metrics = append(metrics, tmpstr)
 		}
 	}
@@ -174,9 +227,14 @@ func NewStatsdSinkWithClient(uri *url.URL, client statsdClient) (sink core.DataS
 	if err != nil {
 		return nil, err
 	}
-	formatter, err := NewFormatter(config.protocolType)
-	if err != nil {
-		return nil, err
+	var formatter Formatter
+	if config.protocolType == "dogstatsd" {
+		formatter = NewDogstatsdFormatter(config.metricTypes, config.sampleRate)
+	} else {
+		formatter, err = NewFormatter(config.protocolType)
+		if err != nil {
+			return nil, err
+		}
 	}
 	glog.V(2).Info("statsd metrics sink is created")
 	return &statsdSink{
@@ -191,7 +249,7 @@ func NewStatsdSink(uri *url.URL) (sink core.DataSink, err error) {
 	if err != nil {
 		return nil, err
 	}
-	client, err := NewStatsdClient(config.host, config.numMetricsPerMsg)
+	client, err := NewStatsdClient(config.host, config.numMetricsPerMsg, config.maxPacketSize)
 	if err != nil {
 		return nil, err
 	}
@@ -116,3 +116,51 @@ func TestDriverExportData(t *testing.T) {
 		assert.Contains(t, res, expectedMsg)
 	}
 }
+
+func TestDogstatsdConfig(t *testing.T) {
+	uri, err := url.Parse("udp://127.0.0.1:8125?protocolType=dogstatsd&cumulativeType=count&deltaType=histogram&sampleRate=0.5")
+	assert.NoError(t, err)
+	config, err := getConfig(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultDogstatsdMaxPacketSize, config.maxPacketSize)
+	assert.Equal(t, map[core.MetricType]string{
+		core.MetricCumulative: "c",
+		core.MetricDelta:      "h",
+	}, config.metricTypes)
+	assert.Equal(t, 0.5, config.sampleRate)
+
+	uri, err = url.Parse("udp://127.0.0.1:8125?protocolType=dogstatsd&maxPacketSize=8192")
+	assert.NoError(t, err)
+	config, err = getConfig(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, 8192, config.maxPacketSize)
+
+	for _, query := range []string{"deltaType=set", "sampleRate=0", "sampleRate=2", "maxPacketSize=-1"} {
+		uri, err = url.Parse("udp://127.0.0.1:8125?protocolType=dogstatsd&" + query)
+		assert.NoError(t, err)
+		_, err = getConfig(uri)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestDogstatsdExportData(t *testing.T) {
+	uri, err := url.Parse("udp://127.0.0.1:8125?protocolType=dogstatsd&prefix=k8s.&allowedLabels=pod_name")
+	assert.NoError(t, err)
+
+	client := &dummyStatsdClientImpl{messages: nil}
+	sink, err := NewStatsdSinkWithClient(uri, client)
+	assert.NoError(t, err)
+
+	sink.ExportData(&core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			"pod1": {
+				Labels: map[string]string{"pod_name": "pod1", "namespace_name": "default"},
+				MetricValues: map[string]core.MetricValue{
+					"cpu/usage": {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: 10},
+				},
+			},
+		},
+	})
+	assert.Equal(t, []string{"k8s.cpu/usage:10|g|#pod_name:pod1"}, client.messages)
+}
@@ -46,11 +46,35 @@ func NewFormatter(protocolType string) (formatter Formatter, err error) {
 		return NewEtsystatsdFormatter(), nil
 	case "influxstatsd":
 		return NewInfluxstatsdFormatter(), nil
+	case "dogstatsd":
+		return NewDogstatsdFormatter(nil, 1), nil
 	default:
 		return nil, fmt.Errorf("Unknown statd formatter %s", protocolType)
 	}
 }
 
+// expandUserLabels returns the labels with the user labels of the pod, which are
+// concatenated in the LabelLabels label, added as separate labels.
+func expandUserLabels(labels map[string]string) map[string]string {
+	res := make(map[string]string)
+	var userLabelStr string
+	for k, v := range labels {
+		if k == core.LabelLabels.Key {
+			userLabelStr = v
+		} else {
+			res[k] = v
+		}
+	}
+	kvPairs := strings.Split(userLabelStr, ",")
+	for _, kvPair := range kvPairs {
+		kv := strings.Split(kvPair, ":")
+		if len(kv) >= 2 {
+			res[kv[0]] = kv[1]
+		}
+	}
+	return res
+}
+
 func DefaultLabelStyle(str string) string {
 	return str
 }
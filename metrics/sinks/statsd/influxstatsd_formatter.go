@@ -30,7 +30,7 @@ type InfluxstatsdFormatter struct {
 func (formatter *InfluxstatsdFormatter) Format(prefix string, name string, labels map[string]string, customizeLabel CustomizeLabel, metricValue core.MetricValue) (res string, err error) {
 	var buffer bytes.Buffer
 	buffer.WriteString(fmt.Sprintf("%s%s", formatter.delimReplacer.Replace(prefix), formatter.delimReplacer.Replace(name)))
-	expandedLabels := formatter.expandUserLabels(labels)
+	expandedLabels := expandUserLabels(labels)
 	keys := make([]string, len(expandedLabels))
 	for k := range expandedLabels {
 		keys = append(keys, k)
@@ -47,26 +47,6 @@ func (formatter *InfluxstatsdFormatter) Format(prefix string, name string, label
 	return buffer.String(), nil
 }
 
-func (formatter *InfluxstatsdFormatter) expandUserLabels(labels map[string]string) map[string]string {
-	res := make(map[string]string)
-	var userLabelStr string
-	for k, v := range labels {
-		if k == core.LabelLabels.Key {
-			userLabelStr = v
-		} else {
-			res[k] = v
-		}
-	}
-	kvPairs := strings.Split(userLabelStr, ",")
-	for _, kvPair := range kvPairs {
-		kv := strings.Split(kvPair, ":")
-		if len(kv) >= 2 {
-			res[kv[0]] = kv[1]
-		}
-	}
-	return res
-}
-
 func NewInfluxstatsdFormatter() Formatter {
 	glog.V(2).Info("influxstatsd formatter is created")
 	return &InfluxstatsdFormatter{
@@ -30,7 +30,10 @@ type statsdClient interface {
 type statsdClientImpl struct {
 	host             string
 	numMetricsPerMsg int
-	conn             net.Conn
+	// Datagrams are filled up to maxPacketSize bytes when it is positive, and hold
+	// numMetricsPerMsg messages otherwise.
+	maxPacketSize int
+	conn          net.Conn
 }
 
 func (client *statsdClientImpl) open() error {
@@ -62,6 +65,9 @@ func (client *statsdClientImpl) send(messages []string) error {
 			return fmt.Errorf("send() failed - %v", err)
 		}
 	}
+	if client.maxPacketSize > 0 {
+		return client.sendPackets(messages)
+	}
 	var numMetrics = 0
 	var err, tmpErr error
 	buf := bytes.NewBufferString("")
@@ -86,10 +92,38 @@ func (client *statsdClientImpl) send(messages []string) error {
 	return err
 }
 
-func NewStatsdClient(host string, numMetricsPerMsg int) (client statsdClient, err error) {
+// sendPackets packs as many messages as possible in every datagram. Messages longer than
+// maxPacketSize are sent alone.
+func (client *statsdClientImpl) sendPackets(messages []string) error {
+	var err, tmpErr error
+	buf := bytes.NewBufferString("")
+	for _, msg := range messages {
+		if buf.Len() > 0 && buf.Len()+len(msg)+1 > client.maxPacketSize {
+			_, tmpErr = client.conn.Write(buf.Bytes())
+			if tmpErr != nil {
+				err = tmpErr
+			}
+			buf.Reset()
+		}
+		buf.WriteString(msg)
+		buf.WriteString("\n")
+	}
+	if buf.Len() > 0 {
+		_, tmpErr = client.conn.Write(buf.Bytes())
+		if tmpErr != nil {
+			err = tmpErr
+		}
+	}
+	return err
+}
+
+func NewStatsdClient(host string, numMetricsPerMsg int, maxPacketSize int) (client statsdClient, err error) {
 	if numMetricsPerMsg <= 0 {
 		return nil, fmt.Errorf("numMetricsPerMsg should be a positive integer : %d", numMetricsPerMsg)
 	}
+	if maxPacketSize < 0 {
+		return nil, fmt.Errorf("maxPacketSize can't be negative : %d", maxPacketSize)
+	}
 	glog.V(2).Infof("statsd client created")
-	return &statsdClientImpl{host: host, numMetricsPerMsg: numMetricsPerMsg}, nil
+	return &statsdClientImpl{host: host, numMetricsPerMsg: numMetricsPerMsg, maxPacketSize: maxPacketSize}, nil
 }
@@ -41,7 +41,7 @@ var msgs = [...]string{
 }
 
 func TestInvalidHostname(t *testing.T) {
-	client, err := NewStatsdClient("badhostname:8125", validNumMetricsPerMsg)
+	client, err := NewStatsdClient("badhostname:8125", validNumMetricsPerMsg, 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
 	err = client.open()
@@ -49,13 +49,13 @@ func TestInvalidHostname(t *testing.T) {
 }
 
 func TestInvalidPortNumber(t *testing.T) {
-	client, err := NewStatsdClient("localhost", validNumMetricsPerMsg)
+	client, err := NewStatsdClient("localhost", validNumMetricsPerMsg, 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
 	err = client.open()
 	assert.Error(t, err, "Error expected - missing port number")
 
-	client, err = NewStatsdClient("localhost:-8125", validNumMetricsPerMsg)
+	client, err = NewStatsdClient("localhost:-8125", validNumMetricsPerMsg, 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
 	err = client.open()
@@ -63,15 +63,15 @@ func TestInvalidPortNumber(t *testing.T) {
 }
 
 func TestInvalidNumMetricsPerMsg(t *testing.T) {
-	_, err := NewStatsdClient(validHost, 0)
+	_, err := NewStatsdClient(validHost, 0, 0)
 	assert.Error(t, err, "Error expected - number of metrics per message cannot be 0")
 
-	_, err = NewStatsdClient(validHost, -1)
+	_, err = NewStatsdClient(validHost, -1, 0)
 	assert.Error(t, err, "Error expected - number of metrics per message cannot be negative")
 }
 
 func TestClose(t *testing.T) {
-	client, err := NewStatsdClient(validHost, validNumMetricsPerMsg)
+	client, err := NewStatsdClient(validHost, validNumMetricsPerMsg, 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
 	err = client.close()
@@ -86,7 +86,7 @@ func TestClose(t *testing.T) {
 }
 
 func initClientServer(t *testing.T, messages []string, numMetricsPerMsg int) (client statsdClient, serverConn *net.UDPConn) {
-	client, err := NewStatsdClient(validHost, numMetricsPerMsg)
+	client, err := NewStatsdClient(validHost, numMetricsPerMsg, 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
 
@@ -178,3 +178,40 @@ func TestSendMultipleMsgsUnequalBatches(t *testing.T) {
 	assert.NoError(t, err)
 	conn.Close()
 }
+
+func TestInvalidMaxPacketSize(t *testing.T) {
+	_, err := NewStatsdClient(validHost, validNumMetricsPerMsg, -1)
+	assert.Error(t, err, "Error expected - maximum packet size cannot be negative")
+}
+
+func TestSendPackets(t *testing.T) {
+	buf := make([]byte, bufferSize)
+	// Fits two messages of 15 bytes with their newline.
+	client, err := NewStatsdClient(validHost, 1, 32)
+	assert.NoError(t, err)
+	assert.NoError(t, client.open())
+
+	addr, err := net.ResolveUDPAddr("udp", validHost)
+	assert.NoError(t, err)
+	conn, err := net.ListenUDP("udp", addr)
+	assert.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	longMsg := strings.Repeat("x", 40)
+	messages := []string{msgs[0], msgs[1], msgs[2], longMsg, msgs[3]}
+	assert.NoError(t, client.send(messages))
+
+	for _, expectedMsg := range []string{
+		strings.Join(msgs[0:2], "\n") + "\n",
+		msgs[2] + "\n",
+		longMsg + "\n",
+		msgs[3] + "\n",
+	} {
+		n, _, err := conn.ReadFromUDP(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedMsg, string(buf[0:n]))
+	}
+
+	assert.NoError(t, client.close())
+	conn.Close()
+}
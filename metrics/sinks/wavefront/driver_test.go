@@ -15,13 +15,21 @@
 package wavefront
 
 import (
-	"github.com/stretchr/testify/assert"
-	"k8s.io/heapster/metrics/core"
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/heapster/metrics/core"
 )
 
 var (
@@ -124,6 +132,260 @@ func TestCreateWavefrontSinkWithNoEmptyInputs(t *testing.T) {
 	assert.Equal(t, true, wfSink.IncludeContainers)
 }
 
+func TestDeltaCounters(t *testing.T) {
+	fakeSink := NewFakeWavefrontSink()
+	fakeSink.DeltaCounters = true
+
+	batch := generateFakeBatch()
+	fakeSink.ExportData(batch)
+	// the cumulative metrics have no previous value yet
+	assert.Equal(t, len(batch.MetricSets)-2, len(fakeSink.testReceivedLines))
+	for _, line := range fakeSink.testReceivedLines {
+		assert.False(t, strings.HasPrefix(line, deltaPrefix))
+	}
+
+	batch = generateFakeBatch()
+	batch.MetricSets["m2"] = generateMetricSet("cpu/usage", core.MetricCumulative, 43363764)
+	batch.MetricSets["m8"] = generateMetricSet("uptime", core.MetricCumulative, 100)
+	fakeSink.ExportData(batch)
+	var deltas []string
+	for _, line := range fakeSink.testReceivedLines {
+		if strings.HasPrefix(line, deltaPrefix) {
+			deltas = append(deltas, strings.Join(strings.Fields(line)[:2], " "))
+		}
+	}
+	// uptime was reset, so its value is the increase
+	assert.Equal(t, []string{
+		deltaPrefix + ".cpu.usage 100",
+		deltaPrefix + ".uptime 100",
+	}, deltas)
+}
+
+// clusterBatch returns a batch with the cpu/usage of a node of the cluster.
+func clusterBatch(cluster string, timestamp time.Time, usage int64) *core.DataBatch {
+	ms := generateMetricSet("cpu/usage", core.MetricCumulative, usage)
+	ms.Labels = map[string]string{"type": "node", "hostname": "node1", "cluster": cluster}
+	return &core.DataBatch{Timestamp: timestamp, MetricSets: map[string]*core.MetricSet{"node1": ms}}
+}
+
+func TestDeltaCountersOfSeveralClusters(t *testing.T) {
+	fakeSink := NewFakeWavefrontSink()
+	fakeSink.DeltaCounters = true
+	start := time.Unix(1500000000, 0)
+
+	// The clusters export their batches to the same sink in turns.
+	fakeSink.ExportData(clusterBatch("prod", start, 1000))
+	fakeSink.ExportData(clusterBatch("staging", start, 5000))
+	fakeSink.ExportData(clusterBatch("prod", start.Add(time.Minute), 1100))
+	require.Len(t, fakeSink.testReceivedLines, 1)
+	assert.Equal(t, deltaPrefix+"node.cpu.usage 100 source=\"node1\" cluster=\"prod\" type=\"node\" \n",
+		fakeSink.testReceivedLines[0])
+	fakeSink.ExportData(clusterBatch("staging", start.Add(time.Minute), 5300))
+	require.Len(t, fakeSink.testReceivedLines, 1)
+	assert.Contains(t, fakeSink.testReceivedLines[0], deltaPrefix+"node.cpu.usage 300 ")
+	assert.Contains(t, fakeSink.testReceivedLines[0], "cluster=\"staging\"")
+
+	// The series of a cluster not exported for a while are forgotten.
+	fakeSink.ExportData(clusterBatch("prod", start.Add(20*time.Minute), 1200))
+	assert.Empty(t, fakeSink.testReceivedLines)
+	assert.Len(t, fakeSink.counters, 1)
+}
+
+func TestDeltaCountersWithDroppedTags(t *testing.T) {
+	fakeSink := NewFakeWavefrontSink()
+	fakeSink.DeltaCounters = true
+	fakeSink.MaxTagValues = 1
+	start := time.Unix(1500000000, 0)
+
+	podBatch := func(timestamp time.Time, usages map[string]int64) *core.DataBatch {
+		batch := &core.DataBatch{Timestamp: timestamp, MetricSets: map[string]*core.MetricSet{}}
+		for pod, usage := range usages {
+			ms := generateMetricSet("cpu/usage", core.MetricCumulative, usage)
+			ms.Labels = map[string]string{"type": "pod", "hostname": "node1", "pod_name": pod}
+			batch.MetricSets[pod] = ms
+		}
+		return batch
+	}
+	// The pod_name tags of the last two pods are dropped, the pods are still separate series.
+	fakeSink.ExportData(podBatch(start, map[string]int64{"pod1": 1000, "pod2": 10, "pod3": 500}))
+	assert.Empty(t, fakeSink.testReceivedLines)
+	fakeSink.ExportData(podBatch(start.Add(time.Minute), map[string]int64{"pod1": 1100, "pod2": 30, "pod3": 600}))
+	assert.Equal(t, []string{
+		deltaPrefix + "pod.cpu.usage 100 source=\"node1\" cluster=\"testCluster\" pod_name=\"pod1\" type=\"pod\" \n",
+		deltaPrefix + "pod.cpu.usage 20 source=\"node1\" cluster=\"testCluster\" type=\"pod\" \n",
+		deltaPrefix + "pod.cpu.usage 100 source=\"node1\" cluster=\"testCluster\" type=\"pod\" \n",
+	}, fakeSink.testReceivedLines)
+}
+
+func TestHistograms(t *testing.T) {
+	fakeSink := NewFakeWavefrontSink()
+	fakeSink.Prefix = "heapster."
+	fakeSink.HistogramMetrics = []string{"cpu/usage_rate"}
+
+	batch := &core.DataBatch{Timestamp: time.Unix(1500000000, 0), MetricSets: map[string]*core.MetricSet{}}
+	for i, pod := range []struct {
+		node  string
+		usage int64
+	}{{"node1", 100}, {"node1", 250}, {"node1", 100}, {"node2", 50}} {
+		ms := generateMetricSet("cpu/usage_rate", core.MetricGauge, pod.usage)
+		ms.Labels = map[string]string{"type": "pod", "nodename": pod.node, "hostname": pod.node}
+		batch.MetricSets["pod"+strconv.Itoa(i)] = ms
+	}
+	node := generateMetricSet("cpu/usage_rate", core.MetricGauge, 450)
+	node.Labels = map[string]string{"type": "node", "nodename": "node1", "hostname": "node1"}
+	batch.MetricSets["node1"] = node
+
+	fakeSink.ExportData(batch)
+	// the points of the pods and the node, then the distributions
+	assert.Equal(t, 7, len(fakeSink.testReceivedLines))
+	assert.Equal(t, []string{
+		"!M 1500000000 #2 100 #1 250 heapster.pod.cpu.usage_rate source=\"node1\" cluster=\"testCluster\" nodename=\"node1\" \n",
+		"!M 1500000000 #1 50 heapster.pod.cpu.usage_rate source=\"node2\" cluster=\"testCluster\" nodename=\"node2\" \n",
+	}, fakeSink.histograms)
+}
+
+func TestHistogramsOfSeveralClusters(t *testing.T) {
+	fakeSink := NewFakeWavefrontSink()
+	fakeSink.HistogramMetrics = []string{"cpu/usage_rate"}
+
+	batch := &core.DataBatch{Timestamp: time.Unix(1500000000, 0), MetricSets: map[string]*core.MetricSet{}}
+	for _, cluster := range []string{"prod", "staging"} {
+		ms := generateMetricSet("cpu/usage_rate", core.MetricGauge, 100)
+		ms.Labels = map[string]string{"type": "pod", "nodename": "node1", "hostname": "node1", "cluster": cluster}
+		batch.MetricSets[cluster] = ms
+	}
+
+	fakeSink.ExportData(batch)
+	assert.Equal(t, []string{
+		"!M 1500000000 #1 100 pod.cpu.usage_rate source=\"node1\" cluster=\"prod\" nodename=\"node1\" \n",
+		"!M 1500000000 #1 100 pod.cpu.usage_rate source=\"node1\" cluster=\"staging\" nodename=\"node1\" \n",
+	}, fakeSink.histograms)
+}
+
+func TestTagLimits(t *testing.T) {
+	fakeSink := NewFakeWavefrontSink()
+	fakeSink.MaxTags = 2
+	assert.Equal(t, "a=\"1\" b=\"2\" ", fakeSink.tagsToString(map[string]string{"label.x": "0", "b": "2", "a": "1"}))
+	assert.Equal(t, int64(1), fakeSink.stats.tagsDropped)
+
+	fakeSink = NewFakeWavefrontSink()
+	fakeSink.MaxTagValues = 2
+	assert.Equal(t, "pod_name=\"a\" ", fakeSink.tagsToString(map[string]string{"pod_name": "a"}))
+	assert.Equal(t, "pod_name=\"b\" ", fakeSink.tagsToString(map[string]string{"pod_name": "b"}))
+	assert.Equal(t, "", fakeSink.tagsToString(map[string]string{"pod_name": "c"}))
+	assert.Equal(t, "pod_name=\"a\" ", fakeSink.tagsToString(map[string]string{"pod_name": "a"}))
+	assert.Equal(t, int64(1), fakeSink.stats.tagsDropped)
+
+	// a value not sent within the retention makes room for a new one
+	fakeSink.TagValuesRetention = time.Hour
+	start := time.Unix(1500000000, 0)
+	fakeSink.exportTime = start.Add(30 * time.Minute)
+	fakeSink.expireTagValues()
+	assert.Equal(t, "pod_name=\"a\" ", fakeSink.tagsToString(map[string]string{"pod_name": "a"}))
+	fakeSink.exportTime = start.Add(90 * time.Minute)
+	fakeSink.expireTagValues()
+	assert.Equal(t, "pod_name=\"c\" ", fakeSink.tagsToString(map[string]string{"pod_name": "c"}))
+	assert.Equal(t, "", fakeSink.tagsToString(map[string]string{"pod_name": "b"}))
+	assert.Equal(t, int64(2), fakeSink.stats.tagsDropped)
+
+	fakeSink = NewFakeWavefrontSink()
+	tagStr := fakeSink.tagsToString(map[string]string{"msg": "say \"hi\"", "long": strings.Repeat("x", 300)})
+	assert.Equal(t, "long=\""+strings.Repeat("x", 250)+"\" msg=\"say \\\"hi\\\"\" ", tagStr)
+}
+
+type fakeWavefrontServer struct {
+	sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	status   int
+}
+
+func (s *fakeWavefrontServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+	}
+}
+
+func TestDirectIngestion(t *testing.T) {
+	fake := &fakeWavefrontServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	uri, err := url.Parse(server.URL + "?token=secret&batchSize=5&clusterName=testCluster&histograms=true")
+	require.NoError(t, err)
+	sink, err := NewWavefrontSink(uri)
+	require.NoError(t, err)
+	wfSink := sink.(*wavefrontSink)
+	assert.Equal(t, server.URL, wfSink.Server)
+	assert.Equal(t, defaultHistogramMetrics, wfSink.HistogramMetrics)
+
+	sink.ExportData(generateFakeBatch())
+	// 8 points in batches of 5, then the sink counters
+	require.Equal(t, 3, len(fake.requests))
+	for _, r := range fake.requests {
+		assert.Equal(t, "/report", r.URL.Path)
+		assert.Equal(t, "wavefront", r.URL.Query().Get("f"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+	}
+	assert.Equal(t, 5, strings.Count(fake.bodies[0], "\n"))
+	assert.Equal(t, 3, strings.Count(fake.bodies[1], "\n"))
+	assert.Contains(t, fake.bodies[2], "heapster.sink.wavefront.points.sent 8 ")
+	assert.Equal(t, int64(8+3), wfSink.stats.pointsSent)
+
+	fake.status = http.StatusUnauthorized
+	sink.ExportData(generateFakeBatch())
+	assert.Equal(t, int64(8+3), wfSink.stats.pointsFailed)
+	fake.status = 0
+	sink.ExportData(&core.DataBatch{Timestamp: time.Now()})
+	assert.Contains(t, fake.bodies[len(fake.bodies)-1], "heapster.sink.wavefront.points.failed 11 ")
+}
+
+func TestDirectIngestionRequiresToken(t *testing.T) {
+	uri, err := url.Parse("https://example.wavefront.com")
+	require.NoError(t, err)
+	_, err = NewWavefrontSink(uri)
+	assert.Error(t, err)
+}
+
+func TestProxyReporterReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			scanner := bufio.NewScanner(conn)
+			scanner.Scan()
+			lines <- scanner.Text()
+			// drop the connection after every line
+			conn.Close()
+		}
+	}()
+
+	reporter := &proxyReporter{address: listener.Addr().String()}
+	defer reporter.close()
+	require.NoError(t, reporter.send(formatWavefront, []string{"a 1 source=\"x\"\n"}))
+	assert.Equal(t, "a 1 source=\"x\"", <-lines)
+
+	// writes to the dropped connection fail at some point, after which it is reopened
+	for i := 0; i < 100 && reporter.conn != nil; i++ {
+		reporter.send(formatWavefront, []string{"b 1 source=\"x\"\n"})
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Nil(t, reporter.conn)
+	require.NoError(t, reporter.send(formatWavefront, []string{"c 1 source=\"x\"\n"}))
+	assert.Equal(t, "c 1 source=\"x\"", <-lines)
+}
+
 func generateFakeBatch() *core.DataBatch {
 	batch := core.DataBatch{
 		Timestamp:  time.Now(),
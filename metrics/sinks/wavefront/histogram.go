@@ -0,0 +1,109 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wavefront
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// sendHistograms sends, for every node, the distribution of the values of
+// the histogram metrics over the pods running on it, e.g. the CPU usage of
+// all the pods of a node. The distributions have a minute granularity.
+func (wfSink *wavefrontSink) sendHistograms(batch *core.DataBatch) {
+	// cluster and node -> metric -> values
+	distributions := make(map[clusterNode]map[string][]float64)
+	for _, ms := range batch.MetricSets {
+		if ms.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePod {
+			continue
+		}
+		node := clusterNode{cluster: ms.Labels[core.LabelClusterName.Key], node: ms.Labels[core.LabelNodename.Key]}
+		if node.node == "" {
+			continue
+		}
+		if node.cluster == "" {
+			node.cluster = wfSink.ClusterName
+		}
+		for _, metricName := range wfSink.HistogramMetrics {
+			metricValue, found := ms.MetricValues[metricName]
+			if !found {
+				continue
+			}
+			value, ok := floatValue(metricValue)
+			if !ok {
+				continue
+			}
+			if distributions[node] == nil {
+				distributions[node] = make(map[string][]float64)
+			}
+			distributions[node][metricName] = append(distributions[node][metricName], value)
+		}
+	}
+
+	ts := strconv.FormatInt(batch.Timestamp.Unix(), 10)
+	for _, node := range sortedNodes(distributions) {
+		tagStr := wfSink.tagsToString(map[string]string{
+			"cluster":              node.cluster,
+			core.LabelNodename.Key: node.node,
+		})
+		for _, metricName := range wfSink.HistogramMetrics {
+			values := distributions[node][metricName]
+			if len(values) == 0 {
+				continue
+			}
+			wfSink.histograms = append(wfSink.histograms, fmt.Sprintf("!M %s %s %s source=\"%s\" %s\n",
+				ts, centroids(values), wfSink.cleanMetricName(core.MetricSetTypePod, metricName), node.node, tagStr))
+		}
+	}
+}
+
+// centroids formats the values as `#<count> <value>` pairs, counting equal values once.
+func centroids(values []float64) string {
+	sort.Float64s(values)
+	var parts []string
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j] == values[i] {
+			j++
+		}
+		parts = append(parts, fmt.Sprintf("#%d %s", j-i, formatFloat(values[i])))
+		i = j
+	}
+	return strings.Join(parts, " ")
+}
+
+// clusterNode identifies a node, the batches of several clusters may be exported to the sink.
+type clusterNode struct {
+	cluster string
+	node    string
+}
+
+func sortedNodes(m map[clusterNode]map[string][]float64) []clusterNode {
+	keys := make([]clusterNode, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].cluster != keys[j].cluster {
+			return keys[i].cluster < keys[j].cluster
+		}
+		return keys[i].node < keys[j].node
+	})
+	return keys
+}
@@ -0,0 +1,109 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wavefront
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	formatWavefront = "wavefront"
+	formatHistogram = "histogram"
+)
+
+// reporter delivers lines of the Wavefront data format, either points or
+// histogram distributions depending on the format.
+type reporter interface {
+	send(format string, lines []string) error
+	close()
+}
+
+// proxyReporter writes to the socket of a Wavefront proxy, which accepts
+// points and distributions on the same port. The connection is kept open and
+// reopened on the next send after a failed write.
+type proxyReporter struct {
+	address string
+	conn    net.Conn
+}
+
+func (r *proxyReporter) send(format string, lines []string) error {
+	if r.conn == nil {
+		conn, err := net.DialTimeout("tcp", r.address, 10*time.Second)
+		if err != nil {
+			return fmt.Errorf("unable to connect to Wavefront proxy at address %s: %v", r.address, err)
+		}
+		glog.Infof("Connected to Wavefront proxy at address: %s", r.address)
+		r.conn = conn
+	}
+	r.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := io.WriteString(r.conn, strings.Join(lines, "")); err != nil {
+		r.close()
+		return err
+	}
+	return nil
+}
+
+func (r *proxyReporter) close() {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+// directReporter posts to the direct ingestion API of a Wavefront server,
+// authenticating with an API token.
+type directReporter struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+func newDirectReporter(server, token string) *directReporter {
+	return &directReporter{
+		server: strings.TrimSuffix(server, "/"),
+		token:  token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (r *directReporter) send(format string, lines []string) error {
+	req, err := http.NewRequest("POST", r.server+"/report?f="+format, bytes.NewBufferString(strings.Join(lines, "")))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+r.token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("wavefront server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (r *directReporter) close() {}
@@ -16,29 +16,112 @@ package wavefront
 
 import (
 	"fmt"
-	"github.com/golang/glog"
-	"k8s.io/heapster/metrics/core"
-	"net"
 	"net/url"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/heapster/metrics/core"
 )
 
 const (
 	sysSubContainerName = "system.slice/"
+	// Prefix of the delta counters, aggregated by Wavefront instead of stored as is.
+	deltaPrefix = "\u2206"
+	// Wavefront rejects points with a point tag longer than this, key and value included.
+	maxTagLength = 254
+	// How long a tag value counts against maxTagValues after it was last sent.
+	defaultTagValuesRetention = time.Hour
+	// How long the last value of a delta counter series is kept after it was last exported.
+	counterRetention = 10 * time.Minute
 )
 
 var excludeTagList = [...]string{"namespace_id", "host_id", "pod_id", "hostname"}
 
+// The per-node aggregates sent as histograms by default.
+var defaultHistogramMetrics = []string{
+	core.MetricCpuUsageRate.Name,
+	core.MetricMemoryUsage.Name,
+	core.MetricMemoryWorkingSet.Name,
+}
+
+var (
+	// Sink performance metrics
+
+	pointsSent = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "wavefront",
+			Name:      "points_count",
+			Help:      "Number of points and distributions sent to Wavefront, by result",
+		},
+		[]string{"result"},
+	)
+	tagsDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "wavefront",
+			Name:      "dropped_tags_count",
+			Help:      "Number of point tags dropped by the point tag limits",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(pointsSent)
+	prometheus.MustRegister(tagsDropped)
+}
+
+// lastCounter is the value of a cumulative series when it was last exported.
+type lastCounter struct {
+	value float64
+	seen  time.Time
+}
+
+// sinkStats counts the failures of the sink since it started, and is
+// reported to Wavefront along with the metrics.
+type sinkStats struct {
+	pointsSent   int64
+	pointsFailed int64
+	tagsDropped  int64
+}
+
 type wavefrontSink struct {
-	Conn              net.Conn
 	ProxyAddress      string
+	Server            string
 	ClusterName       string
 	Prefix            string
 	IncludeLabels     bool
 	IncludeContainers bool
+	// Send the cumulative metrics as delta counters
+	DeltaCounters bool
+	// Send the distribution of the pod values of these metrics on every node
+	HistogramMetrics []string
+	BatchSize        int
+	// Point tag limits, per point and per tag key. Zero disables a limit.
+	MaxTags      int
+	MaxTagValues int
+	// A tag value not sent for this long no longer counts against MaxTagValues.
+	TagValuesRetention time.Duration
+
+	reporter reporter
+	stats    sinkStats
+	// Cumulative values of the series by series key, for the delta counters. The
+	// batches of several clusters may be exported to the same sink, each with some
+	// of the series.
+	counters map[string]lastCounter
+	// values seen for every tag key with the time of the export they were last
+	// sent in, for the cardinality limit
+	tagValues map[string]map[string]time.Time
+	// Timestamp of the batch being exported.
+	exportTime time.Time
+
+	points            []string
+	histograms        []string
 	testMode          bool
 	testReceivedLines []string
 }
@@ -48,26 +131,13 @@ func (wfSink *wavefrontSink) Name() string {
 }
 
 func (wfSink *wavefrontSink) Stop() {
-	// Do nothing.
-	wfSink.Conn.Close()
+	if wfSink.reporter != nil {
+		wfSink.reporter.close()
+	}
 }
 
 func (wfSink *wavefrontSink) sendLine(line string) {
-	if wfSink.testMode {
-		wfSink.testReceivedLines = append(wfSink.testReceivedLines, line)
-		glog.Infoln(line)
-		return
-	}
-	//if the connection was closed or interrupted - don't cause a panic (we'll retry at next interval)
-	defer func() {
-		if r := recover(); r != nil {
-			//we couldn't write the line so something is wrong with the connection
-			wfSink.Conn = nil
-		}
-	}()
-	if wfSink.Conn != nil {
-		wfSink.Conn.Write([]byte(line))
-	}
+	wfSink.points = append(wfSink.points, line)
 }
 
 func (wfSink *wavefrontSink) sendPoint(metricName string, metricValStr string, ts string, source string, tagStr string) {
@@ -75,17 +145,186 @@ func (wfSink *wavefrontSink) sendPoint(metricName string, metricValStr string, t
 	wfSink.sendLine(metricLine)
 }
 
-func tagsToString(tags map[string]string) string {
-	tagStr := ""
+// sendDelta sends the increase of a cumulative metric since the last export
+// as a delta counter. Delta counters carry no timestamp. The series is the full
+// tag set, before the point tag limits dropped some of the tags.
+func (wfSink *wavefrontSink) sendDelta(metricName string, value float64, source string, tagStr string, series string) {
+	key := metricName + " " + source + " " + series
+	if wfSink.counters == nil {
+		wfSink.counters = make(map[string]lastCounter)
+	}
+	last, found := wfSink.counters[key]
+	wfSink.counters[key] = lastCounter{value: value, seen: wfSink.exportTime}
+	if !found {
+		// first value of the series, the next export sends the increase
+		return
+	}
+	delta := value - last.value
+	if value < last.value {
+		// the counter was reset
+		delta = value
+	}
+	if delta == 0 {
+		return
+	}
+	wfSink.sendLine(fmt.Sprintf("%s%s %s source=\"%s\" %s\n", deltaPrefix, metricName, formatFloat(delta), source, tagStr))
+}
+
+// sendMetric sends a point with the formatted tags. The tags are the ones of the
+// point before the point tag limits, which identify the series of a delta counter.
+func (wfSink *wavefrontSink) sendMetric(metricName string, metricValue core.MetricValue, ts string, source string, tagStr string, tags map[string]string) {
+	var metricValStr string
+	if core.ValueInt64 == metricValue.ValueType {
+		metricValStr = fmt.Sprintf("%d", metricValue.IntValue)
+	} else if core.ValueFloat == metricValue.ValueType { // W
+		metricValStr = fmt.Sprintf("%f", metricValue.FloatValue)
+	} else {
+		//do nothing for now
+		return
+	}
+	if wfSink.DeltaCounters && metricValue.MetricType == core.MetricCumulative {
+		value, _ := floatValue(metricValue)
+		wfSink.sendDelta(metricName, value, source, tagStr, seriesKey(tags))
+		return
+	}
+	wfSink.sendPoint(metricName, metricValStr, ts, source, tagStr)
+}
+
+func floatValue(metricValue core.MetricValue) (float64, bool) {
+	switch metricValue.ValueType {
+	case core.ValueInt64:
+		return float64(metricValue.IntValue), true
+	case core.ValueFloat:
+		return float64(metricValue.FloatValue), true
+	}
+	return 0, false
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func escapeTagValue(value string) string {
+	return strings.Replace(value, "\"", "\\\"", -1)
+}
+
+// seriesKey formats all the point tags, without the point tag limits.
+func seriesKey(tags map[string]string) string {
+	var keys []string
+	for k, v := range tags {
+		if excludeTag(k) || len(v) == 0 {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	key := ""
+	for _, k := range keys {
+		key += k + "=\"" + escapeTagValue(tags[k]) + "\" "
+	}
+	return key
+}
+
+// tagsToString formats the point tags, applying the point tag limits. Tags
+// from Kubernetes labels are the first dropped when a point has too many.
+func (wfSink *wavefrontSink) tagsToString(tags map[string]string) string {
+	var keys, labelKeys []string
 	for k, v := range tags {
 		// ignore tags with empty values as well so the data point doesn't fail validation
-		if excludeTag(k) == false && len(v) > 0 {
-			tagStr += k + "=\"" + v + "\" "
+		if excludeTag(k) || len(v) == 0 {
+			continue
+		}
+		if strings.HasPrefix(k, "label.") {
+			labelKeys = append(labelKeys, k)
+		} else {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	sort.Strings(labelKeys)
+	keys = append(keys, labelKeys...)
+
+	tagStr := ""
+	count := 0
+	for _, k := range keys {
+		v := tags[k]
+		if wfSink.MaxTags > 0 && count >= wfSink.MaxTags {
+			wfSink.dropTag()
+			continue
+		}
+		if !wfSink.allowTagValue(k, v) {
+			wfSink.dropTag()
+			continue
+		}
+		if len(k)+len(v) > maxTagLength {
+			if len(k) >= maxTagLength {
+				wfSink.dropTag()
+				continue
+			}
+			v = v[:maxTagLength-len(k)]
 		}
+		tagStr += k + "=\"" + escapeTagValue(v) + "\" "
+		count++
 	}
 	return tagStr
 }
 
+// allowTagValue returns whether the value is one of at most MaxTagValues
+// values sent for the tag key within the retention.
+func (wfSink *wavefrontSink) allowTagValue(key, value string) bool {
+	if wfSink.MaxTagValues <= 0 {
+		return true
+	}
+	if wfSink.tagValues == nil {
+		wfSink.tagValues = make(map[string]map[string]time.Time)
+	}
+	values, found := wfSink.tagValues[key]
+	if !found {
+		values = make(map[string]time.Time)
+		wfSink.tagValues[key] = values
+	}
+	if _, found := values[value]; !found && len(values) >= wfSink.MaxTagValues {
+		return false
+	}
+	values[value] = wfSink.exportTime
+	return true
+}
+
+// expireTagValues forgets the tag values not sent within the retention, which
+// makes room for new values of their key.
+func (wfSink *wavefrontSink) expireTagValues() {
+	if wfSink.TagValuesRetention <= 0 {
+		return
+	}
+	cutoff := wfSink.exportTime.Add(-wfSink.TagValuesRetention)
+	for key, values := range wfSink.tagValues {
+		for value, lastSent := range values {
+			if lastSent.Before(cutoff) {
+				delete(values, value)
+			}
+		}
+		if len(values) == 0 {
+			delete(wfSink.tagValues, key)
+		}
+	}
+}
+
+// expireCounters forgets the series of the delta counters not exported for a while,
+// e.g. of deleted pods.
+func (wfSink *wavefrontSink) expireCounters() {
+	cutoff := wfSink.exportTime.Add(-counterRetention)
+	for key, last := range wfSink.counters {
+		if last.seen.Before(cutoff) {
+			delete(wfSink.counters, key)
+		}
+	}
+}
+
+func (wfSink *wavefrontSink) dropTag() {
+	wfSink.stats.tagsDropped++
+	tagsDropped.Inc()
+}
+
 func excludeTag(a string) bool {
 	for _, b := range excludeTagList {
 		if b == a {
@@ -121,8 +360,10 @@ func (wfSink *wavefrontSink) addLabelTags(ms *core.MetricSet, tags map[string]st
 }
 
 func (wfSink *wavefrontSink) send(batch *core.DataBatch) {
-
-	metricCounter := 0
+	wfSink.exportTime = batch.Timestamp
+	wfSink.expireTagValues()
+	wfSink.expireCounters()
+	ts := strconv.FormatInt(batch.Timestamp.Unix(), 10)
 
 	for _, key := range sortedMetricSetKeys(batch.MetricSets) {
 		ms := batch.MetricSets[key]
@@ -142,99 +383,110 @@ func (wfSink *wavefrontSink) send(batch *core.DataBatch) {
 			// the user doesn't want to include container metrics (only pod and above)
 			continue
 		}
+		source := ""
+		if metricType == "cluster" {
+			source = tags["cluster"]
+		} else if metricType == "ns" {
+			source = tags["namespace_name"] + "-ns"
+		} else {
+			source = tags["hostname"]
+		}
+		tagStr := wfSink.tagsToString(tags)
 		for _, metricName := range sortedMetricValueKeys(ms.MetricValues) {
-			var metricValStr string
-			metricValue := ms.MetricValues[metricName]
-			if core.ValueInt64 == metricValue.ValueType {
-				metricValStr = fmt.Sprintf("%d", metricValue.IntValue)
-			} else if core.ValueFloat == metricValue.ValueType { // W
-				metricValStr = fmt.Sprintf("%f", metricValue.FloatValue)
-			} else {
-				//do nothing for now
-				metricValStr = ""
-			}
-			if metricValStr != "" {
-				ts := strconv.FormatInt(batch.Timestamp.Unix(), 10)
-				source := ""
-				if metricType == "cluster" {
-					source = wfSink.ClusterName
-				} else if metricType == "ns" {
-					source = tags["namespace_name"] + "-ns"
-				} else {
-					source = tags["hostname"]
-				}
-				tagStr := tagsToString(tags)
-				wfSink.sendPoint(wfSink.cleanMetricName(metricType, metricName), metricValStr, ts, source, tagStr)
-				metricCounter = metricCounter + 1
-			}
+			wfSink.sendMetric(wfSink.cleanMetricName(metricType, metricName), ms.MetricValues[metricName], ts, source, tagStr, tags)
 		}
 		for _, metric := range ms.LabeledMetrics {
-			metricName := wfSink.cleanMetricName(metricType, metric.Name)
-			metricValStr := ""
-			if core.ValueInt64 == metric.ValueType {
-				metricValStr = fmt.Sprintf("%d", metric.IntValue)
-			} else if core.ValueFloat == metric.ValueType { // W
-				metricValStr = fmt.Sprintf("%f", metric.FloatValue)
-			} else {
-				//do nothing for now
-				metricValStr = ""
+			metricTags := make(map[string]string, len(tags)+len(metric.Labels))
+			for k, v := range tags {
+				metricTags[k] = v
 			}
-			if metricValStr != "" {
-				ts := strconv.FormatInt(batch.Timestamp.Unix(), 10)
-				source := tags["hostname"]
-				tagStr := tagsToString(tags)
-				for labelName, labelValue := range metric.Labels {
-					tagStr += labelName + "=\"" + labelValue + "\" "
-				}
-				metricCounter = metricCounter + 1
-				wfSink.sendPoint(metricName, metricValStr, ts, source, tagStr)
+			for k, v := range metric.Labels {
+				metricTags[k] = v
 			}
+			wfSink.sendMetric(wfSink.cleanMetricName(metricType, metric.Name), metric.MetricValue, ts, tags["hostname"],
+				wfSink.tagsToString(metricTags), metricTags)
 		}
 	}
 
-}
-
-func (wfSink *wavefrontSink) ExportData(batch *core.DataBatch) {
-
-	if wfSink.testMode {
-		//clear lines from last batch
-		wfSink.testReceivedLines = wfSink.testReceivedLines[:0]
-		wfSink.send(batch)
-		return
+	if len(wfSink.HistogramMetrics) > 0 {
+		wfSink.sendHistograms(batch)
 	}
+}
 
-	//make sure we're Connected before sending a real batch
-	err := wfSink.connect()
-	if err != nil {
-		glog.Warning(err)
+// statsLines returns the points reporting the failure counters of the sink.
+func (wfSink *wavefrontSink) statsLines(ts string) []string {
+	tagStr := wfSink.tagsToString(map[string]string{"cluster": wfSink.ClusterName})
+	var lines []string
+	for _, stat := range []struct {
+		name  string
+		value int64
+	}{
+		{"points.sent", wfSink.stats.pointsSent},
+		{"points.failed", wfSink.stats.pointsFailed},
+		{"tags.dropped", wfSink.stats.tagsDropped},
+	} {
+		lines = append(lines, fmt.Sprintf("%ssink.wavefront.%s %d %s source=\"%s\" %s\n",
+			wfSink.Prefix, stat.name, stat.value, ts, wfSink.ClusterName, tagStr))
 	}
+	return lines
+}
 
-	if wfSink.Conn != nil && err == nil {
-		wfSink.send(batch)
+// report sends the lines in batches of BatchSize and counts the failures.
+func (wfSink *wavefrontSink) report(format string, lines []string) {
+	for len(lines) > 0 {
+		n := len(lines)
+		if wfSink.BatchSize > 0 && n > wfSink.BatchSize {
+			n = wfSink.BatchSize
+		}
+		if err := wfSink.reporter.send(format, lines[:n]); err != nil {
+			glog.Warningf("Failed to send %d lines to Wavefront: %v", n, err)
+			wfSink.stats.pointsFailed += int64(n)
+			pointsSent.WithLabelValues("failure").Add(float64(n))
+		} else {
+			wfSink.stats.pointsSent += int64(n)
+			pointsSent.WithLabelValues("success").Add(float64(n))
+		}
+		lines = lines[n:]
 	}
 }
 
-func (wfSink *wavefrontSink) connect() error {
-	var err error
-	wfSink.Conn, err = net.DialTimeout("tcp", wfSink.ProxyAddress, time.Second*10)
-	if err != nil {
-		glog.Warningf("Unable to connect to Wavefront proxy at address: %s", wfSink.ProxyAddress)
-		return err
-	} else {
-		glog.Infof("Connected to Wavefront proxy at address: %s", wfSink.ProxyAddress)
-		return nil
+func (wfSink *wavefrontSink) ExportData(batch *core.DataBatch) {
+	wfSink.points = wfSink.points[:0]
+	wfSink.histograms = wfSink.histograms[:0]
+	wfSink.send(batch)
+
+	if wfSink.testMode {
+		//keep the lines of the last batch
+		wfSink.testReceivedLines = append(append(wfSink.testReceivedLines[:0], wfSink.points...), wfSink.histograms...)
+		for _, line := range wfSink.testReceivedLines {
+			glog.Infoln(line)
+		}
+		return
 	}
+
+	wfSink.report(formatWavefront, wfSink.points)
+	wfSink.report(formatHistogram, wfSink.histograms)
+	wfSink.report(formatWavefront, wfSink.statsLines(strconv.FormatInt(batch.Timestamp.Unix(), 10)))
 }
 
 func NewWavefrontSink(uri *url.URL) (core.DataSink, error) {
 
 	storage := &wavefrontSink{
-		ProxyAddress:      uri.Scheme + ":" + uri.Opaque,
-		ClusterName:       "k8s-cluster",
-		Prefix:            "heapster.",
-		IncludeLabels:     false,
-		IncludeContainers: true,
-		testMode:          false,
+		ProxyAddress:       uri.Scheme + ":" + uri.Opaque,
+		ClusterName:        "k8s-cluster",
+		Prefix:             "heapster.",
+		IncludeLabels:      false,
+		IncludeContainers:  true,
+		BatchSize:          10000,
+		MaxTags:            20,
+		TagValuesRetention: defaultTagValuesRetention,
+		testMode:           false,
+	}
+	// Direct ingestion, e.g. https://mydomain.wavefront.com
+	direct := (uri.Scheme == "https" || uri.Scheme == "http") && uri.Host != ""
+	if direct {
+		storage.ProxyAddress = ""
+		storage.Server = uri.Scheme + "://" + uri.Host + uri.Path
 	}
 
 	vals := uri.Query()
@@ -262,6 +514,50 @@ func NewWavefrontSink(uri *url.URL) (core.DataSink, error) {
 		}
 		storage.IncludeContainers = incContainers
 	}
+	if len(vals["deltaCounters"]) > 0 {
+		deltaCounters, err := strconv.ParseBool(vals["deltaCounters"][0])
+		if err != nil {
+			glog.Warning("Unable to parse the deltaCounters argument. This argument is a boolean, please pass \"true\" or \"false\"")
+			return nil, err
+		}
+		storage.DeltaCounters = deltaCounters
+	}
+	if len(vals["histograms"]) > 0 {
+		histograms, err := strconv.ParseBool(vals["histograms"][0])
+		if err != nil {
+			glog.Warning("Unable to parse the histograms argument. This argument is a boolean, please pass \"true\" or \"false\"")
+			return nil, err
+		}
+		if histograms {
+			storage.HistogramMetrics = defaultHistogramMetrics
+		}
+	}
+	if len(vals["histogramMetrics"]) > 0 {
+		storage.HistogramMetrics = strings.Split(vals["histogramMetrics"][0], ",")
+	}
+	for _, opt := range []struct {
+		name  string
+		value *int
+	}{
+		{"batchSize", &storage.BatchSize},
+		{"maxTags", &storage.MaxTags},
+		{"maxTagValues", &storage.MaxTagValues},
+	} {
+		if len(vals[opt.name]) > 0 {
+			value, err := strconv.Atoi(vals[opt.name][0])
+			if err != nil || value < 0 {
+				return nil, fmt.Errorf("failed to parse `%s` flag - %v", opt.name, vals[opt.name][0])
+			}
+			*opt.value = value
+		}
+	}
+	if len(vals["tagValuesRetention"]) > 0 {
+		retention, err := time.ParseDuration(vals["tagValuesRetention"][0])
+		if err != nil || retention < 0 {
+			return nil, fmt.Errorf("failed to parse `tagValuesRetention` flag - %v", vals["tagValuesRetention"][0])
+		}
+		storage.TagValuesRetention = retention
+	}
 	if len(vals["testMode"]) > 0 {
 		testMode := false
 		testMode, err := strconv.ParseBool(vals["testMode"][0])
@@ -271,6 +567,19 @@ func NewWavefrontSink(uri *url.URL) (core.DataSink, error) {
 		}
 		storage.testMode = testMode
 	}
+
+	if direct {
+		token := os.Getenv("WAVEFRONT_TOKEN")
+		if len(vals["token"]) > 0 {
+			token = vals["token"][0]
+		}
+		if token == "" {
+			return nil, fmt.Errorf("direct ingestion to %s requires an API token, set the `token` flag or WAVEFRONT_TOKEN", storage.Server)
+		}
+		storage.reporter = newDirectReporter(storage.Server, token)
+	} else {
+		storage.reporter = &proxyReporter{address: storage.ProxyAddress}
+	}
 	return storage, nil
 }
 
@@ -0,0 +1,150 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	webhook_common "k8s.io/heapster/common/webhook"
+	"k8s.io/heapster/metrics/core"
+)
+
+// Units of the known metrics, by name.
+var metricUnits = make(map[string]string)
+
+func init() {
+	for _, metric := range core.AllMetrics {
+		metricUnits[metric.Name] = metric.Units.String()
+	}
+}
+
+// Metric is a data point, with the labels of its metric set and its own.
+type Metric struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  interface{}       `json:"value"`
+	// One of gauge, cumulative or delta.
+	Type  string `json:"type"`
+	Units string `json:"units,omitempty"`
+}
+
+// MetricsPayload is the body of a request, encoded as JSON or passed to the template.
+type MetricsPayload struct {
+	Cluster   string    `json:"cluster"`
+	Timestamp time.Time `json:"timestamp"`
+	Metrics   []Metric  `json:"metrics"`
+}
+
+type webhookSink struct {
+	client       webhook_common.WebhookClient
+	clusterName  string
+	maxBatchSize int
+}
+
+func (sink *webhookSink) Name() string {
+	return "Webhook Sink"
+}
+
+func (sink *webhookSink) Stop() {
+	// nothing needs to be done.
+}
+
+func (sink *webhookSink) ExportData(dataBatch *core.DataBatch) {
+	start := time.Now()
+	metrics := flattenBatch(dataBatch)
+	for len(metrics) > 0 {
+		n := len(metrics)
+		if n > sink.maxBatchSize {
+			n = sink.maxBatchSize
+		}
+		payload := &MetricsPayload{
+			Cluster:   sink.clusterName,
+			Timestamp: dataBatch.Timestamp.UTC(),
+			Metrics:   metrics[:n],
+		}
+		if err := sink.client.Send(payload); err != nil {
+			glog.Errorf("Failed to export %d metrics to the webhook: %v", n, err)
+		}
+		metrics = metrics[n:]
+	}
+	glog.V(4).Infof("Exported %d metric sets to the webhook in %s", len(dataBatch.MetricSets), time.Since(start))
+}
+
+// flattenBatch returns the data points of the batch, ordered by metric set.
+func flattenBatch(dataBatch *core.DataBatch) []Metric {
+	keys := make([]string, 0, len(dataBatch.MetricSets))
+	for key := range dataBatch.MetricSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var metrics []Metric
+	for _, key := range keys {
+		metricSet := dataBatch.MetricSets[key]
+		names := make([]string, 0, len(metricSet.MetricValues))
+		for name := range metricSet.MetricValues {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if metric, ok := newMetric(name, metricSet.Labels, nil, metricSet.MetricValues[name]); ok {
+				metrics = append(metrics, metric)
+			}
+		}
+		for _, labeled := range metricSet.LabeledMetrics {
+			if metric, ok := newMetric(labeled.Name, metricSet.Labels, labeled.Labels, labeled.MetricValue); ok {
+				metrics = append(metrics, metric)
+			}
+		}
+	}
+	return metrics
+}
+
+func newMetric(name string, setLabels, labels map[string]string, value core.MetricValue) (Metric, bool) {
+	v := value.GetValue()
+	if v == nil {
+		return Metric{}, false
+	}
+	merged := make(map[string]string, len(setLabels)+len(labels))
+	for k, v := range setLabels {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return Metric{
+		Name:   name,
+		Labels: merged,
+		Value:  v,
+		Type:   value.MetricType.String(),
+		Units:  metricUnits[name],
+	}, true
+}
+
+func NewWebhookSink(uri *url.URL) (core.DataSink, error) {
+	config, err := webhook_common.BuildConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("created webhook sink posting to %v", config.URLs)
+	return &webhookSink{
+		client:       webhook_common.NewClient(*config),
+		clusterName:  config.ClusterName,
+		maxBatchSize: config.MaxBatchSize,
+	}, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	webhook_common "k8s.io/heapster/common/webhook"
+	"k8s.io/heapster/metrics/core"
+)
+
+func newFakeSink(maxBatchSize int) (*webhookSink, *webhook_common.FakeWebhookClient) {
+	client := webhook_common.NewFakeWebhookClient()
+	return &webhookSink{
+		client:       client,
+		clusterName:  "test",
+		maxBatchSize: maxBatchSize,
+	}, client
+}
+
+func TestExportData(t *testing.T) {
+	sink, client := newFakeSink(100)
+	timestamp := time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)
+	batch := &core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			"namespace:default/pod:redis": {
+				Labels: map[string]string{"type": "pod", "pod_name": "redis"},
+				MetricValues: map[string]core.MetricValue{
+					"cpu/usage":    {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: 100},
+					"memory/usage": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 2048},
+				},
+				LabeledMetrics: []core.LabeledMetric{{
+					Name:        "filesystem/usage",
+					Labels:      map[string]string{"resource_id": "/dev/sda1"},
+					MetricValue: core.MetricValue{ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 1.5},
+				}},
+			},
+		},
+	}
+	sink.ExportData(batch)
+
+	require.Equal(t, 1, len(client.Payloads))
+	assert.Equal(t, &MetricsPayload{
+		Cluster:   "test",
+		Timestamp: timestamp,
+		Metrics: []Metric{
+			{Name: "cpu/usage", Labels: map[string]string{"type": "pod", "pod_name": "redis"}, Value: int64(100), Type: "cumulative", Units: "ns"},
+			{Name: "memory/usage", Labels: map[string]string{"type": "pod", "pod_name": "redis"}, Value: int64(2048), Type: "gauge", Units: "bytes"},
+			{Name: "filesystem/usage", Labels: map[string]string{"type": "pod", "pod_name": "redis", "resource_id": "/dev/sda1"}, Value: 1.5, Type: "gauge", Units: "bytes"},
+		},
+	}, client.Payloads[0])
+}
+
+func TestExportDataMaxBatchSize(t *testing.T) {
+	sink, client := newFakeSink(2)
+	batch := &core.DataBatch{Timestamp: time.Now(), MetricSets: map[string]*core.MetricSet{}}
+	for i := 0; i < 5; i++ {
+		batch.MetricSets[fmt.Sprintf("node:%d", i)] = &core.MetricSet{
+			MetricValues: map[string]core.MetricValue{
+				"uptime": {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: int64(i)},
+			},
+		}
+	}
+	sink.ExportData(batch)
+
+	require.Equal(t, 3, len(client.Payloads))
+	var sizes []int
+	for _, payload := range client.Payloads {
+		sizes = append(sizes, len(payload.(*MetricsPayload).Metrics))
+	}
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+}
+
+func TestExportDataEmpty(t *testing.T) {
+	sink, client := newFakeSink(100)
+	sink.ExportData(&core.DataBatch{Timestamp: time.Now()})
+	assert.Equal(t, 0, len(client.Payloads))
+}
+
+func TestNewWebhookSink(t *testing.T) {
+	uri, err := url.Parse("http://collector:8080/metrics?max_batch_size=10&cluster_name=prod")
+	require.NoError(t, err)
+	sink, err := NewWebhookSink(uri)
+	require.NoError(t, err)
+	webhookSink := sink.(*webhookSink)
+	assert.Equal(t, 10, webhookSink.maxBatchSize)
+	assert.Equal(t, "prod", webhookSink.clusterName)
+}
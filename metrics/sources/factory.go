@@ -16,8 +16,10 @@ package sources
 
 import (
 	"fmt"
+	"net/url"
 
 	"k8s.io/heapster/common/flags"
+	kube_config "k8s.io/heapster/common/kubernetes"
 	"k8s.io/heapster/metrics/core"
 	"k8s.io/heapster/metrics/sources/kubelet"
 	"k8s.io/heapster/metrics/sources/summary"
@@ -39,11 +41,27 @@ func (this *SourceFactory) Build(uri flags.Uri) (core.MetricsSourceProvider, err
 	}
 }
 
-func (this *SourceFactory) BuildAll(uris flags.Uris) (core.MetricsSourceProvider, error) {
-	if len(uris) != 1 {
-		return nil, fmt.Errorf("Only one source is supported")
+// BuildAll builds a provider for each source, one per cluster.
+func (this *SourceFactory) BuildAll(uris flags.Uris) ([]core.MetricsSourceProvider, error) {
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("No source specified")
 	}
-	return this.Build(uris[0])
+	urls := []*url.URL{}
+	for i := range uris {
+		urls = append(urls, &uris[i].Val)
+	}
+	if err := kube_config.ValidateClusterNames(urls); err != nil {
+		return nil, err
+	}
+	result := []core.MetricsSourceProvider{}
+	for _, uri := range uris {
+		provider, err := this.Build(uri)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, provider)
+	}
+	return result, nil
 }
 
 func NewSourceFactory() *SourceFactory {
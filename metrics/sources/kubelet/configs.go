@@ -17,6 +17,7 @@ package kubelet
 import (
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/golang/glog"
 	kube_client "k8s.io/client-go/rest"
@@ -32,6 +33,11 @@ const (
 	defaultUseServiceAccount  = false
 	defaultServiceAccountFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 	defaultInClusterConfig    = true
+
+	defaultKubeletMaxIdleConnsPerHost = 2
+	defaultKubeletIdleConnTimeout     = 90 * time.Second
+	defaultKubeletHTTP2               = false
+	defaultKubeletCompression         = true
 )
 
 func GetKubeConfigs(uri *url.URL) (*kube_client.Config, *kubelet_client.KubeletClientConfig, error) {
@@ -58,6 +64,38 @@ func GetKubeConfigs(uri *url.URL) (*kube_client.Config, *kubelet_client.KubeletC
 		}
 	}
 
+	kubeletMaxIdleConnsPerHost := defaultKubeletMaxIdleConnsPerHost
+	if len(opts["kubeletMaxIdleConnsPerHost"]) >= 1 {
+		kubeletMaxIdleConnsPerHost, err = strconv.Atoi(opts["kubeletMaxIdleConnsPerHost"][0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	kubeletIdleConnTimeout := defaultKubeletIdleConnTimeout
+	if len(opts["kubeletIdleConnTimeout"]) >= 1 {
+		kubeletIdleConnTimeout, err = time.ParseDuration(opts["kubeletIdleConnTimeout"][0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	kubeletHTTP2 := defaultKubeletHTTP2
+	if len(opts["kubeletHttp2"]) >= 1 {
+		kubeletHTTP2, err = strconv.ParseBool(opts["kubeletHttp2"][0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	kubeletCompression := defaultKubeletCompression
+	if len(opts["kubeletCompression"]) >= 1 {
+		kubeletCompression, err = strconv.ParseBool(opts["kubeletCompression"][0])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	glog.Infof("Using Kubernetes client with master %q and version %+v\n", kubeConfig.Host, kubeConfig.GroupVersion)
 	glog.Infof("Using kubelet port %d", kubeletPort)
 
@@ -66,6 +104,11 @@ func GetKubeConfigs(uri *url.URL) (*kube_client.Config, *kubelet_client.KubeletC
 		EnableHttps:     kubeletHttps,
 		TLSClientConfig: kubeConfig.TLSClientConfig,
 		BearerToken:     kubeConfig.BearerToken,
+
+		MaxIdleConnsPerHost: kubeletMaxIdleConnsPerHost,
+		IdleConnTimeout:     kubeletIdleConnTimeout,
+		EnableHTTP2:         kubeletHTTP2,
+		EnableCompression:   kubeletCompression,
 	}
 
 	return kubeConfig, kubeletConfig, nil
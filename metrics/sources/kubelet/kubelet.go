@@ -270,6 +270,7 @@ type kubeletProvider struct {
 	nodeLister    v1listers.NodeLister
 	reflector     *cache.Reflector
 	kubeletClient *KubeletClient
+	nodeFilter    NodeFilter
 }
 
 func (this *kubeletProvider) GetMetricsSources() []MetricsSource {
@@ -285,6 +286,10 @@ func (this *kubeletProvider) GetMetricsSources() []MetricsSource {
 	}
 
 	for _, node := range nodes {
+		if !this.nodeFilter.Matches(node) {
+			glog.V(4).Infof("Skipping node %s excluded by the node filter", node.Name)
+			continue
+		}
 		hostname, ip, err := GetNodeHostnameAndIP(node)
 		if err != nil {
 			glog.Errorf("%v", err)
@@ -311,11 +316,6 @@ func getNodeSchedulableStatus(node *kube_api.Node) string {
 }
 
 func GetNodeHostnameAndIP(node *kube_api.Node) (string, net.IP, error) {
-	for _, c := range node.Status.Conditions {
-		if c.Type == kube_api.NodeReady && c.Status != kube_api.ConditionTrue {
-			return "", nil, fmt.Errorf("node %v is not ready", node.Name)
-		}
-	}
 	hostname, ip := node.Name, ""
 	for _, addr := range node.Status.Addresses {
 		if addr.Type == kube_api.NodeHostName && addr.Address != "" {
@@ -347,6 +347,10 @@ func NewKubeletProvider(uri *url.URL) (MetricsSourceProvider, error) {
 	if err != nil {
 		return nil, err
 	}
+	nodeFilter, err := GetNodeFilter(uri)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get nodes to test if the client is configured well. Watch gives less error information.
 	if _, err := kubeClient.CoreV1().Nodes().List(metav1.ListOptions{}); err != nil {
@@ -360,5 +364,6 @@ func NewKubeletProvider(uri *url.URL) (MetricsSourceProvider, error) {
 		nodeLister:    nodeLister,
 		reflector:     reflector,
 		kubeletClient: kubeletClient,
+		nodeFilter:    nodeFilter,
 	}, nil
 }
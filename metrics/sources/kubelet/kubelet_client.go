@@ -19,7 +19,9 @@ package kubelet
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -30,6 +32,9 @@ import (
 	"github.com/golang/glog"
 	cadvisor "github.com/google/cadvisor/info/v1"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/heapster/common/httputil"
 	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
 	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
 )
@@ -44,6 +49,31 @@ func (h Host) String() string {
 	return net.JoinHostPort(h.IP.String(), strconv.Itoa(h.Port))
 }
 
+// Summary is the response of the kubelet summary API. It extends the vendored stats types with
+// node-level fields reported by newer kubelets.
+type Summary struct {
+	// Overall node stats.
+	Node NodeStats `json:"node"`
+	// Per-pod stats.
+	Pods []stats.PodStats `json:"pods"`
+}
+
+// NodeStats holds node-level unprocessed sample stats.
+type NodeStats struct {
+	stats.NodeStats
+	// Stats about the rlimit of the node.
+	Rlimit *RlimitStats `json:"rlimit,omitempty"`
+}
+
+// RlimitStats are stats about the process limits of the node.
+type RlimitStats struct {
+	Time metav1.Time `json:"time"`
+	// The max PID of the OS.
+	MaxPID *int64 `json:"maxpid,omitempty"`
+	// The number of running processes in the OS.
+	NumOfRunningProcesses *int64 `json:"curproc,omitempty"`
+}
+
 type KubeletClient struct {
 	config *kubelet_client.KubeletClientConfig
 	client *http.Client
@@ -69,31 +99,127 @@ func sampleContainerStats(stats []*cadvisor.ContainerStats) []*cadvisor.Containe
 	return []*cadvisor.ContainerStats{stats[len(stats)-1]}
 }
 
+var (
+	// Bytes of Kubelet responses received over the network.
+	kubeletResponseWireBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "kubelet",
+			Name:      "response_wire_bytes_total",
+			Help:      "Bytes of Kubelet responses received over the network, possibly compressed.",
+		},
+	)
+	// Bytes of JSON decoded from Kubelet responses.
+	kubeletResponseDecodedBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "kubelet",
+			Name:      "response_decoded_bytes_total",
+			Help:      "Bytes of JSON decoded from Kubelet responses.",
+		},
+	)
+	// Bytes of JSON decoded per Kubelet scrape. The decoded stats are allocated in proportion to it.
+	kubeletScrapeDecodedBytes = prometheus.NewSummary(
+		prometheus.SummaryOpts{
+			Namespace: "heapster",
+			Subsystem: "kubelet",
+			Name:      "scrape_decoded_bytes",
+			Help:      "Bytes of JSON decoded per Kubelet scrape, which the memory allocated by decoding grows with.",
+		},
+	)
+	// Time spent reading and decoding Kubelet responses.
+	kubeletDecodeDuration = prometheus.NewSummary(
+		prometheus.SummaryOpts{
+			Namespace: "heapster",
+			Subsystem: "kubelet",
+			Name:      "decode_duration_milliseconds",
+			Help:      "Time spent reading and decoding Kubelet responses in milliseconds.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(kubeletResponseWireBytes)
+	prometheus.MustRegister(kubeletResponseDecodedBytes)
+	prometheus.MustRegister(kubeletScrapeDecodedBytes)
+	prometheus.MustRegister(kubeletDecodeDuration)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
 func (self *KubeletClient) postRequestAndGetValue(client *http.Client, req *http.Request, value interface{}) error {
+	if self.config != nil && self.config.EnableCompression {
+		// Setting the header explicitly disables the transparent decompression of the transport,
+		// which lets us account for the compressed size.
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
 	response, err := client.Do(req)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body - %v", err)
-	}
+	defer func() {
+		// The connection is only reused once the body is read to the end, e.g. past
+		// the gzip trailer.
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+	}()
+
+	wire := &countingReader{reader: response.Body}
+	var body io.Reader = wire
+	gzipped := response.Header.Get("Content-Encoding") == "gzip"
 	if response.StatusCode == http.StatusNotFound {
 		return &ErrNotFound{req.URL.String()}
 	} else if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("request failed - %q, response: %q", response.Status, string(body))
+		if gzipped {
+			if gzipReader, err := gzip.NewReader(wire); err == nil {
+				defer gzipReader.Close()
+				body = gzipReader
+			}
+		}
+		return fmt.Errorf("request failed - %q, response: %q", response.Status, httputil.ErrorBody(body))
+	}
+	if gzipped {
+		gzipReader, err := gzip.NewReader(wire)
+		if err != nil {
+			return fmt.Errorf("failed to read compressed response body - %v", err)
+		}
+		defer gzipReader.Close()
+		body = gzipReader
 	}
 
 	kubeletAddr := "[unknown]"
 	if req.URL != nil {
 		kubeletAddr = req.URL.Host
 	}
-	glog.V(10).Infof("Raw response from Kubelet at %s: %s", kubeletAddr, string(body))
+	// The response is decoded as it arrives, so it is only buffered when it has to be logged.
+	var raw *bytes.Buffer
+	if glog.V(10) {
+		raw = &bytes.Buffer{}
+		body = io.TeeReader(body, raw)
+	}
 
-	err = jsoniter.ConfigFastest.Unmarshal(body, value)
+	decodeStart := time.Now()
+	decoded := &countingReader{reader: body}
+	err = jsoniter.ConfigFastest.NewDecoder(decoded).Decode(value)
+	kubeletDecodeDuration.Observe(float64(time.Since(decodeStart)) / float64(time.Millisecond))
+	kubeletResponseWireBytes.Add(float64(wire.count))
+	kubeletResponseDecodedBytes.Add(float64(decoded.count))
+	kubeletScrapeDecodedBytes.Observe(float64(decoded.count))
+	if raw != nil {
+		glog.V(10).Infof("Raw response from Kubelet at %s: %s", kubeletAddr, raw.String())
+	}
 	if err != nil {
-		return fmt.Errorf("failed to parse output. Response: %q. Error: %v", string(body), err)
+		return fmt.Errorf("failed to parse output from Kubelet at %s: %v", kubeletAddr, err)
 	}
 	return nil
 }
@@ -155,14 +281,14 @@ func (self *KubeletClient) GetAllRawContainers(host Host, start, end time.Time)
 	return self.getAllContainers(url, start, end)
 }
 
-func (self *KubeletClient) GetSummary(host Host) (*stats.Summary, error) {
+func (self *KubeletClient) GetSummary(host Host) (*Summary, error) {
 	url := self.getUrl(host, "/stats/summary/")
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	summary := &stats.Summary{}
+	summary := &Summary{}
 	client := self.client
 	if client == nil {
 		client = http.DefaultClient
@@ -15,15 +15,23 @@
 package kubelet
 
 import (
+	"compress/gzip"
+	"math/rand"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	cadvisor_api "github.com/google/cadvisor/info/v1"
 	jsoniter "github.com/json-iterator/go"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	util "k8s.io/client-go/util/testing"
+	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
+	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
 )
 
 func checkContainer(t *testing.T, expected cadvisor_api.ContainerInfo, actual cadvisor_api.ContainerInfo) {
@@ -102,3 +110,126 @@ func TestAllContainers(t *testing.T) {
 	checkContainer(t, rootContainer, containers[0])
 	checkContainer(t, subcontainer, containers[1])
 }
+
+func TestCompressedSummary(t *testing.T) {
+	maxPID := int64(4096)
+	summary := Summary{
+		Node: NodeStats{
+			NodeStats: stats.NodeStats{NodeName: "node1"},
+			Rlimit:    &RlimitStats{MaxPID: &maxPID},
+		},
+	}
+	data, err := jsoniter.ConfigFastest.Marshal(&summary)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write(data)
+	}))
+	defer server.Close()
+
+	kubeletClient := KubeletClient{
+		config: &kubelet_client.KubeletClientConfig{EnableCompression: true},
+	}
+	req, err := http.NewRequest("GET", server.URL, nil)
+	require.NoError(t, err)
+	result := &Summary{}
+	decodedBefore := decodedBytesSum(t)
+	require.NoError(t, kubeletClient.postRequestAndGetValue(http.DefaultClient, req, result))
+	assert.Equal(t, float64(len(data)), decodedBytesSum(t)-decodedBefore)
+	assert.Equal(t, "node1", result.Node.NodeName)
+	require.NotNil(t, result.Node.Rlimit)
+	assert.Equal(t, maxPID, *result.Node.Rlimit.MaxPID)
+}
+
+func decodedBytesSum(t *testing.T) float64 {
+	value := &dto.Metric{}
+	require.NoError(t, kubeletScrapeDecodedBytes.Write(value))
+	return value.GetSummary().GetSampleSum()
+}
+
+func TestFailedRequest(t *testing.T) {
+	handler := util.FakeHandler{
+		StatusCode:   500,
+		ResponseBody: "internal error",
+		T:            t,
+	}
+	server := httptest.NewServer(&handler)
+	defer server.Close()
+
+	kubeletClient := KubeletClient{}
+	req, err := http.NewRequest("GET", server.URL, nil)
+	require.NoError(t, err)
+	err = kubeletClient.postRequestAndGetValue(http.DefaultClient, req, &Summary{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "internal error")
+}
+
+func TestConnectionReused(t *testing.T) {
+	data, err := jsoniter.ConfigFastest.Marshal(&Summary{Node: NodeStats{NodeStats: stats.NodeStats{NodeName: "node1"}}})
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		gz.Write(data)
+		// Not read by the decoder, which stops after the JSON value.
+		tail := make([]byte, 1024*1024)
+		rand.New(rand.NewSource(1)).Read(tail)
+		gz.Write(tail)
+	}))
+	var lock sync.Mutex
+	connections := 0
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			lock.Lock()
+			defer lock.Unlock()
+			connections++
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	kubeletClient := KubeletClient{
+		config: &kubelet_client.KubeletClientConfig{EnableCompression: true},
+	}
+	client := &http.Client{Transport: &http.Transport{}}
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		require.NoError(t, err)
+		require.NoError(t, kubeletClient.postRequestAndGetValue(client, req, &Summary{}))
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 1, connections)
+}
+
+func TestFailedRequestWithInvalidCompression(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("internal error"))
+	}))
+	defer server.Close()
+
+	kubeletClient := KubeletClient{
+		config: &kubelet_client.KubeletClientConfig{EnableCompression: true},
+	}
+	req, err := http.NewRequest("GET", server.URL+"/missing", nil)
+	require.NoError(t, err)
+	err = kubeletClient.postRequestAndGetValue(http.DefaultClient, req, &Summary{})
+	assert.IsType(t, &ErrNotFound{}, err)
+
+	req, err = http.NewRequest("GET", server.URL, nil)
+	require.NoError(t, err)
+	err = kubeletClient.postRequestAndGetValue(http.DefaultClient, req, &Summary{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "500 Internal Server Error")
+}
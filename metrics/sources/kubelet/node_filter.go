@@ -0,0 +1,122 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	kube_api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	defaultExcludeUnschedulable = false
+	defaultExcludeNotReady      = true
+)
+
+// NodeFilter decides which of the nodes known to the apiserver are scraped by a source provider.
+type NodeFilter interface {
+	// Matches returns true if the node should be scraped.
+	Matches(node *kube_api.Node) bool
+}
+
+type nodeFilter struct {
+	labelSelector        labels.Selector
+	fieldSelector        fields.Selector
+	excludeUnschedulable bool
+	excludeNotReady      bool
+}
+
+func (this *nodeFilter) Matches(node *kube_api.Node) bool {
+	if !this.labelSelector.Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	if !this.fieldSelector.Matches(nodeFields(node)) {
+		return false
+	}
+	if this.excludeUnschedulable && node.Spec.Unschedulable {
+		return false
+	}
+	if this.excludeNotReady && !isNodeReady(node) {
+		return false
+	}
+	return true
+}
+
+// nodeFields returns the node fields supported by the apiserver in field selectors.
+func nodeFields(node *kube_api.Node) fields.Set {
+	return fields.Set{
+		"metadata.name":      node.Name,
+		"spec.unschedulable": strconv.FormatBool(node.Spec.Unschedulable),
+	}
+}
+
+func isNodeReady(node *kube_api.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == kube_api.NodeReady {
+			return c.Status == kube_api.ConditionTrue
+		}
+	}
+	// Nodes without the Ready condition have always been scraped.
+	return true
+}
+
+// GetNodeFilter builds the node filter described by the source URI options.
+func GetNodeFilter(uri *url.URL) (NodeFilter, error) {
+	opts := uri.Query()
+	var err error
+
+	labelSelector := labels.Everything()
+	if len(opts["node_selector"]) >= 1 {
+		labelSelector, err = labels.Parse(opts["node_selector"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse node_selector: %v", err)
+		}
+	}
+
+	fieldSelector := fields.Everything()
+	if len(opts["node_field_selector"]) >= 1 {
+		fieldSelector, err = fields.ParseSelector(opts["node_field_selector"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse node_field_selector: %v", err)
+		}
+	}
+
+	excludeUnschedulable := defaultExcludeUnschedulable
+	if len(opts["exclude_unschedulable"]) >= 1 {
+		excludeUnschedulable, err = strconv.ParseBool(opts["exclude_unschedulable"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse exclude_unschedulable: %v", err)
+		}
+	}
+
+	excludeNotReady := defaultExcludeNotReady
+	if len(opts["exclude_not_ready"]) >= 1 {
+		excludeNotReady, err = strconv.ParseBool(opts["exclude_not_ready"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse exclude_not_ready: %v", err)
+		}
+	}
+
+	return &nodeFilter{
+		labelSelector:        labelSelector,
+		fieldSelector:        fieldSelector,
+		excludeUnschedulable: excludeUnschedulable,
+		excludeNotReady:      excludeNotReady,
+	}, nil
+}
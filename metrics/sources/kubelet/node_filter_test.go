@@ -0,0 +1,106 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kube_api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testNode(name string, nodeLabels map[string]string, unschedulable bool, ready kube_api.ConditionStatus) *kube_api.Node {
+	return &kube_api.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: nodeLabels,
+		},
+		Spec: kube_api.NodeSpec{
+			Unschedulable: unschedulable,
+		},
+		Status: kube_api.NodeStatus{
+			Conditions: []kube_api.NodeCondition{
+				{
+					Type:   kube_api.NodeReady,
+					Status: ready,
+				},
+			},
+		},
+	}
+}
+
+func TestNodeFilter(t *testing.T) {
+	gpu := testNode("gpu", map[string]string{"pool": "gpu"}, false, kube_api.ConditionTrue)
+	batch := testNode("batch", map[string]string{"pool": "batch"}, false, kube_api.ConditionTrue)
+	drained := testNode("drained", map[string]string{"pool": "gpu"}, true, kube_api.ConditionTrue)
+	notReady := testNode("not-ready", map[string]string{"pool": "gpu"}, false, kube_api.ConditionFalse)
+
+	testCases := []struct {
+		options string
+		matches []*kube_api.Node
+	}{
+		{
+			options: "",
+			matches: []*kube_api.Node{gpu, batch, drained},
+		},
+		{
+			options: "?node_selector=pool%3Dgpu",
+			matches: []*kube_api.Node{gpu, drained},
+		},
+		{
+			options: "?node_selector=pool%3Dgpu&exclude_unschedulable=true",
+			matches: []*kube_api.Node{gpu},
+		},
+		{
+			options: "?exclude_not_ready=false",
+			matches: []*kube_api.Node{gpu, batch, drained, notReady},
+		},
+		{
+			options: "?node_field_selector=metadata.name%21%3Dbatch",
+			matches: []*kube_api.Node{gpu, drained},
+		},
+	}
+
+	for _, tc := range testCases {
+		uri, err := url.Parse("kubernetes:http://localhost" + tc.options)
+		assert.NoError(t, err)
+		filter, err := GetNodeFilter(uri)
+		assert.NoError(t, err)
+
+		matched := []*kube_api.Node{}
+		for _, node := range []*kube_api.Node{gpu, batch, drained, notReady} {
+			if filter.Matches(node) {
+				matched = append(matched, node)
+			}
+		}
+		assert.Equal(t, tc.matches, matched, tc.options)
+	}
+}
+
+func TestNodeFilterInvalidOptions(t *testing.T) {
+	for _, options := range []string{
+		"?node_selector=pool%3D%3D%3Dgpu",
+		"?node_field_selector=metadata.name%3D%3D%3Da",
+		"?exclude_unschedulable=maybe",
+		"?exclude_not_ready=maybe",
+	} {
+		uri, err := url.Parse("kubernetes:http://localhost" + options)
+		assert.NoError(t, err)
+		_, err = GetNodeFilter(uri)
+		assert.Error(t, err, options)
+	}
+}
@@ -43,6 +43,18 @@ type KubeletClientConfig struct {
 
 	// Dial is a custom dialer used for the client
 	Dial utilnet.DialFunc
+
+	// MaxIdleConnsPerHost is the number of keep-alive connections kept open to every Kubelet.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout is how long an idle keep-alive connection is kept open.
+	IdleConnTimeout time.Duration
+
+	// EnableHTTP2 negotiates HTTP/2 with Kubelets serving https.
+	EnableHTTP2 bool
+
+	// EnableCompression requests gzip-compressed responses from Kubelets.
+	EnableCompression bool
 }
 
 func MakeTransport(config *KubeletClientConfig) (http.RoundTripper, error) {
@@ -51,12 +63,19 @@ func MakeTransport(config *KubeletClientConfig) (http.RoundTripper, error) {
 		return nil, err
 	}
 
-	rt := http.DefaultTransport
-	if config.Dial != nil || tlsConfig != nil {
-		rt = utilnet.SetOldTransportDefaults(&http.Transport{
-			Dial:            config.Dial,
-			TLSClientConfig: tlsConfig,
-		})
+	// All Kubelets are scraped through a single transport, so the idle connection pool is only
+	// limited per host.
+	t := &http.Transport{
+		Dial:                config.Dial,
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		IdleConnTimeout:     config.IdleConnTimeout,
+	}
+	var rt http.RoundTripper
+	if config.EnableHTTP2 {
+		rt = utilnet.SetTransportDefaults(t)
+	} else {
+		rt = utilnet.SetOldTransportDefaults(t)
 	}
 
 	return transport.HTTPWrappersForConfig(config.transportConfig(), rt)
@@ -0,0 +1,99 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	. "k8s.io/heapster/metrics/core"
+)
+
+// Weight of the most recent observation in the per-source latency average.
+const latencySmoothingFactor = 0.3
+
+// latencyTracker keeps an exponentially weighted average of the scrape latency of every source.
+type latencyTracker struct {
+	sync.Mutex
+	latencies map[string]time.Duration
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		latencies: make(map[string]time.Duration),
+	}
+}
+
+// Observe records the latency of a single scrape of the source.
+func (this *latencyTracker) Observe(source string, latency time.Duration) {
+	this.Lock()
+	defer this.Unlock()
+	previous, found := this.latencies[source]
+	if !found {
+		this.latencies[source] = latency
+		return
+	}
+	this.latencies[source] = time.Duration(latencySmoothingFactor*float64(latency) + (1-latencySmoothingFactor)*float64(previous))
+}
+
+// Expected returns the expected latency of the source and whether it has been observed before.
+func (this *latencyTracker) Expected(source string) (time.Duration, bool) {
+	this.Lock()
+	defer this.Unlock()
+	latency, found := this.latencies[source]
+	return latency, found
+}
+
+// Retain forgets the history of sources that are no longer scraped, e.g. removed nodes.
+func (this *latencyTracker) Retain(sources []MetricsSource) {
+	current := make(map[string]struct{}, len(sources))
+	for _, source := range sources {
+		current[source.Name()] = struct{}{}
+	}
+	this.Lock()
+	defer this.Unlock()
+	for name := range this.latencies {
+		if _, found := current[name]; !found {
+			delete(this.latencies, name)
+		}
+	}
+}
+
+// SlowestFirst orders the sources by decreasing expected latency. Sources without history are
+// assumed to be slow, so they are started first.
+func (this *latencyTracker) SlowestFirst(sources []MetricsSource) []MetricsSource {
+	type entry struct {
+		source   MetricsSource
+		latency  time.Duration
+		observed bool
+	}
+	entries := make([]entry, 0, len(sources))
+	for _, source := range sources {
+		latency, observed := this.Expected(source.Name())
+		entries = append(entries, entry{source: source, latency: latency, observed: observed})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].observed != entries[j].observed {
+			return !entries[i].observed
+		}
+		return entries[i].latency > entries[j].latency
+	})
+	result := make([]MetricsSource, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, e.source)
+	}
+	return result
+}
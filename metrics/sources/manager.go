@@ -15,7 +15,7 @@
 package sources
 
 import (
-	"math/rand"
+	"fmt"
 	"time"
 
 	. "k8s.io/heapster/metrics/core"
@@ -26,6 +26,7 @@ import (
 
 const (
 	DefaultMetricsScrapeTimeout = 20 * time.Second
+	DefaultScrapeConcurrency    = 100
 	MaxDelayMs                  = 4 * 1000
 	DelayPerSourceMs            = 8
 )
@@ -59,16 +60,23 @@ func init() {
 	prometheus.MustRegister(scraperDuration)
 }
 
-func NewSourceManager(metricsSourceProvider MetricsSourceProvider, metricsScrapeTimeout time.Duration) (MetricsSource, error) {
+func NewSourceManager(metricsSourceProvider MetricsSourceProvider, metricsScrapeTimeout time.Duration, scrapeConcurrency int) (MetricsSource, error) {
+	if scrapeConcurrency < 1 {
+		return nil, fmt.Errorf("scrape concurrency must be positive, got %d", scrapeConcurrency)
+	}
 	return &sourceManager{
 		metricsSourceProvider: metricsSourceProvider,
 		metricsScrapeTimeout:  metricsScrapeTimeout,
+		scrapeConcurrency:     scrapeConcurrency,
+		latencies:             newLatencyTracker(),
 	}, nil
 }
 
 type sourceManager struct {
 	metricsSourceProvider MetricsSourceProvider
 	metricsScrapeTimeout  time.Duration
+	scrapeConcurrency     int
+	latencies             *latencyTracker
 }
 
 func (this *sourceManager) Name() string {
@@ -78,47 +86,27 @@ func (this *sourceManager) Name() string {
 func (this *sourceManager) ScrapeMetrics(start, end time.Time) (*DataBatch, error) {
 	glog.V(1).Infof("Scraping metrics start: %s, end: %s", start, end)
 	sources := this.metricsSourceProvider.GetMetricsSources()
+	this.latencies.Retain(sources)
+
+	// Slow sources decide whether the whole batch meets the deadline, so they are started first.
+	jobs := make(chan scrapeJob, len(sources))
+	for i, source := range this.latencies.SlowestFirst(sources) {
+		jobs <- scrapeJob{source: source, position: i}
+	}
+	close(jobs)
 
-	responseChannel := make(chan *DataBatch)
+	responseChannel := make(chan *DataBatch, len(sources))
 	startTime := time.Now()
 	timeoutTime := startTime.Add(this.metricsScrapeTimeout)
 
-	delayMs := DelayPerSourceMs * len(sources)
-	if delayMs > MaxDelayMs {
-		delayMs = MaxDelayMs
+	workers := this.scrapeConcurrency
+	if workers > len(sources) {
+		workers = len(sources)
 	}
-
-	for _, source := range sources {
-
-		go func(source MetricsSource, channel chan *DataBatch, start, end, timeoutTime time.Time, delayInMs int) {
-
-			// Prevents network congestion.
-			time.Sleep(time.Duration(rand.Intn(delayMs)) * time.Millisecond)
-
-			glog.V(2).Infof("Querying source: %s", source)
-			metrics, err := scrape(source, start, end)
-			if err != nil {
-				glog.Errorf("Error in scraping containers from %s: %v", source.Name(), err)
-				return
-			}
-
-			now := time.Now()
-			if !now.Before(timeoutTime) {
-				glog.Warningf("Failed to get %s response in time", source)
-				return
-			}
-			timeForResponse := timeoutTime.Sub(now)
-
-			select {
-			case channel <- metrics:
-				// passed the response correctly.
-				return
-			case <-time.After(timeForResponse):
-				glog.Warningf("Failed to send the response back %s", source)
-				return
-			}
-		}(source, responseChannel, start, end, timeoutTime, delayMs)
+	for w := 0; w < workers; w++ {
+		go this.scrapeWorker(jobs, responseChannel, start, end, startTime, timeoutTime)
 	}
+
 	response := DataBatch{
 		Timestamp:  end,
 		MetricSets: map[string]*MetricSet{},
@@ -141,7 +129,7 @@ responseloop:
 					response.MetricSets[key] = value
 				}
 			}
-			latency := now.Sub(startTime)
+			latency := time.Since(startTime)
 			bucket := int(latency.Seconds())
 			if bucket >= len(latencies) {
 				bucket = len(latencies) - 1
@@ -161,6 +149,67 @@ responseloop:
 	return &response, nil
 }
 
+type scrapeJob struct {
+	source MetricsSource
+	// Position of the source in the slowest-first order.
+	position int
+}
+
+// scrapeWorker scrapes sources from the jobs channel until it is drained. Every source is reported
+// on the channel, with a nil batch if the scrape failed, so that the response loop can count them.
+func (this *sourceManager) scrapeWorker(jobs <-chan scrapeJob, channel chan<- *DataBatch, start, end, startTime, timeoutTime time.Time) {
+	for job := range jobs {
+		if !time.Now().Before(timeoutTime) {
+			glog.Warningf("No time left to query source: %s", job.source)
+			channel <- nil
+			continue
+		}
+
+		// Prevents network congestion by spreading the start of fast sources, as long as they are
+		// still expected to finish well before the deadline.
+		if delay := this.spreadDelay(job, startTime, timeoutTime); delay > 0 {
+			time.Sleep(delay)
+		}
+
+		glog.V(2).Infof("Querying source: %s", job.source)
+		scrapeStart := time.Now()
+		metrics, err := scrape(job.source, start, end)
+		latency := time.Since(scrapeStart)
+		this.latencies.Observe(job.source.Name(), latency)
+		if err != nil {
+			glog.Errorf("Error in scraping containers from %s: %v", job.source.Name(), err)
+			channel <- nil
+			continue
+		}
+		if !time.Now().Before(timeoutTime) {
+			glog.Warningf("Failed to get %s response in time", job.source)
+			channel <- nil
+			continue
+		}
+		channel <- metrics
+	}
+}
+
+// spreadDelay returns how long the start of the job can still be postponed. The job starts at an
+// offset from the start of the round growing with the position of the source, but never later than
+// twice its expected latency before the deadline. The delay is zero once that time has passed, e.g.
+// when the worker was busy with earlier jobs.
+func (this *sourceManager) spreadDelay(job scrapeJob, startTime, timeoutTime time.Time) time.Duration {
+	expected, observed := this.latencies.Expected(job.source.Name())
+	if !observed {
+		return 0
+	}
+	offset := time.Duration(job.position*DelayPerSourceMs) * time.Millisecond
+	if offset > MaxDelayMs*time.Millisecond {
+		offset = MaxDelayMs * time.Millisecond
+	}
+	startAt := startTime.Add(offset)
+	if latest := timeoutTime.Add(-2 * expected); startAt.After(latest) {
+		startAt = latest
+	}
+	return startAt.Sub(time.Now())
+}
+
 func scrape(s MetricsSource, start, end time.Time) (*DataBatch, error) {
 	sourceName := s.Name()
 	startTime := time.Now()
@@ -15,9 +15,12 @@
 package sources
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"k8s.io/heapster/metrics/core"
 	"k8s.io/heapster/metrics/util"
 )
 
@@ -26,7 +29,7 @@ func TestAllSourcesReplyInTime(t *testing.T) {
 		util.NewDummyMetricsSource("s1", time.Second),
 		util.NewDummyMetricsSource("s2", time.Second))
 
-	manager, _ := NewSourceManager(metricsSourceProvider, time.Second*3)
+	manager, _ := NewSourceManager(metricsSourceProvider, time.Second*3, DefaultScrapeConcurrency)
 	now := time.Now()
 	end := now.Truncate(10 * time.Second)
 	dataBatch, err := manager.ScrapeMetrics(end.Add(-10*time.Second), end)
@@ -58,7 +61,7 @@ func TestOneSourcesReplyInTime(t *testing.T) {
 		util.NewDummyMetricsSource("s1", time.Second),
 		util.NewDummyMetricsSource("s2", 30*time.Second))
 
-	manager, _ := NewSourceManager(metricsSourceProvider, time.Second*3)
+	manager, _ := NewSourceManager(metricsSourceProvider, time.Second*3, DefaultScrapeConcurrency)
 	now := time.Now()
 	end := now.Truncate(10 * time.Second)
 	dataBatch, err := manager.ScrapeMetrics(end.Add(-10*time.Second), end)
@@ -94,7 +97,7 @@ func TestNoSourcesReplyInTime(t *testing.T) {
 		util.NewDummyMetricsSource("s1", 30*time.Second),
 		util.NewDummyMetricsSource("s2", 30*time.Second))
 
-	manager, _ := NewSourceManager(metricsSourceProvider, time.Second*3)
+	manager, _ := NewSourceManager(metricsSourceProvider, time.Second*3, DefaultScrapeConcurrency)
 	now := time.Now()
 	end := now.Truncate(10 * time.Second)
 	dataBatch, err := manager.ScrapeMetrics(end.Add(-10*time.Second), end)
@@ -124,3 +127,134 @@ func TestNoSourcesReplyInTime(t *testing.T) {
 		t.Fatal("s2 found")
 	}
 }
+
+// countingSource records how many scrapes are running at the same time.
+type countingSource struct {
+	name    string
+	latency time.Duration
+	state   *concurrencyState
+}
+
+type concurrencyState struct {
+	sync.Mutex
+	running    int
+	maxRunning int
+}
+
+func (this *countingSource) Name() string { return this.name }
+
+func (this *countingSource) ScrapeMetrics(start, end time.Time) (*core.DataBatch, error) {
+	this.state.Lock()
+	this.state.running++
+	if this.state.running > this.state.maxRunning {
+		this.state.maxRunning = this.state.running
+	}
+	this.state.Unlock()
+
+	time.Sleep(this.latency)
+
+	this.state.Lock()
+	this.state.running--
+	this.state.Unlock()
+	return &core.DataBatch{
+		Timestamp: end,
+		MetricSets: map[string]*core.MetricSet{
+			this.name: {},
+		},
+	}, nil
+}
+
+func TestScrapeConcurrencyIsLimited(t *testing.T) {
+	state := &concurrencyState{}
+	sources := []core.MetricsSource{}
+	for _, name := range []string{"s1", "s2", "s3", "s4"} {
+		sources = append(sources, &countingSource{name: name, latency: 500 * time.Millisecond, state: state})
+	}
+
+	manager, err := NewSourceManager(util.NewDummyMetricsSourceProvider(sources...), time.Second*3, 2)
+	if err != nil {
+		t.Fatalf("NewSourceManager error. %v", err)
+	}
+	end := time.Now().Truncate(10 * time.Second)
+	dataBatch, err := manager.ScrapeMetrics(end.Add(-10*time.Second), end)
+	if err != nil {
+		t.Fatalf("ScrapeMetrics error. %v", err)
+	}
+
+	if len(dataBatch.MetricSets) != 4 {
+		t.Fatalf("Expected 4 metric sets, got %d", len(dataBatch.MetricSets))
+	}
+	if state.maxRunning > 2 {
+		t.Fatalf("Expected at most 2 concurrent scrapes, got %d", state.maxRunning)
+	}
+}
+
+func TestInvalidScrapeConcurrency(t *testing.T) {
+	if _, err := NewSourceManager(util.NewDummyMetricsSourceProvider(), time.Second, 0); err == nil {
+		t.Fatal("Expected an error for zero scrape concurrency")
+	}
+}
+
+func TestSlowestSourcesFirst(t *testing.T) {
+	state := &concurrencyState{}
+	fast := &countingSource{name: "fast", state: state}
+	slow := &countingSource{name: "slow", state: state}
+	medium := &countingSource{name: "medium", state: state}
+	unknown := &countingSource{name: "unknown", state: state}
+
+	tracker := newLatencyTracker()
+	tracker.Observe("fast", 10*time.Millisecond)
+	tracker.Observe("slow", 5*time.Second)
+	tracker.Observe("medium", 100*time.Millisecond)
+	tracker.Observe("removed", time.Second)
+
+	sources := []core.MetricsSource{fast, slow, medium, unknown}
+	tracker.Retain(sources)
+	if _, found := tracker.Expected("removed"); found {
+		t.Fatal("Latency of a removed source should be forgotten")
+	}
+
+	ordered := tracker.SlowestFirst(sources)
+	expected := []core.MetricsSource{unknown, slow, medium, fast}
+	for i := range expected {
+		if ordered[i] != expected[i] {
+			t.Fatalf("Unexpected order at %d: got %s, want %s", i, ordered[i].Name(), expected[i].Name())
+		}
+	}
+
+	// A single fast scrape moves the slow source only part of the way.
+	tracker.Observe("slow", 0)
+	if latency, _ := tracker.Expected("slow"); latency != 3500*time.Millisecond {
+		t.Fatalf("Unexpected smoothed latency: %s", latency)
+	}
+}
+
+func TestSpreadDoesNotAccumulateOverRounds(t *testing.T) {
+	state := &concurrencyState{}
+	sources := []core.MetricsSource{}
+	for i := 0; i < 300; i++ {
+		sources = append(sources, &countingSource{name: fmt.Sprintf("s%d", i), latency: 50 * time.Millisecond, state: state})
+	}
+
+	timeout := 4 * time.Second
+	manager, err := NewSourceManager(util.NewDummyMetricsSourceProvider(sources...), timeout, 100)
+	if err != nil {
+		t.Fatalf("NewSourceManager error. %v", err)
+	}
+	// The spread only applies once the latencies are known, from the second round on.
+	for round := 0; round < 3; round++ {
+		now := time.Now()
+		end := now.Truncate(10 * time.Second)
+		dataBatch, err := manager.ScrapeMetrics(end.Add(-10*time.Second), end)
+		if err != nil {
+			t.Fatalf("ScrapeMetrics error. %v", err)
+		}
+		if len(dataBatch.MetricSets) != len(sources) {
+			t.Fatalf("Expected %d metric sets in round %d, got %d", len(sources), round, len(dataBatch.MetricSets))
+		}
+		// The last source starts 299*8ms after the start of the round, whichever worker scrapes it.
+		if elapsed := time.Since(now); elapsed > 3*time.Second {
+			t.Fatalf("ScrapeMetrics took too long in round %d: %s", round, elapsed)
+		}
+	}
+}
@@ -58,6 +58,7 @@ type NodeInfo struct {
 	HostName       string
 	HostID         string
 	KubeletVersion string
+	Conditions     []kube_api.NodeCondition
 }
 
 // Kubelet-provided metrics for pod and system container.
@@ -87,7 +88,7 @@ func (this *summaryMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatc
 		MetricSets: map[string]*MetricSet{},
 	}
 
-	summary, err := func() (*stats.Summary, error) {
+	summary, err := func() (*kubelet.Summary, error) {
 		startTime := time.Now()
 		defer func() {
 			summaryRequestLatency.WithLabelValues(this.node.HostName).Observe(float64(time.Since(startTime)) / float64(time.Millisecond))
@@ -105,10 +106,21 @@ func (this *summaryMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatc
 }
 
 const (
-	RootFsKey = "/"
-	LogsKey   = "logs"
+	RootFsKey  = "/"
+	LogsKey    = "logs"
+	ImageFsKey = "imagefs"
 )
 
+// The PIDPressure condition is newer than the vendored API types.
+const nodePIDPressure kube_api.NodeConditionType = "PIDPressure"
+
+// Node conditions reported as node metrics.
+var nodeConditionMetrics = map[kube_api.NodeConditionType]*Metric{
+	kube_api.NodeMemoryPressure: &MetricNodeMemoryPressure,
+	kube_api.NodeDiskPressure:   &MetricNodeDiskPressure,
+	nodePIDPressure:             &MetricNodePIDPressure,
+}
+
 // For backwards compatibility, map summary system names into original names.
 // TODO: Migrate to the new system names and remove this.
 var systemNameMap = map[string]string{
@@ -117,7 +129,7 @@ var systemNameMap = map[string]string{
 }
 
 // decodeSummary translates the kubelet statsSummary API into the flattened heapster MetricSet API.
-func (this *summaryMetricsSource) decodeSummary(summary *stats.Summary) map[string]*MetricSet {
+func (this *summaryMetricsSource) decodeSummary(summary *kubelet.Summary) map[string]*MetricSet {
 	glog.V(9).Infof("Begin summary decode")
 	result := map[string]*MetricSet{}
 
@@ -145,7 +157,7 @@ func (this *summaryMetricsSource) cloneLabels(labels map[string]string) map[stri
 	return clone
 }
 
-func (this *summaryMetricsSource) decodeNodeStats(metrics map[string]*MetricSet, labels map[string]string, node *stats.NodeStats) {
+func (this *summaryMetricsSource) decodeNodeStats(metrics map[string]*MetricSet, labels map[string]string, node *kubelet.NodeStats) {
 	glog.V(9).Infof("Decoding node stats for node %s...", node.NodeName)
 	nodeMetrics := &MetricSet{
 		Labels:              this.cloneLabels(labels),
@@ -162,6 +174,11 @@ func (this *summaryMetricsSource) decodeNodeStats(metrics map[string]*MetricSet,
 	this.decodeNetworkStats(nodeMetrics, node.Network)
 	this.decodeFsStats(nodeMetrics, RootFsKey, node.Fs)
 	this.decodeEphemeralStorageStats(nodeMetrics, node.Fs)
+	if node.Runtime != nil {
+		this.decodeFsStats(nodeMetrics, ImageFsKey, node.Runtime.ImageFs)
+	}
+	this.decodeRlimitStats(nodeMetrics, node.Rlimit)
+	this.decodeNodeConditions(nodeMetrics, this.node.Conditions)
 	metrics[NodeKey(node.NodeName)] = nodeMetrics
 
 	for _, container := range node.SystemContainers {
@@ -194,6 +211,7 @@ func (this *summaryMetricsSource) decodePodStats(metrics map[string]*MetricSet,
 	this.decodeEphemeralStorageStats(podMetrics, pod.EphemeralStorage)
 	for _, vol := range pod.VolumeStats {
 		this.decodeFsStats(podMetrics, VolumeResourcePrefix+vol.Name, &vol.FsStats)
+		this.decodeVolumeStats(podMetrics, &vol)
 	}
 	metrics[PodKey(ref.Namespace, ref.Name)] = podMetrics
 
@@ -327,6 +345,58 @@ func (this *summaryMetricsSource) decodeFsStats(metrics *MetricSet, fsKey string
 	this.addLabeledIntMetric(metrics, &MetricFilesystemAvailable, fsLabels, fs.AvailableBytes)
 	this.addLabeledIntMetric(metrics, &MetricFilesystemInodes, fsLabels, fs.Inodes)
 	this.addLabeledIntMetric(metrics, &MetricFilesystemInodesFree, fsLabels, fs.InodesFree)
+	this.addLabeledIntMetric(metrics, &MetricFilesystemInodesUsed, fsLabels, fs.InodesUsed)
+}
+
+func (this *summaryMetricsSource) decodeRlimitStats(metrics *MetricSet, rlimit *kubelet.RlimitStats) {
+	if rlimit == nil {
+		glog.V(9).Infof("missing rlimit metrics!")
+		return
+	}
+
+	if rlimit.MaxPID != nil {
+		metrics.MetricValues[MetricProcessLimit.Name] = MetricValue{
+			ValueType:  ValueInt64,
+			MetricType: MetricProcessLimit.Type,
+			IntValue:   *rlimit.MaxPID,
+		}
+	}
+	if rlimit.NumOfRunningProcesses != nil {
+		metrics.MetricValues[MetricProcessCount.Name] = MetricValue{
+			ValueType:  ValueInt64,
+			MetricType: MetricProcessCount.Type,
+			IntValue:   *rlimit.NumOfRunningProcesses,
+		}
+	}
+}
+
+func (this *summaryMetricsSource) decodeNodeConditions(metrics *MetricSet, conditions []kube_api.NodeCondition) {
+	for _, condition := range conditions {
+		metric, found := nodeConditionMetrics[condition.Type]
+		if !found {
+			continue
+		}
+		value := uint64(0)
+		if condition.Status == kube_api.ConditionTrue {
+			value = 1
+		}
+		this.addIntMetric(metrics, metric, &value)
+	}
+}
+
+func (this *summaryMetricsSource) decodeVolumeStats(metrics *MetricSet, vol *stats.VolumeStats) {
+	volumeLabels := map[string]string{LabelVolumeName.Key: vol.Name}
+	// The kubelet resolves the claim through the pod spec. Older kubelets don't report it, in which case
+	// the PodBasedEnricher fills it in from the pod lister.
+	if vol.PVCRef != nil {
+		volumeLabels[LabelPersistentVolumeClaimName.Key] = vol.PVCRef.Name
+		volumeLabels[LabelPersistentVolumeClaimNamespace.Key] = vol.PVCRef.Namespace
+	}
+	this.addLabeledIntMetric(metrics, &MetricVolumeUsage, volumeLabels, vol.UsedBytes)
+	this.addLabeledIntMetric(metrics, &MetricVolumeCapacity, volumeLabels, vol.CapacityBytes)
+	this.addLabeledIntMetric(metrics, &MetricVolumeAvailable, volumeLabels, vol.AvailableBytes)
+	this.addLabeledIntMetric(metrics, &MetricVolumeInodes, volumeLabels, vol.Inodes)
+	this.addLabeledIntMetric(metrics, &MetricVolumeInodesFree, volumeLabels, vol.InodesFree)
 }
 
 func (this *summaryMetricsSource) decodeUserDefinedMetrics(metrics *MetricSet, udm []stats.UserDefinedMetric) {
@@ -413,6 +483,7 @@ type summaryProvider struct {
 	reflector        *cache.Reflector
 	kubeletClient    *kubelet.KubeletClient
 	hostIDAnnotation string
+	nodeFilter       kubelet.NodeFilter
 }
 
 func (this *summaryProvider) GetMetricsSources() []MetricsSource {
@@ -424,6 +495,10 @@ func (this *summaryProvider) GetMetricsSources() []MetricsSource {
 	}
 
 	for _, node := range nodes {
+		if !this.nodeFilter.Matches(node) {
+			glog.V(4).Infof("Skipping node %s excluded by the node filter", node.Name)
+			continue
+		}
 		info, err := this.getNodeInfo(node)
 		if err != nil {
 			glog.Errorf("%v", err)
@@ -456,6 +531,7 @@ func (this *summaryProvider) getNodeInfo(node *kube_api.Node) (NodeInfo, error)
 			Port: this.kubeletClient.GetPort(),
 		},
 		KubeletVersion: node.Status.NodeInfo.KubeletVersion,
+		Conditions:     node.Status.Conditions,
 	}
 	return info, nil
 }
@@ -477,6 +553,10 @@ func NewSummaryProvider(uri *url.URL) (MetricsSourceProvider, error) {
 	if err != nil {
 		return nil, err
 	}
+	nodeFilter, err := kubelet.GetNodeFilter(uri)
+	if err != nil {
+		return nil, err
+	}
 	// watch nodes
 	nodeLister, reflector, _ := util.GetNodeLister(kubeClient)
 
@@ -485,5 +565,6 @@ func NewSummaryProvider(uri *url.URL) (MetricsSourceProvider, error) {
 		reflector:        reflector,
 		kubeletClient:    kubeletClient,
 		hostIDAnnotation: hostIDAnnotation,
+		nodeFilter:       nodeFilter,
 	}, nil
 }
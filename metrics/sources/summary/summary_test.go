@@ -25,6 +25,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	kube_api "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	util "k8s.io/client-go/util/testing"
 	"k8s.io/heapster/metrics/core"
@@ -48,6 +49,7 @@ const (
 	offsetFsUsed
 	offsetFsCapacity
 	offsetFsAvailable
+	offsetFsInodesUsed
 	offsetAcceleratorMemoryTotal
 	offsetAcceleratorMemoryUsed
 	offsetAcceleratorDutyCycle
@@ -103,6 +105,8 @@ var (
 	freeInode        = uint64(10440)
 	usedInode        = uint64(103520)
 	totalInode       = uint64(103620)
+	maxPID           = int64(32768)
+	runningProcesses = int64(312)
 	scrapeTime       = time.Now()
 	startTime        = time.Now().Add(-time.Minute)
 )
@@ -134,19 +138,33 @@ func testingSummaryMetricsSource() *summaryMetricsSource {
 func TestDecodeSummaryMetrics(t *testing.T) {
 
 	ms := testingSummaryMetricsSource()
-	summary := stats.Summary{
-		Node: stats.NodeStats{
-			NodeName:  nodeInfo.NodeName,
-			StartTime: metav1.NewTime(startTime),
-			CPU:       genTestSummaryCPU(seedNode),
-			Memory:    genTestSummaryMemory(seedNode),
-			Network:   genTestSummaryNetwork(seedNode),
-			SystemContainers: []stats.ContainerStats{
-				genTestSummaryContainer(stats.SystemContainerKubelet, seedKubelet),
-				genTestSummaryContainer(stats.SystemContainerRuntime, seedRuntime),
-				genTestSummaryContainer(stats.SystemContainerMisc, seedMisc),
+	ms.node.Conditions = []kube_api.NodeCondition{
+		{Type: kube_api.NodeReady, Status: kube_api.ConditionTrue},
+		{Type: kube_api.NodeMemoryPressure, Status: kube_api.ConditionFalse},
+		{Type: kube_api.NodeDiskPressure, Status: kube_api.ConditionTrue},
+	}
+	summary := kubelet.Summary{
+		Node: kubelet.NodeStats{
+			NodeStats: stats.NodeStats{
+				NodeName:  nodeInfo.NodeName,
+				StartTime: metav1.NewTime(startTime),
+				CPU:       genTestSummaryCPU(seedNode),
+				Memory:    genTestSummaryMemory(seedNode),
+				Network:   genTestSummaryNetwork(seedNode),
+				SystemContainers: []stats.ContainerStats{
+					genTestSummaryContainer(stats.SystemContainerKubelet, seedKubelet),
+					genTestSummaryContainer(stats.SystemContainerRuntime, seedRuntime),
+					genTestSummaryContainer(stats.SystemContainerMisc, seedMisc),
+				},
+				Fs: genTestSummaryFsStats(seedNode),
+				Runtime: &stats.RuntimeStats{
+					ImageFs: genTestSummaryFsStats(seedNode),
+				},
+			},
+			Rlimit: &kubelet.RlimitStats{
+				MaxPID:                &maxPID,
+				NumOfRunningProcesses: &runningProcesses,
 			},
-			Fs: genTestSummaryFsStats(seedNode),
 		},
 		Pods: []stats.PodStats{{
 			PodRef: stats.PodReference{
@@ -201,6 +219,10 @@ func TestDecodeSummaryMetrics(t *testing.T) {
 			},
 			VolumeStats: []stats.VolumeStats{{
 				Name: "C",
+				PVCRef: &stats.PVCReference{
+					Name:      "claim-c",
+					Namespace: namespace0,
+				},
 				FsStats: stats.FsStats{
 					AvailableBytes: &availableFsBytes,
 					UsedBytes:      &usedFsBytes,
@@ -256,7 +278,7 @@ func TestDecodeSummaryMetrics(t *testing.T) {
 		memory:           true,
 		network:          true,
 		ephemeralstorage: true,
-		fs:               []string{"/"},
+		fs:               []string{"/", "imagefs"},
 	}, {
 		key:     core.NodeContainerKey(nodeInfo.NodeName, "kubelet"),
 		setType: core.MetricSetTypeSystemContainer,
@@ -363,6 +385,7 @@ func TestDecodeSummaryMetrics(t *testing.T) {
 	}}
 
 	metrics := ms.decodeSummary(&summary)
+	node := metrics[core.NodeKey(nodeInfo.NodeName)]
 	for _, e := range expectations {
 		m, ok := metrics[e.key]
 		if !assert.True(t, ok, "missing metric %q", e.key) {
@@ -402,6 +425,7 @@ func TestDecodeSummaryMetrics(t *testing.T) {
 			checkFsMetric(t, m, e.key, label, core.MetricFilesystemAvailable, e.seed+offsetFsAvailable)
 			checkFsMetric(t, m, e.key, label, core.MetricFilesystemLimit, e.seed+offsetFsCapacity)
 			checkFsMetric(t, m, e.key, label, core.MetricFilesystemUsage, e.seed+offsetFsUsed)
+			checkFsMetric(t, m, e.key, label, core.MetricFilesystemInodesUsed, e.seed+offsetFsInodesUsed)
 		}
 		delete(metrics, e.key)
 	}
@@ -410,16 +434,35 @@ func TestDecodeSummaryMetrics(t *testing.T) {
 	var volumeInformationMetricsKey = core.PodKey(namespace0, pName3)
 	var mappedVolumeStats = map[string]int64{}
 	for _, labeledMetric := range metrics[volumeInformationMetricsKey].LabeledMetrics {
-		assert.True(t, strings.HasPrefix("Volume:C", labeledMetric.Labels["resource_id"]))
+		if strings.HasPrefix(labeledMetric.Name, "volume/") {
+			assert.Equal(t, "C", labeledMetric.Labels[core.LabelVolumeName.Key])
+			assert.Equal(t, "claim-c", labeledMetric.Labels[core.LabelPersistentVolumeClaimName.Key])
+			assert.Equal(t, namespace0, labeledMetric.Labels[core.LabelPersistentVolumeClaimNamespace.Key])
+		} else {
+			assert.True(t, strings.HasPrefix("Volume:C", labeledMetric.Labels["resource_id"]))
+		}
 		mappedVolumeStats[labeledMetric.Name] = labeledMetric.IntValue
 	}
 
 	assert.True(t, mappedVolumeStats["filesystem/available"] == int64(availableFsBytes))
 	assert.True(t, mappedVolumeStats["filesystem/usage"] == int64(usedFsBytes))
 	assert.True(t, mappedVolumeStats["filesystem/limit"] == int64(totalFsBytes))
+	assert.Equal(t, int64(availableFsBytes), mappedVolumeStats[core.MetricVolumeAvailable.Name])
+	assert.Equal(t, int64(usedFsBytes), mappedVolumeStats[core.MetricVolumeUsage.Name])
+	assert.Equal(t, int64(totalFsBytes), mappedVolumeStats[core.MetricVolumeCapacity.Name])
+	assert.Equal(t, int64(totalInode), mappedVolumeStats[core.MetricVolumeInodes.Name])
+	assert.Equal(t, int64(freeInode), mappedVolumeStats[core.MetricVolumeInodesFree.Name])
 
 	delete(metrics, volumeInformationMetricsKey)
 
+	// Verify node status metrics
+	checkIntMetric(t, node, "node", core.MetricProcessLimit, maxPID)
+	checkIntMetric(t, node, "node", core.MetricProcessCount, runningProcesses)
+	checkIntMetric(t, node, "node", core.MetricNodeMemoryPressure, 0)
+	checkIntMetric(t, node, "node", core.MetricNodeDiskPressure, 1)
+	_, found := node.MetricValues[core.MetricNodePIDPressure.Name]
+	assert.False(t, found, "condition not reported by the node")
+
 	for k, v := range metrics {
 		assert.Fail(t, "unexpected metric", "%q: %+v", k, v)
 	}
@@ -556,6 +599,7 @@ func genTestSummaryFsStats(seed int) *stats.FsStats {
 		AvailableBytes: uint64Val(seed, offsetFsAvailable),
 		CapacityBytes:  uint64Val(seed, offsetFsCapacity),
 		UsedBytes:      uint64Val(seed, offsetFsUsed),
+		InodesUsed:     uint64Val(seed, offsetFsInodesUsed),
 	}
 }
 
@@ -594,10 +638,15 @@ func checkAcceleratorMetric(t *testing.T, metrics *core.MetricSet, key string, m
 }
 
 func TestScrapeSummaryMetrics(t *testing.T) {
-	summary := stats.Summary{
-		Node: stats.NodeStats{
-			NodeName:  nodeInfo.NodeName,
-			StartTime: metav1.NewTime(startTime),
+	summary := kubelet.Summary{
+		Node: kubelet.NodeStats{
+			NodeStats: stats.NodeStats{
+				NodeName:  nodeInfo.NodeName,
+				StartTime: metav1.NewTime(startTime),
+			},
+			Rlimit: &kubelet.RlimitStats{
+				MaxPID: &maxPID,
+			},
 		},
 	}
 	data, err := json.Marshal(&summary)
@@ -619,4 +668,5 @@ func TestScrapeSummaryMetrics(t *testing.T) {
 	res, err := ms.ScrapeMetrics(time.Now(), time.Now())
 	assert.Nil(t, err, "scrape error")
 	assert.Equal(t, res.MetricSets["node:test"].Labels[core.LabelMetricSetType.Key], core.MetricSetTypeNode)
+	checkIntMetric(t, res.MetricSets["node:test"], "node:test", core.MetricProcessLimit, maxPID)
 }